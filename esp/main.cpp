@@ -10,8 +10,12 @@ const char* WIFI_PASS = "15092004";
 // ================= MQTT CONFIG =================
 const char* MQTT_SERVER = "192.168.100.65";   // IP của broker
 const int   MQTT_PORT   = 1883;
-const char* TOPIC_CONTROL = "led/control";
-const char* TOPIC_SENSOR  = "sensor/information";
+// Client ID + secret + tenant_id cấp từ POST /api/v1/device/:id/credentials (hoặc /provision/claim)
+const char* DEVICE_CLIENT_ID = "dev-1-00000000";
+const char* DEVICE_SECRET    = "change-me";
+const char* DEVICE_TENANT_ID = "1";
+String TOPIC_CONTROL = String("tenants/") + DEVICE_TENANT_ID + "/devices/" + DEVICE_CLIENT_ID + "/control";
+String TOPIC_SENSOR  = String("tenants/") + DEVICE_TENANT_ID + "/sensor/" + DEVICE_CLIENT_ID + "/information";
 
 // ================= LED PINS ====================
 #define LED1_PIN 18   // D25
@@ -106,12 +110,9 @@ void reconnectMQTT() {
 
   while (!client.connected()) {
     Serial.print("Connecting to MQTT...");
-    String clientId = "ESP32Client-";
-    clientId += String(random(0xffff), HEX);
-
-    if (client.connect(clientId.c_str())) {
+    if (client.connect(DEVICE_CLIENT_ID, DEVICE_CLIENT_ID, DEVICE_SECRET)) {
       Serial.println("Connected!");
-      client.subscribe(TOPIC_CONTROL);
+      client.subscribe(TOPIC_CONTROL.c_str());
     } else {
       Serial.print("Failed, rc=");
       Serial.print(client.state());
@@ -218,7 +219,7 @@ void readAndPublishSensor() {
   serializeJson(doc, jsonData);
 
   if (client.connected()) {
-    client.publish(TOPIC_SENSOR, jsonData.c_str());
+    client.publish(TOPIC_SENSOR.c_str(), jsonData.c_str());
     Serial.println("Published: " + jsonData);
   }
 }
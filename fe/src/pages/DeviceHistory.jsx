@@ -81,7 +81,7 @@ const DeviceHistory = () => {
       console.log('Fetching with params:', params);
 
       const response = await apiClient.get('/device_history', { params });
-      const { data, status, total } = response.data;
+      const { data, status, pagination } = response.data;
 
       if (status === 'success') {
         const mappedData = data.map((item) => ({
@@ -97,7 +97,7 @@ const DeviceHistory = () => {
         }));
         
         setDataSource(mappedData);
-        setTotalCount(total || 0);
+        setTotalCount(pagination?.total || 0);
       } else {
         throw new Error('API response not success');
       }
@@ -216,7 +216,7 @@ const DeviceHistory = () => {
         <Row gutter={[12, 12]} align="middle">
           <Col xs={24} sm={12} lg={8}>
             <Search
-              placeholder="Search by User, Device..."
+              placeholder="e.g. status=ON and user~admin"
               value={tempFilters.search}
               onChange={(e) => setTempFilters({ ...tempFilters, search: e.target.value })}
               onSearch={applyFilters}
@@ -69,7 +69,7 @@ const SensorHistory = () => {
       console.log('Fetching with params:', params);
 
       const response = await apiClient.get('/sensor/all', { params });
-      const { data, total } = response.data;
+      const { data, pagination } = response.data;
 
       if (data) {
         // Apply client-side filters for temperature, humidity, light
@@ -106,7 +106,7 @@ const SensorHistory = () => {
         }));
         
         setDataSource(mappedData);
-        setTotalCount(total || 0);
+        setTotalCount(pagination?.total || 0);
       } else {
         throw new Error('API response not success');
       }
@@ -244,7 +244,7 @@ const SensorHistory = () => {
         <Row gutter={[12, 12]} align="middle">
           <Col xs={24} sm={12} lg={8}>
             <Search
-              placeholder="Search by ID or date..."
+              placeholder="e.g. temperature>30 and time>=2025-10-01"
               value={tempFilters.search}
               onChange={(e) => setTempFilters({ ...tempFilters, search: e.target.value })}
               onSearch={applyFilters}
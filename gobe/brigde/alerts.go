@@ -0,0 +1,69 @@
+package bridge
+
+import (
+	"encoding/json"
+	"iot/internal/dto"
+	"iot/pkg/config"
+	"iot/pkg/logger"
+	"iot/pkg/stream"
+
+	"go.uber.org/zap"
+)
+
+const (
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+)
+
+type thresholdAlert struct {
+	Type   string  `json:"type"`
+	State  string  `json:"state"`
+	Topic  string  `json:"topic"`
+	Metric string  `json:"metric"`
+	Value  float64 `json:"value"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// checkThresholds - ngưỡng đọc từ cấu hình mỗi lần nên đổi alerts trong file là có hiệu lực ngay.
+// Chỉ phát event khi một chỉ số bắt đầu vượt ngưỡng và khi trở lại bình thường, không phát theo từng bản tin.
+func (b *mqttSocketBridge) checkThresholds(tenantID uint, topic string, data *dto.CreateSensorDTO) {
+	alerts := config.GetConfig().Alerts
+	if !alerts.Enabled {
+		return
+	}
+	checks := []thresholdAlert{
+		{Metric: "temperature", Value: data.Temperature, Min: alerts.TemperatureMin, Max: alerts.TemperatureMax},
+		{Metric: "humidity", Value: data.Humidity, Min: alerts.HumidityMin, Max: alerts.HumidityMax},
+		{Metric: "light", Value: float64(data.Light), Min: alerts.LightMin, Max: alerts.LightMax},
+	}
+	for _, alert := range checks {
+		breached := alert.Value < alert.Min || alert.Value > alert.Max
+		key := topic + "|" + alert.Metric
+
+		b.alertMu.Lock()
+		changed := b.alerting[key] != breached
+		if breached {
+			b.alerting[key] = true
+		} else {
+			delete(b.alerting, key)
+		}
+		b.alertMu.Unlock()
+		if !changed {
+			continue
+		}
+
+		alert.Type = "threshold"
+		alert.Topic = topic
+		alert.State = alertResolved
+		if breached {
+			alert.State = alertFiring
+		}
+		payload, err := json.Marshal(alert)
+		if err != nil {
+			continue
+		}
+		logger.Log.Info("Telemetry threshold alert", zap.String("topic", topic), zap.String("metric", alert.Metric), zap.String("state", alert.State), zap.Float64("value", alert.Value))
+		b.events.Publish(stream.Event{Topic: stream.TopicAlert, TenantID: tenantID, Data: payload})
+	}
+}
@@ -6,26 +6,50 @@ import (
 	"errors"
 	"fmt"
 	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
 	"iot/internal/services"
+	"iot/internal/tenant"
 	"iot/pkg/logger"
+	"iot/pkg/metrics"
+	mymqtt "iot/pkg/mqtt"
 	"iot/pkg/socket"
+	"iot/pkg/stream"
+	"iot/pkg/tracing"
 	"strconv"
 	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// errPayloadNotObject - payload "null" hợp lệ với json.Unmarshal nhưng cho map nil
+var errPayloadNotObject = errors.New("payload is not a JSON object")
+
+var errTopicWithoutTenant = errors.New("topic without tenant")
+
 type mqttSocketBridge struct {
 	mqtt      mqtt.Client
 	socketHub *socket.Hub
 	redis     *redis.Client
 	db        *gorm.DB
 	service   services.SensorServiceInterface
+	realtime  services.RealtimeServiceInterface
+	events    *stream.Broker
+	tenants   repository.TenantRepositoryInterface
 	wg        sync.WaitGroup
+
+	// defaultTenantID - tenant của board còn dùng topic cũ, 0 nếu chưa có tenant mặc định
+	defaultTenantID uint
+
+	alertMu sync.Mutex
+	// alerting - topic + chỉ số đang vượt ngưỡng, để chỉ phát alert khi vào/ra khỏi ngưỡng
+	alerting map[string]bool
 }
 
 func NewMqttSocketBridge(
@@ -34,6 +58,9 @@ func NewMqttSocketBridge(
 	redis *redis.Client,
 	db *gorm.DB,
 	service services.SensorServiceInterface,
+	realtime services.RealtimeServiceInterface,
+	events *stream.Broker,
+	tenants repository.TenantRepositoryInterface,
 ) *mqttSocketBridge {
 	return &mqttSocketBridge{
 		mqtt:      mqttClient,
@@ -41,24 +68,41 @@ func NewMqttSocketBridge(
 		redis:     redis,
 		db:        db,
 		service:   service,
+		realtime:  realtime,
+		events:    events,
+		tenants:   tenants,
+		alerting:  map[string]bool{},
 	}
 }
 
 func (b *mqttSocketBridge) SubscribeSensorData(ctx context.Context) error {
-	if !b.mqtt.IsConnected() {
-		return errors.New("MQTT client not connected")
+	if defaultTenant, err := b.tenants.GetBySlug(b.db.WithContext(ctx), model.DefaultTenantSlug); err == nil {
+		b.defaultTenantID = defaultTenant.ID
+	} else {
+		logger.Log.Warn("Default tenant not found, legacy telemetry topic will be ignored", zap.Error(err))
 	}
 
-	topic := "sensor/information"
-	token := b.mqtt.Subscribe(topic, 0, b.handleSensorMessage(ctx))
-	token.Wait()
-
-	if err := token.Error(); err != nil {
-		logger.Log.Error("Failed to subscribe", zap.String("topic", topic), zap.Error(err))
-		return err
+	// Topic cũ dùng chung cho board chưa provisioning (thuộc tenant mặc định),
+	// topic theo tenant + client ID cho board có credential riêng
+	topics := map[string]byte{
+		mymqtt.LegacyTelemetryTopic: 0,
+		mymqtt.TelemetryWildcard:    0,
 	}
-
-	logger.Log.Info("Subscribed to topic", zap.String("topic", topic))
+	handler := b.handleSensorMessage(ctx)
+	// Subscribe trong hook connect: broker khởi động lại hoặc rớt mạng thì paho kết nối lại
+	// bằng session mới và subscription cũ mất, hook chạy lại để đăng ký lại
+	mymqtt.OnConnect(b.mqtt, func(client mqtt.Client) {
+		if ctx.Err() != nil {
+			return
+		}
+		token := client.SubscribeMultiple(topics, handler)
+		token.Wait()
+		if err := token.Error(); err != nil {
+			logger.Log.Error("Failed to subscribe", zap.Error(err))
+			return
+		}
+		logger.Log.Info("Subscribed to telemetry topics", zap.String("legacy", mymqtt.LegacyTelemetryTopic), zap.String("per_device", mymqtt.TelemetryWildcard))
+	})
 
 	<-ctx.Done()
 	logger.Log.Info("Shutdown signal received, waiting for pending operations...")
@@ -78,48 +122,124 @@ func (b *mqttSocketBridge) SubscribeSensorData(ctx context.Context) error {
 	}
 
 	// Unsubscribe
-	if token := b.mqtt.Unsubscribe(topic); token.Wait() && token.Error() != nil {
+	if token := b.mqtt.Unsubscribe(mymqtt.LegacyTelemetryTopic, mymqtt.TelemetryWildcard); token.Wait() && token.Error() != nil {
 		logger.Log.Error("Failed to unsubscribe", zap.Error(token.Error()))
 		return token.Error()
 	}
 
-	logger.Log.Info("Unsubscribed from topic", zap.String("topic", topic))
+	logger.Log.Info("Unsubscribed from telemetry topics")
 	return nil
 }
 
 func (b *mqttSocketBridge) handleSensorMessage(ctx context.Context) mqtt.MessageHandler {
 	return func(client mqtt.Client, msg mqtt.Message) {
 		payload := msg.Payload()
+		topic := msg.Topic()
+		subscription := subscriptionOf(topic)
+		metrics.MQTTMessages.WithLabelValues(subscription, metrics.MQTTReceived).Inc()
 
-		// Debug log - track MQTT messages and WebSocket clients
+		// Board gửi lại traceparent của lệnh vừa nhận thì message này nối vào trace của lệnh đó
+		var data map[string]interface{}
+		parseErr := json.Unmarshal(payload, &data)
+		if parseErr == nil && data == nil {
+			parseErr = errPayloadNotObject
+		}
+		msgCtx := ctx
+		if traceparent, ok := data[tracing.TraceparentHeader].(string); ok {
+			msgCtx = tracing.ContextWithTraceparent(ctx, traceparent)
+			delete(data, tracing.TraceparentHeader)
+		}
+		msgCtx, span := tracing.Start(msgCtx, "process telemetry", trace.SpanKindConsumer,
+			attribute.String("messaging.system", "mqtt"),
+			attribute.String("messaging.operation.type", "process"),
+			attribute.String("messaging.destination.name", topic),
+		)
+		defer span.End()
+
+		// Debug log - track MQTT messages
 		logger.Log.Info("MQTT message received",
 			zap.String("topic", msg.Topic()),
-			zap.ByteString("payload", payload),
-			zap.Int("websocket_clients", len(b.socketHub.Clients)))
-
-		select {
-		case b.socketHub.Broadcast <- payload:
-			logger.Log.Info("Message broadcasted to WebSocket clients",
-				zap.Int("client_count", len(b.socketHub.Clients)))
-		default:
-			logger.Log.Warn("Broadcast channel full, message dropped")
+			zap.ByteString("payload", payload))
+
+		tenantID := b.tenantOf(msg.Topic())
+		if tenantID == 0 {
+			metrics.MQTTMessages.WithLabelValues(subscription, metrics.MQTTDropped).Inc()
+			tracing.RecordError(span, errTopicWithoutTenant)
+			logger.Log.Warn("Telemetry topic without tenant, message dropped", zap.String("topic", msg.Topic()))
+			return
 		}
 
-		// Parse sensor data
-		var data map[string]interface{}
-		if err := json.Unmarshal(payload, &data); err != nil {
-			logger.Log.Error("Invalid sensor payload", zap.Error(err))
+		if parseErr != nil {
+			metrics.MQTTMessages.WithLabelValues(subscription, metrics.MQTTRejected).Inc()
+			tracing.RecordError(span, parseErr)
+			logger.Log.Error("Invalid sensor payload", zap.Error(parseErr))
 			return
 		}
 
+		// Kiểm tra trước khi buffer/phát để client không nhận giá trị sẽ bị từ chối lúc lưu
 		dataSensor, err := b.parseSensorData(data)
+		if err == nil {
+			err = services.ValidateReading(dataSensor)
+		}
 		if err != nil {
+			metrics.MQTTMessages.WithLabelValues(subscription, metrics.MQTTRejected).Inc()
+			tracing.RecordError(span, err)
 			logger.Log.Error("Invalid sensor values", zap.Error(err), zap.Any("data", data))
 			return
 		}
 
+		// Lưu vào stream buffer để client reconnect có thể catch-up, gắn cursor vào message
+		if cursor, err := b.realtime.AppendReading(msgCtx, tenantID, payload); err != nil {
+			logger.Log.Warn("Failed to append reading to stream", zap.Error(err))
+			b.broadcast(tenantID, payload)
+			b.events.Publish(stream.Event{Topic: stream.TopicTelemetry, TenantID: tenantID, Data: payload})
+		} else {
+			b.events.Publish(stream.Event{ID: cursor, Topic: stream.TopicTelemetry, TenantID: tenantID, Data: payload})
+			data["cursor"] = cursor
+			if enriched, err := json.Marshal(data); err == nil {
+				b.broadcast(tenantID, enriched)
+			} else {
+				b.broadcast(tenantID, payload)
+			}
+		}
+
+		metrics.MQTTMessages.WithLabelValues(subscription, metrics.MQTTParsed).Inc()
+		b.checkThresholds(tenantID, topic, dataSensor)
+
 		b.wg.Add(1)
-		go b.saveSensorData(ctx, dataSensor)
+		go b.saveSensorData(msgCtx, tenantID, topic, dataSensor)
+	}
+}
+
+// subscriptionOf - label metric theo pattern đã subscribe, không theo topic cụ thể của từng board
+// để số series không tăng theo số thiết bị
+func subscriptionOf(topic string) string {
+	if topic == mymqtt.LegacyTelemetryTopic {
+		return mymqtt.LegacyTelemetryTopic
+	}
+	return mymqtt.TelemetryWildcard
+}
+
+// tenantOf - tenant lấy từ topic (broker ACL đã đảm bảo board chỉ publish vào tenant của credential),
+// topic cũ thuộc tenant mặc định, 0 nếu không xác định được
+func (b *mqttSocketBridge) tenantOf(topic string) uint {
+	if tenantID, _, ok := mymqtt.ParseTelemetryTopic(topic); ok {
+		return tenantID
+	}
+	if topic == mymqtt.LegacyTelemetryTopic {
+		return b.defaultTenantID
+	}
+	return 0
+}
+
+// broadcast - chỉ gửi cho client thuộc group telemetry của tenant (đã kiểm tra quyền lúc subscribe)
+func (b *mqttSocketBridge) broadcast(tenantID uint, message []byte) {
+	select {
+	case b.socketHub.Group <- socket.GroupMessage{GroupID: socket.TelemetryGroup(tenantID), Message: message}:
+		logger.Log.Debug("Message sent to telemetry subscribers")
+	default:
+		metrics.WebSocketDropped.WithLabelValues("telemetry").Inc()
+		logger.Log.Warn("Broadcast channel full, message dropped")
 	}
 }
 
@@ -146,17 +266,22 @@ func (b *mqttSocketBridge) parseSensorData(data map[string]interface{}) (*dto.Cr
 	}, nil
 }
 
-func (b *mqttSocketBridge) saveSensorData(ctx context.Context, data *dto.CreateSensorDTO) {
+func (b *mqttSocketBridge) saveSensorData(ctx context.Context, tenantID uint, topic string, data *dto.CreateSensorDTO) {
 	defer b.wg.Done()
 
 	saveCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	if err := b.service.CreateSensorData(b.db, data, b.redis); err != nil {
+	if err := b.service.CreateSensorData(tenant.WithTenant(b.db.WithContext(saveCtx), tenantID), data, b.redis); err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(saveCtx.Err(), context.DeadlineExceeded) {
 			logger.Log.Warn("Save operation cancelled or timed out")
 			return
 		}
+		if errors.Is(err, services.ErrInvalidReading) {
+			metrics.MQTTMessages.WithLabelValues(subscriptionOf(topic), metrics.MQTTRejected).Inc()
+			logger.Log.Warn("Sensor reading rejected", zap.Error(err))
+			return
+		}
 		logger.Log.Error("Failed to save sensor data", zap.Error(err))
 	} else {
 		logger.Log.Info("Sensor data saved successfully")
@@ -0,0 +1,223 @@
+// mockoidc - OIDC issuer giả lập để thử đăng nhập OIDC ở máy local.
+// Chạy: go run ./cmd/mockoidc, rồi đặt OIDC_ISSUER=http://localhost:9400, OIDC_CLIENT_ID=iot-local,
+// OIDC_REDIRECT_URL=http://localhost:8080/api/v1/auth/oidc/callback, OIDC_ROLE_MAP=iot-admins=admin
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock-key-1"
+
+// authRequest - thông tin của một authorization code chờ đổi token
+type authRequest struct {
+	ClientID      string
+	RedirectURI   string
+	CodeChallenge string
+	Nonce         string
+	Claims        jwt.MapClaims
+	ExpiresAt     time.Time
+}
+
+type issuer struct {
+	url   string
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]*authRequest
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<title>Mock OIDC</title>
+<h3>Mock OIDC login</h3>
+<form method="post">
+  {{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{$v}}">{{end}}
+  <p><label>Subject <input name="sub" value="mock-user-1"></label></p>
+  <p><label>Email <input name="email" value="user@example.com"></label></p>
+  <p><label><input type="checkbox" name="email_verified" value="true" checked> Email verified</label></p>
+  <p><label>Name <input name="name" value="Mock User"></label></p>
+  <p><label>Roles (comma separated) <input name="roles" value=""></label></p>
+  <button type="submit">Sign in</button>
+</form>`))
+
+func main() {
+	addr := getEnv("MOCK_OIDC_ADDR", ":9400")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+	iss := &issuer{
+		url:   getEnv("MOCK_OIDC_ISSUER", "http://localhost:9400"),
+		key:   key,
+		codes: map[string]*authRequest{},
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", iss.discovery)
+	http.HandleFunc("/jwks", iss.jwks)
+	http.HandleFunc("/authorize", iss.authorize)
+	http.HandleFunc("/token", iss.token)
+
+	log.Printf("Mock OIDC issuer %s listening on %s", iss.url, addr)
+	log.Fatal(http.ListenAndServe(addr, nil))
+}
+
+func (i *issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                i.url,
+		"authorization_endpoint":                i.url + "/authorize",
+		"token_endpoint":                        i.url + "/token",
+		"jwks_uri":                              i.url + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (i *issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := i.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize - GET hiện form chọn user giả, POST cấp code và chuyển hướng về redirect_uri
+func (i *issuer) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("response_type") != "code" || r.Form.Get("code_challenge_method") != "S256" || r.Form.Get("code_challenge") == "" {
+		http.Error(w, "only response_type=code with PKCE S256 is supported", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodGet {
+		params := map[string]string{}
+		for _, name := range []string{"response_type", "client_id", "redirect_uri", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			params[name] = r.Form.Get(name)
+		}
+		loginPage.Execute(w, map[string]interface{}{"Params": params})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"sub":            r.Form.Get("sub"),
+		"email":          r.Form.Get("email"),
+		"email_verified": r.Form.Get("email_verified") == "true",
+		"name":           r.Form.Get("name"),
+	}
+	if roles := strings.TrimSpace(r.Form.Get("roles")); roles != "" {
+		claims["roles"] = strings.Split(roles, ",")
+	}
+	code := randomString()
+	i.mu.Lock()
+	i.codes[code] = &authRequest{
+		ClientID:      r.Form.Get("client_id"),
+		RedirectURI:   r.Form.Get("redirect_uri"),
+		CodeChallenge: r.Form.Get("code_challenge"),
+		Nonce:         r.Form.Get("nonce"),
+		Claims:        claims,
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	i.mu.Unlock()
+
+	target, err := url.Parse(r.Form.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	query := target.Query()
+	query.Set("code", code)
+	query.Set("state", r.Form.Get("state"))
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// token - kiểm tra code, redirect_uri, client_id và PKCE rồi ký ID token
+func (i *issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	clientID := r.Form.Get("client_id")
+	if user, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(user)
+	}
+
+	i.mu.Lock()
+	req := i.codes[r.Form.Get("code")]
+	delete(i.codes, r.Form.Get("code"))
+	i.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if req == nil || time.Now().After(req.ExpiresAt) || req.ClientID != clientID || req.RedirectURI != r.Form.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != req.CodeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   i.url,
+		"aud":   clientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": req.Nonce,
+	}
+	for k, v := range req.Claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(i.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -2,15 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	bridge "iot/brigde"
+	"iot/internal/handler"
 	"iot/internal/helper/mailer"
 	"iot/internal/initialize"
+	"iot/internal/jwt_utils"
 	"iot/internal/middlewares"
 	"iot/internal/repository"
 	"iot/internal/routes"
 	"iot/internal/services"
 	"iot/pkg/config"
 	"iot/pkg/logger"
+	"iot/pkg/stream"
 	"net/http"
 	"os"
 	"os/signal"
@@ -23,19 +27,44 @@ import (
 
 func main() {
 	logger.InitLogger()
+	cfg, err := config.Init()
+	if err != nil {
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			logger.Log.Fatal("Invalid configuration", zap.Strings("problems", invalid.Problems))
+		}
+		logger.Log.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	jwt_utils.Configure(cfg.JWTConfig)
+	applyRuntimeConfig(cfg)
+	logger.Log.Info("Configuration loaded", zap.Stringer("config", cfg))
+
 	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	go config.Watch(rootCtx, 10*time.Second, applyRuntimeConfig)
+
+	shutdownTracing, err := initialize.InitTracing(rootCtx)
+	if err != nil {
+		logger.Log.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
 
 	r := gin.New()
+	// Mặc định gin tin mọi proxy, client tự gửi X-Forwarded-For là đổi được IP
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Log.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
 	r.Use(middlewares.CorsMiddleware())
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 	// Set Gin to release mode
 	gin.SetMode(gin.ReleaseMode)
 
-	db, err := initialize.InitMysql(rootCtx)
+	db, err := initialize.InitDatabase(rootCtx)
 	if err != nil {
-		logger.Log.Fatal("Failed to initialize MySQL", zap.Error(err))
+		logger.Log.Fatal("Failed to initialize database", zap.Error(err))
 	}
 
 	redisClient, err := initialize.InitRedis(rootCtx)
@@ -43,27 +72,36 @@ func main() {
 		logger.Log.Fatal("Failed to initialize Redis", zap.Error(err))
 	}
 
+	// Database/Redis/MQTT chưa lên thì các hàm Init đã thử lại và chuyển sang chế độ degraded,
+	// lỗi trả về ở đây là lỗi cấu hình. Thiếu MQTT thì API vẫn chạy, chỉ mất telemetry và lệnh điều khiển.
 	mqttClient, err := initialize.InitMqtt(rootCtx)
 	if err != nil {
-		logger.Log.Fatal("Failed to initialize MQTT", zap.Error(err))
+		logger.Log.Warn("MQTT disabled, running without telemetry", zap.Error(err))
 	}
 
-	mailerService := mailer.NewMailService(config.GetConfig().EmailConfig, logger.Log)
+	mailerService := mailer.NewMailService(cfg.EmailConfig, logger.Log)
 
+	events := stream.NewBroker()
+	deviceService := services.NewDeviceService(repository.NewDeviceRepository(), repository.NewDeviceHistoryRepository(), repository.NewDeviceGrantRepository(), repository.NewDeviceCredentialRepository(), repository.NewTenantRepository())
+	realtimeService := services.NewRealtimeService(db, redisClient, deviceService, repository.NewSensorRepository())
+
+	routes.InitRouter(r, db, mailerService, redisClient, rootCtx, mqttClient, events, realtimeService)
+	// /ws đăng ký sau InitRouter để có tracing, log và metrics như các route khác
 	socketHub := initialize.InitSocketServer(r)
 	if socketHub != nil {
+		socketHub.SetStateProvider(realtimeService)
+		socketHub.SetCommandHandler(handler.NewSocketCommandHandler(db, deviceService, mqttClient, events, services.NewAuditService(repository.NewAuditLogRepository())))
 		go socketHub.Run()
 	}
 
 	if mqttClient != nil && socketHub != nil {
 		sensorRepo := services.NewSensorService(repository.NewSensorRepository())
-		bridge := bridge.NewMqttSocketBridge(mqttClient, socketHub, redisClient, db, sensorRepo)
+		bridge := bridge.NewMqttSocketBridge(mqttClient, socketHub, redisClient, db, sensorRepo, realtimeService, events, repository.NewTenantRepository())
 		go bridge.SubscribeSensorData(rootCtx)
 	}
-	routes.InitRouter(r, db, mailerService, redisClient, rootCtx, mqttClient)
 
 	server := &http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.Server.Addr,
 		Handler: r,
 	}
 
@@ -75,7 +113,18 @@ func main() {
 
 	<-rootCtx.Done()
 	logger.Log.Info("Shutting down gracefully...")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 	_ = server.Shutdown(ctx)
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Log.Warn("Failed to flush traces", zap.Error(err))
+	}
+}
+
+// applyRuntimeConfig - phần cấu hình đổi được khi đang chạy; ngưỡng alert được bridge đọc trực tiếp
+func applyRuntimeConfig(cfg *config.Config) {
+	if err := logger.SetLevel(cfg.Log.Level); err != nil {
+		logger.Log.Warn("Invalid log level", zap.String("level", cfg.Log.Level), zap.Error(err))
+	}
+	middlewares.SetAllowedOrigins(cfg.CORS.AllowedOrigins)
 }
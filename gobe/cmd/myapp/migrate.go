@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iot/internal/initialize"
+	"iot/internal/migrations"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+)
+
+const migrateUsage = `usage: myapp migrate <command>
+
+commands:
+  status           list migrations and whether they are applied
+  up               apply all pending migrations
+  down [n]         roll back the last n migrations (default 1)
+  to <version>     migrate up or down to exactly <version>, 0 rolls back everything
+  force <version>  mark <version> as the current schema without running anything,
+                   after fixing a failed migration by hand`
+
+// runMigrate - lệnh "myapp migrate ...", trả exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := initialize.OpenDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: connect: %v\n", err)
+		return 1
+	}
+	migrator, err := migrations.New(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+
+	var done []uint
+	switch args[0] {
+	case "status":
+		err = printStatus(ctx, migrator)
+	case "up":
+		done, err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintf(os.Stderr, "migrate: invalid step count %q\n", args[1])
+				return 2
+			}
+		}
+		done, err = migrator.Down(ctx, steps)
+	case "to", "force":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, parseErr := strconv.ParseUint(args[1], 10, 32)
+		if parseErr != nil {
+			fmt.Fprintf(os.Stderr, "migrate: invalid version %q\n", args[1])
+			return 2
+		}
+		if args[0] == "to" {
+			done, err = migrator.To(ctx, uint(version))
+		} else if err = migrator.Force(ctx, uint(version)); err == nil {
+			fmt.Printf("schema marked as version %d\n", version)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	for _, version := range done {
+		fmt.Printf("migrated %d\n", version)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	if args[0] != "status" && args[0] != "force" && len(done) == 0 {
+		fmt.Println("no change")
+	}
+	return 0
+}
+
+func printStatus(ctx context.Context, migrator *migrations.Migrator) error {
+	list, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range list {
+		state, appliedAt := "pending", ""
+		if s.Applied {
+			state = "applied"
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if s.Dirty {
+			state = "dirty"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,18 @@
+package dto
+
+// Actor - người thực hiện request, lấy từ token đã xác thực
+type Actor struct {
+	UserID   uint
+	TenantID uint
+	Role     string
+	// Name - tên ghi vào lịch sử thay đổi device, không nhận từ body
+	Name string
+}
+
+// ActorName - username của token (API key là tên key), trống thì dùng email
+func ActorName(username, email string) string {
+	if username != "" {
+		return username
+	}
+	return email
+}
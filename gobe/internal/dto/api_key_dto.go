@@ -0,0 +1,40 @@
+package dto
+
+import "time"
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=telemetry:read device:control admin"`
+	// ExpiresInDays - bỏ trống thì mặc định 90 ngày
+	ExpiresInDays int `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
+
+type APIKeyDTO struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyResponse - Key chỉ trả về đúng một lần lúc tạo
+type CreateAPIKeyResponse struct {
+	APIKeyDTO
+	Key string `json:"key"`
+}
+
+// APIKeyPrincipal - chủ key và scope, middleware dựng claims từ đây. Role là role của chủ key trong tenant của key.
+type APIKeyPrincipal struct {
+	KeyID    uint
+	UserID   uint
+	TenantID uint
+	Name     string
+	Email    string
+	Role     string
+	Scopes   []string
+}
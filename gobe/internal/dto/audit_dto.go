@@ -0,0 +1,63 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry - dữ liệu handler gửi sang AuditService, actor/IP/user agent đã lấy từ request
+type AuditEntry struct {
+	TenantID   uint
+	ActorID    uint
+	ActorEmail string
+	APIKeyID   uint
+	Action     string
+	Outcome    string
+	TargetType string
+	TargetID   string
+	IP         string
+	UserAgent  string
+	Details    map[string]interface{}
+}
+
+// AuditLogQuery - bộ lọc của API tra cứu/xuất audit log, thời gian dạng RFC3339
+type AuditLogQuery struct {
+	ActorID    uint       `form:"actor_id"`
+	Action     string     `form:"action"`
+	Outcome    string     `form:"outcome" binding:"omitempty,oneof=success failure challenge"`
+	TargetType string     `form:"target_type"`
+	TargetID   string     `form:"target_id"`
+	IP         string     `form:"ip"`
+	From       *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit      int        `form:"limit,default=50" binding:"min=1,max=500"`
+	Offset     int        `form:"offset,default=0" binding:"min=0"`
+	// Format - chỉ dùng cho export: csv (mặc định) hoặc ndjson
+	Format string `form:"format" binding:"omitempty,oneof=csv ndjson"`
+}
+
+type AuditLogDTO struct {
+	ID         uint            `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	TenantID   uint            `json:"tenant_id"`
+	ActorID    uint            `json:"actor_id,omitempty"`
+	ActorEmail string          `json:"actor_email,omitempty"`
+	APIKeyID   uint            `json:"api_key_id,omitempty"`
+	Action     string          `json:"action"`
+	Outcome    string          `json:"outcome"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   string          `json:"target_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// AuditVerifyResult - BrokenAt là id của bản ghi đầu tiên không khớp chuỗi hash
+type AuditVerifyResult struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	BrokenAt uint   `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
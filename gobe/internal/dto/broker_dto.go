@@ -0,0 +1,19 @@
+package dto
+
+// Request từ plugin HTTP auth của Mosquitto, nhận cả JSON lẫn form tùy http_params_mode
+type BrokerUserRequest struct {
+	Username string `json:"username" form:"username" binding:"required"`
+	Password string `json:"password" form:"password"`
+	ClientID string `json:"clientid" form:"clientid"`
+}
+
+type BrokerSuperuserRequest struct {
+	Username string `json:"username" form:"username" binding:"required"`
+}
+
+type BrokerACLRequest struct {
+	Username string `json:"username" form:"username" binding:"required"`
+	ClientID string `json:"clientid" form:"clientid" binding:"required"`
+	Topic    string `json:"topic" form:"topic" binding:"required"`
+	Acc      int    `json:"acc" form:"acc" binding:"required"`
+}
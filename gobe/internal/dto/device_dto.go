@@ -1,5 +1,7 @@
 package dto
 
+import "time"
+
 type CreateDeviceRequest struct {
 	Name   string `json:"name" binding:"required"`
 	Status string `json:"status" binding:"required,oneof=ON OFF"`
@@ -12,8 +14,8 @@ type UpdateDeviceRequest struct {
 type DeviceControlRequest struct {
 	DeviceID   uint   `json:"device_id" binding:"required"`
 	Status     string `json:"status" binding:"required,oneof=ON OFF"`
-	UserId     uint   `json:"user_id" binding:"required"`
-	UserChange string `json:"user_change" binding:"required"`
+	UserId     uint   `json:"-"` // lấy từ token, không nhận từ body
+	UserChange string `json:"-"` // tên người điều khiển, cũng lấy từ token
 }
 type DevicesControlRequest struct {
 	Device1 DeviceControlRequest `json:"device1" binding:"required"`
@@ -23,3 +25,33 @@ type DevicesControlRequest struct {
 
 type DevicesControl struct {
 }
+
+type ShareDeviceRequest struct {
+	UserID     uint   `json:"user_id" binding:"required"`
+	Permission string `json:"permission" binding:"required,oneof=view control"`
+}
+
+// IssueCredentialRequest - mode "secret" trả secret ngay, "claim" trả claim code để board tự đổi lấy secret
+type IssueCredentialRequest struct {
+	Mode string `json:"mode" binding:"omitempty,oneof=secret claim"`
+}
+
+type ClaimDeviceRequest struct {
+	ClaimCode string `json:"claim_code" binding:"required"`
+}
+
+// DeviceCredentialResponse - secret/claim code chỉ xuất hiện đúng một lần khi được tạo
+type DeviceCredentialResponse struct {
+	DeviceID       uint       `json:"device_id"`
+	ClientID       string     `json:"client_id"`
+	TenantID       uint       `json:"tenant_id"`
+	TelemetryTopic string     `json:"telemetry_topic"`
+	CommandTopic   string     `json:"command_topic"`
+	Secret         string     `json:"secret,omitempty"`
+	ClaimCode      string     `json:"claim_code,omitempty"`
+	ClaimExpiresAt *time.Time `json:"claim_expires_at,omitempty"`
+	Status         string     `json:"status"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RotatedAt      *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
@@ -3,6 +3,6 @@ package dto
 type CreateDeviceHistoryRequest struct {
 	UserID     string `json:"user_id" binding:"required"`
 	DeviceID   string `json:"device_id" binding:"required"`
-	UserChange string `json:"user_change" binding:"required"`
+	UserChange string `json:"-"` // API lấy từ token, import lấy từ file
 	Status     string `json:"status" binding:"required,oneof=ON OFF"`
 }
@@ -0,0 +1,15 @@
+package dto
+
+// ExportQuery - cùng bộ lọc với API danh sách (status/device_id chỉ áp dụng cho lịch sử thiết bị),
+// thêm định dạng file và múi giờ in timestamp (tên IANA, mặc định UTC)
+type ExportQuery struct {
+	Format    string `form:"format,default=csv" json:"format" binding:"omitempty,oneof=csv ndjson parquet"`
+	Timezone  string `form:"tz" json:"tz,omitempty"`
+	StartDate string `form:"start_date" json:"start_date,omitempty"`
+	EndDate   string `form:"end_date" json:"end_date,omitempty"`
+	Search    string `form:"search" json:"search,omitempty"`
+	Status    string `form:"status" json:"status,omitempty"`
+	DeviceID  string `form:"device_id" json:"device_id,omitempty"`
+	// Async - luôn chạy nền; không bật thì chỉ chạy nền khi vượt EXPORT_SYNC_MAX_ROWS dòng
+	Async bool `form:"async" json:"-"`
+}
@@ -0,0 +1,27 @@
+package dto
+
+// ImportRequest - field form đi kèm file upload. Format bỏ trống thì đoán theo đuôi file,
+// Timezone áp dụng cho timestamp không ghi múi giờ (tên IANA, mặc định UTC).
+type ImportRequest struct {
+	Format   string `form:"format" json:"format" binding:"omitempty,oneof=csv ndjson"`
+	Timezone string `form:"tz" json:"tz,omitempty"`
+	DryRun   bool   `form:"dry_run" json:"dry_run"`
+	FileName string `form:"-" json:"file_name"`
+}
+
+type ImportRowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ImportReport - kết quả job import; với dry-run Inserted luôn bằng 0 và Duplicates là số dòng đã có sẵn
+type ImportReport struct {
+	DryRun          bool             `json:"dry_run"`
+	Rows            int64            `json:"rows"`
+	Valid           int64            `json:"valid"`
+	Invalid         int64            `json:"invalid"`
+	Inserted        int64            `json:"inserted"`
+	Duplicates      int64            `json:"duplicates"`
+	Errors          []ImportRowError `json:"errors"`
+	ErrorsTruncated bool             `json:"errors_truncated,omitempty"`
+}
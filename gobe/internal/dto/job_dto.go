@@ -0,0 +1,24 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobDTO - trạng thái job nền, DownloadURL chỉ có khi export đã xong và file còn hạn.
+// Result là báo cáo của job import.
+type JobDTO struct {
+	ID          uint            `json:"id"`
+	Kind        string          `json:"kind"`
+	Status      string          `json:"status"`
+	Processed   int64           `json:"processed"`
+	Total       int64           `json:"total"`
+	Error       string          `json:"error,omitempty"`
+	FileName    string          `json:"file_name,omitempty"`
+	FileSize    int64           `json:"file_size,omitempty"`
+	DownloadURL string          `json:"download_url,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,34 @@
+package dto
+
+import "encoding/json"
+
+type DeviceStateDTO struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type LiveReading struct {
+	Cursor string          `json:"cursor"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type LiveSnapshot struct {
+	Type     string           `json:"type"`
+	Devices  []DeviceStateDTO `json:"devices"`
+	Readings []LiveReading    `json:"readings"`
+	Cursor   string           `json:"cursor,omitempty"`
+}
+
+type LiveCatchUp struct {
+	Type      string        `json:"type"`
+	Readings  []LiveReading `json:"readings"`
+	Cursor    string        `json:"cursor,omitempty"`
+	Truncated bool          `json:"truncated"`
+}
+
+type DeviceStateEvent struct {
+	Type      string           `json:"type"`
+	Devices   []DeviceStateDTO `json:"devices"`
+	ChangedBy uint             `json:"changed_by"`
+}
@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+// SessionMeta - thông tin client lúc đăng nhập/refresh, lưu kèm session để user nhận ra thiết bị
+type SessionMeta struct {
+	UserAgent string
+	IP        string
+	Device    string
+}
+
+type SessionDTO struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
@@ -0,0 +1,51 @@
+package dto
+
+import "time"
+
+// TenantSelection - tenant của session/API key và role của user trong tenant đó
+type TenantSelection struct {
+	TenantID uint
+	Role     string
+}
+
+type CreateTenantRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type SwitchTenantRequest struct {
+	TenantID uint `json:"tenant_id" binding:"required"`
+}
+
+type InviteTenantMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=admin operator viewer"`
+}
+
+type UpdateTenantMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin operator viewer"`
+}
+
+type TenantDTO struct {
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	Slug    string `json:"slug"`
+	Role    string `json:"role"`
+	Current bool   `json:"current"`
+}
+
+type TenantMemberDTO struct {
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+// TenantInvitationDTO - lời mời đang chờ, TenantName cho người được mời, Email cho admin tenant
+type TenantInvitationDTO struct {
+	TenantID   uint      `json:"tenant_id"`
+	TenantName string    `json:"tenant_name,omitempty"`
+	UserID     uint      `json:"user_id"`
+	Email      string    `json:"email,omitempty"`
+	Role       string    `json:"role"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
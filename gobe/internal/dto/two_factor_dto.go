@@ -0,0 +1,33 @@
+package dto
+
+type TwoFactorChallenge struct {
+	Token     string `json:"challenge_token"`
+	Method    string `json:"method"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// TwoFactorVerifyRequest - code là mã 6 số (email/TOTP) hoặc một recovery code
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TOTPSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TOTPEnableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// TwoFactorPasswordRequest - xác nhận lại mật khẩu cho các thao tác nhạy cảm với 2FA
+type TwoFactorPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	Method        string   `json:"method"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -5,9 +5,13 @@ import (
 )
 
 type UserDTO struct {
-	ID    uint   `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID              uint   `json:"id"`
+	Name            string `json:"name"`
+	Email           string `json:"email"`
+	Role            string `json:"role"`
+	TwoFactorMethod string `json:"two_factor_method"`
+	// TenantID - tenant của session vừa cấp, Role là role trong tenant này
+	TenantID uint `json:"tenant_id,omitempty"`
 }
 
 type CreateUserRequest struct {
@@ -42,6 +46,8 @@ type LoginRequest struct {
 type LoginResponse struct {
 	User      *UserDTO             `json:"user"`
 	TokenPair *jwt_utils.TokenPair `json:"token_pair"`
+	// Challenge khác nil khi user bật 2FA, TokenPair chỉ được cấp sau bước /login/2fa
+	Challenge *TwoFactorChallenge `json:"challenge,omitempty"`
 }
 
 type CreateUserResponse struct {
@@ -52,10 +58,10 @@ type CreateUserResponse struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// UpdateUserRequest - field rỗng giữ nguyên giá trị cũ; đổi mật khẩu đi qua quên/đặt lại mật khẩu
 type UpdateUserRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email" binding:"omitempty,email"`
-	Password string `json:"password"`
+	Name  string `json:"name"`
+	Email string `json:"email" binding:"omitempty,email"`
 }
 
 type GetAllUsersRequest struct {
@@ -82,4 +88,25 @@ type UserResponse struct {
 	ID    uint   `json:"id"`
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin operator viewer"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email" binding:"required,email"`
+	OTP         string `json:"otp" binding:"required,len=6,numeric"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// OIDCLoginResult - Redirect là URL frontend (đã kiểm tra) để callback chuyển hướng về
+type OIDCLoginResult struct {
+	Login    *LoginResponse
+	Redirect string
 }
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/middlewares"
+	"iot/internal/model"
+	"iot/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APIKeyHandlerInterface interface {
+	CreateAPIKey(c *gin.Context)
+	ListAPIKeys(c *gin.Context)
+	RevokeAPIKey(c *gin.Context)
+	ListUserAPIKeys(c *gin.Context)
+	RevokeUserAPIKey(c *gin.Context)
+}
+
+type APIKeyHandler struct {
+	db    *gorm.DB
+	ks    services.APIKeyServiceInterface
+	audit services.AuditServiceInterface
+}
+
+func NewAPIKeyHandler(db *gorm.DB, ks services.APIKeyServiceInterface, audit services.AuditServiceInterface) APIKeyHandlerInterface {
+	return &APIKeyHandler{
+		db:    db,
+		ks:    ks,
+		audit: audit,
+	}
+}
+
+// CreateAPIKey - key mới thuộc user hiện tại, scope không được vượt quá role
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req = dto.CreateAPIKeyRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor := middlewares.CurrentActor(c)
+	for _, scope := range req.Scopes {
+		if !middlewares.RoleAllowsScope(actor.Role, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your role does not allow scope " + scope})
+			return
+		}
+	}
+	key, err := h.ks.Create(middlewares.TenantDB(c, h.db), actor.UserID, &req)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+	h.recordKey(c, model.AuditAPIKeyCreate, key.ID, map[string]interface{}{"name": key.Name, "prefix": key.Prefix, "scopes": key.Scopes})
+	c.JSON(http.StatusCreated, gin.H{"message": "API key created, store it now, it will not be shown again", "data": key})
+}
+
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.ks.List(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c).UserID)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	keyID, ok := uintParam(c, "keyId", "Invalid API key ID")
+	if !ok {
+		return
+	}
+	if err := h.ks.Revoke(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c).UserID, keyID); err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+	h.recordKey(c, model.AuditAPIKeyRevoke, keyID, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// ListUserAPIKeys - admin xem key của user khác
+func (h *APIKeyHandler) ListUserAPIKeys(c *gin.Context) {
+	userID, ok := uintParam(c, "id", "Invalid User ID")
+	if !ok {
+		return
+	}
+	keys, err := h.ks.List(middlewares.TenantDB(c, h.db), userID)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+// RevokeUserAPIKey - admin thu hồi key của user khác (key bị lộ, nhân viên nghỉ...)
+func (h *APIKeyHandler) RevokeUserAPIKey(c *gin.Context) {
+	userID, ok := uintParam(c, "id", "Invalid User ID")
+	if !ok {
+		return
+	}
+	keyID, ok := uintParam(c, "keyId", "Invalid API key ID")
+	if !ok {
+		return
+	}
+	if err := h.ks.Revoke(middlewares.TenantDB(c, h.db), userID, keyID); err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+	h.recordKey(c, model.AuditAPIKeyRevoke, keyID, map[string]interface{}{"owner_id": userID})
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+func (h *APIKeyHandler) recordKey(c *gin.Context, action string, keyID uint, details map[string]interface{}) {
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     action,
+		Outcome:    model.AuditSuccess,
+		TargetType: "api_key",
+		TargetID:   strconv.FormatUint(uint64(keyID), 10),
+		Details:    details,
+	})
+}
+
+func uintParam(c *gin.Context, name, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondAPIKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTooManyAPIKeys):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
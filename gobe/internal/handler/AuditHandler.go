@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"iot/internal/dto"
+	"iot/internal/middlewares"
+	"iot/internal/services"
+	"iot/internal/tenant"
+	"iot/pkg/logger"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type AuditHandlerInterface interface {
+	ListAuditLogs(c *gin.Context)
+	ExportAuditLogs(c *gin.Context)
+	VerifyAuditChain(c *gin.Context)
+}
+
+type AuditHandler struct {
+	db *gorm.DB
+	as services.AuditServiceInterface
+	ts services.TenantServiceInterface
+}
+
+func NewAuditHandler(db *gorm.DB, as services.AuditServiceInterface, ts services.TenantServiceInterface) AuditHandlerInterface {
+	return &AuditHandler{
+		db: db,
+		as: as,
+		ts: ts,
+	}
+}
+
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	var query = dto.AuditLogQuery{}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logs, total, err := h.as.List(middlewares.TenantDB(c, h.db), &query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": logs, "total": total})
+}
+
+var auditCSVHeader = []string{"id", "created_at", "tenant_id", "actor_id", "actor_email", "api_key_id", "action", "outcome", "target_type", "target_id", "ip", "user_agent", "details", "prev_hash", "hash"}
+
+// ExportAuditLogs - ghi dần từng lô ra response, không giới hạn limit/offset như API tra cứu
+func (h *AuditHandler) ExportAuditLogs(c *gin.Context) {
+	var query = dto.AuditLogQuery{}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filename := "audit-logs-" + time.Now().UTC().Format("20060102T150405Z")
+	var write func(dto.AuditLogDTO) error
+	var flush func()
+	if query.Format == "ndjson" {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.ndjson"`)
+		encoder := json.NewEncoder(c.Writer)
+		write = func(log dto.AuditLogDTO) error { return encoder.Encode(log) }
+		flush = c.Writer.Flush
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		writer := csv.NewWriter(c.Writer)
+		write = func(log dto.AuditLogDTO) error {
+			return writer.Write([]string{
+				strconv.FormatUint(uint64(log.ID), 10),
+				log.CreatedAt.UTC().Format(time.RFC3339Nano),
+				strconv.FormatUint(uint64(log.TenantID), 10),
+				strconv.FormatUint(uint64(log.ActorID), 10),
+				log.ActorEmail,
+				strconv.FormatUint(uint64(log.APIKeyID), 10),
+				log.Action,
+				log.Outcome,
+				log.TargetType,
+				log.TargetID,
+				log.IP,
+				log.UserAgent,
+				string(log.Details),
+				log.PrevHash,
+				log.Hash,
+			})
+		}
+		flush = func() {
+			writer.Flush()
+			c.Writer.Flush()
+		}
+		if err := writer.Write(auditCSVHeader); err != nil {
+			return
+		}
+	}
+	c.Status(http.StatusOK)
+
+	count := 0
+	err := h.as.Export(middlewares.TenantDB(c, h.db), &query, func(log dto.AuditLogDTO) error {
+		if err := write(log); err != nil {
+			return err
+		}
+		count++
+		if count%500 == 0 {
+			flush()
+		}
+		return nil
+	})
+	flush()
+	// Header đã gửi nên lỗi giữa chừng chỉ ghi log, client nhận file bị cắt
+	if err != nil {
+		logger.Log.Error("Audit log export failed", zap.Int("written", count), zap.Error(err))
+	}
+}
+
+// VerifyAuditChain - kiểm tra toàn bộ chuỗi hash, chỉ trả id bản ghi lỗi chứ không trả nội dung.
+// Chuỗi dùng chung cho mọi tenant nên chỉ admin hệ thống được chạy, admin tenant thì không.
+func (h *AuditHandler) VerifyAuditChain(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	admin, err := h.ts.IsSystemAdmin(db, middlewares.CurrentActor(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !admin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Audit chain verification requires a system admin"})
+		return
+	}
+	result, err := h.as.Verify(tenant.System(db))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// recordAudit - actor và tenant lấy từ token nếu entry chưa có, IP và user agent lấy từ request.
+// Ghi audit lỗi không làm hỏng response của hành động chính nên chỉ ghi log.
+func recordAudit(c *gin.Context, as services.AuditServiceInterface, db *gorm.DB, entry dto.AuditEntry) {
+	if claims, ok := middlewares.CurrentUser(c); ok {
+		if entry.ActorID == 0 {
+			entry.ActorID = claims.Id
+			entry.ActorEmail = claims.Email
+		}
+		if entry.TenantID == 0 {
+			entry.TenantID = claims.TenantID
+		}
+	}
+	if key := middlewares.CurrentAPIKey(c); key != nil {
+		entry.APIKeyID = key.KeyID
+	}
+	entry.IP = c.ClientIP()
+	entry.UserAgent = c.Request.UserAgent()
+	if err := as.Record(c.Request.Context(), db, &entry); err != nil {
+		logger.Log.Error("Audit log write failed", zap.String("action", entry.Action), zap.Uint("actor_id", entry.ActorID), zap.Error(err))
+	}
+}
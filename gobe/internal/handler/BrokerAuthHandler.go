@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"iot/internal/dto"
+	"iot/internal/services"
+	"iot/pkg/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// BrokerAuthHandler - endpoint cho plugin HTTP auth của Mosquitto.
+// Plugin chỉ xét status code: 200 là cho phép, còn lại là từ chối.
+type BrokerAuthHandlerInterface interface {
+	User(c *gin.Context)
+	Superuser(c *gin.Context)
+	ACL(c *gin.Context)
+}
+
+type BrokerAuthHandler struct {
+	db *gorm.DB
+	bs services.BrokerAuthServiceInterface
+}
+
+func NewBrokerAuthHandler(db *gorm.DB, bs services.BrokerAuthServiceInterface) BrokerAuthHandlerInterface {
+	return &BrokerAuthHandler{
+		db: db,
+		bs: bs,
+	}
+}
+
+func (h *BrokerAuthHandler) User(c *gin.Context) {
+	var req = dto.BrokerUserRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		respondBroker(c, false, err.Error())
+		return
+	}
+	ok, err := h.bs.AuthenticateUser(h.db, &req)
+	if err != nil {
+		logger.Log.Error("Broker user check failed", zap.String("username", req.Username), zap.Error(err))
+		respondBroker(c, false, "internal error")
+		return
+	}
+	respondBroker(c, ok, "invalid credentials")
+}
+
+func (h *BrokerAuthHandler) Superuser(c *gin.Context) {
+	var req = dto.BrokerSuperuserRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		respondBroker(c, false, err.Error())
+		return
+	}
+	respondBroker(c, h.bs.IsSuperuser(&req), "not a superuser")
+}
+
+func (h *BrokerAuthHandler) ACL(c *gin.Context) {
+	var req = dto.BrokerACLRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		respondBroker(c, false, err.Error())
+		return
+	}
+	ok, err := h.bs.CheckACL(h.db, &req)
+	if err != nil {
+		logger.Log.Error("Broker ACL check failed", zap.String("clientid", req.ClientID), zap.String("topic", req.Topic), zap.Error(err))
+		respondBroker(c, false, "internal error")
+		return
+	}
+	if !ok {
+		logger.Log.Warn("Broker ACL denied", zap.String("clientid", req.ClientID), zap.String("topic", req.Topic), zap.Int("acc", req.Acc))
+	}
+	respondBroker(c, ok, "topic not allowed")
+}
+
+// respondBroker - body {"ok", "error"} cho chế độ http_response_mode json của plugin
+func respondBroker(c *gin.Context, ok bool, reason string) {
+	if ok {
+		c.JSON(http.StatusOK, gin.H{"ok": true, "error": ""})
+		return
+	}
+	c.JSON(http.StatusForbidden, gin.H{"ok": false, "error": reason})
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/middlewares"
+	"iot/internal/model"
+	"iot/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DeviceCredentialHandlerInterface interface {
+	IssueCredential(c *gin.Context)
+	GetCredential(c *gin.Context)
+	RotateCredential(c *gin.Context)
+	RevokeCredential(c *gin.Context)
+	ClaimDevice(c *gin.Context)
+}
+
+type DeviceCredentialHandler struct {
+	db    *gorm.DB
+	cs    services.DeviceCredentialServiceInterface
+	audit services.AuditServiceInterface
+}
+
+func NewDeviceCredentialHandler(db *gorm.DB, cs services.DeviceCredentialServiceInterface, audit services.AuditServiceInterface) DeviceCredentialHandlerInterface {
+	return &DeviceCredentialHandler{
+		db:    db,
+		cs:    cs,
+		audit: audit,
+	}
+}
+
+func (h *DeviceCredentialHandler) IssueCredential(c *gin.Context) {
+	id, ok := deviceIDParam(c)
+	if !ok {
+		return
+	}
+	var req = dto.IssueCredentialRequest{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	credential, err := h.cs.Issue(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c), id, req.Mode)
+	if err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+	h.recordCredential(c, model.AuditCredentialIssue, id, map[string]interface{}{"client_id": credential.ClientID, "mode": req.Mode})
+	c.JSON(http.StatusCreated, gin.H{"message": "Device credentials issued, store them now, they will not be shown again", "data": credential})
+}
+
+func (h *DeviceCredentialHandler) GetCredential(c *gin.Context) {
+	id, ok := deviceIDParam(c)
+	if !ok {
+		return
+	}
+	credential, err := h.cs.Get(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c), id)
+	if err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": credential})
+}
+
+func (h *DeviceCredentialHandler) RotateCredential(c *gin.Context) {
+	id, ok := deviceIDParam(c)
+	if !ok {
+		return
+	}
+	credential, err := h.cs.Rotate(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c), id)
+	if err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+	h.recordCredential(c, model.AuditCredentialRotate, id, map[string]interface{}{"client_id": credential.ClientID})
+	c.JSON(http.StatusOK, gin.H{"message": "Device secret rotated", "data": credential})
+}
+
+func (h *DeviceCredentialHandler) RevokeCredential(c *gin.Context) {
+	id, ok := deviceIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.cs.Revoke(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c), id); err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+	h.recordCredential(c, model.AuditCredentialRevoke, id, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Device credentials revoked"})
+}
+
+// ClaimDevice - board tự gọi (không có user token), chỉ cần claim code
+func (h *DeviceCredentialHandler) ClaimDevice(c *gin.Context) {
+	var req = dto.ClaimDeviceRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	credential, err := h.cs.Claim(h.db, req.ClaimCode)
+	if err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": credential})
+}
+
+func deviceIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondCredentialError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+	case errors.Is(err, services.ErrCredentialNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDeviceForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCredentialExists), errors.Is(err, services.ErrCredentialRevoked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidClaimCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (h *DeviceCredentialHandler) recordCredential(c *gin.Context, action string, deviceID uint, details map[string]interface{}) {
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     action,
+		Outcome:    model.AuditSuccess,
+		TargetType: "device",
+		TargetID:   strconv.FormatUint(uint64(deviceID), 10),
+		Details:    details,
+	})
+}
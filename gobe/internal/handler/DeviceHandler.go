@@ -2,7 +2,10 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"iot/internal/dto"
+	"iot/internal/middlewares"
+	"iot/internal/model"
 	"iot/internal/services"
 	"net/http"
 	"strconv"
@@ -10,6 +13,7 @@ import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
@@ -20,6 +24,9 @@ type DeviceHandlerInterface interface {
 	UpdateDevice(c *gin.Context)
 	DeleteDevice(c *gin.Context)
 	DeviceController(c *gin.Context)
+	ShareDevice(c *gin.Context)
+	RevokeShare(c *gin.Context)
+	GetShares(c *gin.Context)
 }
 
 type DeviceHandler struct {
@@ -28,15 +35,17 @@ type DeviceHandler struct {
 	deviceService services.DeviceServiceInterface
 	context       context.Context
 	mqtt          mqtt.Client
+	audit         services.AuditServiceInterface
 }
 
-func NewDeviceHandler(db *gorm.DB, redis *redis.Client, ds services.DeviceServiceInterface, context context.Context, mqtt mqtt.Client) DeviceHandlerInterface {
+func NewDeviceHandler(db *gorm.DB, redis *redis.Client, ds services.DeviceServiceInterface, context context.Context, mqtt mqtt.Client, audit services.AuditServiceInterface) DeviceHandlerInterface {
 	return &DeviceHandler{
 		db:            db,
 		redis:         redis,
 		deviceService: ds,
 		context:       context,
 		mqtt:          mqtt,
+		audit:         audit,
 	}
 }
 
@@ -46,11 +55,17 @@ func (h *DeviceHandler) CreateDevice(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	err := h.deviceService.CreateDevice(h.db, &req, h.redis)
+	err := h.deviceService.CreateDevice(middlewares.TenantDB(c, h.db), &req, middlewares.CurrentActor(c), h.redis)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     model.AuditDeviceCreate,
+		Outcome:    model.AuditSuccess,
+		TargetType: "device",
+		Details:    map[string]interface{}{"name": req.Name, "status": req.Status},
+	})
 	c.JSON(200, gin.H{"message": "Device created successfully"})
 }
 
@@ -61,7 +76,10 @@ func (h *DeviceHandler) GetDeviceByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
 		return
 	}
-	device, err := h.deviceService.GetByID(h.db, uint(id))
+	if !h.authorizeDevice(c, uint(id), model.GrantView) {
+		return
+	}
+	device, err := h.deviceService.GetByID(middlewares.TenantDB(c, h.db), uint(id))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -74,24 +92,20 @@ func (h *DeviceHandler) GetDeviceByID(c *gin.Context) {
 }
 
 func (h *DeviceHandler) GetAllDevices(c *gin.Context) {
-	limitParam := c.DefaultQuery("limit", "10")
-	offsetParam := c.DefaultQuery("offset", "0")
-
-	limit, err := strconv.Atoi(limitParam)
-	if err != nil || limit <= 0 || limit > 100 {
-		limit = 10
-	}
-	offset, err := strconv.Atoi(offsetParam)
-	if err != nil || offset < 0 {
-		offset = 0
+	page, ok := pageRequest(c)
+	if !ok {
+		return
 	}
 
-	devices, err := h.deviceService.GetAllDevices(h.db, limit, offset, h.redis)
+	devices, pageInfo, err := h.deviceService.GetAllDevices(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c), page, h.redis)
+	if respondListError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"data": devices})
+	c.JSON(200, gin.H{"data": devices, "pagination": pageInfo})
 }
 
 func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
@@ -113,7 +127,11 @@ func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
 		return
 	}
 
-	device, err := h.deviceService.GetByID(h.db, uint(id))
+	if !h.authorizeDevice(c, uint(id), "") {
+		return
+	}
+
+	device, err := h.deviceService.GetByID(middlewares.TenantDB(c, h.db), uint(id))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -123,10 +141,17 @@ func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
 		return
 	}
 
-	if err := h.deviceService.UpdateDevice(h.db, uint(id), &req); err != nil {
+	if err := h.deviceService.UpdateDevice(middlewares.TenantDB(c, h.db), uint(id), &req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     model.AuditDeviceUpdate,
+		Outcome:    model.AuditSuccess,
+		TargetType: "device",
+		TargetID:   strconv.Itoa(id),
+		Details:    map[string]interface{}{"name": req.Name, "status": req.Status},
+	})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Device updated successfully"})
 }
@@ -138,11 +163,20 @@ func (h *DeviceHandler) DeleteDevice(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
 		return
 	}
-	err = h.deviceService.DeleteDevice(h.db, uint(id), h.redis)
+	if !h.authorizeDevice(c, uint(id), "") {
+		return
+	}
+	err = h.deviceService.DeleteDevice(middlewares.TenantDB(c, h.db), uint(id), h.redis)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     model.AuditDeviceDelete,
+		Outcome:    model.AuditSuccess,
+		TargetType: "device",
+		TargetID:   strconv.Itoa(id),
+	})
 	c.JSON(200, gin.H{"message": "Device deleted successfully"})
 }
 
@@ -153,9 +187,129 @@ func (h *DeviceHandler) DeviceController(c *gin.Context) {
 		return
 	}
 
-	if err := h.deviceService.DeviceController(h.context, h.db, req, h.mqtt); err != nil {
+	// ctx của server để lệnh không bị hủy khi client ngắt, vẫn nối vào trace của request
+	ctx := trace.ContextWithSpan(h.context, trace.SpanFromContext(c.Request.Context()))
+	err := h.deviceService.DeviceController(ctx, middlewares.TenantDB(c, h.db), req, middlewares.CurrentActor(c), h.mqtt)
+	entry := dto.AuditEntry{
+		Action:  model.AuditDeviceControl,
+		Outcome: model.AuditSuccess,
+		Details: controlAuditDetails(req, "http", err),
+	}
+	if err != nil {
+		entry.Outcome = model.AuditFailure
+	}
+	recordAudit(c, h.audit, h.db, entry)
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Device control command sent", "data": req})
 }
+
+func (h *DeviceHandler) ShareDevice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
+		return
+	}
+	var req dto.ShareDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.deviceService.ShareDevice(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c), uint(id), &req); err != nil {
+		h.respondDeviceError(c, err)
+		return
+	}
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     model.AuditDeviceShare,
+		Outcome:    model.AuditSuccess,
+		TargetType: "device",
+		TargetID:   strconv.Itoa(id),
+		Details:    map[string]interface{}{"user_id": req.UserID, "permission": req.Permission},
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Device shared successfully"})
+}
+
+func (h *DeviceHandler) RevokeShare(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if err := h.deviceService.RevokeShare(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c), uint(id), uint(userID)); err != nil {
+		h.respondDeviceError(c, err)
+		return
+	}
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     model.AuditDeviceUnshare,
+		Outcome:    model.AuditSuccess,
+		TargetType: "device",
+		TargetID:   strconv.Itoa(id),
+		Details:    map[string]interface{}{"user_id": userID},
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Device share revoked"})
+}
+
+func (h *DeviceHandler) GetShares(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
+		return
+	}
+	grants, err := h.deviceService.GetShares(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c), uint(id))
+	if err != nil {
+		h.respondDeviceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": grants})
+}
+
+// authorizeDevice - ghi response lỗi và trả false nếu user không có quyền trên device
+func (h *DeviceHandler) authorizeDevice(c *gin.Context, id uint, permission string) bool {
+	ok, err := h.deviceService.CanAccessDevice(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c), id, permission)
+	if err != nil {
+		h.respondDeviceError(c, err)
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrDeviceForbidden.Error()})
+		return false
+	}
+	return true
+}
+
+func (h *DeviceHandler) respondDeviceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+	case errors.Is(err, services.ErrDeviceForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrShareWithSelf), errors.Is(err, services.ErrShareNotMember):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// controlAuditDetails - lệnh của từng device, người điều khiển đã có ở actor của audit entry
+func controlAuditDetails(req *dto.DevicesControlRequest, channel string, err error) map[string]interface{} {
+	devices := make([]map[string]interface{}, 0, 3)
+	for _, d := range []dto.DeviceControlRequest{req.Device1, req.Device2, req.Device3} {
+		devices = append(devices, map[string]interface{}{"device_id": d.DeviceID, "status": d.Status})
+	}
+	details := map[string]interface{}{"channel": channel, "devices": devices}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	return details
+}
@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"iot/internal/dto"
+	"iot/internal/middlewares"
 	"iot/internal/services"
 	"strconv"
 
@@ -33,7 +35,12 @@ func (h *DeviceHistoryHandler) CreateDeviceHistory(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	history, err := h.deviceHistoryService.CreateDeviceHistory(h.db, *req)
+	req.UserChange = middlewares.CurrentActor(c).Name
+	history, err := h.deviceHistoryService.CreateDeviceHistory(middlewares.TenantDB(c, h.db), *req)
+	if errors.Is(err, services.ErrInvalidHistory) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -48,7 +55,7 @@ func (h *DeviceHistoryHandler) GetDeviceHistoryByDeviceID(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid device ID"})
 		return
 	}
-	history, err := h.deviceHistoryService.GetDeviceHistoryByDeviceID(h.db, uint(deviceID))
+	history, err := h.deviceHistoryService.GetDeviceHistoryByDeviceID(middlewares.TenantDB(c, h.db), uint(deviceID))
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -58,17 +65,9 @@ func (h *DeviceHistoryHandler) GetDeviceHistoryByDeviceID(c *gin.Context) {
 
 func (h *DeviceHistoryHandler) GetAllDeviceHistories(c *gin.Context) {
 	// This function can be implemented to fetch all device histories if needed
-	limit := c.DefaultQuery("limit", "10")
-	offset := c.DefaultQuery("offset", "0")
-	order := c.DefaultQuery("order", "desc")
-	sort_by := c.DefaultQuery("sort_by", "created_at")
-	limitInt, err := strconv.Atoi(limit)
-	if err != nil || limitInt <= 0 || limitInt > 100 {
-		limitInt = 10
-	}
-	offsetInt, err := strconv.Atoi(offset)
-	if err != nil || offsetInt < 0 {
-		offsetInt = 0
+	page, ok := pageRequest(c)
+	if !ok {
+		return
 	}
 	status := c.DefaultQuery("status", "")
 	deviceId := c.DefaultQuery("device_id", "")
@@ -76,10 +75,13 @@ func (h *DeviceHistoryHandler) GetAllDeviceHistories(c *gin.Context) {
 	endDate := c.DefaultQuery("end_date", "")
 	search := c.DefaultQuery("search", "")
 
-	histories, total, err := h.deviceHistoryService.GetAllDeviceHistories(h.db, limitInt, offsetInt, sort_by+" "+order, status, deviceId, startDate, endDate, search)
+	histories, pageInfo, err := h.deviceHistoryService.GetAllDeviceHistories(middlewares.TenantDB(c, h.db), page, status, deviceId, startDate, endDate, search)
+	if respondListError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "success", "data": histories, "total": total})
+	c.JSON(200, gin.H{"status": "success", "data": histories, "pagination": pageInfo})
 }
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/middlewares"
+	"iot/internal/model"
+	"iot/internal/services"
+	"iot/pkg/export"
+	"iot/pkg/logger"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type ExportHandlerInterface interface {
+	ExportSensorData(c *gin.Context)
+	ExportDeviceHistories(c *gin.Context)
+}
+
+type ExportHandler struct {
+	db            *gorm.DB
+	es            services.ExportServiceInterface
+	deviceService services.DeviceServiceInterface
+}
+
+func NewExportHandler(db *gorm.DB, es services.ExportServiceInterface, ds services.DeviceServiceInterface) ExportHandlerInterface {
+	return &ExportHandler{
+		db:            db,
+		es:            es,
+		deviceService: ds,
+	}
+}
+
+func (h *ExportHandler) ExportSensorData(c *gin.Context) {
+	if !authorizeTelemetry(c, h.deviceService, h.db) {
+		return
+	}
+	h.export(c, model.JobExportSensorData)
+}
+
+func (h *ExportHandler) ExportDeviceHistories(c *gin.Context) {
+	h.export(c, model.JobExportDeviceHistory)
+}
+
+// export - ít dòng thì stream thẳng ra response, nhiều dòng (hoặc async=true) thì tạo job và trả 202
+func (h *ExportHandler) export(c *gin.Context, kind string) {
+	var query = dto.ExportQuery{}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	db := middlewares.TenantDB(c, h.db)
+	total, async, err := h.es.Prepare(db, kind, &query)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+
+	if async {
+		job, err := h.es.StartJob(db, middlewares.CurrentActor(c), kind, &query, total)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "Export is running in the background", "data": job})
+		return
+	}
+
+	c.Header("Content-Type", export.ContentType(query.Format))
+	c.Header("Content-Disposition", `attachment; filename="`+h.es.FileName(kind, &query, time.Now())+`"`)
+	c.Status(http.StatusOK)
+	// Header đã gửi nên lỗi giữa chừng chỉ ghi log, client nhận file bị cắt
+	if _, err := h.es.Write(db, kind, &query, c.Writer, nil); err != nil {
+		logger.Log.Error("Export stream failed", zap.String("kind", kind), zap.Error(err))
+	}
+}
+
+func respondExportError(c *gin.Context, err error) {
+	if respondListError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidTimezone):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
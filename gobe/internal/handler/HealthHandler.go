@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"iot/pkg/health"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandlerInterface interface {
+	Liveness(c *gin.Context)
+	Readiness(c *gin.Context)
+}
+
+type HealthHandler struct {
+	checker   *health.Checker
+	startedAt time.Time
+}
+
+func NewHealthHandler(checker *health.Checker) HealthHandlerInterface {
+	return &HealthHandler{
+		checker:   checker,
+		startedAt: time.Now(),
+	}
+}
+
+// Liveness - process còn chạy thì luôn 200, kèm trạng thái dependency để tham khảo.
+// Không trả lỗi khi dependency hỏng để orchestrator không restart vòng lặp lúc MySQL/Redis down.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	report := h.checker.Check(c.Request.Context())
+	c.JSON(http.StatusOK, gin.H{
+		"status":         "alive",
+		"uptime_seconds": int64(time.Since(h.startedAt).Seconds()),
+		"dependencies":   report,
+	})
+}
+
+// Readiness - 503 khi dependency bắt buộc hỏng, degraded (MQTT/SMTP hỏng) vẫn 200
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.checker.Check(c.Request.Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
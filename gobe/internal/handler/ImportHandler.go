@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/middlewares"
+	"iot/internal/model"
+	"iot/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ImportHandlerInterface interface {
+	ImportSensorData(c *gin.Context)
+	ImportDeviceHistories(c *gin.Context)
+}
+
+type ImportHandler struct {
+	db       *gorm.DB
+	is       services.ImportServiceInterface
+	maxBytes int64
+}
+
+func NewImportHandler(db *gorm.DB, is services.ImportServiceInterface, maxBytes int64) ImportHandlerInterface {
+	return &ImportHandler{
+		db:       db,
+		is:       is,
+		maxBytes: maxBytes,
+	}
+}
+
+func (h *ImportHandler) ImportSensorData(c *gin.Context) {
+	h.importFile(c, model.JobImportSensorData)
+}
+
+func (h *ImportHandler) ImportDeviceHistories(c *gin.Context) {
+	h.importFile(c, model.JobImportDeviceHistory)
+}
+
+// importFile - multipart với field "file"; luôn chạy nền, kết quả (kể cả dry-run) xem qua /jobs/:id
+func (h *ImportHandler) importFile(c *gin.Context, kind string) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBytes)
+	var req = dto.ImportRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	req.FileName = header.Filename
+
+	job, err := h.is.Start(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c), kind, file, &req)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+	message := "Import is running in the background"
+	if req.DryRun {
+		message = "Dry run is running in the background, nothing will be written"
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": message, "data": job})
+}
+
+func respondUploadError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "limit": tooLarge.Limit})
+	case errors.Is(err, http.ErrMissingFile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file field"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
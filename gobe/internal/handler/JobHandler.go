@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"iot/internal/middlewares"
+	"iot/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type JobHandlerInterface interface {
+	ListJobs(c *gin.Context)
+	GetJob(c *gin.Context)
+	DownloadJobResult(c *gin.Context)
+}
+
+type JobHandler struct {
+	db *gorm.DB
+	js services.JobServiceInterface
+}
+
+func NewJobHandler(db *gorm.DB, js services.JobServiceInterface) JobHandlerInterface {
+	return &JobHandler{
+		db: db,
+		js: js,
+	}
+}
+
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	jobs, err := h.js.List(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+func (h *JobHandler) GetJob(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+	job, err := h.js.Get(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c).UserID, id)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+func (h *JobHandler) DownloadJobResult(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+	job, err := h.js.ResultFile(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c).UserID, id)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+	c.FileAttachment(job.FilePath, job.FileName)
+}
+
+func jobIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	case errors.Is(err, services.ErrJobNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrJobExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/helper/mailer"
+	"iot/internal/model"
+	"iot/internal/services"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type OIDCHandlerInterface interface {
+	Login(c *gin.Context)
+	Callback(c *gin.Context)
+}
+
+type OIDCHandler struct {
+	db     *gorm.DB
+	mailer *mailer.MailService
+	oidc   services.OIDCServiceInterface
+	audit  services.AuditServiceInterface
+}
+
+func NewOIDCHandler(db *gorm.DB, mailer *mailer.MailService, oidc services.OIDCServiceInterface, audit services.AuditServiceInterface) OIDCHandlerInterface {
+	return &OIDCHandler{
+		db:     db,
+		mailer: mailer,
+		oidc:   oidc,
+		audit:  audit,
+	}
+}
+
+// Login - chuyển hướng sang provider, ?redirect=/path là trang frontend mở sau khi đăng nhập
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, err := h.oidc.Begin(c.Request.Context(), c.Query("redirect"))
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback - provider trả code + state về đây. Thành công thì set cookie như /user/login rồi về frontend;
+// user bật 2FA thì frontend nhận challenge_token trong query và gọi tiếp /user/login/2fa
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Identity provider returned " + providerErr, "description": c.Query("error_description")})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing code or state"})
+		return
+	}
+
+	result, err := h.oidc.Complete(c.Request.Context(), h.db, code, state, sessionMeta(c), h.mailer)
+	entry := dto.AuditEntry{Action: model.AuditLoginOIDC, Outcome: model.AuditSuccess}
+	switch {
+	case err != nil:
+		entry.Outcome = model.AuditFailure
+		entry.Details = map[string]interface{}{"reason": err.Error()}
+	case result.Login.Challenge != nil:
+		entry.Outcome = model.AuditChallenge
+		entry.ActorID, entry.ActorEmail = result.Login.User.ID, result.Login.User.Email
+	default:
+		entry.ActorID, entry.ActorEmail, entry.TenantID = result.Login.User.ID, result.Login.User.Email, result.Login.User.TenantID
+	}
+	recordAudit(c, h.audit, h.db, entry)
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+
+	if challenge := result.Login.Challenge; challenge != nil {
+		c.Redirect(http.StatusFound, appendQuery(result.Redirect, url.Values{
+			"challenge_token": {challenge.Token},
+			"method":          {challenge.Method},
+		}))
+		return
+	}
+	setAuthCookies(c, result.Login.TokenPair)
+	c.Redirect(http.StatusFound, result.Redirect)
+}
+
+func appendQuery(target string, values url.Values) string {
+	separator := "?"
+	if strings.Contains(target, "?") {
+		separator = "&"
+	}
+	return target + separator + values.Encode()
+}
+
+func respondOIDCError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOIDCInvalidState):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOIDCEmailNotVerified), errors.Is(err, services.ErrOIDCSignupDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOIDCProvider):
+		// Chi tiết lỗi từ provider chỉ ghi log, không trả cho client
+		log.Printf("OIDC login failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": services.ErrOIDCProvider.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Linked account no longer exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,7 +1,12 @@
 package handler
 
 import (
+	"errors"
+	"iot/internal/middlewares"
 	"iot/internal/services"
+	"iot/pkg/filter"
+	"iot/pkg/pagination"
+	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -13,57 +18,79 @@ type SensorHandlerInterface interface {
 	GetAllSensorData(c *gin.Context)
 	GetSensorDataByID(c *gin.Context)
 	GetLastSensorData(c *gin.Context)
+	AggregateSensorData(c *gin.Context)
 }
 
 type SensorHandler struct {
-	s     services.SensorServiceInterface
-	redis *redis.Client
-	db    *gorm.DB
+	s             services.SensorServiceInterface
+	deviceService services.DeviceServiceInterface
+	redis         *redis.Client
+	db            *gorm.DB
 }
 
-func NewSensorHandler(s services.SensorServiceInterface, redis *redis.Client, db *gorm.DB) SensorHandlerInterface {
+func NewSensorHandler(s services.SensorServiceInterface, ds services.DeviceServiceInterface, redis *redis.Client, db *gorm.DB) SensorHandlerInterface {
 	return &SensorHandler{
-		s:     s,
-		redis: redis,
-		db:    db,
+		s:             s,
+		deviceService: ds,
+		redis:         redis,
+		db:            db,
 	}
 }
 
+func (h *SensorHandler) authorizeTelemetry(c *gin.Context) bool {
+	return authorizeTelemetry(c, h.deviceService, h.db)
+}
+
+// authorizeTelemetry - user chỉ xem sensor data khi sở hữu hoặc được chia sẻ ít nhất một device
+func authorizeTelemetry(c *gin.Context, ds services.DeviceServiceInterface, db *gorm.DB) bool {
+	ok, err := ds.CanReadTelemetry(middlewares.TenantDB(c, db), middlewares.CurrentActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrTelemetryForbidden.Error()})
+		return false
+	}
+	return true
+}
+
 func (h *SensorHandler) GetAllSensorData(c *gin.Context) {
-	limit := c.DefaultQuery("limit", "10")
-	offset := c.DefaultQuery("offset", "0")
-	order := c.DefaultQuery("order", "desc")
-	sort_by := c.DefaultQuery("sort_by", "created_at")
-	limitInt, err := strconv.Atoi(limit)
-	if err != nil || limitInt <= 0 || limitInt > 100 {
-		limitInt = 10
-	}
-	offsetInt, err := strconv.Atoi(offset)
-	if err != nil || offsetInt < 0 {
-		offsetInt = 0
+	if !h.authorizeTelemetry(c) {
+		return
+	}
+	page, ok := pageRequest(c)
+	if !ok {
+		return
 	}
 	startDate := c.DefaultQuery("start_date", "")
 	endDate := c.DefaultQuery("end_date", "")
 	search := c.DefaultQuery("search", "")
 
 
-	data, total, err := h.s.GetAllSensorData(h.db, limitInt, offsetInt, sort_by, order, startDate, endDate, search)
+	data, pageInfo, err := h.s.GetAllSensorData(middlewares.TenantDB(c, h.db), page, startDate, endDate, search)
+	if respondListError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to retrieve sensor data"})
 		return
 	}
-	c.JSON(200, gin.H{"data": data, "total": total})
+	c.JSON(200, gin.H{"data": data, "pagination": pageInfo})
 
 }
 
 func (h *SensorHandler) GetSensorDataByID(c *gin.Context) {
+	if !h.authorizeTelemetry(c) {
+		return
+	}
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil || id <= 0 {
 		c.JSON(400, gin.H{"error": "Invalid ID parameter"})
 		return
 	}
-	data, err := h.s.GetSensorDataByID(h.db, uint(id))
+	data, err := h.s.GetSensorDataByID(middlewares.TenantDB(c, h.db), uint(id))
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to retrieve sensor data"})
 		return
@@ -76,10 +103,62 @@ func (h *SensorHandler) GetSensorDataByID(c *gin.Context) {
 }
 
 func (h *SensorHandler) GetLastSensorData(c *gin.Context) {
-	data, err := h.s.GetLastSensorData(h.db, h.redis, c)
+	if !h.authorizeTelemetry(c) {
+		return
+	}
+	data, err := h.s.GetLastSensorData(middlewares.TenantDB(c, h.db), h.redis, c)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to retrieve last sensor data"})
 		return
 	}
 	c.JSON(200, gin.H{"data": data})
 }
+
+// AggregateSensorData - trung bình/min/max theo khoảng thời gian (bucket=1h mặc định), cùng bộ lọc với /all
+func (h *SensorHandler) AggregateSensorData(c *gin.Context) {
+	if !h.authorizeTelemetry(c) {
+		return
+	}
+	bucket := c.DefaultQuery("bucket", "1h")
+	startDate := c.DefaultQuery("start_date", "")
+	endDate := c.DefaultQuery("end_date", "")
+	search := c.DefaultQuery("search", "")
+
+	data, err := h.s.AggregateSensorData(middlewares.TenantDB(c, h.db), bucket, startDate, endDate, search)
+	if errors.Is(err, services.ErrInvalidBucket) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if respondListError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate sensor data"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data, "bucket": bucket, "truncated": len(data) == services.MaxSensorBuckets})
+}
+
+// pageRequest - limit/offset/sort/cursor của các API list, sai thì trả 400
+func pageRequest(c *gin.Context) (*pagination.Request, bool) {
+	page, err := pagination.Parse(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return page, true
+}
+
+// respondListError - search sai cú pháp (kèm vị trí lỗi), sort ngoài whitelist hoặc cursor hỏng thì trả 400
+func respondListError(c *gin.Context, err error) bool {
+	var filterErr *filter.Error
+	switch {
+	case errors.As(err, &filterErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": filterErr.Error(), "position": filterErr.Pos, "token": filterErr.Token})
+	case errors.Is(err, pagination.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/middlewares"
+	"iot/internal/model"
+	"iot/internal/services"
+	"iot/internal/tenant"
+	"iot/pkg/logger"
+	"iot/pkg/socket"
+	"iot/pkg/stream"
+	"iot/pkg/tracing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gin-gonic/gin/binding"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SocketCommandHandler - điều khiển device qua WebSocket, dùng chung DeviceService với POST /device/control
+type SocketCommandHandler struct {
+	db            *gorm.DB
+	deviceService services.DeviceServiceInterface
+	mqtt          mqtt.Client
+	events        *stream.Broker
+	audit         services.AuditServiceInterface
+}
+
+func NewSocketCommandHandler(db *gorm.DB, ds services.DeviceServiceInterface, mqtt mqtt.Client, events *stream.Broker, audit services.AuditServiceInterface) socket.CommandHandler {
+	return &SocketCommandHandler{
+		db:            db,
+		deviceService: ds,
+		mqtt:          mqtt,
+		events:        events,
+		audit:         audit,
+	}
+}
+
+func (h *SocketCommandHandler) HandleCommand(ctx context.Context, identity *socket.Identity, payload json.RawMessage) (*socket.CommandOutcome, error) {
+	if !middlewares.HasPermission(identity.Role, middlewares.PermDeviceControl) {
+		return nil, errors.New("forbidden: missing permission " + string(middlewares.PermDeviceControl))
+	}
+	if len(payload) == 0 {
+		return nil, errors.New("missing command payload")
+	}
+	req := &dto.DevicesControlRequest{}
+	if err := json.Unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.Start(ctx, "ws device_control", trace.SpanKindServer,
+		attribute.Int64("enduser.id", int64(identity.UserID)))
+	defer span.End()
+	actor := dto.Actor{UserID: identity.UserID, TenantID: identity.TenantID, Role: identity.Role, Name: dto.ActorName(identity.Username, identity.Email)}
+	db := tenant.WithTenant(h.db.WithContext(ctx), identity.TenantID)
+	err := h.deviceService.DeviceController(ctx, db, req, actor, h.mqtt)
+	tracing.RecordError(span, err)
+	h.recordControl(ctx, identity, req, err)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]dto.DeviceStateDTO, 0, 3)
+	for _, d := range []dto.DeviceControlRequest{req.Device1, req.Device2, req.Device3} {
+		device, err := h.deviceService.GetByID(db, d.DeviceID)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, dto.DeviceStateDTO{ID: device.ID, Name: device.Name, Status: device.Status})
+	}
+
+	// Mỗi device một event, chỉ gửi tới group của device đó để user không thấy device không được chia sẻ
+	events := make([]socket.GroupEvent, 0, len(states))
+	for _, state := range states {
+		event := &dto.DeviceStateEvent{
+			Type:      stream.TopicDeviceState,
+			Devices:   []dto.DeviceStateDTO{state},
+			ChangedBy: identity.UserID,
+		}
+		if data, err := json.Marshal(event); err == nil {
+			h.events.Publish(stream.Event{Topic: stream.TopicDeviceState, TenantID: identity.TenantID, DeviceID: state.ID, Data: data})
+		}
+		events = append(events, socket.GroupEvent{GroupID: socket.DeviceGroup(state.ID), Payload: event})
+	}
+
+	return &socket.CommandOutcome{
+		Result: states,
+		Events: events,
+	}, nil
+}
+
+// recordControl - kết nối WebSocket không có gin.Context nên dựng audit entry từ identity của kết nối
+func (h *SocketCommandHandler) recordControl(ctx context.Context, identity *socket.Identity, req *dto.DevicesControlRequest, err error) {
+	entry := &dto.AuditEntry{
+		TenantID:   identity.TenantID,
+		ActorID:    identity.UserID,
+		ActorEmail: identity.Email,
+		Action:     model.AuditDeviceControl,
+		Outcome:    model.AuditSuccess,
+		IP:         identity.IP,
+		UserAgent:  identity.UserAgent,
+		Details:    controlAuditDetails(req, "websocket", err),
+	}
+	if err != nil {
+		entry.Outcome = model.AuditFailure
+	}
+	if auditErr := h.audit.Record(ctx, h.db, entry); auditErr != nil {
+		logger.Log.Error("Audit log write failed", zap.String("action", entry.Action), zap.Uint("actor_id", entry.ActorID), zap.Error(auditErr))
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"iot/internal/dto"
+	"iot/internal/middlewares"
+	"iot/internal/model"
+	"iot/internal/services"
+	"iot/pkg/pagination"
+	"iot/pkg/socket"
+	"iot/pkg/stream"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StreamHandlerInterface interface {
+	Stream(c *gin.Context)
+}
+
+type StreamHandler struct {
+	db            *gorm.DB
+	events        *stream.Broker
+	realtime      services.RealtimeServiceInterface
+	deviceService services.DeviceServiceInterface
+}
+
+func NewStreamHandler(db *gorm.DB, events *stream.Broker, realtime services.RealtimeServiceInterface, ds services.DeviceServiceInterface) StreamHandlerInterface {
+	return &StreamHandler{
+		db:            db,
+		events:        events,
+		realtime:      realtime,
+		deviceService: ds,
+	}
+}
+
+// Stream - Server-Sent Events cho client không dùng được WebSocket.
+// Query: topics=telemetry,device_state,alert (mặc định tất cả).
+// Header Last-Event-ID (hoặc query last_event_id) để resume telemetry bị lỡ.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	topics, err := stream.ParseTopics(c.Query("topics"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	if lastID != "" {
+		if _, _, err := stream.ParseID(lastID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	actor := middlewares.CurrentActor(c)
+	topics, visible, err := h.scopeTopics(middlewares.TenantDB(c, h.db), actor, topics)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(topics) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No accessible topics"})
+		return
+	}
+
+	// Subscribe trước khi catch-up để không lọt event giữa hai bước
+	sub := h.events.Subscribe(topics)
+	defer h.events.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if lastID != "" && sub.Wants(stream.TopicTelemetry) {
+		identity := &socket.Identity{UserID: actor.UserID, TenantID: actor.TenantID, Role: actor.Role}
+		if result, err := h.realtime.Since(c.Request.Context(), identity, lastID); err == nil {
+			if catchUp, ok := result.(*dto.LiveCatchUp); ok {
+				for _, r := range catchUp.Readings {
+					c.Render(-1, sse.Event{Id: r.Cursor, Event: stream.TopicTelemetry, Data: string(r.Data)})
+				}
+				if catchUp.Truncated {
+					c.Render(-1, sse.Event{Event: "truncated", Data: catchUp.Cursor})
+				}
+				lastID = catchUp.Cursor
+			}
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if e.TenantID != actor.TenantID {
+				continue
+			}
+			// Bỏ qua telemetry đã gửi trong phần catch-up
+			if e.ID != "" && lastID != "" && stream.CompareIDs(e.ID, lastID) <= 0 {
+				continue
+			}
+			if e.DeviceID != 0 && visible != nil && !visible[e.DeviceID] {
+				continue
+			}
+			c.Render(-1, sse.Event{Id: e.ID, Event: e.Topic, Data: string(e.Data)})
+			c.Writer.Flush()
+		}
+	}
+}
+
+// scopeTopics - bỏ các topic user không có quyền; visible là tập device được xem (nil = tất cả)
+func (h *StreamHandler) scopeTopics(db *gorm.DB, actor dto.Actor, topics []string) ([]string, map[uint]bool, error) {
+	var visible map[uint]bool
+	if actor.Role != model.RoleAdmin {
+		devices, _, err := h.deviceService.GetAllDevices(db, actor, &pagination.Request{Limit: pagination.MaxLimit}, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		visible = make(map[uint]bool, len(devices))
+		for _, d := range devices {
+			visible[d.ID] = true
+		}
+	}
+
+	telemetryAllowed, err := h.deviceService.CanReadTelemetry(db, actor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scoped := make([]string, 0, len(topics))
+	for _, t := range topics {
+		if t == stream.TopicTelemetry && !telemetryAllowed {
+			continue
+		}
+		scoped = append(scoped, t)
+	}
+	return scoped, visible, nil
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/middlewares"
+	"iot/internal/model"
+	"iot/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TenantHandlerInterface interface {
+	ListTenants(c *gin.Context)
+	CreateTenant(c *gin.Context)
+	SwitchTenant(c *gin.Context)
+	ListMembers(c *gin.Context)
+	InviteMember(c *gin.Context)
+	ListInvitations(c *gin.Context)
+	AcceptInvitation(c *gin.Context)
+	DeclineInvitation(c *gin.Context)
+	UpdateMember(c *gin.Context)
+	RemoveMember(c *gin.Context)
+}
+
+// TenantHandler - bảng tenant/membership không theo tenant nên dùng h.db trực tiếp, tenant lấy từ token
+type TenantHandler struct {
+	db    *gorm.DB
+	ts    services.TenantServiceInterface
+	audit services.AuditServiceInterface
+}
+
+func NewTenantHandler(db *gorm.DB, ts services.TenantServiceInterface, audit services.AuditServiceInterface) TenantHandlerInterface {
+	return &TenantHandler{
+		db:    db,
+		ts:    ts,
+		audit: audit,
+	}
+}
+
+// ListTenants - các tenant user tham gia, đánh dấu tenant đang chọn
+func (h *TenantHandler) ListTenants(c *gin.Context) {
+	actor := middlewares.CurrentActor(c)
+	tenants, err := h.ts.ListMine(h.db.WithContext(c.Request.Context()), actor.UserID, actor.TenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tenants})
+}
+
+func (h *TenantHandler) CreateTenant(c *gin.Context) {
+	var req = dto.CreateTenantRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	created, err := h.ts.Create(h.db.WithContext(c.Request.Context()), middlewares.CurrentActor(c).UserID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Tenant created successfully", "data": created})
+}
+
+// SwitchTenant - cấp cặp token mới mang tenant được chọn, session cũ bị thu hồi
+func (h *TenantHandler) SwitchTenant(c *gin.Context) {
+	var req = dto.SwitchTenantRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	claims, ok := middlewares.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthenticated"})
+		return
+	}
+	pair, err := h.ts.Switch(c.Request.Context(), h.db.WithContext(c.Request.Context()), claims, req.TenantID, sessionMeta(c))
+	entry := dto.AuditEntry{
+		Action:     model.AuditTenantSwitch,
+		Outcome:    model.AuditSuccess,
+		TenantID:   req.TenantID,
+		TargetType: "tenant",
+		TargetID:   strconv.FormatUint(uint64(req.TenantID), 10),
+		Details:    map[string]interface{}{"from_tenant_id": claims.TenantID},
+	}
+	if err != nil {
+		// Không vào được tenant đích thì ghi vào tenant đang đứng
+		entry.TenantID = claims.TenantID
+		entry.Outcome = model.AuditFailure
+		entry.Details["reason"] = err.Error()
+	}
+	recordAudit(c, h.audit, h.db, entry)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+	setAuthCookies(c, pair)
+	c.JSON(http.StatusOK, gin.H{"message": "Tenant switched successfully", "data": pair})
+}
+
+func (h *TenantHandler) ListMembers(c *gin.Context) {
+	members, err := h.ts.ListMembers(h.db.WithContext(c.Request.Context()), middlewares.CurrentTenant(c))
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+// InviteMember - mời user đã có tài khoản vào tenant hiện tại, user phải tự chấp nhận
+func (h *TenantHandler) InviteMember(c *gin.Context) {
+	var req = dto.InviteTenantMemberRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor := middlewares.CurrentActor(c)
+	invitation, err := h.ts.InviteMember(h.db.WithContext(c.Request.Context()), actor.TenantID, actor.UserID, &req)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+	h.recordMember(c, model.AuditMemberInvite, invitation.UserID, map[string]interface{}{"role": invitation.Role})
+	c.JSON(http.StatusCreated, gin.H{"message": "Invitation sent successfully", "data": invitation})
+}
+
+// ListInvitations - lời mời đang chờ của chính user
+func (h *TenantHandler) ListInvitations(c *gin.Context) {
+	invitations, err := h.ts.ListInvitations(h.db.WithContext(c.Request.Context()), middlewares.CurrentActor(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": invitations})
+}
+
+// AcceptInvitation - vào tenant theo lời mời, audit ghi vào tenant vừa tham gia
+func (h *TenantHandler) AcceptInvitation(c *gin.Context) {
+	tenantID, ok := uintParam(c, "tenantId", "Invalid Tenant ID")
+	if !ok {
+		return
+	}
+	actor := middlewares.CurrentActor(c)
+	joined, err := h.ts.AcceptInvitation(h.db.WithContext(c.Request.Context()), actor.UserID, tenantID)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     model.AuditMemberAdd,
+		Outcome:    model.AuditSuccess,
+		TenantID:   tenantID,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(actor.UserID), 10),
+		Details:    map[string]interface{}{"role": joined.Role},
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted successfully", "data": joined})
+}
+
+func (h *TenantHandler) DeclineInvitation(c *gin.Context) {
+	tenantID, ok := uintParam(c, "tenantId", "Invalid Tenant ID")
+	if !ok {
+		return
+	}
+	if err := h.ts.DeclineInvitation(h.db.WithContext(c.Request.Context()), middlewares.CurrentActor(c).UserID, tenantID); err != nil {
+		respondTenantError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined successfully"})
+}
+
+func (h *TenantHandler) UpdateMember(c *gin.Context) {
+	var req = dto.UpdateTenantMemberRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := uintParam(c, "userId", "Invalid User ID")
+	if !ok {
+		return
+	}
+	actor := middlewares.CurrentActor(c)
+	if err := h.ts.UpdateMemberRole(h.db.WithContext(c.Request.Context()), actor.TenantID, actor.UserID, userID, req.Role); err != nil {
+		respondTenantError(c, err)
+		return
+	}
+	h.recordMember(c, model.AuditRoleAssign, userID, map[string]interface{}{"role": req.Role})
+	c.JSON(http.StatusOK, gin.H{"message": "Member role updated successfully", "data": gin.H{"user_id": userID, "role": req.Role}})
+}
+
+func (h *TenantHandler) RemoveMember(c *gin.Context) {
+	userID, ok := uintParam(c, "userId", "Invalid User ID")
+	if !ok {
+		return
+	}
+	if err := h.ts.RemoveMember(c.Request.Context(), h.db.WithContext(c.Request.Context()), middlewares.CurrentTenant(c), userID); err != nil {
+		respondTenantError(c, err)
+		return
+	}
+	h.recordMember(c, model.AuditMemberRemove, userID, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func (h *TenantHandler) recordMember(c *gin.Context, action string, userID uint, details map[string]interface{}) {
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     action,
+		Outcome:    model.AuditSuccess,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+		Details:    details,
+	})
+}
+
+func respondTenantError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrNotTenantMember):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvitationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTenantMemberExists), errors.Is(err, services.ErrInvitationExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSelfDemotion), errors.Is(err, services.ErrLastTenantAdmin), errors.Is(err, gorm.ErrInvalidData):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
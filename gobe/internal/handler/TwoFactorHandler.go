@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/middlewares"
+	"iot/internal/model"
+	"iot/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TwoFactorHandlerInterface interface {
+	VerifyLogin(c *gin.Context)
+	SetupTOTP(c *gin.Context)
+	EnableTOTP(c *gin.Context)
+	EnableEmail(c *gin.Context)
+	Disable(c *gin.Context)
+	RegenerateRecoveryCodes(c *gin.Context)
+}
+
+type TwoFactorHandler struct {
+	ts    services.TwoFactorServiceInterface
+	db    *gorm.DB
+	audit services.AuditServiceInterface
+}
+
+func NewTwoFactorHandler(db *gorm.DB, ts services.TwoFactorServiceInterface, audit services.AuditServiceInterface) TwoFactorHandlerInterface {
+	return &TwoFactorHandler{
+		ts:    ts,
+		db:    db,
+		audit: audit,
+	}
+}
+
+// VerifyLogin - bước 2 của đăng nhập: challenge token + mã 2FA (hoặc recovery code) -> token pair
+func (h *TwoFactorHandler) VerifyLogin(c *gin.Context) {
+	var req = dto.TwoFactorVerifyRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	response, err := h.ts.VerifyChallenge(c.Request.Context(), h.db, &req, sessionMeta(c))
+	entry := dto.AuditEntry{Action: model.AuditLoginTwoFactor, Outcome: model.AuditSuccess}
+	if err != nil {
+		entry.Outcome = model.AuditFailure
+		entry.Details = map[string]interface{}{"reason": err.Error()}
+	} else {
+		entry.ActorID, entry.ActorEmail, entry.TenantID = response.User.ID, response.User.Email, response.User.TenantID
+	}
+	recordAudit(c, h.audit, h.db, entry)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	setAuthCookies(c, response.TokenPair)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User logged in successfully",
+		"user":    response.User,
+	})
+}
+
+func (h *TwoFactorHandler) SetupTOTP(c *gin.Context) {
+	var req = dto.TwoFactorPasswordRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor := middlewares.CurrentActor(c)
+	setup, err := h.ts.SetupTOTP(c.Request.Context(), h.db, actor.UserID, req.Password)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": setup})
+}
+
+func (h *TwoFactorHandler) EnableTOTP(c *gin.Context) {
+	var req = dto.TOTPEnableRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor := middlewares.CurrentActor(c)
+	codes, err := h.ts.EnableTOTP(c.Request.Context(), h.db, actor.UserID, req.Password, req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication enabled",
+		"data":    dto.RecoveryCodesResponse{Method: "totp", RecoveryCodes: codes},
+	})
+}
+
+func (h *TwoFactorHandler) EnableEmail(c *gin.Context) {
+	var req = dto.TwoFactorPasswordRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor := middlewares.CurrentActor(c)
+	codes, err := h.ts.EnableEmail(c.Request.Context(), h.db, actor.UserID, req.Password)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication enabled",
+		"data":    dto.RecoveryCodesResponse{Method: "email", RecoveryCodes: codes},
+	})
+}
+
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req = dto.TwoFactorPasswordRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor := middlewares.CurrentActor(c)
+	if err := h.ts.Disable(c.Request.Context(), h.db, actor.UserID, req.Password); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req = dto.TwoFactorPasswordRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor := middlewares.CurrentActor(c)
+	codes, err := h.ts.RegenerateRecoveryCodes(c.Request.Context(), h.db, actor.UserID, req.Password)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"recovery_codes": codes}})
+}
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidChallenge), errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled), errors.Is(err, services.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTOTPSetupExpired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -2,11 +2,16 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"iot/internal/dto"
 	"iot/internal/helper/mailer"
+	"iot/internal/jwt_utils"
+	"iot/internal/middlewares"
+	"iot/internal/model"
 	"iot/internal/services"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -26,6 +31,12 @@ type UserHandlerInterface interface {
 	UpdateUser(c *gin.Context)
 	DeleteUser(c *gin.Context)
 	RefreshToken(c *gin.Context)
+	AssignRole(c *gin.Context)
+	ListSessions(c *gin.Context)
+	RevokeSession(c *gin.Context)
+	LogoutAll(c *gin.Context)
+	ForgotPassword(c *gin.Context)
+	ResetPassword(c *gin.Context)
 }
 
 type UserHandler struct {
@@ -34,15 +45,17 @@ type UserHandler struct {
 	mailer *mailer.MailService
 	redis  *redis.Client
 	ctx    context.Context
+	audit  services.AuditServiceInterface
 }
 
-func NewUserHandler(db *gorm.DB, us services.UserServiceInterface, mailer *mailer.MailService, redis *redis.Client, ctx context.Context) UserHandlerInterface {
+func NewUserHandler(db *gorm.DB, us services.UserServiceInterface, mailer *mailer.MailService, redis *redis.Client, ctx context.Context, audit services.AuditServiceInterface) UserHandlerInterface {
 	return &UserHandler{
 		us:     us,
 		db:     db,
 		mailer: mailer,
 		redis:  redis,
 		ctx:    ctx,
+		audit:  audit,
 	}
 }
 
@@ -67,8 +80,11 @@ func (h *UserHandler) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	userData, err := h.us.Register(h.db, &req, h.mailer, h.redis)
-
+	userData, err := h.us.Register(h.db, &req, h.mailer, h.redis, sessionMeta(c))
+	if errors.Is(err, gorm.ErrInvalidData) || errors.Is(err, services.ErrOTPExhausted) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -78,13 +94,15 @@ func (h *UserHandler) Register(c *gin.Context) {
 
 func (h *UserHandler) Logout(c *gin.Context) {
 	rfToken, err := c.Cookie("refresh_token")
-	fmt.Println("Refresh token:", rfToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token not found"})
 		return
 	}
-	c.SetCookie("refresh_token", "", -1, "/", "localhost", false, true)
-	c.SetCookie("access_token", "", -1, "/", "localhost", false, true)
+	if err := h.us.Logout(c.Request.Context(), rfToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	clearAuthCookies(c)
 
 	c.JSON(200, gin.H{
 		"message": "User logged out successfully",
@@ -97,14 +115,28 @@ func (h *UserHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	reponse, err := h.us.Login(h.db, req.Email, req.Password)
-
-	c.SetCookie("refresh_token", reponse.TokenPair.RefreshToken, 3600*24*7, "/", "localhost", false, true)
-	if err != nil {
+	reponse, err := h.us.Login(c.Request.Context(), h.db, req.Email, req.Password, sessionMeta(c), h.mailer)
+	h.recordLogin(c, req.Email, reponse, err)
+	var locked *services.LockedError
+	switch {
+	case errors.As(err, &locked):
+		middlewares.AbortTooManyRequests(c, locked.RetryAfter, locked.Error())
+		return
+	case errors.Is(err, services.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.SetCookie("access_token", reponse.TokenPair.AccessToken, 3600, "/", "localhost", false, true)
+	if reponse.Challenge != nil {
+		c.JSON(200, gin.H{
+			"message":   "Two-factor authentication required",
+			"challenge": reponse.Challenge,
+		})
+		return
+	}
+	setAuthCookies(c, reponse.TokenPair)
 	c.JSON(200, gin.H{
 		"message": "User logged in successfully",
 		"user":    reponse.User,
@@ -117,13 +149,24 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token not found"})
 		return
 	}
-	response, err := h.us.RefreshToken(h.db, refreshToken)
+	response, err := h.us.RefreshToken(c.Request.Context(), h.db, refreshToken, sessionMeta(c))
+	entry := dto.AuditEntry{Action: model.AuditTokenRefresh, Outcome: model.AuditSuccess}
+	if err != nil {
+		entry.Outcome = model.AuditFailure
+		entry.Details = map[string]interface{}{"error": err.Error()}
+	} else {
+		entry.ActorID, entry.ActorEmail, entry.TenantID = response.User.ID, response.User.Email, response.User.TenantID
+	}
+	recordAudit(c, h.audit, h.db, entry)
 	if err != nil {
+		// Session đã bị thu hồi (hoặc token bị dùng lại) thì xóa cookie để client đăng nhập lại
+		if errors.Is(err, services.ErrRefreshTokenReused) || errors.Is(err, services.ErrSessionRevoked) {
+			clearAuthCookies(c)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
-	c.SetCookie("refresh_token", response.TokenPair.RefreshToken, 3600*24*7, "/", "localhost", false, true)
-	c.SetCookie("access_token", response.TokenPair.AccessToken, 3600, "/", "localhost", false, true)
+	setAuthCookies(c, response.TokenPair)
 	c.JSON(200, gin.H{
 		"message": "Token refreshed successfully",
 	})
@@ -140,7 +183,11 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID"})
 		return
 	}
-	user, err := h.us.GetUserByID(h.db, id)
+	user, err := h.us.GetUserByID(middlewares.TenantDB(c, h.db), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -150,23 +197,19 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 }
 
 func (h *UserHandler) GetAllUsers(c *gin.Context) {
-	limit := c.Query("limit")
-	offset := c.Query("offset")
-
-	limitInt, err := strconv.Atoi(limit)
-	if err != nil {
-		limitInt = 10
+	page, ok := pageRequest(c)
+	if !ok {
+		return
 	}
-	offsetInt, err := strconv.Atoi(offset)
-	if err != nil {
-		offsetInt = 0
+	users, pageInfo, err := h.us.GetAllUsers(middlewares.TenantDB(c, h.db), page, h.redis)
+	if respondListError(c, err) {
+		return
 	}
-	users, err := h.us.GetAllUsers(h.db, limitInt, offsetInt, h.redis)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"message": "Users fetched successfully", "data": users})
+	c.JSON(200, gin.H{"message": "Users fetched successfully", "data": users, "pagination": pageInfo})
 }
 
 func (h *UserHandler) UpdateUser(c *gin.Context) {
@@ -185,11 +228,30 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID"})
 		return
 	}
-	err := h.us.UpdateUser(h.db, id, &req, h.redis)
+	err := h.us.UpdateUser(middlewares.TenantDB(c, h.db), middlewares.CurrentActor(c).UserID, id, &req, h.redis)
+	if errors.Is(err, services.ErrAccountForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	// Chỉ ghi tên field đã đổi, không ghi giá trị
+	fields := []string{}
+	for name, value := range map[string]string{"name": req.Name, "email": req.Email} {
+		if value != "" {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     model.AuditUserUpdate,
+		Outcome:    model.AuditSuccess,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(id), 10),
+		Details:    map[string]interface{}{"fields": fields},
+	})
 	c.JSON(200, gin.H{"message": "User updated successfully"})
 }
 
@@ -204,10 +266,181 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID"})
 		return
 	}
-	err := h.us.DeleteUser(h.db, id, h.redis)
+	err := h.us.DeleteUser(h.db.WithContext(c.Request.Context()), middlewares.CurrentActor(c).UserID, id, h.redis)
+	if errors.Is(err, services.ErrAdminOnly) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     model.AuditUserDelete,
+		Outcome:    model.AuditSuccess,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(id), 10),
+	})
 	c.JSON(200, gin.H{"message": "User deleted successfully"})
 }
+
+func (h *UserHandler) AssignRole(c *gin.Context) {
+	var req = dto.AssignRoleRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var id uint
+	if _, err := fmt.Sscan(c.Param("id"), &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID"})
+		return
+	}
+	actor, _ := middlewares.CurrentUser(c)
+	err := h.us.AssignRole(middlewares.TenantDB(c, h.db), actor.Id, id, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, services.ErrSelfDemotion), errors.Is(err, services.ErrLastTenantAdmin):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	recordAudit(c, h.audit, h.db, dto.AuditEntry{
+		Action:     model.AuditRoleAssign,
+		Outcome:    model.AuditSuccess,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(id), 10),
+		Details:    map[string]interface{}{"role": req.Role},
+	})
+	c.JSON(200, gin.H{"message": "Role assigned successfully", "data": gin.H{"id": id, "role": req.Role}})
+}
+
+// ListSessions - các phiên đăng nhập đang hoạt động của user hiện tại
+func (h *UserHandler) ListSessions(c *gin.Context) {
+	claims, ok := middlewares.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthenticated"})
+		return
+	}
+	sessions, err := h.us.ListSessions(c.Request.Context(), claims.Id, claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	claims, ok := middlewares.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthenticated"})
+		return
+	}
+	sid := c.Param("sid")
+	err := h.us.RevokeSession(c.Request.Context(), claims.Id, sid)
+	if errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if sid == claims.SessionID {
+		clearAuthCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// LogoutAll - thu hồi mọi session của user, kể cả session hiện tại
+func (h *UserHandler) LogoutAll(c *gin.Context) {
+	claims, ok := middlewares.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthenticated"})
+		return
+	}
+	if err := h.us.LogoutAll(c.Request.Context(), claims.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all sessions"})
+}
+
+// recordLogin - đăng nhập sai chưa có actor nên chỉ ghi email được nhập
+func (h *UserHandler) recordLogin(c *gin.Context, email string, response *dto.LoginResponse, err error) {
+	entry := dto.AuditEntry{Action: model.AuditLogin, Outcome: model.AuditSuccess, ActorEmail: email}
+	var locked *services.LockedError
+	switch {
+	case errors.As(err, &locked):
+		entry.Outcome = model.AuditFailure
+		entry.Details = map[string]interface{}{"reason": "locked"}
+	case err != nil:
+		entry.Outcome = model.AuditFailure
+		entry.Details = map[string]interface{}{"reason": err.Error()}
+	default:
+		entry.ActorID, entry.TenantID = response.User.ID, response.User.TenantID
+		if response.Challenge != nil {
+			entry.Outcome = model.AuditChallenge
+		}
+	}
+	recordAudit(c, h.audit, h.db, entry)
+}
+
+func sessionMeta(c *gin.Context) *dto.SessionMeta {
+	return &dto.SessionMeta{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+		Device:    c.GetHeader("X-Device-Name"),
+	}
+}
+
+func setAuthCookies(c *gin.Context, pair *jwt_utils.TokenPair) {
+	c.SetCookie("refresh_token", pair.RefreshToken, 3600*24*7, "/", "localhost", false, true)
+	c.SetCookie("access_token", pair.AccessToken, 3600, "/", "localhost", false, true)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie("refresh_token", "", -1, "/", "localhost", false, true)
+	c.SetCookie("access_token", "", -1, "/", "localhost", false, true)
+}
+
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req = dto.ForgotPasswordRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.us.ForgotPassword(c.Request.Context(), h.db, req.Email, h.mailer, h.redis); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Luôn trả về cùng một message dù email có tồn tại hay không
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a reset code has been sent"})
+}
+
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req = dto.ResetPasswordRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := h.us.ResetPassword(c.Request.Context(), h.db, &req, h.redis)
+	if errors.Is(err, services.ErrInvalidResetCode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
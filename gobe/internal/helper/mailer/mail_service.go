@@ -4,6 +4,7 @@ import (
 	"context"
 	"iot/internal/helper"
 	"iot/pkg/config"
+	"iot/pkg/metrics"
 
 	"go.uber.org/zap"
 )
@@ -27,12 +28,14 @@ func (s *MailService) Send(ctx context.Context, sender EmailSender, to string, d
 	err := helper.SendMail(ctx, s.cfg, msg, s.logger)
 
 	if err != nil {
+		metrics.MailSent.WithLabelValues("failure").Inc()
 		s.logger.Error("Failed to send email",
 			zap.Error(err),
 			zap.String("to", to))
 		return err
 	}
 
+	metrics.MailSent.WithLabelValues("success").Inc()
 	s.logger.Info("Email sent successfully", zap.String("to", to))
 	return nil
 }
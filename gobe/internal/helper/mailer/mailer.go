@@ -49,3 +49,17 @@ func (w *WelcomeEmail) BuildMessage(to string, data map[string]string) *helper.M
 		IsHTML:  true,
 	}
 }
+
+type PasswordResetEmail struct {
+	From string
+}
+
+func (p *PasswordResetEmail) BuildMessage(to string, data map[string]string) *helper.MailMessage {
+	return &helper.MailMessage{
+		From:    p.From,
+		To:      []string{to},
+		Subject: "Đặt lại mật khẩu",
+		Body:    "<p>Xin chào " + data["name"] + ",</p><p>Mã đặt lại mật khẩu của bạn là: <b>" + data["otp"] + "</b></p><p>Mã có hiệu lực trong " + data["ttl"] + " phút. Nếu bạn không yêu cầu đặt lại mật khẩu, hãy bỏ qua email này.</p>",
+		IsHTML:  true,
+	}
+}
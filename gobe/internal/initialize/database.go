@@ -0,0 +1,238 @@
+package initialize
+
+import (
+	"context"
+	"fmt"
+	"iot/internal/migrations"
+	"iot/internal/model"
+	"iot/internal/storage"
+	"iot/pkg/config"
+	"iot/pkg/metrics"
+	"iot/pkg/tracing"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// InitDatabase - mở database theo storage.driver. MySQL/PostgreSQL thử kết nối theo STARTUP_RETRIES, vẫn không được
+// thì trả handle kết nối lười để API chạy ở chế độ degraded (route cần database báo lỗi), nền tiếp tục thử và
+// kiểm tra schema khi database lên. SQLite là file cục bộ nên lỗi mở là lỗi thật.
+// Trả lỗi khi không tạo nổi handle (DSN sai) hoặc database chưa migrate tới version binary cần.
+func InitDatabase(ctx context.Context) (*gorm.DB, error) {
+	cfg := config.GetConfig()
+	name := driverName(cfg.Storage.Driver)
+
+	attempts := cfg.StartupRetries
+	if cfg.Storage.Driver == config.DriverSQLite {
+		attempts = 1
+	}
+	var db *gorm.DB
+	err := retry(ctx, name, attempts, func() error {
+		dialector, err := openDialector(cfg, false)
+		if err != nil {
+			return err
+		}
+		db, err = gorm.Open(dialector, gormConfig())
+		return err
+	})
+	if err != nil && cfg.Storage.Driver == config.DriverSQLite {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("%s unavailable, starting in degraded mode: %v", name, err)
+		lazyConfig := gormConfig()
+		lazyConfig.DisableAutomaticPing = true
+		dialector, err := openDialector(cfg, true)
+		if err != nil {
+			return nil, err
+		}
+		db, err = gorm.Open(dialector, lazyConfig)
+		if err != nil {
+			return nil, err
+		}
+		prepare(db)
+		go func() {
+			sqlDB, _ := db.DB()
+			if err := retry(ctx, name, 0, func() error { return sqlDB.PingContext(ctx) }); err != nil {
+				return
+			}
+			// schema lệch thì đóng pool để không ghi sai dữ liệu, API vẫn chạy và /readyz báo lỗi tới khi deploy lại
+			if err := bootstrap(ctx, db, cfg.AdminEmail); err != nil {
+				log.Printf("%s is up but not usable: %v", name, err)
+				storage.SetSchemaError(err)
+				sqlDB.Close()
+			}
+		}()
+		return db, nil
+	}
+
+	prepare(db)
+	if err := bootstrap(ctx, db, cfg.AdminEmail); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// OpenDatabase - kết nối một lần, không retry hay chế độ degraded, cho lệnh migrate
+func OpenDatabase() (*gorm.DB, error) {
+	dialector, err := openDialector(config.GetConfig(), false)
+	if err != nil {
+		return nil, err
+	}
+	return gorm.Open(dialector, gormConfig())
+}
+
+// openDialector - lazy là khi server chưa trả lời: dialector MySQL không hỏi version mà giả định MySQL 8,
+// PostgreSQL vốn không hỏi gì lúc mở
+func openDialector(cfg *config.Config, lazy bool) (gorm.Dialector, error) {
+	switch cfg.Storage.Driver {
+	case config.DriverPostgres:
+		return postgres.Open(postgresDSN(cfg.Postgres)), nil
+	case config.DriverSQLite:
+		// driver không tự tạo thư mục chứa file
+		if dir := filepath.Dir(cfg.SQLite.Path); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, err
+			}
+		}
+		return sqlite.Open(sqliteDSN(cfg.SQLite)), nil
+	}
+	if lazy {
+		return mysql.New(mysql.Config{DSN: mysqlDSN(cfg.DbConfig), SkipInitializeWithVersion: true}), nil
+	}
+	return mysql.Open(mysqlDSN(cfg.DbConfig)), nil
+}
+
+func mysqlDSN(dbConf *config.MysqlConfig) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		dbConf.User,
+		dbConf.Pass,
+		dbConf.Host,
+		dbConf.Port,
+		dbConf.Dbname,
+	)
+}
+
+func postgresDSN(pgConf *config.PostgresConfig) string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(pgConf.User, pgConf.Pass),
+		Host:     net.JoinHostPort(pgConf.Host, pgConf.Port),
+		Path:     "/" + pgConf.Dbname,
+		RawQuery: url.Values{"sslmode": {pgConf.SSLMode}}.Encode(),
+	}
+	return dsn.String()
+}
+
+// sqliteDSN - WAL cho đọc song song với ghi, busy_timeout thay cho lỗi "database is locked" ngay lập tức,
+// txlock=immediate để transaction giữ quyền ghi từ đầu, tránh deadlock khi hai transaction cùng nâng quyền
+func sqliteDSN(sqliteConf *config.SQLiteConfig) string {
+	return "file:" + sqliteConf.Path + "?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+}
+
+func driverName(driver string) string {
+	switch driver {
+	case config.DriverPostgres:
+		return "PostgreSQL"
+	case config.DriverSQLite:
+		return "SQLite"
+	}
+	return "MySQL"
+}
+
+func gormConfig() *gorm.Config {
+	return &gorm.Config{
+		SkipDefaultTransaction: false,
+		TranslateError:         true,
+	}
+}
+
+// prepare - cấu hình không cần tới server
+func prepare(db *gorm.DB) {
+	setPool(db)
+	if err := metrics.RegisterGormCallbacks(db); err != nil {
+		log.Printf("Failed to register metrics callbacks: %v", err)
+	}
+	if err := tracing.RegisterGormCallbacks(db); err != nil {
+		log.Printf("Failed to register tracing callbacks: %v", err)
+	}
+}
+
+// bootstrap - kiểm tra schema và seed admin, chạy khi đã kết nối được. Server không tự migrate,
+// schema cũ hơn (hoặc mới hơn) binary thì từ chối chạy.
+func bootstrap(ctx context.Context, db *gorm.DB, adminEmail string) error {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	if err := migrator.Check(ctx); err != nil {
+		return err
+	}
+	switch db.Dialector.Name() {
+	case config.DriverMySQL:
+		protectAuditLogs(db)
+	case config.DriverPostgres:
+		detectTimescale(ctx, db)
+	}
+	if adminEmail != "" {
+		promoteAdmin(db, adminEmail)
+	}
+	return nil
+}
+
+func setPool(db *gorm.DB) {
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxIdleConns(10)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetMaxOpenConns(100)
+	sqlDB.SetConnMaxIdleTime(10 * time.Minute)
+}
+
+// detectTimescale - có extension timescaledb thì gộp theo thời gian dùng time_bucket
+func detectTimescale(ctx context.Context, db *gorm.DB) {
+	var enabled bool
+	err := db.WithContext(ctx).Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')").Scan(&enabled).Error
+	if err != nil {
+		log.Printf("Failed to detect TimescaleDB: %v", err)
+		return
+	}
+	storage.SetTimescale(enabled)
+}
+
+// protectAuditLogs - trigger chặn UPDATE/DELETE trên audit_logs ngay ở database (PostgreSQL, SQLite tạo trong migration).
+// Tạo trigger cần quyền TRIGGER (và SUPER khi bật binlog), thiếu quyền thì chỉ cảnh báo,
+// chuỗi hash vẫn phát hiện được bản ghi bị sửa/xóa.
+func protectAuditLogs(db *gorm.DB) {
+	for _, event := range []string{"UPDATE", "DELETE"} {
+		name := "audit_logs_no_" + strings.ToLower(event)
+		var count int64
+		err := db.Raw("SELECT COUNT(*) FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = DATABASE() AND TRIGGER_NAME = ?", name).Scan(&count).Error
+		if err == nil && count == 0 {
+			err = db.Exec("CREATE TRIGGER " + name + " BEFORE " + event + " ON audit_logs FOR EACH ROW " +
+				"SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only'").Error
+		}
+		if err != nil {
+			log.Printf("Failed to protect audit_logs against %s: %v", event, err)
+		}
+	}
+}
+
+// promoteAdmin - đảm bảo tài khoản ADMIN_EMAIL có role admin để bootstrap phân quyền
+func promoteAdmin(db *gorm.DB, email string) {
+	result := db.Model(&model.User{}).Where("email = ?", email).Update("role", model.RoleAdmin)
+	if result.Error != nil {
+		log.Printf("Failed to promote admin %s: %v", email, result.Error)
+	} else if result.RowsAffected == 0 {
+		log.Printf("Admin account %s not found or already admin", email)
+	}
+}
@@ -4,10 +4,16 @@ import (
 	"context"
 	"fmt"
 	"iot/pkg/config"
+	mymqtt "iot/pkg/mqtt"
+	"log"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// mqttConnectWait - thời gian chờ lần kết nối đầu trước khi để paho thử tiếp ở nền
+const mqttConnectWait = 10 * time.Second
+
 func InitMqtt(ctx context.Context) (mqtt.Client, error) {
 	MqttConfig := config.GetConfig().MQTTConfig
 	if MqttConfig == nil {
@@ -19,8 +25,21 @@ func InitMqtt(ctx context.Context) (mqtt.Client, error) {
 	opts.SetUsername(MqttConfig.Username)
 	opts.SetPassword(MqttConfig.Password)
 
+	// paho tự thử lại lần kết nối đầu và kết nối lại khi rớt, nên broker chưa lên không chặn khởi động
+	opts.SetAutoReconnect(true)
+	opts.SetConnectRetry(true)
+	opts.SetConnectRetryInterval(5 * time.Second)
+	opts.SetMaxReconnectInterval(time.Minute)
+	// clean session: broker quên subscription khi mất kết nối, các hook đăng ký lại sau mỗi lần connect
+	opts.SetOnConnectHandler(mymqtt.RunConnectHooks)
+
 	client := mqtt.NewClient(opts)
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
+	token := client.Connect()
+	if !token.WaitTimeout(mqttConnectWait) {
+		log.Printf("MQTT broker %s unavailable, retrying in background", MqttConfig.Broker)
+		return client, nil
+	}
+	if token.Error() != nil {
 		return nil, token.Error()
 	}
 
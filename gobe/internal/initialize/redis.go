@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"iot/internal/cache"
 	"iot/pkg/config"
+	"iot/pkg/tracing"
 	"log"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
 )
 
 func SetupKeyRedis(cache *cache.KeyCache) {
@@ -22,11 +25,21 @@ func InitRedis(ctx context.Context) (*redis.Client, error) {
 		Password: redis_config.Pass,
 		DB:       0,
 	})
+	// Chỉ ghi tên lệnh, tham số (token session, key...) không vào span
+	if err := redisotel.InstrumentTracing(rdb,
+		redisotel.WithTracerProvider(tracing.ChildOnly(otel.GetTracerProvider())),
+		redisotel.WithDBStatement(false),
+	); err != nil {
+		log.Printf("Failed to instrument Redis tracing: %v", err)
+	}
 
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Print(err)
-	} else {
-		log.Print(err)
+	// go-redis tự kết nối lại theo từng lệnh nên Redis chưa lên thì vẫn giữ client,
+	// các tính năng dùng Redis (session, cache, realtime) lỗi cho tới khi Redis trở lại
+	err := retry(ctx, "Redis", cfg.StartupRetries, func() error {
+		return rdb.Ping(ctx).Err()
+	})
+	if err != nil {
+		log.Printf("Redis unavailable, starting in degraded mode: %v", err)
 	}
 
 	redis_config.DB = rdb
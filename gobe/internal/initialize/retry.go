@@ -0,0 +1,37 @@
+package initialize
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	retryInitialBackoff = time.Second
+	retryMaxBackoff     = 30 * time.Second
+)
+
+// retry - gọi op tới khi thành công, hết attempts (<= 0 là thử mãi) hoặc ctx bị hủy,
+// giữa các lần đợi theo backoff lũy thừa 1s, 2s, 4s... tối đa 30s. Trả lỗi của lần thử cuối.
+func retry(ctx context.Context, name string, attempts int, op func() error) error {
+	backoff := retryInitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("%s connected after %d attempts", name, attempt)
+			}
+			return nil
+		}
+		if attempts > 0 && attempt >= attempts {
+			return err
+		}
+		log.Printf("%s unavailable (attempt %d): %v, retrying in %s", name, attempt, err, backoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, retryMaxBackoff)
+	}
+}
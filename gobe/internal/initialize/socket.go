@@ -1,6 +1,8 @@
 package initialize
 
 import (
+	"iot/internal/jwt_utils"
+	"iot/internal/middlewares"
 	"iot/pkg/socket"
 	"log"
 
@@ -11,9 +13,37 @@ func InitSocketServer(r *gin.Engine) *socket.Hub {
 	hub := socket.NewHub()
 
 	r.GET("/ws", func(c *gin.Context) {
-		socket.ServeWs(hub, c.Writer, c.Request)
+		socket.ServeWs(hub, c.Writer, c.Request, socketIdentity(c))
 	})
 
 	log.Println("WebSocket server initialized on /ws endpoint")
 	return hub
 }
+
+// socketIdentity - xác thực tùy chọn bằng cookie access_token, client không có token vẫn nhận telemetry
+func socketIdentity(c *gin.Context) *socket.Identity {
+	token, err := c.Cookie("access_token")
+	if err != nil {
+		return nil
+	}
+	claims, err := jwt_utils.VerifyToken(token, true)
+	if err != nil {
+		return nil
+	}
+	if active, err := middlewares.SessionActive(c.Request.Context(), claims); err != nil || !active {
+		return nil
+	}
+	// Token chưa có tenant thì coi như ẩn danh cho tới khi client refresh
+	if claims.TenantID == 0 {
+		return nil
+	}
+	return &socket.Identity{
+		UserID:    claims.Id,
+		TenantID:  claims.TenantID,
+		Username:  claims.Username,
+		Email:     claims.Email,
+		Role:      claims.Role,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
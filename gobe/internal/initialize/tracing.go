@@ -0,0 +1,27 @@
+package initialize
+
+import (
+	"context"
+	"iot/pkg/config"
+	"iot/pkg/tracing"
+	"log"
+	"strings"
+)
+
+// InitTracing - trả hàm flush span còn lại, gọi lúc tắt server
+func InitTracing(ctx context.Context) (func(context.Context) error, error) {
+	cfg := config.GetConfig().TracingConfig
+	shutdown, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:    cfg.Exporter,
+		Endpoint:    strings.TrimSuffix(cfg.Endpoint, "/"),
+		ServiceName: cfg.ServiceName,
+		SampleRatio: cfg.SampleRatio,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Exporter != tracing.ExporterNone {
+		log.Printf("Tracing enabled, exporter=%s sample_ratio=%g", cfg.Exporter, cfg.SampleRatio)
+	}
+	return shutdown, nil
+}
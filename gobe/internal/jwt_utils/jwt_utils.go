@@ -1,8 +1,9 @@
 package jwt_utils
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"iot/pkg/config"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,12 +12,30 @@ import (
 var (
 	AccessTokenSecret  []byte
 	RefreshTokenSecret []byte
+	AccessTokenTTL     = 3 * time.Hour
+	RefreshTokenTTL    = 7 * 24 * time.Hour
 )
 
+// ErrNotConfigured - chưa gọi Configure, không ký/kiểm tra token bằng secret rỗng
+var ErrNotConfigured = errors.New("jwt secrets are not configured")
+
+// Configure - gọi một lần lúc khởi động, trước khi phát hay kiểm tra token
+func Configure(cfg *config.JWTConfig) {
+	AccessTokenSecret = []byte(cfg.AccessSecret)
+	RefreshTokenSecret = []byte(cfg.RefreshSecret)
+	AccessTokenTTL = cfg.AccessTTL
+	RefreshTokenTTL = cfg.RefreshTTL
+}
+
 type Claims struct {
 	Id       uint   `json:"id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	Role     string `json:"role"`
+	// TenantID - tenant đang chọn, Role là role của user trong tenant này
+	TenantID uint `json:"tid,omitempty"`
+	// SessionID - id session (token family) trong Redis, refresh token có thêm jti riêng
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -27,17 +46,21 @@ type TokenPair struct {
 	TokenType    string `json:"token_type"` // thường "Bearer"
 }
 
-func GenerateTokenPair(id uint, username, email string) (*TokenPair, error) {
-	AccessTokenSecret = []byte(os.Getenv("JWT_ACCESS_SECRET"))
-	RefreshTokenSecret = []byte(os.Getenv("JWT_REFRESH_SECRET"))
-
+// GenerateTokenPair - refreshID là jti của refresh token, dùng để phát hiện token bị dùng lại
+func GenerateTokenPair(id uint, username, email, role string, tenantID uint, sessionID, refreshID string) (*TokenPair, error) {
+	if len(AccessTokenSecret) == 0 || len(RefreshTokenSecret) == 0 {
+		return nil, ErrNotConfigured
+	}
 	// Tạo Access Token
 	accessClaims := &Claims{
-		Id:       id,
-		Username: username,
-		Email:    email,
+		Id:        id,
+		Username:  username,
+		Email:     email,
+		Role:      role,
+		TenantID:  tenantID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(3 * time.Hour)), // Access token hết hạn sau 3 giờ
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "IOT",
 			Subject:   fmt.Sprint(id),
@@ -50,11 +73,15 @@ func GenerateTokenPair(id uint, username, email string) (*TokenPair, error) {
 	}
 
 	refreshClaims := &Claims{
-		Id:       id,
-		Username: username,
-		Email:    email,
+		Id:        id,
+		Username:  username,
+		Email:     email,
+		Role:      role,
+		TenantID:  tenantID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // Refresh token hết hạn sau 7 ngày
+			ID:        refreshID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(RefreshTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "IOT",
 			Subject:   fmt.Sprint(id),
@@ -75,9 +102,12 @@ func GenerateTokenPair(id uint, username, email string) (*TokenPair, error) {
 }
 
 func VerifyToken(tokenString string, isAccessToken bool) (*Claims, error) {
-	secret := []byte(os.Getenv("JWT_REFRESH_SECRET"))
+	secret := RefreshTokenSecret
 	if isAccessToken {
-		secret = []byte(os.Getenv("JWT_ACCESS_SECRET"))
+		secret = AccessTokenSecret
+	}
+	if len(secret) == 0 {
+		return nil, ErrNotConfigured
 	}
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AllowIPs - chỉ cho các IP/CIDR trong danh sách gọi vào, danh sách rỗng thì không giới hạn
+func AllowIPs(allowed []string) gin.HandlerFunc {
+	return ipFilter(allowed, true)
+}
+
+// RequireIPs - như AllowIPs nhưng danh sách rỗng thì chặn mọi request, dùng cho endpoint không được mở mặc định
+func RequireIPs(allowed []string) gin.HandlerFunc {
+	return ipFilter(allowed, false)
+}
+
+func ipFilter(allowed []string, openWhenEmpty bool) gin.HandlerFunc {
+	var networks []*net.IPNet
+	for _, entry := range allowed {
+		if !strings.Contains(entry, "/") {
+			if strings.Contains(entry, ":") {
+				entry += "/128"
+			} else {
+				entry += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid allowed IP %q: %v", entry, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+
+	return func(c *gin.Context) {
+		if len(allowed) == 0 && openWhenEmpty {
+			c.Next()
+			return
+		}
+		ip := net.ParseIP(c.ClientIP())
+		for _, network := range networks {
+			if ip != nil && network.Contains(ip) {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		c.Abort()
+	}
+}
@@ -1,17 +1,69 @@
 package middlewares
 
 import (
+	"context"
 	"net/http"
+	"strings"
 
+	"iot/internal/dto"
 	"iot/internal/jwt_utils"
+	"iot/internal/model"
 
 	"github.com/gin-gonic/gin"
 )
 
+// SessionChecker - kiểm tra session của access token chưa bị logout/revoke
+type SessionChecker interface {
+	IsSessionActive(ctx context.Context, userID uint, sessionID string) (bool, error)
+}
+
+// APIKeyAuthenticator - xác thực API key, principal nil nghĩa là key không hợp lệ
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, rawKey, ip string) (*dto.APIKeyPrincipal, error)
+}
+
+var (
+	sessionChecker      SessionChecker
+	apiKeyAuthenticator APIKeyAuthenticator
+)
+
+// SetSessionChecker - gọi lúc khởi tạo router, nil thì chỉ kiểm tra chữ ký token
+func SetSessionChecker(checker SessionChecker) {
+	sessionChecker = checker
+}
+
+// SetAPIKeyAuthenticator - gọi lúc khởi tạo router, nil thì Authen() từ chối mọi API key
+func SetAPIKeyAuthenticator(authenticator APIKeyAuthenticator) {
+	apiKeyAuthenticator = authenticator
+}
+
+// SessionActive - dùng chung cho Authen() và kết nối WebSocket
+func SessionActive(ctx context.Context, claims *jwt_utils.Claims) (bool, error) {
+	if sessionChecker == nil {
+		return true, nil
+	}
+	return sessionChecker.IsSessionActive(ctx, claims.Id, claims.SessionID)
+}
+
+// Authen - nhận API key (X-API-Key hoặc Authorization: Bearer iotk_...),
+// access token trong cookie, hoặc access token trong Authorization: Bearer
 func Authen() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		bearer := bearerToken(c)
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			authenAPIKey(c, key)
+			return
+		}
+		if strings.HasPrefix(bearer, model.APIKeyTokenPrefix) {
+			authenAPIKey(c, bearer)
+			return
+		}
+
 		token, err := c.Cookie("access_token")
-		if err != nil {
+		if err != nil || token == "" {
+			token = bearer
+		}
+		if token == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "No access token found"})
 			c.Abort()
 			return
@@ -23,8 +75,87 @@ func Authen() gin.HandlerFunc {
 			return
 		}
 
+		active, err := SessionActive(c.Request.Context(), data)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify session"})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+		// Token cấp trước khi có tenant: 401 để client refresh và nhận token mang tenant
+		if data.TenantID == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has no tenant, please refresh"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user", data)
 
 		c.Next()
 	}
 }
+
+// authenAPIKey - dựng claims từ chủ key để các handler dùng chung CurrentUser/CurrentActor
+func authenAPIKey(c *gin.Context, rawKey string) {
+	if apiKeyAuthenticator == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API keys are not accepted"})
+		c.Abort()
+		return
+	}
+	principal, err := apiKeyAuthenticator.AuthenticateAPIKey(c.Request.Context(), rawKey, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify API key"})
+		c.Abort()
+		return
+	}
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid, expired or revoked API key"})
+		c.Abort()
+		return
+	}
+
+	c.Set("user", &jwt_utils.Claims{
+		Id:       principal.UserID,
+		Username: principal.Name,
+		Email:    principal.Email,
+		Role:     principal.Role,
+		TenantID: principal.TenantID,
+	})
+	c.Set("api_key", principal)
+
+	c.Next()
+}
+
+// CurrentAPIKey - nil nếu request xác thực bằng session/JWT
+func CurrentAPIKey(c *gin.Context) *dto.APIKeyPrincipal {
+	data, ok := c.Get("api_key")
+	if !ok {
+		return nil
+	}
+	principal, _ := data.(*dto.APIKeyPrincipal)
+	return principal
+}
+
+// SessionOnly - chặn API key ở các route quản lý tài khoản (session, 2FA, tạo key mới...)
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if CurrentAPIKey(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint requires a login session, API keys are not accepted"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
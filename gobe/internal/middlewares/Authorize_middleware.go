@@ -0,0 +1,173 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+
+	"iot/internal/dto"
+	"iot/internal/jwt_utils"
+	"iot/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Permission string
+
+const (
+	PermUserRead      Permission = "user:read"
+	PermUserManage    Permission = "user:manage"
+	PermRoleAssign    Permission = "role:assign"
+	PermDeviceRead    Permission = "device:read"
+	PermDeviceControl Permission = "device:control"
+	PermDeviceManage  Permission = "device:manage"
+	PermSensorRead    Permission = "sensor:read"
+	PermSensorWrite   Permission = "sensor:write"
+	PermHistoryRead   Permission = "history:read"
+	PermHistoryWrite  Permission = "history:write"
+	PermAuditRead     Permission = "audit:read"
+)
+
+var rolePermissions = map[string][]Permission{
+	model.RoleAdmin: {
+		PermUserRead, PermUserManage, PermRoleAssign,
+		PermDeviceRead, PermDeviceControl, PermDeviceManage,
+		PermSensorRead, PermSensorWrite, PermHistoryRead, PermHistoryWrite,
+		PermAuditRead,
+	},
+	model.RoleOperator: {
+		PermUserRead,
+		PermDeviceRead, PermDeviceControl, PermDeviceManage,
+		PermSensorRead, PermSensorWrite, PermHistoryRead, PermHistoryWrite,
+	},
+	model.RoleViewer: {
+		PermDeviceRead, PermSensorRead, PermHistoryRead,
+	},
+}
+
+// scopePermissions - quyền tối đa của từng scope API key, admin = toàn bộ quyền của role
+var scopePermissions = map[string][]Permission{
+	model.ScopeTelemetryRead: {PermDeviceRead, PermSensorRead, PermHistoryRead},
+	model.ScopeDeviceControl: {PermDeviceRead, PermDeviceControl},
+	model.ScopeAdmin:         rolePermissions[model.RoleAdmin],
+}
+
+// HasPermission - token cũ không có role được coi là viewer
+func HasPermission(role string, perm Permission) bool {
+	if role == "" {
+		role = model.RoleViewer
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeAllowsPermission - scope chưa biết thì không cấp quyền nào
+func ScopeAllowsPermission(scopes []string, perm Permission) bool {
+	for _, scope := range scopes {
+		for _, p := range scopePermissions[scope] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RoleAllowsScope - role phải có đủ mọi quyền của scope mới được tạo key với scope đó
+func RoleAllowsScope(role, scope string) bool {
+	perms, ok := scopePermissions[scope]
+	if !ok {
+		return false
+	}
+	for _, perm := range perms {
+		if !HasPermission(role, perm) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowed - quyền của role, giới hạn thêm bởi scope nếu request dùng API key
+func allowed(c *gin.Context, role string, perm Permission) bool {
+	if !HasPermission(role, perm) {
+		return false
+	}
+	if key := CurrentAPIKey(c); key != nil {
+		return ScopeAllowsPermission(key.Scopes, perm)
+	}
+	return true
+}
+
+// selfAllowed - thao tác trên tài khoản của chính mình qua API key cần scope admin
+func selfAllowed(c *gin.Context) bool {
+	key := CurrentAPIKey(c)
+	if key == nil {
+		return true
+	}
+	for _, scope := range key.Scopes {
+		if scope == model.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentUser - claims do Authen() gắn vào context
+func CurrentUser(c *gin.Context) (*jwt_utils.Claims, bool) {
+	data, ok := c.Get("user")
+	if !ok {
+		return nil, false
+	}
+	claims, ok := data.(*jwt_utils.Claims)
+	return claims, ok
+}
+
+// Authorize - yêu cầu user có đủ tất cả permission, dùng sau Authen()
+func Authorize(perms ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := CurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthenticated"})
+			c.Abort()
+			return
+		}
+		for _, perm := range perms {
+			if !allowed(c, claims.Role, perm) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission " + string(perm)})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// AuthorizeSelfOr - cho phép nếu :param trùng với user hiện tại, ngược lại cần permission
+func AuthorizeSelfOr(param string, perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := CurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthenticated"})
+			c.Abort()
+			return
+		}
+		if (c.Param(param) == fmt.Sprint(claims.Id) && selfAllowed(c)) || allowed(c, claims.Role, perm) {
+			c.Next()
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission " + string(perm)})
+		c.Abort()
+	}
+}
+
+// CurrentActor - user hiện tại dưới dạng dto.Actor để truyền xuống service
+func CurrentActor(c *gin.Context) dto.Actor {
+	claims, ok := CurrentUser(c)
+	if !ok {
+		return dto.Actor{}
+	}
+	return dto.Actor{UserID: claims.Id, TenantID: claims.TenantID, Role: claims.Role, Name: dto.ActorName(claims.Username, claims.Email)}
+}
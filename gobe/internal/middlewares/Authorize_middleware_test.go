@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"iot/internal/dto"
+	"iot/internal/jwt_utils"
+	"iot/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveAs - gắn claims (và API key nếu scopes khác nil) như Authen() rồi chạy guard
+func serveAs(role string, scopes []string, path string, guard gin.HandlerFunc) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Set("user", &jwt_utils.Claims{Id: 7, Role: role, TenantID: 1})
+		if scopes != nil {
+			c.Set("api_key", &dto.APIKeyPrincipal{KeyID: 1, UserID: 7, TenantID: 1, Role: role, Scopes: scopes})
+		}
+	}, guard, func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Code
+}
+
+func TestAuthorizeAPIKeyScopes(t *testing.T) {
+	session := []string(nil)
+	tests := []struct {
+		name   string
+		role   string
+		scopes []string
+		perm   Permission
+		code   int
+	}{
+		{"session uses the role", model.RoleOperator, session, PermDeviceControl, http.StatusOK},
+		{"telemetry key reads sensors", model.RoleOperator, []string{model.ScopeTelemetryRead}, PermSensorRead, http.StatusOK},
+		{"telemetry key cannot control devices", model.RoleOperator, []string{model.ScopeTelemetryRead}, PermDeviceControl, http.StatusForbidden},
+		{"control key controls devices", model.RoleOperator, []string{model.ScopeDeviceControl}, PermDeviceControl, http.StatusOK},
+		{"control key cannot read sensors", model.RoleOperator, []string{model.ScopeDeviceControl}, PermSensorRead, http.StatusForbidden},
+		{"scopes add up", model.RoleOperator, []string{model.ScopeTelemetryRead, model.ScopeDeviceControl}, PermSensorRead, http.StatusOK},
+		{"scope never exceeds the current role", model.RoleViewer, []string{model.ScopeDeviceControl}, PermDeviceControl, http.StatusForbidden},
+		{"admin scope is capped by an operator role", model.RoleOperator, []string{model.ScopeAdmin}, PermAuditRead, http.StatusForbidden},
+		{"admin scope with admin role", model.RoleAdmin, []string{model.ScopeAdmin}, PermAuditRead, http.StatusOK},
+		{"unknown scope grants nothing", model.RoleAdmin, []string{"everything"}, PermDeviceRead, http.StatusForbidden},
+		{"key without scopes grants nothing", model.RoleAdmin, []string{}, PermDeviceRead, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := serveAs(tt.role, tt.scopes, "/users/1", Authorize(tt.perm)); code != tt.code {
+				t.Errorf("Authorize(%s) = %d, want %d", tt.perm, code, tt.code)
+			}
+		})
+	}
+}
+
+func TestAuthorizeSelfOrAPIKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   string
+		scopes []string
+		path   string
+		code   int
+	}{
+		{"own account with a session", model.RoleViewer, nil, "/users/7", http.StatusOK},
+		{"own account needs the admin scope", model.RoleViewer, []string{model.ScopeTelemetryRead}, "/users/7", http.StatusForbidden},
+		{"own account with the admin scope", model.RoleAdmin, []string{model.ScopeAdmin}, "/users/7", http.StatusOK},
+		{"other account needs the permission", model.RoleViewer, nil, "/users/1", http.StatusForbidden},
+		{"other account through the permission", model.RoleOperator, nil, "/users/1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := serveAs(tt.role, tt.scopes, tt.path, AuthorizeSelfOr("id", PermUserRead)); code != tt.code {
+				t.Errorf("AuthorizeSelfOr = %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestRoleAllowsScope(t *testing.T) {
+	tests := []struct {
+		role  string
+		scope string
+		want  bool
+	}{
+		{model.RoleViewer, model.ScopeTelemetryRead, true},
+		{model.RoleViewer, model.ScopeDeviceControl, false},
+		{model.RoleOperator, model.ScopeDeviceControl, true},
+		{model.RoleOperator, model.ScopeAdmin, false},
+		{model.RoleAdmin, model.ScopeAdmin, true},
+		{model.RoleAdmin, "everything", false},
+	}
+	for _, tt := range tests {
+		if got := RoleAllowsScope(tt.role, tt.scope); got != tt.want {
+			t.Errorf("RoleAllowsScope(%s, %s) = %v, want %v", tt.role, tt.scope, got, tt.want)
+		}
+	}
+}
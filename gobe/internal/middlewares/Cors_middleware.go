@@ -1,24 +1,30 @@
 package middlewares
 
 import (
+	"sync/atomic"
+
 	"github.com/gin-gonic/gin"
 )
 
+// allowedOrigins - lấy từ cors.allowed_origins, đổi được khi nạp lại cấu hình
+var allowedOrigins atomic.Pointer[[]string]
+
+// SetAllowedOrigins - gọi lúc khởi động và mỗi lần cấu hình được nạp lại
+func SetAllowedOrigins(origins []string) {
+	allowedOrigins.Store(&origins)
+}
+
 func CorsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.GetHeader("Origin")
-		// Danh sách origins cho phép (dev + prod)
-		allowedOrigins := []string{
-			"http://localhost:3000",
-			"http://localhost:5173", // Vite port
-			"http://127.0.0.1:3000",
-			"http://127.0.0.1:5173",
-			"https://yourdomain.com", // Thêm prod domain
+		var origins []string
+		if current := allowedOrigins.Load(); current != nil {
+			origins = *current
 		}
 
 		// Check origin có trong list không
 		originAllowed := false
-		for _, allowed := range allowedOrigins {
+		for _, allowed := range origins {
 			if origin == allowed {
 				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 				originAllowed = true
@@ -26,9 +32,9 @@ func CorsMiddleware() gin.HandlerFunc {
 			}
 		}
 
-		// Nếu không match, default localhost:5173 (dev)
-		if !originAllowed {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
+		// Nếu không match, dùng origin đầu tiên trong cấu hình
+		if !originAllowed && len(origins) > 0 {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origins[0])
 		}
 
 		// Headers chung
@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"iot/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateKeyFunc - trả về phần định danh của key (IP, email...), rỗng thì bỏ qua limit này
+type RateKeyFunc func(c *gin.Context) string
+
+// RateRule - tối đa Limit request trong Window cho mỗi giá trị của Key
+type RateRule struct {
+	Scope  string
+	Limit  int
+	Window time.Duration
+	Key    RateKeyFunc
+}
+
+func ByIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// ByEmail - đọc field email trong JSON body rồi trả body lại cho handler
+func ByEmail(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(payload.Email))
+}
+
+// RateLimit - kiểm tra lần lượt các rule, vượt rule nào thì trả 429 kèm Retry-After.
+// Redis lỗi thì cho qua để sự cố cache không khóa toàn bộ đăng nhập.
+func RateLimit(limiter *ratelimit.Limiter, name string, rules ...RateRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+		for _, rule := range rules {
+			id := rule.Key(c)
+			if id == "" {
+				continue
+			}
+			key := "ratelimit:" + name + ":" + rule.Scope + ":" + id
+			result, err := limiter.Allow(c.Request.Context(), key, rule.Limit, rule.Window)
+			if err != nil {
+				log.Printf("Rate limiter unavailable for %s: %v", key, err)
+				continue
+			}
+			c.Header("X-RateLimit-Limit", strconv.Itoa(rule.Limit))
+			c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			if !result.Allowed {
+				AbortTooManyRequests(c, result.RetryAfter, "Too many requests, please try again later")
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// AbortTooManyRequests - 429 với Retry-After tính bằng giây (làm tròn lên)
+func AbortTooManyRequests(c *gin.Context, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message, "retry_after": seconds})
+	c.Abort()
+}
@@ -0,0 +1,120 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"iot/pkg/ratelimit"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// newRateLimitRouter - POST /login với rule giống route đăng nhập, handler trả lại body nhận được
+func newRateLimitRouter(t *testing.T, limiter *ratelimit.Limiter) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/login", RateLimit(limiter, "login",
+		RateRule{Scope: "ip", Limit: 3, Window: time.Minute, Key: ByIP},
+		RateRule{Scope: "email", Limit: 2, Window: time.Minute, Key: ByEmail},
+	), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	return r
+}
+
+func login(r *gin.Engine, ip, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.RemoteAddr = ip + ":40000"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("keys per rule and email is normalized", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		r := newRateLimitRouter(t, ratelimit.NewLimiter(client))
+
+		body := `{"email":"  Alice@Example.com "}`
+		w := login(r, "10.0.0.1", body)
+		if w.Code != http.StatusOK || w.Body.String() != body {
+			t.Fatalf("status %d body %q, want 200 with the original body", w.Code, w.Body.String())
+		}
+		for _, key := range []string{"ratelimit:login:ip:10.0.0.1", "ratelimit:login:email:alice@example.com"} {
+			if !mr.Exists(key) {
+				t.Errorf("key %s not counted, have %v", key, mr.Keys())
+			}
+		}
+		// rule cuối được kiểm tra sau cùng nên header là của rule email
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("X-RateLimit-Limit = %q, want 2", got)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != "1" {
+			t.Errorf("X-RateLimit-Remaining = %q, want 1", got)
+		}
+	})
+
+	t.Run("each rule limits its own key", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		r := newRateLimitRouter(t, ratelimit.NewLimiter(client))
+
+		steps := []struct {
+			ip, body string
+			code     int
+		}{
+			{"10.0.0.1", `{"email":"a@example.com"}`, http.StatusOK},
+			{"10.0.0.2", `{"email":"a@example.com"}`, http.StatusOK},
+			{"10.0.0.3", `{"email":"A@example.com"}`, http.StatusTooManyRequests}, // email hết quota dù đổi IP
+			{"10.0.0.3", `{"email":"b@example.com"}`, http.StatusOK},
+			{"10.0.0.3", `not json`, http.StatusOK}, // không đọc được email thì chỉ áp rule IP
+			{"10.0.0.3", `{"email":"c@example.com"}`, http.StatusTooManyRequests},
+		}
+		for i, step := range steps {
+			w := login(r, step.ip, step.body)
+			if w.Code != step.code {
+				t.Fatalf("request %d from %s: status %d, want %d", i+1, step.ip, w.Code, step.code)
+			}
+			if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+				t.Errorf("request %d: 429 without Retry-After", i+1)
+			}
+		}
+		if mr.Exists("ratelimit:login:email:c@example.com") {
+			t.Error("request rejected by the ip rule was counted against the email")
+		}
+	})
+
+	t.Run("redis down lets requests through", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+		t.Cleanup(func() { client.Close() })
+		r := newRateLimitRouter(t, ratelimit.NewLimiter(client))
+		mr.Close()
+
+		for i := 0; i < 5; i++ {
+			if w := login(r, "10.0.0.1", `{"email":"a@example.com"}`); w.Code != http.StatusOK {
+				t.Fatalf("request %d: status %d, want 200", i+1, w.Code)
+			}
+		}
+	})
+
+	t.Run("nil limiter is disabled", func(t *testing.T) {
+		r := newRateLimitRouter(t, nil)
+		for i := 0; i < 5; i++ {
+			if w := login(r, "10.0.0.1", `{"email":"a@example.com"}`); w.Code != http.StatusOK {
+				t.Fatalf("request %d: status %d, want 200", i+1, w.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"iot/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CurrentTenant - tenant của token/API key, 0 nếu request chưa qua Authen()
+func CurrentTenant(c *gin.Context) uint {
+	claims, ok := CurrentUser(c)
+	if !ok {
+		return 0
+	}
+	return claims.TenantID
+}
+
+// TenantDB - db chỉ thấy dữ liệu của tenant hiện tại, dùng cho mọi repository theo tenant.
+// Request chưa xác thực nhận db không chọn tenant nên repository theo tenant sẽ trả ErrMissingTenant.
+func TenantDB(c *gin.Context, db *gorm.DB) *gorm.DB {
+	id := CurrentTenant(c)
+	if id == 0 {
+		return db.WithContext(c.Request.Context())
+	}
+	return tenant.WithTenant(db.WithContext(c.Request.Context()), id)
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Database tạo bằng AutoMigrate trước khi có migration (chỉ có trên MySQL) đã có sẵn bảng nên CREATE TABLE IF NOT EXISTS
+// của baseline bỏ qua, nhưng bảng thiếu các cột thêm về sau (users.role, tenant_id...) và 0002 sẽ lỗi.
+// upgradeAutoMigrate chạy cùng transaction với baseline, thêm cột/index còn thiếu; database mới đã đủ nên không làm gì.
+
+// legacyColumns - cột baseline có mà bảng tạo bằng AutoMigrate của bản cũ hơn có thể chưa có
+var legacyColumns = []struct {
+	table, column, definition string
+}{
+	{"users", "role", "varchar(20) NOT NULL DEFAULT 'viewer'"},
+	{"users", "two_factor_method", "varchar(10) NOT NULL DEFAULT ''"},
+	{"users", "totp_secret", "varchar(64) NULL"},
+	{"devices", "owner_id", "bigint unsigned NULL"},
+	{"devices", "tenant_id", "bigint unsigned NOT NULL DEFAULT 0"},
+	{"sensor_data", "tenant_id", "bigint unsigned NOT NULL DEFAULT 0"},
+	{"sensor_data", "fingerprint", "char(64) NULL"},
+	{"device_histories", "tenant_id", "bigint unsigned NOT NULL DEFAULT 0"},
+	{"device_histories", "fingerprint", "char(64) NULL"},
+	{"device_credentials", "tenant_id", "bigint unsigned NOT NULL DEFAULT 0"},
+	{"api_keys", "tenant_id", "bigint unsigned NOT NULL DEFAULT 0"},
+}
+
+// legacyIndexes - index baseline tạo cùng các cột trên
+var legacyIndexes = []struct {
+	table, name, definition string
+}{
+	{"devices", "idx_devices_owner_id", "INDEX idx_devices_owner_id ON devices (owner_id)"},
+	{"devices", "idx_devices_tenant_id", "INDEX idx_devices_tenant_id ON devices (tenant_id)"},
+	{"sensor_data", "idx_sensor_data_tenant_id", "INDEX idx_sensor_data_tenant_id ON sensor_data (tenant_id)"},
+	{"sensor_data", "idx_sensor_data_fingerprint", "UNIQUE INDEX idx_sensor_data_fingerprint ON sensor_data (tenant_id, fingerprint)"},
+	{"device_histories", "idx_device_histories_tenant_id", "INDEX idx_device_histories_tenant_id ON device_histories (tenant_id)"},
+	{"device_histories", "idx_device_histories_fingerprint", "UNIQUE INDEX idx_device_histories_fingerprint ON device_histories (tenant_id, fingerprint)"},
+	{"device_credentials", "idx_device_credentials_tenant_id", "INDEX idx_device_credentials_tenant_id ON device_credentials (tenant_id)"},
+}
+
+func upgradeAutoMigrate(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	for _, c := range legacyColumns {
+		if !migrator.HasTable(c.table) || migrator.HasColumn(c.table, c.column) {
+			continue
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)).Error; err != nil {
+			return fmt.Errorf("add %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	for _, index := range legacyIndexes {
+		if !migrator.HasTable(index.table) || migrator.HasIndex(index.table, index.name) {
+			continue
+		}
+		if err := tx.Exec("CREATE " + index.definition).Error; err != nil {
+			return fmt.Errorf("create index %s: %w", index.name, err)
+		}
+	}
+	return nil
+}
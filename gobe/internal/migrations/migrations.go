@@ -0,0 +1,443 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iot/pkg/config"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration có version thay cho AutoMigrate. Mỗi dialect một thư mục (mysql, postgres, sqlite) với lịch sử
+// version riêng, mỗi version một cặp file <version>_<tên>.up.sql / .down.sql. Câu lệnh kết thúc bằng ";" ở cuối dòng,
+// trừ khi nằm trong khối $$ ... $$ của PostgreSQL; dòng bắt đầu bằng "--" là chú thích.
+// Đã phát hành thì không sửa file cũ, thay đổi schema luôn là một version mới.
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Table - bảng ghi các version đã chạy
+const Table = "schema_migrations"
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// Status - một migration kèm trạng thái trong database
+type Status struct {
+	Version   uint
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Dirty - migration dừng giữa chừng trên MySQL (không rollback được DDL), phải sửa tay rồi Force
+	Dirty bool
+}
+
+type schemaMigration struct {
+	Version   uint      `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;type:varchar(255);not null"`
+	Dirty     bool      `gorm:"column:dirty;not null;default:false"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return Table
+}
+
+var (
+	ErrDirty         = errors.New("database is dirty after a failed migration, fix the schema by hand then run `migrate force <version>`")
+	ErrUnknownTarget = errors.New("unknown migration version")
+)
+
+// PendingError - database chưa ở version mới nhất mà binary biết
+type PendingError struct {
+	Current uint
+	Latest  uint
+}
+
+func (e *PendingError) Error() string {
+	return fmt.Sprintf("database schema is at version %d, this build needs %d: run `migrate up` first", e.Current, e.Latest)
+}
+
+// AheadError - database đã được migrate bởi bản build mới hơn
+type AheadError struct {
+	Current uint
+	Latest  uint
+}
+
+func (e *AheadError) Error() string {
+	return fmt.Sprintf("database schema is at version %d but this build only knows up to %d", e.Current, e.Latest)
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New - nạp migration nhúng trong binary theo dialect của db
+func New(db *gorm.DB) (*Migrator, error) {
+	list, err := load(files, db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: list}, nil
+}
+
+func load(fsys fs.FS, dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: no migrations for dialect %q", dialect)
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrations: unexpected file %s/%s", dialect, entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migrations: invalid version in %s/%s", dialect, entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[uint(version)]
+		if m == nil {
+			m = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrations: version %d has two names, %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = statements(string(data))
+		} else {
+			m.Down = statements(string(data))
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migrations: version %d has no up file", m.Version)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// statements - tách file thành từng câu lệnh vì driver không bật multiStatements
+func statements(script string) []string {
+	list := []string{}
+	var current strings.Builder
+	inBlock := false
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !inBlock && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.Count(line, "$$")%2 == 1 {
+			inBlock = !inBlock
+		}
+		if !inBlock && strings.HasSuffix(trimmed, ";") {
+			list = append(list, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		list = append(list, rest)
+	}
+	return list
+}
+
+// Latest - version mới nhất binary biết, 0 nếu không có migration nào
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	if db.Migrator().HasTable(Table) {
+		return nil
+	}
+	return db.Migrator().CreateTable(&schemaMigration{})
+}
+
+// applied - chỉ đọc, chưa có bảng schema_migrations thì coi như chưa chạy version nào
+func (m *Migrator) applied(ctx context.Context) (map[uint]schemaMigration, error) {
+	if !m.db.WithContext(ctx).Migrator().HasTable(Table) {
+		return map[uint]schemaMigration{}, nil
+	}
+	var rows []schemaMigration
+	if err := m.db.WithContext(ctx).Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// Version - version cao nhất đã chạy và có migration nào đang dirty không
+func (m *Migrator) Version(ctx context.Context) (uint, bool, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	var current uint
+	dirty := false
+	for version, row := range applied {
+		if version > current {
+			current = version
+		}
+		dirty = dirty || row.Dirty
+	}
+	return current, dirty, nil
+}
+
+// Status - mọi migration binary biết, cộng version lạ có trong database (của bản build khác)
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.Applied, status.AppliedAt, status.Dirty = true, &appliedAt, row.Dirty
+			delete(applied, migration.Version)
+		}
+		list = append(list, status)
+	}
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		list = append(list, Status{Version: row.Version, Name: row.Name, Applied: true, AppliedAt: &appliedAt, Dirty: row.Dirty})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Check - lỗi nếu database không khớp đúng version binary cần, dùng lúc khởi động server
+func (m *Migrator) Check(ctx context.Context) error {
+	current, dirty, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case dirty:
+		return ErrDirty
+	case current > m.Latest():
+		return &AheadError{Current: current, Latest: m.Latest()}
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		if !s.Applied {
+			return &PendingError{Current: current, Latest: m.Latest()}
+		}
+	}
+	return nil
+}
+
+// Up - chạy mọi migration chưa chạy, trả các version đã chạy
+func (m *Migrator) Up(ctx context.Context) ([]uint, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down - lùi steps migration gần nhất
+func (m *Migrator) Down(ctx context.Context, steps int) ([]uint, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var versions []uint
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	if steps <= 0 || len(versions) == 0 {
+		return nil, nil
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	var target uint
+	if steps < len(versions) {
+		target = versions[steps]
+	}
+	return m.To(ctx, target)
+}
+
+// To - đưa database tới đúng version target (0 là xóa hết): chạy up các version <= target còn thiếu
+// rồi down các version > target đã chạy
+func (m *Migrator) To(ctx context.Context, target uint) ([]uint, error) {
+	if target != 0 && m.find(target) == nil {
+		return nil, fmt.Errorf("%w %d", ErrUnknownTarget, target)
+	}
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range applied {
+		if row.Dirty {
+			return nil, ErrDirty
+		}
+		if m.find(row.Version) == nil && row.Version > target {
+			return nil, fmt.Errorf("version %d is applied but unknown to this build, cannot migrate down past it", row.Version)
+		}
+	}
+
+	var done []uint
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > target {
+			continue
+		}
+		if err := m.up(ctx, migration); err != nil {
+			return done, err
+		}
+		done = append(done, migration.Version)
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= target {
+			continue
+		}
+		if err := m.down(ctx, migration); err != nil {
+			return done, err
+		}
+		done = append(done, migration.Version)
+	}
+	return done, nil
+}
+
+// Force - ghi nhận database đang ở version (đã sửa tay sau migration lỗi) mà không chạy câu lệnh nào
+func (m *Migrator) Force(ctx context.Context, version uint) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w %d", ErrUnknownTarget, version)
+	}
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&schemaMigration{}).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			if err := tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: now}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) find(version uint) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// transactionalDDL - PostgreSQL và SQLite rollback được cả DDL, lỗi giữa chừng không để lại gì
+func (m *Migrator) transactionalDDL() bool {
+	return m.db.Dialector.Name() != config.DriverMySQL
+}
+
+// up - đánh dấu dirty trước để lỗi giữa chừng (DDL của MySQL tự commit) không bị coi là đã chạy xong
+func (m *Migrator) up(ctx context.Context, migration Migration) error {
+	db := m.db.WithContext(ctx)
+	row := schemaMigration{Version: migration.Version, Name: migration.Name, Dirty: true, AppliedAt: time.Now()}
+	if err := db.Create(&row).Error; err != nil {
+		return err
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := exec(tx, migration.Up); err != nil {
+			return err
+		}
+		if migration.Version == 1 {
+			if err := upgradeAutoMigrate(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&row).Updates(map[string]interface{}{"dirty": false, "applied_at": time.Now()}).Error
+	})
+	if err != nil {
+		if m.transactionalDDL() {
+			db.Delete(&row)
+		}
+		return fmt.Errorf("migration %04d_%s up: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) down(ctx context.Context, migration Migration) error {
+	if migration.Down == nil {
+		return fmt.Errorf("migration %04d_%s has no down file", migration.Version, migration.Name)
+	}
+	db := m.db.WithContext(ctx)
+	row := schemaMigration{Version: migration.Version}
+	if err := db.Model(&row).Update("dirty", true).Error; err != nil {
+		return err
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := exec(tx, migration.Down); err != nil {
+			return err
+		}
+		return tx.Delete(&row).Error
+	})
+	if err != nil {
+		if m.transactionalDDL() {
+			db.Model(&row).Update("dirty", false)
+		}
+		return fmt.Errorf("migration %04d_%s down: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// exec - cùng một connection (transaction) để biến phiên như @x dùng được giữa các câu lệnh
+func exec(tx *gorm.DB, list []string) error {
+	for _, statement := range list {
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("%w\n  in: %s", err, firstLine(statement))
+		}
+	}
+	return nil
+}
+
+func firstLine(statement string) string {
+	line, _, _ := strings.Cut(statement, "\n")
+	return line
+}
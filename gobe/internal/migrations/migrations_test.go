@@ -0,0 +1,344 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB - một connection để mọi câu lệnh thấy cùng database :memory:
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+var testFiles = fstest.MapFS{
+	"sqlite/0001_items.up.sql":     {Data: []byte("-- bảng thử\nCREATE TABLE items (id integer PRIMARY KEY);\n")},
+	"sqlite/0001_items.down.sql":   {Data: []byte("DROP TABLE items;\n")},
+	"sqlite/0002_name.up.sql":      {Data: []byte("ALTER TABLE items ADD COLUMN name text;\n")},
+	"sqlite/0002_name.down.sql":    {Data: []byte("ALTER TABLE items DROP COLUMN name;\n")},
+	"sqlite/0003_broken.up.sql":    {Data: []byte("CREATE TABLE broken (id integer);\nINSERT INTO missing VALUES (1);\n")},
+	"sqlite/0003_broken.down.sql":  {Data: []byte("DROP TABLE broken;\n")},
+	"sqlite/0004_indexes.up.sql":   {Data: []byte("CREATE INDEX idx_items_name ON items (name);\n")},
+	"sqlite/0004_indexes.down.sql": {Data: []byte("DROP INDEX idx_items_name;\n")},
+}
+
+func testMigrator(t *testing.T, versions ...uint) *Migrator {
+	t.Helper()
+	all, err := load(testFiles, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []Migration
+	for _, migration := range all {
+		for _, version := range versions {
+			if migration.Version == version {
+				list = append(list, migration)
+			}
+		}
+	}
+	return &Migrator{db: openTestDB(t), migrations: list}
+}
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"comments and blank lines", "-- note\n\nCREATE TABLE a (id int);\n  -- indented\nDROP TABLE b;\n",
+			[]string{"CREATE TABLE a (id int)", "DROP TABLE b"}},
+		{"multi line statement", "CREATE TABLE a (\n  id int\n);\n", []string{"CREATE TABLE a (\n  id int\n)"}},
+		{"dollar quoted block", "DO $$\nBEGIN\n  PERFORM 1;\nEND\n$$;\nSELECT 1;\n",
+			[]string{"DO $$\nBEGIN\n  PERFORM 1;\nEND\n$$", "SELECT 1"}},
+		{"missing final semicolon", "SELECT 1", []string{"SELECT 1"}},
+		{"empty", "-- nothing\n", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"unexpected file", fstest.MapFS{"sqlite/readme.md": {}}},
+		{"version zero", fstest.MapFS{"sqlite/0000_init.up.sql": {}}},
+		{"two names", fstest.MapFS{"sqlite/0001_a.up.sql": {}, "sqlite/0001_b.down.sql": {}}},
+		{"down without up", fstest.MapFS{"sqlite/0001_a.down.sql": {}}},
+		{"unknown dialect", fstest.MapFS{"mysql/0001_a.up.sql": {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := load(tt.files, "sqlite"); err == nil {
+				t.Fatal("load succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied []schemaMigration
+		err     error
+	}{
+		{"fresh database", nil, &PendingError{Current: 0, Latest: 2}},
+		{"up to date", []schemaMigration{{Version: 1}, {Version: 2}}, nil},
+		{"behind", []schemaMigration{{Version: 1}}, &PendingError{Current: 1, Latest: 2}},
+		{"gap in history", []schemaMigration{{Version: 2}}, &PendingError{Current: 2, Latest: 2}},
+		{"ahead of build", []schemaMigration{{Version: 1}, {Version: 2}, {Version: 3}}, &AheadError{Current: 3, Latest: 2}},
+		{"dirty", []schemaMigration{{Version: 1}, {Version: 2, Dirty: true}}, ErrDirty},
+		{"dirty wins over ahead", []schemaMigration{{Version: 3, Dirty: true}}, ErrDirty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testMigrator(t, 1, 2)
+			ctx := context.Background()
+			if err := m.ensureTable(ctx); err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range tt.applied {
+				row.Name, row.AppliedAt = "test", time.Now()
+				if err := m.db.Create(&row).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := m.Check(ctx)
+			if !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("Check = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTo(t *testing.T) {
+	ctx := context.Background()
+	m := testMigrator(t, 1, 2, 4)
+
+	tests := []struct {
+		name   string
+		target uint
+		done   []uint
+		err    bool
+	}{
+		{"up to 1", 1, []uint{1}, false},
+		{"up to latest", 4, []uint{2, 4}, false},
+		{"already there", 4, nil, false},
+		{"down to 1", 1, []uint{4, 2}, false},
+		{"unknown target", 3, nil, true},
+		{"down to nothing", 0, []uint{1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done, err := m.To(ctx, tt.target)
+			if tt.err {
+				if !errors.Is(err, ErrUnknownTarget) {
+					t.Fatalf("err = %v, want ErrUnknownTarget", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("To(%d): %v", tt.target, err)
+			}
+			if !reflect.DeepEqual(done, tt.done) {
+				t.Errorf("done = %v, want %v", done, tt.done)
+			}
+		})
+	}
+}
+
+// TestFailedUp - SQLite rollback được DDL nên migration lỗi không để lại bảng lẫn dòng dirty;
+// dòng dirty (MySQL) chặn mọi lần migrate cho tới khi Force
+func TestFailedUp(t *testing.T) {
+	ctx := context.Background()
+	m := testMigrator(t, 1, 3)
+
+	done, err := m.Up(ctx)
+	if err == nil {
+		t.Fatal("Up succeeded, want error from 0003_broken")
+	}
+	if !reflect.DeepEqual(done, []uint{1}) {
+		t.Errorf("done = %v, want [1]", done)
+	}
+	if m.db.Migrator().HasTable("broken") {
+		t.Error("table of failed migration was not rolled back")
+	}
+	if current, dirty, _ := m.Version(ctx); current != 1 || dirty {
+		t.Errorf("version = %d dirty = %v, want 1 clean", current, dirty)
+	}
+
+	m.db.Create(&schemaMigration{Version: 3, Name: "broken", Dirty: true, AppliedAt: time.Now()})
+	if _, err := m.Up(ctx); !errors.Is(err, ErrDirty) {
+		t.Fatalf("Up on dirty database = %v, want ErrDirty", err)
+	}
+	if err := m.Force(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if current, dirty, _ := m.Version(ctx); current != 1 || dirty {
+		t.Errorf("after Force version = %d dirty = %v, want 1 clean", current, dirty)
+	}
+}
+
+func TestToUnknownApplied(t *testing.T) {
+	ctx := context.Background()
+	m := testMigrator(t, 1, 2)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	m.db.Create(&schemaMigration{Version: 9, Name: "newer", AppliedAt: time.Now()})
+	if _, err := m.To(ctx, 1); err == nil {
+		t.Fatal("migrating down past a version unknown to this build succeeded")
+	}
+}
+
+// TestEmbeddedSQLite - migration thật của SQLite chạy được lên, xuống hết rồi lên lại
+func TestEmbeddedSQLite(t *testing.T) {
+	ctx := context.Background()
+	m, err := New(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []func() error{
+		func() error { _, err := m.Up(ctx); return err },
+		func() error { _, err := m.To(ctx, 0); return err },
+		func() error { _, err := m.Up(ctx); return err },
+	} {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("Check after Up: %v", err)
+	}
+}
+
+// Model của bản đầu tiên (trước khi có role, tenant...), enum của MySQL thay bằng varchar vì SQLite không có
+type legacyUser struct {
+	gorm.Model
+	Name     string `gorm:"column:name;type:varchar(100);not null"`
+	Email    string `gorm:"column:email;type:varchar(100);not null;unique"`
+	Password string `gorm:"column:password;type:varchar(255);not null"`
+}
+
+type legacyDevice struct {
+	gorm.Model
+	Name   string `gorm:"column:name;type:varchar(50)"`
+	Status string `gorm:"column:status;type:varchar(3);not null"`
+}
+
+type legacySensorData struct {
+	gorm.Model
+	Temperature float64 `gorm:"column:temperature;type:decimal(5,2);not null"`
+	Humidity    float64 `gorm:"column:humidity;type:decimal(5,2);not null"`
+	Light       int     `gorm:"column:light;type:int;not null"`
+}
+
+type legacyDeviceHistory struct {
+	gorm.Model
+	UserID     uint   `gorm:"column:user_id;not null"`
+	DeviceID   uint   `gorm:"column:device_id;type:varchar(50);not null"`
+	UserChange string `gorm:"column:user_change;type:varchar(100);not null"`
+	Status     string `gorm:"column:status;type:varchar(3);not null"`
+}
+
+// TestUpgradeAutoMigrate - bảng tạo bằng AutoMigrate của bản đầu tiên phải có đủ cột và index như baseline mới tạo
+func TestUpgradeAutoMigrate(t *testing.T) {
+	ctx := context.Background()
+	legacy := openTestDB(t)
+	tables := map[string]interface{}{
+		"users":            &legacyUser{},
+		"devices":          &legacyDevice{},
+		"sensor_data":      &legacySensorData{},
+		"device_histories": &legacyDeviceHistory{},
+	}
+	for table, model := range tables {
+		if err := legacy.Table(table).AutoMigrate(model); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy.Exec("INSERT INTO users (name, email, password) VALUES ('alice', 'alice@example.com', 'x')")
+	legacy.Exec("INSERT INTO devices (name, status) VALUES ('fan', 'ON')")
+
+	if err := upgradeAutoMigrate(legacy); err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	if err := upgradeAutoMigrate(legacy); err != nil {
+		t.Fatalf("second upgrade: %v", err)
+	}
+
+	// baseline SQLite đã gồm các index MySQL tạo ở 0003, sau baseline
+	later := map[string]bool{
+		"idx_device_histories_device_created": true,
+		"idx_device_histories_tenant_created": true,
+		"idx_sensor_data_tenant_created":      true,
+	}
+	fresh, err := New(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fresh.To(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	for table := range tables {
+		columns := map[string]bool{}
+		types, err := legacy.Migrator().ColumnTypes(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, column := range types {
+			columns[column.Name()] = true
+		}
+		want, err := fresh.db.Migrator().ColumnTypes(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, column := range want {
+			if !columns[column.Name()] {
+				t.Errorf("%s.%s missing after upgrade", table, column.Name())
+			}
+		}
+		indexes, err := fresh.db.Migrator().GetIndexes(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, index := range indexes {
+			if !later[index.Name()] && !legacy.Migrator().HasIndex(table, index.Name()) {
+				t.Errorf("index %s on %s missing after upgrade", index.Name(), table)
+			}
+		}
+	}
+
+	var role string
+	legacy.Raw("SELECT role FROM users WHERE email = 'alice@example.com'").Scan(&role)
+	if role != "viewer" {
+		t.Errorf("existing user role = %q, want viewer", role)
+	}
+	var tenantID uint
+	if err := legacy.Raw("SELECT tenant_id FROM devices WHERE name = 'fan'").Scan(&tenantID).Error; err != nil || tenantID != 0 {
+		t.Errorf("existing device tenant_id = %d (%v), want 0 until 0002 assigns the default tenant", tenantID, err)
+	}
+}
@@ -0,0 +1,228 @@
+-- Schema tương đương AutoMigrate trước khi chuyển sang migration có version.
+-- IF NOT EXISTS để database đã tạo bằng AutoMigrate nhận baseline mà không mất dữ liệu; cột/index mà bảng cũ
+-- còn thiếu được thêm trong cùng version (upgradeAutoMigrate trong legacy.go).
+
+CREATE TABLE IF NOT EXISTS users (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  name varchar(100) NOT NULL,
+  email varchar(100) NOT NULL,
+  password varchar(255) NOT NULL,
+  role varchar(20) NOT NULL DEFAULT 'viewer',
+  two_factor_method varchar(10) NOT NULL DEFAULT '',
+  totp_secret varchar(64) NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT uni_users_email UNIQUE (email),
+  INDEX idx_users_deleted_at (deleted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS devices (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  name varchar(50) NULL,
+  status enum('ON','OFF') NOT NULL,
+  owner_id bigint unsigned NULL,
+  tenant_id bigint unsigned NOT NULL DEFAULT 0,
+  PRIMARY KEY (id),
+  INDEX idx_devices_deleted_at (deleted_at),
+  INDEX idx_devices_owner_id (owner_id),
+  INDEX idx_devices_tenant_id (tenant_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS sensor_data (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  temperature decimal(5,2) NOT NULL,
+  humidity decimal(5,2) NOT NULL,
+  light int NOT NULL,
+  tenant_id bigint unsigned NOT NULL DEFAULT 0,
+  fingerprint char(64) NULL,
+  PRIMARY KEY (id),
+  INDEX idx_sensor_data_deleted_at (deleted_at),
+  INDEX idx_sensor_data_tenant_id (tenant_id),
+  UNIQUE INDEX idx_sensor_data_fingerprint (tenant_id, fingerprint)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS device_histories (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  user_id bigint unsigned NOT NULL,
+  device_id varchar(50) NOT NULL,
+  user_change varchar(100) NOT NULL,
+  status enum('ON','OFF') NOT NULL,
+  tenant_id bigint unsigned NOT NULL DEFAULT 0,
+  fingerprint char(64) NULL,
+  PRIMARY KEY (id),
+  INDEX idx_device_histories_deleted_at (deleted_at),
+  INDEX idx_device_histories_tenant_id (tenant_id),
+  UNIQUE INDEX idx_device_histories_fingerprint (tenant_id, fingerprint)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS device_grants (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  device_id bigint unsigned NOT NULL,
+  user_id bigint unsigned NOT NULL,
+  permission enum('view','control') NOT NULL,
+  granted_by bigint unsigned NOT NULL,
+  PRIMARY KEY (id),
+  INDEX idx_device_grants_deleted_at (deleted_at),
+  UNIQUE INDEX idx_device_grant_device_user (device_id, user_id),
+  INDEX idx_device_grants_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  user_id bigint unsigned NOT NULL,
+  code_hash char(64) NOT NULL,
+  used_at datetime(3) NULL,
+  PRIMARY KEY (id),
+  INDEX idx_recovery_codes_deleted_at (deleted_at),
+  INDEX idx_recovery_codes_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS device_credentials (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  device_id bigint unsigned NOT NULL,
+  tenant_id bigint unsigned NOT NULL DEFAULT 0,
+  client_id varchar(64) NOT NULL,
+  secret_hash varchar(255) NULL,
+  claim_code_hash char(64) NULL,
+  claim_expires_at datetime(3) NULL,
+  rotated_at datetime(3) NULL,
+  revoked_at datetime(3) NULL,
+  PRIMARY KEY (id),
+  INDEX idx_device_credentials_deleted_at (deleted_at),
+  UNIQUE INDEX idx_device_credentials_device_id (device_id),
+  INDEX idx_device_credentials_tenant_id (tenant_id),
+  UNIQUE INDEX idx_device_credentials_client_id (client_id),
+  UNIQUE INDEX idx_device_credentials_claim_code_hash (claim_code_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS api_keys (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  user_id bigint unsigned NOT NULL,
+  tenant_id bigint unsigned NOT NULL DEFAULT 0,
+  name varchar(100) NOT NULL,
+  prefix varchar(16) NOT NULL,
+  key_hash char(64) NOT NULL,
+  scopes varchar(255) NOT NULL,
+  expires_at datetime(3) NULL,
+  last_used_at datetime(3) NULL,
+  last_used_ip varchar(45) NULL,
+  revoked_at datetime(3) NULL,
+  PRIMARY KEY (id),
+  INDEX idx_api_keys_deleted_at (deleted_at),
+  INDEX idx_api_keys_user_id (user_id),
+  UNIQUE INDEX idx_api_keys_prefix (prefix)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_identities (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  user_id bigint unsigned NOT NULL,
+  issuer varchar(255) NOT NULL,
+  subject varchar(255) NOT NULL,
+  email varchar(100) NULL,
+  last_login_at datetime(3) NULL,
+  PRIMARY KEY (id),
+  INDEX idx_user_identities_deleted_at (deleted_at),
+  INDEX idx_user_identities_user_id (user_id),
+  UNIQUE INDEX idx_identity_issuer_subject (issuer, subject)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS tenants (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  name varchar(100) NOT NULL,
+  slug varchar(64) NOT NULL,
+  PRIMARY KEY (id),
+  INDEX idx_tenants_deleted_at (deleted_at),
+  UNIQUE INDEX idx_tenants_slug (slug)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS tenant_memberships (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  deleted_at datetime(3) NULL,
+  tenant_id bigint unsigned NOT NULL,
+  user_id bigint unsigned NOT NULL,
+  role varchar(20) NOT NULL DEFAULT 'viewer',
+  PRIMARY KEY (id),
+  INDEX idx_tenant_memberships_deleted_at (deleted_at),
+  UNIQUE INDEX idx_membership_tenant_user (tenant_id, user_id),
+  INDEX idx_tenant_memberships_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NOT NULL,
+  tenant_id bigint unsigned NOT NULL DEFAULT 0,
+  actor_id bigint unsigned NOT NULL DEFAULT 0,
+  actor_email varchar(255) NULL,
+  api_key_id bigint unsigned NOT NULL DEFAULT 0,
+  action varchar(64) NOT NULL,
+  outcome varchar(16) NOT NULL,
+  target_type varchar(32) NULL,
+  target_id varchar(64) NULL,
+  ip varchar(45) NULL,
+  user_agent varchar(255) NULL,
+  details text NULL,
+  prev_hash char(64) NOT NULL,
+  hash char(64) NOT NULL,
+  PRIMARY KEY (id),
+  INDEX idx_audit_logs_created_at (created_at),
+  INDEX idx_audit_logs_tenant_id (tenant_id),
+  INDEX idx_audit_logs_actor_id (actor_id),
+  INDEX idx_audit_logs_action (action),
+  UNIQUE INDEX idx_audit_logs_prev_hash (prev_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS jobs (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  updated_at datetime(3) NULL,
+  tenant_id bigint unsigned NOT NULL DEFAULT 0,
+  user_id bigint unsigned NOT NULL,
+  kind varchar(32) NOT NULL,
+  status varchar(16) NOT NULL,
+  params text NULL,
+  result text NULL,
+  processed bigint NOT NULL DEFAULT 0,
+  total bigint NOT NULL DEFAULT 0,
+  file_path varchar(255) NULL,
+  file_name varchar(255) NULL,
+  file_size bigint NOT NULL DEFAULT 0,
+  error varchar(500) NULL,
+  finished_at datetime(3) NULL,
+  expires_at datetime(3) NULL,
+  PRIMARY KEY (id),
+  INDEX idx_jobs_tenant_id (tenant_id),
+  INDEX idx_jobs_user_id (user_id),
+  INDEX idx_jobs_status (status),
+  INDEX idx_jobs_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+-- Không tách lại được dữ liệu đã gom, tenant "default" và thành viên được giữ nguyên.
@@ -0,0 +1,18 @@
+-- Dữ liệu có từ trước khi tách tenant (tenant_id = 0) được gom vào tenant "default".
+-- Chỉ khi tenant này được tạo ở đây thì mọi user hiện có mới thành thành viên với role đang có.
+
+SET @create_default_tenant = (SELECT COUNT(*) = 0 FROM tenants WHERE slug = 'default');
+
+INSERT INTO tenants (name, slug, created_at, updated_at)
+SELECT 'Default', 'default', NOW(3), NOW(3) FROM DUAL WHERE @create_default_tenant;
+
+INSERT INTO tenant_memberships (tenant_id, user_id, role, created_at, updated_at)
+SELECT t.id, u.id, u.role, NOW(3), NOW(3)
+FROM users u JOIN tenants t ON t.slug = 'default'
+WHERE @create_default_tenant AND u.deleted_at IS NULL;
+
+UPDATE devices SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default') WHERE tenant_id = 0;
+UPDATE sensor_data SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default') WHERE tenant_id = 0;
+UPDATE device_histories SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default') WHERE tenant_id = 0;
+UPDATE device_credentials SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default') WHERE tenant_id = 0;
+UPDATE api_keys SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default') WHERE tenant_id = 0;
@@ -0,0 +1,5 @@
+DROP INDEX idx_sensor_data_tenant_created ON sensor_data;
+DROP INDEX idx_device_histories_tenant_created ON device_histories;
+DROP INDEX idx_device_histories_device_created ON device_histories;
+
+ALTER TABLE device_histories MODIFY device_id varchar(50) NOT NULL;
@@ -0,0 +1,10 @@
+-- device_id là id của devices (bigint unsigned) nhưng cột được tạo là varchar(50),
+-- so sánh với số làm MySQL ép kiểu từng dòng và không dùng được index.
+-- Dòng có device_id không phải số sẽ làm migration dừng lại để xử lý tay trước.
+
+ALTER TABLE device_histories MODIFY device_id bigint unsigned NOT NULL;
+
+-- lịch sử theo thiết bị và lọc theo khoảng thời gian trong từng tenant
+CREATE INDEX idx_device_histories_device_created ON device_histories (device_id, created_at);
+CREATE INDEX idx_device_histories_tenant_created ON device_histories (tenant_id, created_at);
+CREATE INDEX idx_sensor_data_tenant_created ON sensor_data (tenant_id, created_at);
@@ -0,0 +1,8 @@
+ALTER TABLE device_grants DROP CHECK chk_device_grants_permission;
+ALTER TABLE device_grants MODIFY permission enum('view','control') NOT NULL;
+
+ALTER TABLE device_histories DROP CHECK chk_device_histories_status;
+ALTER TABLE device_histories MODIFY status enum('ON','OFF') NOT NULL;
+
+ALTER TABLE devices DROP CHECK chk_devices_status;
+ALTER TABLE devices MODIFY status enum('ON','OFF') NOT NULL;
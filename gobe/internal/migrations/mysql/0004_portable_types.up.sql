@@ -0,0 +1,11 @@
+-- enum chỉ có trên MySQL, đổi sang varchar kèm CHECK để model dùng chung cho mọi dialect.
+-- CHECK được kiểm tra từ MySQL 8.0.16, bản cũ hơn chấp nhận cú pháp nhưng bỏ qua.
+
+ALTER TABLE devices MODIFY status varchar(3) NOT NULL;
+ALTER TABLE devices ADD CONSTRAINT chk_devices_status CHECK (status IN ('ON', 'OFF'));
+
+ALTER TABLE device_histories MODIFY status varchar(3) NOT NULL;
+ALTER TABLE device_histories ADD CONSTRAINT chk_device_histories_status CHECK (status IN ('ON', 'OFF'));
+
+ALTER TABLE device_grants MODIFY permission varchar(10) NOT NULL;
+ALTER TABLE device_grants ADD CONSTRAINT chk_device_grants_permission CHECK (permission IN ('view', 'control'));
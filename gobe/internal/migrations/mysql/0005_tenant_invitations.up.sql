@@ -0,0 +1,14 @@
+-- Thêm thành viên vào tenant thành lời mời, user được mời phải tự chấp nhận.
+
+CREATE TABLE IF NOT EXISTS tenant_invitations (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  created_at datetime(3) NULL,
+  tenant_id bigint unsigned NOT NULL,
+  user_id bigint unsigned NOT NULL,
+  role varchar(20) NOT NULL,
+  invited_by bigint unsigned NOT NULL,
+  expires_at datetime(3) NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE INDEX idx_invitation_tenant_user (tenant_id, user_id),
+  INDEX idx_tenant_invitations_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE devices MODIFY name varchar(50) NULL;
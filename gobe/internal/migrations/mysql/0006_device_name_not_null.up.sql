@@ -0,0 +1,4 @@
+-- Sort theo name dùng keyset (name, id), cột sort phải NOT NULL để so sánh với cursor không bỏ sót dòng.
+
+UPDATE devices SET name = '' WHERE name IS NULL;
+ALTER TABLE devices MODIFY name varchar(50) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+-- Hypertable không đổi ngược lại được thành bảng thường, chỉ bỏ ghi nhận version để up chạy lại được.
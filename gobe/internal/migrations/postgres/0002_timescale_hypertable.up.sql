@@ -0,0 +1,21 @@
+-- Có extension timescaledb (CREATE EXTENSION timescaledb do DBA chạy trước) thì sensor_data thành hypertable
+-- chia chunk theo created_at, không có thì migration này không làm gì. Cài extension sau khi đã chạy version này
+-- thì lùi một version rồi up lại.
+-- Hypertable yêu cầu mọi khóa unique chứa cột thời gian; fingerprint đã được tính từ created_at nên
+-- unique (tenant_id, fingerprint, created_at) vẫn chặn trùng như trước.
+
+DO $$
+BEGIN
+  -- IF lồng nhau: view timescaledb_information chỉ có khi đã cài extension
+  IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+    IF NOT EXISTS (SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'sensor_data') THEN
+      ALTER TABLE sensor_data ALTER COLUMN created_at SET NOT NULL;
+      ALTER TABLE sensor_data DROP CONSTRAINT sensor_data_pkey;
+      ALTER TABLE sensor_data ADD PRIMARY KEY (id, created_at);
+      DROP INDEX idx_sensor_data_fingerprint;
+      CREATE UNIQUE INDEX idx_sensor_data_fingerprint ON sensor_data (tenant_id, fingerprint, created_at);
+      PERFORM create_hypertable('sensor_data', 'created_at', migrate_data => true);
+    END IF;
+  END IF;
+END
+$$;
@@ -0,0 +1,13 @@
+-- Thêm thành viên vào tenant thành lời mời, user được mời phải tự chấp nhận.
+
+CREATE TABLE tenant_invitations (
+  id bigserial PRIMARY KEY,
+  created_at timestamptz(3) NULL,
+  tenant_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  role varchar(20) NOT NULL,
+  invited_by bigint NOT NULL,
+  expires_at timestamptz(3) NOT NULL
+);
+CREATE UNIQUE INDEX idx_invitation_tenant_user ON tenant_invitations (tenant_id, user_id);
+CREATE INDEX idx_tenant_invitations_user_id ON tenant_invitations (user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE devices ALTER COLUMN name DROP NOT NULL;
+ALTER TABLE devices ALTER COLUMN name DROP DEFAULT;
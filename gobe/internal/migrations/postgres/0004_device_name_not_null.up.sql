@@ -0,0 +1,5 @@
+-- Sort theo name dùng keyset (name, id), cột sort phải NOT NULL để so sánh với cursor không bỏ sót dòng.
+
+UPDATE devices SET name = '' WHERE name IS NULL;
+ALTER TABLE devices ALTER COLUMN name SET DEFAULT '';
+ALTER TABLE devices ALTER COLUMN name SET NOT NULL;
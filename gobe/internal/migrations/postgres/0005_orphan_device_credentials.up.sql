@@ -0,0 +1,3 @@
+-- Trước đây xóa device không xóa credential nên client ID của board đã xóa vẫn đăng nhập được broker.
+
+DELETE FROM device_credentials WHERE NOT EXISTS (SELECT 1 FROM devices WHERE devices.id = device_credentials.device_id);
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS jobs;
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS tenant_memberships;
+DROP TABLE IF EXISTS tenants;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS device_credentials;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS device_grants;
+DROP TABLE IF EXISTS device_histories;
+DROP TABLE IF EXISTS sensor_data;
+DROP TABLE IF EXISTS devices;
+DROP TABLE IF EXISTS users;
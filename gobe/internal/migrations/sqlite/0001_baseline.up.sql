@@ -0,0 +1,224 @@
+-- Schema tương đương MySQL ở version 4. Kiểu cột chỉ là affinity với SQLite, thời gian lưu dạng text
+-- theo định dạng của driver, varchar kèm CHECK thay cho enum.
+
+CREATE TABLE users (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  name varchar(100) NOT NULL,
+  email varchar(100) NOT NULL,
+  password varchar(255) NOT NULL,
+  role varchar(20) NOT NULL DEFAULT 'viewer',
+  two_factor_method varchar(10) NOT NULL DEFAULT '',
+  totp_secret varchar(64) NULL,
+  CONSTRAINT uni_users_email UNIQUE (email)
+);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE devices (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  name varchar(50) NULL,
+  status varchar(3) NOT NULL CONSTRAINT chk_devices_status CHECK (status IN ('ON', 'OFF')),
+  owner_id bigint NULL,
+  tenant_id bigint NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_devices_deleted_at ON devices (deleted_at);
+CREATE INDEX idx_devices_owner_id ON devices (owner_id);
+CREATE INDEX idx_devices_tenant_id ON devices (tenant_id);
+
+CREATE TABLE sensor_data (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  temperature decimal(5,2) NOT NULL,
+  humidity decimal(5,2) NOT NULL,
+  light integer NOT NULL,
+  tenant_id bigint NOT NULL DEFAULT 0,
+  fingerprint char(64) NULL
+);
+CREATE INDEX idx_sensor_data_deleted_at ON sensor_data (deleted_at);
+CREATE INDEX idx_sensor_data_tenant_id ON sensor_data (tenant_id);
+CREATE UNIQUE INDEX idx_sensor_data_fingerprint ON sensor_data (tenant_id, fingerprint);
+CREATE INDEX idx_sensor_data_tenant_created ON sensor_data (tenant_id, created_at);
+
+CREATE TABLE device_histories (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  user_id bigint NOT NULL,
+  device_id bigint NOT NULL,
+  user_change varchar(100) NOT NULL,
+  status varchar(3) NOT NULL CONSTRAINT chk_device_histories_status CHECK (status IN ('ON', 'OFF')),
+  tenant_id bigint NOT NULL DEFAULT 0,
+  fingerprint char(64) NULL
+);
+CREATE INDEX idx_device_histories_deleted_at ON device_histories (deleted_at);
+CREATE INDEX idx_device_histories_tenant_id ON device_histories (tenant_id);
+CREATE UNIQUE INDEX idx_device_histories_fingerprint ON device_histories (tenant_id, fingerprint);
+CREATE INDEX idx_device_histories_device_created ON device_histories (device_id, created_at);
+CREATE INDEX idx_device_histories_tenant_created ON device_histories (tenant_id, created_at);
+
+CREATE TABLE device_grants (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  device_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  permission varchar(10) NOT NULL CONSTRAINT chk_device_grants_permission CHECK (permission IN ('view', 'control')),
+  granted_by bigint NOT NULL
+);
+CREATE INDEX idx_device_grants_deleted_at ON device_grants (deleted_at);
+CREATE UNIQUE INDEX idx_device_grant_device_user ON device_grants (device_id, user_id);
+CREATE INDEX idx_device_grants_user_id ON device_grants (user_id);
+
+CREATE TABLE recovery_codes (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  user_id bigint NOT NULL,
+  code_hash char(64) NOT NULL,
+  used_at datetime NULL
+);
+CREATE INDEX idx_recovery_codes_deleted_at ON recovery_codes (deleted_at);
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE device_credentials (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  device_id bigint NOT NULL,
+  tenant_id bigint NOT NULL DEFAULT 0,
+  client_id varchar(64) NOT NULL,
+  secret_hash varchar(255) NULL,
+  claim_code_hash char(64) NULL,
+  claim_expires_at datetime NULL,
+  rotated_at datetime NULL,
+  revoked_at datetime NULL
+);
+CREATE INDEX idx_device_credentials_deleted_at ON device_credentials (deleted_at);
+CREATE UNIQUE INDEX idx_device_credentials_device_id ON device_credentials (device_id);
+CREATE INDEX idx_device_credentials_tenant_id ON device_credentials (tenant_id);
+CREATE UNIQUE INDEX idx_device_credentials_client_id ON device_credentials (client_id);
+CREATE UNIQUE INDEX idx_device_credentials_claim_code_hash ON device_credentials (claim_code_hash);
+
+CREATE TABLE api_keys (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  user_id bigint NOT NULL,
+  tenant_id bigint NOT NULL DEFAULT 0,
+  name varchar(100) NOT NULL,
+  prefix varchar(16) NOT NULL,
+  key_hash char(64) NOT NULL,
+  scopes varchar(255) NOT NULL,
+  expires_at datetime NULL,
+  last_used_at datetime NULL,
+  last_used_ip varchar(45) NULL,
+  revoked_at datetime NULL
+);
+CREATE INDEX idx_api_keys_deleted_at ON api_keys (deleted_at);
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
+CREATE UNIQUE INDEX idx_api_keys_prefix ON api_keys (prefix);
+
+CREATE TABLE user_identities (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  user_id bigint NOT NULL,
+  issuer varchar(255) NOT NULL,
+  subject varchar(255) NOT NULL,
+  email varchar(100) NULL,
+  last_login_at datetime NULL
+);
+CREATE INDEX idx_user_identities_deleted_at ON user_identities (deleted_at);
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+CREATE UNIQUE INDEX idx_identity_issuer_subject ON user_identities (issuer, subject);
+
+CREATE TABLE tenants (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  name varchar(100) NOT NULL,
+  slug varchar(64) NOT NULL
+);
+CREATE INDEX idx_tenants_deleted_at ON tenants (deleted_at);
+CREATE UNIQUE INDEX idx_tenants_slug ON tenants (slug);
+
+CREATE TABLE tenant_memberships (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  tenant_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  role varchar(20) NOT NULL DEFAULT 'viewer'
+);
+CREATE INDEX idx_tenant_memberships_deleted_at ON tenant_memberships (deleted_at);
+CREATE UNIQUE INDEX idx_membership_tenant_user ON tenant_memberships (tenant_id, user_id);
+CREATE INDEX idx_tenant_memberships_user_id ON tenant_memberships (user_id);
+
+CREATE TABLE audit_logs (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NOT NULL,
+  tenant_id bigint NOT NULL DEFAULT 0,
+  actor_id bigint NOT NULL DEFAULT 0,
+  actor_email varchar(255) NULL,
+  api_key_id bigint NOT NULL DEFAULT 0,
+  action varchar(64) NOT NULL,
+  outcome varchar(16) NOT NULL,
+  target_type varchar(32) NULL,
+  target_id varchar(64) NULL,
+  ip varchar(45) NULL,
+  user_agent varchar(255) NULL,
+  details text NULL,
+  prev_hash char(64) NOT NULL,
+  hash char(64) NOT NULL
+);
+CREATE INDEX idx_audit_logs_created_at ON audit_logs (created_at);
+CREATE INDEX idx_audit_logs_tenant_id ON audit_logs (tenant_id);
+CREATE INDEX idx_audit_logs_actor_id ON audit_logs (actor_id);
+CREATE INDEX idx_audit_logs_action ON audit_logs (action);
+CREATE UNIQUE INDEX idx_audit_logs_prev_hash ON audit_logs (prev_hash);
+
+-- audit_logs chỉ được thêm, chặn ngay ở database như trigger của MySQL
+CREATE TRIGGER audit_logs_no_update BEFORE UPDATE ON audit_logs BEGIN SELECT RAISE(ABORT, 'audit_logs is append-only'); END;
+CREATE TRIGGER audit_logs_no_delete BEFORE DELETE ON audit_logs BEGIN SELECT RAISE(ABORT, 'audit_logs is append-only'); END;
+
+CREATE TABLE jobs (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  tenant_id bigint NOT NULL DEFAULT 0,
+  user_id bigint NOT NULL,
+  kind varchar(32) NOT NULL,
+  status varchar(16) NOT NULL,
+  params text NULL,
+  result text NULL,
+  processed bigint NOT NULL DEFAULT 0,
+  total bigint NOT NULL DEFAULT 0,
+  file_path varchar(255) NULL,
+  file_name varchar(255) NULL,
+  file_size bigint NOT NULL DEFAULT 0,
+  error varchar(500) NULL,
+  finished_at datetime NULL,
+  expires_at datetime NULL
+);
+CREATE INDEX idx_jobs_tenant_id ON jobs (tenant_id);
+CREATE INDEX idx_jobs_user_id ON jobs (user_id);
+CREATE INDEX idx_jobs_status ON jobs (status);
+CREATE INDEX idx_jobs_expires_at ON jobs (expires_at);
+
+-- tenant cho board dùng topic MQTT cũ
+INSERT INTO tenants (name, slug, created_at, updated_at) VALUES ('Default', 'default', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
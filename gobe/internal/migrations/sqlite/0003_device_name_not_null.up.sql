@@ -0,0 +1,20 @@
+-- Sort theo name dùng keyset (name, id), cột sort phải NOT NULL để so sánh với cursor không bỏ sót dòng.
+-- SQLite không đổi được ràng buộc của cột nên dựng lại bảng.
+
+CREATE TABLE devices_new (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  created_at datetime NULL,
+  updated_at datetime NULL,
+  deleted_at datetime NULL,
+  name varchar(50) NOT NULL DEFAULT '',
+  status varchar(3) NOT NULL CONSTRAINT chk_devices_status CHECK (status IN ('ON', 'OFF')),
+  owner_id bigint NULL,
+  tenant_id bigint NOT NULL DEFAULT 0
+);
+INSERT INTO devices_new (id, created_at, updated_at, deleted_at, name, status, owner_id, tenant_id)
+  SELECT id, created_at, updated_at, deleted_at, COALESCE(name, ''), status, owner_id, tenant_id FROM devices;
+DROP TABLE devices;
+ALTER TABLE devices_new RENAME TO devices;
+CREATE INDEX idx_devices_deleted_at ON devices (deleted_at);
+CREATE INDEX idx_devices_owner_id ON devices (owner_id);
+CREATE INDEX idx_devices_tenant_id ON devices (tenant_id);
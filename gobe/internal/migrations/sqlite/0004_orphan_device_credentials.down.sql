@@ -0,0 +1 @@
+-- Credential đã xóa không khôi phục lại được, down không làm gì.
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scope của API key, quyền thực tế là giao của scope và role hiện tại của chủ key
+const (
+	ScopeTelemetryRead = "telemetry:read"
+	ScopeDeviceControl = "device:control"
+	ScopeAdmin         = "admin"
+
+	// APIKeyTokenPrefix - key dạng iotk_<prefix 12 hex>_<secret 64 hex>
+	APIKeyTokenPrefix = "iotk_"
+)
+
+// APIKey - key cho script/tích hợp máy-máy. Chỉ lưu SHA-256 của key,
+// prefix lưu rõ để tìm nhanh và để user nhận ra key trong danh sách.
+type APIKey struct {
+	gorm.Model
+	UserID     uint       `gorm:"column:user_id;not null;index" json:"user_id"`
+	TenantID   uint       `gorm:"column:tenant_id;not null;default:0" json:"tenant_id"`
+	Name       string     `gorm:"column:name;type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"column:prefix;type:varchar(16);not null;uniqueIndex" json:"prefix"`
+	KeyHash    string     `gorm:"column:key_hash;type:char(64);not null" json:"-"`
+	Scopes     string     `gorm:"column:scopes;type:varchar(255);not null" json:"scopes"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at" json:"last_used_at,omitempty"`
+	LastUsedIP string     `gorm:"column:last_used_ip;type:varchar(45)" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList - scopes lưu dạng "a,b"
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// Active - chưa bị thu hồi và chưa hết hạn tại thời điểm now
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Action của audit log, dạng <nhóm>.<hành động>
+const (
+	AuditLogin            = "auth.login"
+	AuditLoginTwoFactor   = "auth.login_2fa"
+	AuditLoginOIDC        = "auth.login_oidc"
+	AuditTokenRefresh     = "auth.token_refresh"
+	AuditTenantSwitch     = "auth.tenant_switch"
+	AuditUserUpdate       = "user.update"
+	AuditUserDelete       = "user.delete"
+	AuditRoleAssign       = "user.role_assign"
+	AuditMemberInvite     = "tenant.member_invite"
+	AuditMemberAdd        = "tenant.member_add"
+	AuditMemberRemove     = "tenant.member_remove"
+	AuditDeviceCreate     = "device.create"
+	AuditDeviceUpdate     = "device.update"
+	AuditDeviceDelete     = "device.delete"
+	AuditDeviceControl    = "device.control"
+	AuditDeviceShare      = "device.share"
+	AuditDeviceUnshare    = "device.unshare"
+	AuditCredentialIssue  = "device.credential_issue"
+	AuditCredentialRotate = "device.credential_rotate"
+	AuditCredentialRevoke = "device.credential_revoke"
+	AuditAPIKeyCreate     = "api_key.create"
+	AuditAPIKeyRevoke     = "api_key.revoke"
+)
+
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+	// AuditChallenge - đăng nhập đúng mật khẩu nhưng còn chờ bước 2FA
+	AuditChallenge = "challenge"
+)
+
+// AuditLog - bản ghi chỉ thêm, không sửa/xóa. Mỗi bản ghi giữ hash của bản ghi trước nên
+// sửa, xóa hay chèn một dòng đều làm đứt chuỗi khi verify. prev_hash unique để chuỗi không rẽ nhánh.
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"column:created_at;not null;index"`
+	TenantID   uint      `gorm:"column:tenant_id;not null;default:0;index"`
+	ActorID    uint      `gorm:"column:actor_id;not null;default:0;index"` // 0 = chưa xác thực (đăng nhập sai...)
+	ActorEmail string    `gorm:"column:actor_email;type:varchar(255)"`
+	APIKeyID   uint      `gorm:"column:api_key_id;not null;default:0"`
+	Action     string    `gorm:"column:action;type:varchar(64);not null;index"`
+	Outcome    string    `gorm:"column:outcome;type:varchar(16);not null"`
+	TargetType string    `gorm:"column:target_type;type:varchar(32)"`
+	TargetID   string    `gorm:"column:target_id;type:varchar(64)"`
+	IP         string    `gorm:"column:ip;type:varchar(45)"`
+	UserAgent  string    `gorm:"column:user_agent;type:varchar(255)"`
+	Details    string    `gorm:"column:details;type:text"` // JSON
+	PrevHash   string    `gorm:"column:prev_hash;type:char(64);not null;uniqueIndex"`
+	Hash       string    `gorm:"column:hash;type:char(64);not null"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// ComputeHash - SHA-256 của PrevHash và mọi field nội dung, mỗi field có tiền tố độ dài để không ghép nhập nhằng.
+// CreatedAt tính theo UTC, độ chính xác mili giây đúng với cột thời gian của migration (datetime(3), timestamptz(3)).
+func (l *AuditLog) ComputeHash() string {
+	fields := []string{
+		l.PrevHash,
+		l.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		strconv.FormatUint(uint64(l.TenantID), 10),
+		strconv.FormatUint(uint64(l.ActorID), 10),
+		l.ActorEmail,
+		strconv.FormatUint(uint64(l.APIKeyID), 10),
+		l.Action,
+		l.Outcome,
+		l.TargetType,
+		l.TargetID,
+		l.IP,
+		l.UserAgent,
+		l.Details,
+	}
+	h := sha256.New()
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditLogComputeHash(t *testing.T) {
+	base := AuditLog{
+		CreatedAt:  time.Date(2025, 10, 1, 8, 30, 0, 123_000_000, time.UTC),
+		TenantID:   1,
+		ActorID:    2,
+		ActorEmail: "alice@example.com",
+		Action:     AuditLogin,
+		Outcome:    "success",
+		TargetType: "user",
+		TargetID:   "2",
+		IP:         "10.0.0.1",
+		UserAgent:  "curl/8",
+		Details:    `{"method":"password"}`,
+		PrevHash:   "abc",
+	}
+	want := base.ComputeHash()
+
+	tests := []struct {
+		name   string
+		change func(*AuditLog)
+		same   bool
+	}{
+		{"other time zone", func(l *AuditLog) { l.CreatedAt = l.CreatedAt.In(time.FixedZone("ICT", 7*3600)) }, true},
+		{"below millisecond", func(l *AuditLog) { l.CreatedAt = l.CreatedAt.Add(400 * time.Microsecond) }, true},
+		{"id and stored hash are not content", func(l *AuditLog) { l.ID, l.Hash = 9, "x" }, true},
+		{"prev hash", func(l *AuditLog) { l.PrevHash = "abd" }, false},
+		{"created at", func(l *AuditLog) { l.CreatedAt = l.CreatedAt.Add(time.Millisecond) }, false},
+		{"tenant", func(l *AuditLog) { l.TenantID = 3 }, false},
+		{"actor", func(l *AuditLog) { l.ActorID = 3 }, false},
+		{"actor email", func(l *AuditLog) { l.ActorEmail = "bob@example.com" }, false},
+		{"api key", func(l *AuditLog) { l.APIKeyID = 1 }, false},
+		{"action", func(l *AuditLog) { l.Action = AuditTokenRefresh }, false},
+		{"outcome", func(l *AuditLog) { l.Outcome = "failure" }, false},
+		{"target type", func(l *AuditLog) { l.TargetType = "device" }, false},
+		{"target id", func(l *AuditLog) { l.TargetID = "3" }, false},
+		{"ip", func(l *AuditLog) { l.IP = "10.0.0.2" }, false},
+		{"user agent", func(l *AuditLog) { l.UserAgent = "curl/9" }, false},
+		{"details", func(l *AuditLog) { l.Details = `{"method":"totp"}` }, false},
+		// độ dài đứng trước mỗi field nên dời ký tự sang field bên cạnh vẫn ra hash khác
+		{"shifted between fields", func(l *AuditLog) { l.TargetType, l.TargetID = "user2", "" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := base
+			tt.change(&entry)
+			got := entry.ComputeHash()
+			if (got == want) != tt.same {
+				t.Errorf("hash changed = %v, want %v", got != want, !tt.same)
+			}
+		})
+	}
+}
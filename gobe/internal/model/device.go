@@ -6,8 +6,10 @@ import (
 
 type Device struct {
 	gorm.Model
-	Name   string `gorm:"column:name;type:varchar(50);notnull" json:"name"`
-	Status string `gorm:"column:status;type:enum('ON','OFF');not null" json:"status"`
+	Name     string `gorm:"column:name;type:varchar(50);not null" json:"name"`
+	Status   string `gorm:"column:status;type:varchar(3);not null" json:"status"`
+	OwnerID  uint   `gorm:"column:owner_id;index" json:"owner_id"`
+	TenantID uint   `gorm:"column:tenant_id;not null;default:0;index" json:"tenant_id"`
 }
 
 func (Device) TableName() string {
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeviceCredential - thông tin đăng nhập MQTT riêng của từng board.
+// Secret lưu bằng bcrypt, claim code lưu bằng SHA-256 và chỉ dùng được một lần.
+type DeviceCredential struct {
+	gorm.Model
+	DeviceID       uint       `gorm:"column:device_id;not null;uniqueIndex" json:"device_id"`
+	TenantID       uint       `gorm:"column:tenant_id;not null;default:0;index" json:"tenant_id"`
+	ClientID       string     `gorm:"column:client_id;type:varchar(64);not null;uniqueIndex" json:"client_id"`
+	SecretHash     string     `gorm:"column:secret_hash;type:varchar(255)" json:"-"`
+	ClaimCodeHash  *string    `gorm:"column:claim_code_hash;type:char(64);uniqueIndex" json:"-"`
+	ClaimExpiresAt *time.Time `gorm:"column:claim_expires_at" json:"claim_expires_at,omitempty"`
+	RotatedAt      *time.Time `gorm:"column:rotated_at" json:"rotated_at,omitempty"`
+	RevokedAt      *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+}
+
+func (DeviceCredential) TableName() string {
+	return "device_credentials"
+}
+
+// Active - đã có secret và chưa bị thu hồi
+func (c *DeviceCredential) Active() bool {
+	return c.RevokedAt == nil && c.SecretHash != ""
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	GrantView    = "view"
+	GrantControl = "control"
+)
+
+// DeviceGrant - quyền chủ device chia sẻ cho user khác, control bao gồm view
+type DeviceGrant struct {
+	gorm.Model
+	DeviceID   uint   `gorm:"column:device_id;not null;uniqueIndex:idx_device_grant_device_user" json:"device_id"`
+	UserID     uint   `gorm:"column:user_id;not null;uniqueIndex:idx_device_grant_device_user;index" json:"user_id"`
+	Permission string `gorm:"column:permission;type:varchar(10);not null" json:"permission"`
+	GrantedBy  uint   `gorm:"column:granted_by;not null" json:"granted_by"`
+}
+
+func (DeviceGrant) TableName() string {
+	return "device_grants"
+}
@@ -7,9 +7,12 @@ import (
 type DeviceHistory struct {
 	gorm.Model
 	UserID     uint   `gorm:"column:user_id;not null"`
-	DeviceID   uint   `gorm:"column:device_id;type:varchar(50);not null"`
+	DeviceID   uint   `gorm:"column:device_id;not null"`
 	UserChange string `gorm:"column:user_change;type:varchar(100);not null"`
-	Status     string `gorm:"column:status;type:enum('ON','OFF');not null"`
+	Status     string `gorm:"column:status;type:varchar(3);not null"`
+	TenantID   uint   `gorm:"column:tenant_id;not null;default:0;index;uniqueIndex:idx_device_histories_fingerprint,priority:1"`
+	// Fingerprint - chỉ có ở dòng import, import lại cùng file không tạo bản ghi trùng
+	Fingerprint *string `gorm:"column:fingerprint;type:char(64);uniqueIndex:idx_device_histories_fingerprint,priority:2" json:"-"`
 }
 
 func (DeviceHistory) TableName() string {
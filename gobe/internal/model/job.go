@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Loại job nền, dạng <việc>.<bảng>
+const (
+	JobExportSensorData    = "export.sensor_data"
+	JobExportDeviceHistory = "export.device_history"
+	JobImportSensorData    = "import.sensor_data"
+	JobImportDeviceHistory = "import.device_history"
+)
+
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job - việc chạy nền của một user trong tenant. Params/Result là JSON tùy theo Kind,
+// FilePath là file kết quả trên máy chủ (export). Job đã xong bị xóa cùng file khi quá ExpiresAt.
+type Job struct {
+	ID         uint       `gorm:"primaryKey"`
+	CreatedAt  time.Time  `gorm:"column:created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at"`
+	TenantID   uint       `gorm:"column:tenant_id;not null;default:0;index"`
+	UserID     uint       `gorm:"column:user_id;not null;index"`
+	Kind       string     `gorm:"column:kind;type:varchar(32);not null"`
+	Status     string     `gorm:"column:status;type:varchar(16);not null;index"`
+	Params     string     `gorm:"column:params;type:text"`
+	Result     string     `gorm:"column:result;type:text"`
+	Processed  int64      `gorm:"column:processed;not null;default:0"`
+	Total      int64      `gorm:"column:total;not null;default:0"`
+	FilePath   string     `gorm:"column:file_path;type:varchar(255)"`
+	FileName   string     `gorm:"column:file_name;type:varchar(255)"`
+	FileSize   int64      `gorm:"column:file_size;not null;default:0"`
+	Error      string     `gorm:"column:error;type:varchar(500)"`
+	FinishedAt *time.Time `gorm:"column:finished_at"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at;index"`
+}
+
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// Finished - job đã xong (thành công hoặc lỗi), không còn thay đổi
+func (j *Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode - mã dự phòng 2FA, chỉ lưu hash, mỗi mã dùng được một lần
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint       `gorm:"column:user_id;not null;index"`
+	CodeHash string     `gorm:"column:code_hash;type:char(64);not null"`
+	UsedAt   *time.Time `gorm:"column:used_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// SensorBucket - sensor_data gộp theo khoảng thời gian, kết quả truy vấn chứ không phải bảng
+type SensorBucket struct {
+	Start          time.Time `json:"start"`
+	Count          int64     `json:"count"`
+	AvgTemperature float64   `json:"avg_temperature"`
+	MinTemperature float64   `json:"min_temperature"`
+	MaxTemperature float64   `json:"max_temperature"`
+	AvgHumidity    float64   `json:"avg_humidity"`
+	MinHumidity    float64   `json:"min_humidity"`
+	MaxHumidity    float64   `json:"max_humidity"`
+	AvgLight       float64   `json:"avg_light"`
+	MinLight       int       `json:"min_light"`
+	MaxLight       int       `json:"max_light"`
+}
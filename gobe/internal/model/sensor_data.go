@@ -9,6 +9,9 @@ type SensorData struct {
 	Temperature float64 `gorm:"column:temperature;type:decimal(5,2);not null"`
 	Humidity    float64 `gorm:"column:humidity;type:decimal(5,2);not null"`
 	Light       int     `gorm:"column:light;type:int;not null"`
+	TenantID    uint    `gorm:"column:tenant_id;not null;default:0;index;uniqueIndex:idx_sensor_data_fingerprint,priority:1"`
+	// Fingerprint - chỉ có ở dòng import, import lại cùng file không tạo bản ghi trùng
+	Fingerprint *string `gorm:"column:fingerprint;type:char(64);uniqueIndex:idx_sensor_data_fingerprint,priority:2" json:"-"`
 }
 
 func (SensorData) TableName() string {
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTenantSlug - tenant chứa dữ liệu có từ trước khi tách tenant và board dùng topic MQTT cũ
+const DefaultTenantSlug = "default"
+
+// Tenant - một nhà/site, device và dữ liệu sensor thuộc về đúng một tenant
+type Tenant struct {
+	gorm.Model
+	Name string `gorm:"column:name;type:varchar(100);not null" json:"name"`
+	Slug string `gorm:"column:slug;type:varchar(64);not null;uniqueIndex" json:"slug"`
+}
+
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// TenantMembership - user thuộc tenant với role (admin/operator/viewer) riêng trong tenant đó
+type TenantMembership struct {
+	gorm.Model
+	TenantID uint   `gorm:"column:tenant_id;not null;uniqueIndex:idx_membership_tenant_user" json:"tenant_id"`
+	UserID   uint   `gorm:"column:user_id;not null;uniqueIndex:idx_membership_tenant_user;index" json:"user_id"`
+	Role     string `gorm:"column:role;type:varchar(20);not null;default:viewer" json:"role"`
+}
+
+func (TenantMembership) TableName() string {
+	return "tenant_memberships"
+}
+
+// TenantInvitation - lời mời vào tenant, chỉ thành membership khi chính user được mời chấp nhận
+type TenantInvitation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	TenantID  uint      `gorm:"column:tenant_id;not null;uniqueIndex:idx_invitation_tenant_user" json:"tenant_id"`
+	UserID    uint      `gorm:"column:user_id;not null;uniqueIndex:idx_invitation_tenant_user;index" json:"user_id"`
+	Role      string    `gorm:"column:role;type:varchar(20);not null" json:"role"`
+	InvitedBy uint      `gorm:"column:invited_by;not null" json:"invited_by"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null" json:"expires_at"`
+}
+
+func (TenantInvitation) TableName() string {
+	return "tenant_invitations"
+}
@@ -4,13 +4,39 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
+// Phương thức xác thực hai lớp, rỗng nghĩa là chưa bật
+const (
+	TwoFactorNone  = ""
+	TwoFactorEmail = "email"
+	TwoFactorTOTP  = "totp"
+)
+
 type User struct {
 	gorm.Model
-	Name          string `gorm:"column:name;type:varchar(100);not null"`
-	Email         string `gorm:"column:email;type:varchar(100);not null;unique"`
-	Password      string `gorm:"column:password;type:varchar(255);not null"`
+	Name     string `gorm:"column:name;type:varchar(100);not null"`
+	Email    string `gorm:"column:email;type:varchar(100);not null;unique"`
+	Password string `gorm:"column:password;type:varchar(255);not null" json:"-"`
+	// Role - role cấp hệ thống, chỉ admin có ý nghĩa (vào được mọi tenant); quyền trong tenant lấy từ TenantMembership
+	Role string `gorm:"column:role;type:varchar(20);not null;default:viewer"`
+
+	TwoFactorMethod string `gorm:"column:two_factor_method;type:varchar(10);not null;default:''"`
+	TOTPSecret      string `gorm:"column:totp_secret;type:varchar(64)" json:"-"`
 }
 
 func (User) TableName() string {
 	return "users"
 }
+
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleOperator, RoleViewer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentity - liên kết user với tài khoản ở OIDC provider, khóa là cặp (issuer, subject)
+type UserIdentity struct {
+	gorm.Model
+	UserID      uint       `gorm:"column:user_id;not null;index" json:"user_id"`
+	Issuer      string     `gorm:"column:issuer;type:varchar(255);not null;uniqueIndex:idx_identity_issuer_subject" json:"issuer"`
+	Subject     string     `gorm:"column:subject;type:varchar(255);not null;uniqueIndex:idx_identity_issuer_subject" json:"subject"`
+	Email       string     `gorm:"column:email;type:varchar(100)" json:"email"`
+	LastLoginAt *time.Time `gorm:"column:last_login_at" json:"last_login_at,omitempty"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
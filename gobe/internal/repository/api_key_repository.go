@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"iot/internal/model"
+	"iot/internal/tenant"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyRepositoryInterface interface {
+	CreateAPIKey(db *gorm.DB, key *model.APIKey) error
+	GetByPrefix(db *gorm.DB, prefix string) (*model.APIKey, error)
+	ListByUser(db *gorm.DB, userID uint) ([]model.APIKey, error)
+	CountActive(db *gorm.DB, userID uint, now time.Time) (int64, error)
+	Revoke(db *gorm.DB, userID, id uint, now time.Time) (bool, error)
+	TouchLastUsed(db *gorm.DB, id uint, ip string, now time.Time, interval time.Duration) error
+}
+
+type APIKeyRepository struct{}
+
+func NewAPIKeyRepository() APIKeyRepositoryInterface {
+	return &APIKeyRepository{}
+}
+
+// CreateAPIKey - key gắn với tenant đang chọn, chỉ dùng được trong tenant đó
+func (r *APIKeyRepository) CreateAPIKey(db *gorm.DB, key *model.APIKey) error {
+	if err := tenant.Stamp(db, &key.TenantID); err != nil {
+		return err
+	}
+	return db.Create(key).Error
+}
+
+func (r *APIKeyRepository) GetByPrefix(db *gorm.DB, prefix string) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := db.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListByUser - gồm cả key đã revoke/hết hạn để user xem lịch sử
+func (r *APIKeyRepository) ListByUser(db *gorm.DB, userID uint) ([]model.APIKey, error) {
+	scoped, err := tenant.Scope(db, "api_keys")
+	if err != nil {
+		return nil, err
+	}
+	var keys []model.APIKey
+	err = scoped.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *APIKeyRepository) CountActive(db *gorm.DB, userID uint, now time.Time) (int64, error) {
+	scoped, err := tenant.Scope(db, "api_keys")
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = scoped.Model(&model.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, now).
+		Count(&count).Error
+	return count, err
+}
+
+// Revoke - false nếu key không thuộc user hoặc đã revoke từ trước
+func (r *APIKeyRepository) Revoke(db *gorm.DB, userID, id uint, now time.Time) (bool, error) {
+	scoped, err := tenant.Scope(db, "api_keys")
+	if err != nil {
+		return false, err
+	}
+	result := scoped.Model(&model.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// TouchLastUsed - chỉ ghi khi lần dùng trước cũ hơn interval để không update DB mỗi request
+func (r *APIKeyRepository) TouchLastUsed(db *gorm.DB, id uint, ip string, now time.Time, interval time.Duration) error {
+	return db.Model(&model.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-interval)).
+		Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		}).Error
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/tenant"
+
+	"gorm.io/gorm"
+)
+
+// AuditLogRepositoryInterface - chỉ có thêm và đọc, audit log không được sửa/xóa
+type AuditLogRepositoryInterface interface {
+	Append(db *gorm.DB, entry *model.AuditLog) error
+	List(db *gorm.DB, query *dto.AuditLogQuery) ([]model.AuditLog, int64, error)
+	Each(db *gorm.DB, query *dto.AuditLogQuery, fn func([]model.AuditLog) error) error
+	EachInChain(db *gorm.DB, fn func([]model.AuditLog) error) error
+}
+
+type AuditLogRepository struct{}
+
+func NewAuditLogRepository() AuditLogRepositoryInterface {
+	return &AuditLogRepository{}
+}
+
+const auditBatchSize = 500
+
+// Append - nối entry vào cuối chuỗi: lấy hash của bản ghi mới nhất làm PrevHash rồi tính Hash.
+// Hai tiến trình ghi cùng lúc sẽ trùng prev_hash và một bên nhận ErrDuplicatedKey.
+func (r *AuditLogRepository) Append(db *gorm.DB, entry *model.AuditLog) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var last model.AuditLog
+		err := tx.Select("hash").Order("id DESC").Take(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		entry.PrevHash = last.Hash
+		entry.Hash = entry.ComputeHash()
+		return tx.Create(entry).Error
+	})
+}
+
+func (r *AuditLogRepository) List(db *gorm.DB, query *dto.AuditLogQuery) ([]model.AuditLog, int64, error) {
+	filtered, err := filterAuditLogs(db, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := filtered.Model(&model.AuditLog{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []model.AuditLog
+	err = filtered.Order("audit_logs.id DESC").Limit(query.Limit).Offset(query.Offset).Find(&logs).Error
+	return logs, total, err
+}
+
+// Each - đọc theo lô để export không phải giữ toàn bộ kết quả trong bộ nhớ
+func (r *AuditLogRepository) Each(db *gorm.DB, query *dto.AuditLogQuery, fn func([]model.AuditLog) error) error {
+	filtered, err := filterAuditLogs(db, query)
+	if err != nil {
+		return err
+	}
+	var batch []model.AuditLog
+	return filtered.FindInBatches(&batch, auditBatchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// EachInChain - toàn bộ chuỗi theo thứ tự id, không lọc tenant vì verify cần mọi bản ghi
+func (r *AuditLogRepository) EachInChain(db *gorm.DB, fn func([]model.AuditLog) error) error {
+	var batch []model.AuditLog
+	return db.FindInBatches(&batch, auditBatchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// filterAuditLogs - trong một tenant thấy bản ghi của tenant đó và bản ghi chưa gắn tenant
+// (đăng nhập sai, refresh lỗi...) của các thành viên hiện tại
+func filterAuditLogs(db *gorm.DB, query *dto.AuditLogQuery) (*gorm.DB, error) {
+	scoped := db
+	if id := tenant.ID(db); id != 0 {
+		members := db.Model(&model.TenantMembership{}).Select("user_id").Where("tenant_id = ?", id)
+		scoped = db.Where("audit_logs.tenant_id = ? OR (audit_logs.tenant_id = 0 AND audit_logs.actor_id IN (?))", id, members)
+	} else if !tenant.IsSystem(db) {
+		return nil, tenant.ErrMissingTenant
+	}
+
+	if query.ActorID != 0 {
+		scoped = scoped.Where("audit_logs.actor_id = ?", query.ActorID)
+	}
+	if query.Action != "" {
+		scoped = scoped.Where("audit_logs.action = ?", query.Action)
+	}
+	if query.Outcome != "" {
+		scoped = scoped.Where("audit_logs.outcome = ?", query.Outcome)
+	}
+	if query.TargetType != "" {
+		scoped = scoped.Where("audit_logs.target_type = ?", query.TargetType)
+	}
+	if query.TargetID != "" {
+		scoped = scoped.Where("audit_logs.target_id = ?", query.TargetID)
+	}
+	if query.IP != "" {
+		scoped = scoped.Where("audit_logs.ip = ?", query.IP)
+	}
+	if query.From != nil {
+		scoped = scoped.Where("audit_logs.created_at >= ?", query.From.UTC())
+	}
+	if query.To != nil {
+		scoped = scoped.Where("audit_logs.created_at < ?", query.To.UTC())
+	}
+	return scoped.Session(&gorm.Session{}), nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"iot/internal/model"
+	"iot/internal/tenant"
+
+	"gorm.io/gorm"
+)
+
+type DeviceCredentialRepositoryInterface interface {
+	SaveCredential(db *gorm.DB, credential *model.DeviceCredential) error
+	GetByDeviceID(db *gorm.DB, deviceID uint) (*model.DeviceCredential, error)
+	GetByClientID(db *gorm.DB, clientID string) (*model.DeviceCredential, error)
+	GetByClaimCodeHash(db *gorm.DB, hash string) (*model.DeviceCredential, error)
+	ConsumeClaim(db *gorm.DB, id uint, hash, secretHash string) (bool, error)
+	DeleteByDeviceID(db *gorm.DB, deviceID uint) error
+}
+
+type DeviceCredentialRepository struct{}
+
+func NewDeviceCredentialRepository() DeviceCredentialRepositoryInterface {
+	return &DeviceCredentialRepository{}
+}
+
+// SaveCredential - tạo mới hoặc cập nhật toàn bộ credential, credential thuộc tenant của device
+func (r *DeviceCredentialRepository) SaveCredential(db *gorm.DB, credential *model.DeviceCredential) error {
+	if err := tenant.Stamp(db, &credential.TenantID); err != nil {
+		return err
+	}
+	return db.Save(credential).Error
+}
+
+func (r *DeviceCredentialRepository) GetByDeviceID(db *gorm.DB, deviceID uint) (*model.DeviceCredential, error) {
+	scoped, err := tenant.Scope(db, "device_credentials")
+	if err != nil {
+		return nil, err
+	}
+	var credential model.DeviceCredential
+	if err := scoped.Where("device_id = ?", deviceID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// GetByClientID - board chưa biết tenant lúc đăng nhập broker nên tra cứu trên toàn hệ thống
+func (r *DeviceCredentialRepository) GetByClientID(db *gorm.DB, clientID string) (*model.DeviceCredential, error) {
+	var credential model.DeviceCredential
+	if err := db.Where("client_id = ?", clientID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// GetByClaimCodeHash - tra cứu trên toàn hệ thống, claim code đủ dài để không đoán được
+func (r *DeviceCredentialRepository) GetByClaimCodeHash(db *gorm.DB, hash string) (*model.DeviceCredential, error) {
+	var credential model.DeviceCredential
+	if err := db.Where("claim_code_hash = ?", hash).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// ConsumeClaim - đổi claim code lấy secret, điều kiện trên claim_code_hash đảm bảo chỉ một request thành công
+func (r *DeviceCredentialRepository) ConsumeClaim(db *gorm.DB, id uint, hash, secretHash string) (bool, error) {
+	result := db.Model(&model.DeviceCredential{}).
+		Where("id = ? AND claim_code_hash = ? AND revoked_at IS NULL", id, hash).
+		Updates(map[string]interface{}{
+			"claim_code_hash":  nil,
+			"claim_expires_at": nil,
+			"secret_hash":      secretHash,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteByDeviceID - xóa hẳn credential khi device bị xóa để client ID không còn đăng nhập broker được,
+// device chưa có credential thì không lỗi
+func (r *DeviceCredentialRepository) DeleteByDeviceID(db *gorm.DB, deviceID uint) error {
+	scoped, err := tenant.Scope(db, "device_credentials")
+	if err != nil {
+		return err
+	}
+	return scoped.Unscoped().Where("device_id = ?", deviceID).Delete(&model.DeviceCredential{}).Error
+}
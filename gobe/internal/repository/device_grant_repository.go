@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"iot/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DeviceGrantRepositoryInterface interface {
+	UpsertGrant(db *gorm.DB, grant *model.DeviceGrant) error
+	DeleteGrant(db *gorm.DB, deviceID, userID uint) error
+	GetGrant(db *gorm.DB, deviceID, userID uint) (*model.DeviceGrant, error)
+	GetGrantsByDevice(db *gorm.DB, deviceID uint) ([]model.DeviceGrant, error)
+}
+
+type DeviceGrantRepository struct{}
+
+func NewDeviceGrantRepository() DeviceGrantRepositoryInterface {
+	return &DeviceGrantRepository{}
+}
+
+// UpsertGrant - tạo mới hoặc cập nhật permission nếu user đã được chia sẻ device
+func (r *DeviceGrantRepository) UpsertGrant(db *gorm.DB, grant *model.DeviceGrant) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"permission", "granted_by", "updated_at"}),
+	}).Create(grant).Error
+}
+
+func (r *DeviceGrantRepository) DeleteGrant(db *gorm.DB, deviceID, userID uint) error {
+	result := db.Unscoped().Where("device_id = ? AND user_id = ?", deviceID, userID).Delete(&model.DeviceGrant{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *DeviceGrantRepository) GetGrant(db *gorm.DB, deviceID, userID uint) (*model.DeviceGrant, error) {
+	var grant model.DeviceGrant
+	if err := db.Where("device_id = ? AND user_id = ?", deviceID, userID).First(&grant).Error; err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+func (r *DeviceGrantRepository) GetGrantsByDevice(db *gorm.DB, deviceID uint) ([]model.DeviceGrant, error) {
+	var grants []model.DeviceGrant
+	if err := db.Where("device_id = ?", deviceID).Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
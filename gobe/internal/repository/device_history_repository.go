@@ -2,14 +2,14 @@ package repository
 
 import (
 	"fmt"
-	"iot/internal/helper"
 	"iot/internal/model"
-	"regexp"
-	"strconv"
-	"strings"
+	"iot/internal/tenant"
+	"iot/pkg/filter"
+	"iot/pkg/pagination"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -17,12 +17,54 @@ const (
 	LOCAL_TIMEZONE = "Asia/Ho_Chi_Minh"
 )
 
+// localTimezone - máy không có tzdata thì dùng UTC+7 cố định
+func localTimezone() *time.Location {
+	loc, err := time.LoadLocation(LOCAL_TIMEZONE)
+	if err != nil {
+		return time.FixedZone(LOCAL_TIMEZONE, 7*60*60)
+	}
+	return loc
+}
+
+// DeviceHistoryFilter - field được dùng trong tham số search, ví dụ "status=ON and user~admin"
+var DeviceHistoryFilter = &filter.Schema{
+	Fields: map[string]filter.Field{
+		"id":        {Column: "device_histories.id", Kind: filter.KindInteger},
+		"device_id": {Column: "device_histories.device_id", Kind: filter.KindInteger},
+		"user_id":   {Column: "device_histories.user_id", Kind: filter.KindInteger},
+		"status":    {Column: "device_histories.status", Kind: filter.KindEnum, Values: []string{"ON", "OFF"}},
+		"user":      {Column: "device_histories.user_change", Kind: filter.KindString},
+		"time":      {Column: "device_histories.created_at", Kind: filter.KindTime},
+	},
+	Time:     "time",
+	Text:     []string{"user"},
+	Location: localTimezone(),
+}
+
+// DeviceHistorySort - field được sort, mặc định mới nhất trước. Không có device_id (cột varchar) và status (enum)
+// vì thứ tự ORDER BY của chúng khác thứ tự khi so sánh keyset
+var DeviceHistorySort = &pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":         {Column: "device_histories.id", Kind: pagination.KindInteger},
+		"created_at": {Column: "device_histories.created_at", Kind: pagination.KindTime},
+		"user_id":    {Column: "device_histories.user_id", Kind: pagination.KindInteger},
+		"user":       {Column: "device_histories.user_change", Kind: pagination.KindString},
+	},
+	Default: "created_at:desc",
+	Unique:  "id",
+}
+
 type DeviceHistoryRepositoryInterface interface {
 	CreateDeviceHistory(db *gorm.DB, history *model.DeviceHistory) error
 	GetByDeviceID(db *gorm.DB, deviceID uint) (*model.DeviceHistory, error)
-	GetAllDeviceHistories(db *gorm.DB, page int, offset int, order string, status string, deviceId string, startDate string, endDate string, search string) ([]model.DeviceHistory, int, error)
+	GetAllDeviceHistories(db *gorm.DB, page *pagination.Request, status string, deviceId string, startDate string, endDate string, search string) ([]model.DeviceHistory, *pagination.Page, error)
+	CountDeviceHistories(db *gorm.DB, status string, deviceId string, startDate string, endDate string, search string) (int64, error)
+	EachDeviceHistory(db *gorm.DB, status string, deviceId string, startDate string, endDate string, search string, batch func([]model.DeviceHistory) error) error
+	ImportDeviceHistories(db *gorm.DB, rows []model.DeviceHistory) (int64, error)
+	ExistingDeviceHistoryFingerprints(db *gorm.DB, fingerprints []string) ([]string, error)
 }
 
+// DeviceHistoryRepository - lịch sử bật/tắt chỉ đọc/ghi trong tenant đã chọn trên db
 type DeviceHistoryRepository struct{}
 
 func NewDeviceHistoryRepository() DeviceHistoryRepositoryInterface {
@@ -30,49 +72,87 @@ func NewDeviceHistoryRepository() DeviceHistoryRepositoryInterface {
 }
 
 func (r *DeviceHistoryRepository) CreateDeviceHistory(db *gorm.DB, history *model.DeviceHistory) error {
+	if err := tenant.Stamp(db, &history.TenantID); err != nil {
+		return err
+	}
 	return db.Create(history).Error
 }
 
+// ImportDeviceHistories - dòng trùng fingerprint trong tenant bị bỏ qua, trả số dòng thực sự được thêm
+func (r *DeviceHistoryRepository) ImportDeviceHistories(db *gorm.DB, rows []model.DeviceHistory) (int64, error) {
+	for i := range rows {
+		if err := tenant.Stamp(db, &rows[i].TenantID); err != nil {
+			return 0, err
+		}
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows)
+	return result.RowsAffected, result.Error
+}
+
+// ExistingDeviceHistoryFingerprints - fingerprint nào trong danh sách đã có trong tenant, dùng cho dry-run
+func (r *DeviceHistoryRepository) ExistingDeviceHistoryFingerprints(db *gorm.DB, fingerprints []string) ([]string, error) {
+	scoped, err := tenant.Scope(db, "device_histories")
+	if err != nil {
+		return nil, err
+	}
+	var existing []string
+	err = scoped.Model(&model.DeviceHistory{}).Where("fingerprint IN ?", fingerprints).Pluck("fingerprint", &existing).Error
+	return existing, err
+}
+
 func (r *DeviceHistoryRepository) GetByDeviceID(db *gorm.DB, deviceID uint) (*model.DeviceHistory, error) {
+	scoped, err := tenant.Scope(db, "device_histories")
+	if err != nil {
+		return nil, err
+	}
 	var history model.DeviceHistory
-	if err := db.Where("ID = ?", deviceID).First(&history).Error; err != nil {
+	if err := scoped.Where("ID = ?", deviceID).First(&history).Error; err != nil {
 		return nil, err
 	}
 	return &history, nil
 }
 
-func (r *DeviceHistoryRepository) GetAllDeviceHistories(db *gorm.DB, Limit int, offset int, order string, status string, deviceId string, startDate string, endDate string, search string) ([]model.DeviceHistory, int, error) {
-	var histories []model.DeviceHistory
+func (r *DeviceHistoryRepository) GetAllDeviceHistories(db *gorm.DB, page *pagination.Request, status string, deviceId string, startDate string, endDate string, search string) ([]model.DeviceHistory, *pagination.Page, error) {
+	query, err := deviceHistoryQuery(db, status, deviceId, startDate, endDate, search)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pagination.Find[model.DeviceHistory](query, DeviceHistorySort, page)
+}
+
+func (r *DeviceHistoryRepository) CountDeviceHistories(db *gorm.DB, status string, deviceId string, startDate string, endDate string, search string) (int64, error) {
+	query, err := deviceHistoryQuery(db, status, deviceId, startDate, endDate, search)
+	if err != nil {
+		return 0, err
+	}
 	var count int64
+	err = query.Count(&count).Error
+	return count, err
+}
 
-	// Build query
-	query := db.Model(&model.DeviceHistory{})
+// EachDeviceHistory - duyệt theo lô tăng dần theo id, dùng cho export
+func (r *DeviceHistoryRepository) EachDeviceHistory(db *gorm.DB, status string, deviceId string, startDate string, endDate string, search string, batch func([]model.DeviceHistory) error) error {
+	query, err := deviceHistoryQuery(db, status, deviceId, startDate, endDate, search)
+	if err != nil {
+		return err
+	}
+	var rows []model.DeviceHistory
+	return query.FindInBatches(&rows, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		return batch(rows)
+	}).Error
+}
 
-	// Apply search logic
-	if search != "" {
-		search = strings.TrimSpace(search)
+// deviceHistoryQuery - bộ lọc chung của danh sách, đếm và export
+func deviceHistoryQuery(db *gorm.DB, status string, deviceId string, startDate string, endDate string, search string) (*gorm.DB, error) {
+	scoped, err := tenant.Scope(db, "device_histories")
+	if err != nil {
+		return nil, err
+	}
+	query := scoped.Model(&model.DeviceHistory{})
 
-		// Check if search is an ID (starts with # or pure number)
-		if strings.HasPrefix(search, "#") {
-			// Remove # and search by ID
-			idStr := strings.TrimPrefix(search, "#")
-			if id, err := strconv.Atoi(idStr); err == nil {
-				query = query.Where("id = ?", id)
-			}
-		} else if matched, _ := regexp.MatchString(`^\d+$`, search); matched {
-			// Pure number - could be ID or device_id
-			query = query.Where("id = ? OR device_id = ?", search, search)
-		} else if matched, _ := regexp.MatchString(`^\d{2}/\d{2}/\d{4}`, search); matched {
-			// Date format: DD/MM/YYYY or partial datetime - convert to YYYY-MM-DD format
-			convertedSearch := helper.ConvertDateFormat(search)
-			query = helper.ApplyDateTimeSearch(query, convertedSearch)
-		} else if matched, _ := regexp.MatchString(`^\d{4}-\d{2}-\d{2}`, search); matched {
-			// Date format: YYYY-MM-DD or partial datetime
-			query = helper.ApplyDateTimeSearch(query, search)
-		} else {
-			// Text search - search in device name or other text fields
-			query = query.Where("device_name LIKE ?", "%"+search+"%")
-		}
+	query, err = DeviceHistoryFilter.Where(query, search)
+	if err != nil {
+		return nil, err
 	}
 
 	// Apply status filter
@@ -105,16 +185,6 @@ func (r *DeviceHistoryRepository) GetAllDeviceHistories(db *gorm.DB, Limit int,
 			fmt.Printf("Error parsing start date: %v\n", err)
 		}
 	}
-
-	// Get total count
-	if err := query.Count(&count).Error; err != nil {
-		return nil, 0, err
-	}
-
-	if err := query.Order(order).Limit(Limit).Offset(offset).Find(&histories).Error; err != nil {
-		return nil, 0, err
-	}
-
-	return histories, int(count), nil
+	return query, nil
 }
 
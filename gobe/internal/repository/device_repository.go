@@ -2,18 +2,35 @@ package repository
 
 import (
 	"iot/internal/model"
+	"iot/internal/tenant"
+	"iot/pkg/pagination"
 
 	"gorm.io/gorm"
 )
 
+// DeviceSort - field được sort, mặc định theo id tăng dần như trước
+var DeviceSort = &pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":         {Column: "devices.id", Kind: pagination.KindInteger},
+		"name":       {Column: "devices.name", Kind: pagination.KindString},
+		"created_at": {Column: "devices.created_at", Kind: pagination.KindTime},
+	},
+	Default: "id:asc",
+	Unique:  "id",
+}
+
 type DeviceRepositoryInterface interface {
 	CreateDevice(db *gorm.DB, device *model.Device) error
 	GetByID(db *gorm.DB, id uint) (*model.Device, error)
-	GetAllDevices(db *gorm.DB, limit, offset int) ([]model.Device, error)
+	GetAllDevices(db *gorm.DB, page *pagination.Request) ([]model.Device, *pagination.Page, error)
 	UpdateDevice(db *gorm.DB, device *model.Device) error
 	DeleteDevice(db *gorm.DB, id uint) error
+	GetAccessibleDevices(db *gorm.DB, userID uint, page *pagination.Request) ([]model.Device, *pagination.Page, error)
+	CountOwnedDevices(db *gorm.DB, userID uint) (int64, error)
+	CountAccessibleDevices(db *gorm.DB, userID uint) (int64, error)
 }
 
+// DeviceRepository - mọi hàm chỉ thấy device của tenant trên db (xem tenant.Scope)
 type DeviceRepository struct{}
 
 func NewDeviceRepository() DeviceRepositoryInterface {
@@ -22,30 +39,41 @@ func NewDeviceRepository() DeviceRepositoryInterface {
 
 // CreateDevice - tạo device mới
 func (r *DeviceRepository) CreateDevice(db *gorm.DB, device *model.Device) error {
+	if err := tenant.Stamp(db, &device.TenantID); err != nil {
+		return err
+	}
 	return db.Create(device).Error
 }
 
 // GetByID - tìm device theo ID
 func (r *DeviceRepository) GetByID(db *gorm.DB, id uint) (*model.Device, error) {
+	scoped, err := tenant.Scope(db, "devices")
+	if err != nil {
+		return nil, err
+	}
 	var device model.Device
-	if err := db.First(&device, id).Error; err != nil {
+	if err := scoped.First(&device, id).Error; err != nil {
 		return nil, err
 	}
 	return &device, nil
 }
 
 // GetAllDevices - lấy tất cả device với phân trang
-func (r *DeviceRepository) GetAllDevices(db *gorm.DB, limit, offset int) ([]model.Device, error) {
-	var devices []model.Device
-	if err := db.Limit(limit).Offset(offset).Find(&devices).Error; err != nil {
-		return nil, err
+func (r *DeviceRepository) GetAllDevices(db *gorm.DB, page *pagination.Request) ([]model.Device, *pagination.Page, error) {
+	scoped, err := tenant.Scope(db, "devices")
+	if err != nil {
+		return nil, nil, err
 	}
-	return devices, nil
+	return pagination.Find[model.Device](scoped.Model(&model.Device{}), DeviceSort, page)
 }
 
 // UpdateDevice - cập nhật device theo ID
 func (r *DeviceRepository) UpdateDevice(db *gorm.DB, device *model.Device) error {
-	result := db.Model(&model.Device{}).Where("id = ?", device.ID).Updates(device)
+	scoped, err := tenant.Scope(db, "devices")
+	if err != nil {
+		return err
+	}
+	result := scoped.Model(&model.Device{}).Where("id = ?", device.ID).Updates(device)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -57,9 +85,50 @@ func (r *DeviceRepository) UpdateDevice(db *gorm.DB, device *model.Device) error
 
 // DeleteDevice - xóa device theo ID
 func (r *DeviceRepository) DeleteDevice(db *gorm.DB, id uint) error {
+	scoped, err := tenant.Scope(db, "devices")
+	if err != nil {
+		return err
+	}
 	var device model.Device
-	if err := db.First(&device, id).Error; err != nil {
+	if err := scoped.First(&device, id).Error; err != nil {
 		return err // không tìm thấy device
 	}
 	return db.Unscoped().Delete(&device).Error
 }
+
+// GetAccessibleDevices - device user sở hữu hoặc được chia sẻ
+func (r *DeviceRepository) GetAccessibleDevices(db *gorm.DB, userID uint, page *pagination.Request) ([]model.Device, *pagination.Page, error) {
+	scoped, err := tenant.Scope(db, "devices")
+	if err != nil {
+		return nil, nil, err
+	}
+	granted := db.Model(&model.DeviceGrant{}).Select("device_id").Where("user_id = ?", userID)
+	query := scoped.Model(&model.Device{}).Where("owner_id = ? OR id IN (?)", userID, granted)
+	return pagination.Find[model.Device](query, DeviceSort, page)
+}
+
+func (r *DeviceRepository) CountOwnedDevices(db *gorm.DB, userID uint) (int64, error) {
+	scoped, err := tenant.Scope(db, "devices")
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := scoped.Model(&model.Device{}).Where("owner_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountAccessibleDevices - số device trong tenant user sở hữu hoặc được chia sẻ
+func (r *DeviceRepository) CountAccessibleDevices(db *gorm.DB, userID uint) (int64, error) {
+	scoped, err := tenant.Scope(db, "devices")
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	granted := db.Model(&model.DeviceGrant{}).Select("device_id").Where("user_id = ?", userID)
+	if err := scoped.Model(&model.Device{}).Where("owner_id = ? OR id IN (?)", userID, granted).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"iot/internal/model"
+	"iot/internal/tenant"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type JobRepositoryInterface interface {
+	CreateJob(db *gorm.DB, job *model.Job) error
+	GetByID(db *gorm.DB, userID, id uint) (*model.Job, error)
+	ListByUser(db *gorm.DB, userID uint, limit int) ([]model.Job, error)
+	UpdateJob(db *gorm.DB, id uint, fields map[string]interface{}) error
+	FailUnfinished(db *gorm.DB, reason string, now, expiresAt time.Time) (int64, error)
+	ListExpired(db *gorm.DB, now time.Time) ([]model.Job, error)
+	DeleteJob(db *gorm.DB, id uint) error
+}
+
+// JobRepository - user chỉ thấy job của mình trong tenant đang chọn.
+// Worker chạy nền cập nhật theo id với db System.
+type JobRepository struct{}
+
+func NewJobRepository() JobRepositoryInterface {
+	return &JobRepository{}
+}
+
+func (r *JobRepository) CreateJob(db *gorm.DB, job *model.Job) error {
+	if err := tenant.Stamp(db, &job.TenantID); err != nil {
+		return err
+	}
+	return db.Create(job).Error
+}
+
+func (r *JobRepository) GetByID(db *gorm.DB, userID, id uint) (*model.Job, error) {
+	scoped, err := tenant.Scope(db, "jobs")
+	if err != nil {
+		return nil, err
+	}
+	var job model.Job
+	if err := scoped.Where("user_id = ?", userID).First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *JobRepository) ListByUser(db *gorm.DB, userID uint, limit int) ([]model.Job, error) {
+	scoped, err := tenant.Scope(db, "jobs")
+	if err != nil {
+		return nil, err
+	}
+	var jobs []model.Job
+	err = scoped.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *JobRepository) UpdateJob(db *gorm.DB, id uint, fields map[string]interface{}) error {
+	return db.Model(&model.Job{}).Where("id = ?", id).Updates(fields).Error
+}
+
+// FailUnfinished - job đang chạy khi server dừng không chạy tiếp được, đánh dấu lỗi lúc khởi động
+func (r *JobRepository) FailUnfinished(db *gorm.DB, reason string, now, expiresAt time.Time) (int64, error) {
+	result := db.Model(&model.Job{}).
+		Where("status IN ?", []string{model.JobPending, model.JobRunning}).
+		Updates(map[string]interface{}{"status": model.JobFailed, "error": reason, "finished_at": now, "expires_at": expiresAt})
+	return result.RowsAffected, result.Error
+}
+
+func (r *JobRepository) ListExpired(db *gorm.DB, now time.Time) ([]model.Job, error) {
+	var jobs []model.Job
+	err := db.Where("expires_at IS NOT NULL AND expires_at < ?", now).Limit(500).Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *JobRepository) DeleteJob(db *gorm.DB, id uint) error {
+	return db.Delete(&model.Job{}, id).Error
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"iot/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepositoryInterface interface {
+	ReplaceCodes(db *gorm.DB, userID uint, hashes []string) error
+	UseCode(db *gorm.DB, userID uint, hash string) (bool, error)
+	DeleteCodes(db *gorm.DB, userID uint) error
+}
+
+type RecoveryCodeRepository struct{}
+
+func NewRecoveryCodeRepository() RecoveryCodeRepositoryInterface {
+	return &RecoveryCodeRepository{}
+}
+
+// ReplaceCodes - xóa bộ mã cũ và lưu bộ mới trong cùng transaction
+func (r *RecoveryCodeRepository) ReplaceCodes(db *gorm.DB, userID uint, hashes []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]model.RecoveryCode, 0, len(hashes))
+		for _, h := range hashes {
+			codes = append(codes, model.RecoveryCode{UserID: userID, CodeHash: h})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseCode - đánh dấu mã đã dùng, false nếu mã không tồn tại hoặc đã dùng trước đó
+func (r *RecoveryCodeRepository) UseCode(db *gorm.DB, userID uint, hash string) (bool, error) {
+	result := db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *RecoveryCodeRepository) DeleteCodes(db *gorm.DB, userID uint) error {
+	return db.Unscoped().Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error
+}
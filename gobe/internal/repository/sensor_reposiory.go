@@ -1,25 +1,61 @@
 package repository
 
 import (
-	"iot/internal/helper"
 	"iot/internal/model"
-	"regexp"
-	"strconv"
-	"strings"
+	"iot/internal/storage"
+	"iot/internal/tenant"
+	"iot/pkg/filter"
+	"iot/pkg/pagination"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SensorRepositoryInterface interface {
 	CreateSensorData(*gorm.DB, *model.SensorData) error
 	DeleteSensorData(*gorm.DB, uint) error
-	GetAllSensorData(*gorm.DB, int, int, string, string, string, string, string) ([]model.SensorData, int, error)
+	GetAllSensorData(*gorm.DB, *pagination.Request, string, string, string) ([]model.SensorData, *pagination.Page, error)
+	CountSensorData(*gorm.DB, string, string, string) (int64, error)
+	EachSensorData(*gorm.DB, string, string, string, func([]model.SensorData) error) error
+	ImportSensorData(*gorm.DB, []model.SensorData) (int64, error)
+	ExistingSensorFingerprints(*gorm.DB, []string) ([]string, error)
+	AggregateSensorData(*gorm.DB, time.Duration, string, string, string) ([]model.SensorBucket, error)
 	GetLastSensorData(*gorm.DB) (*model.SensorData, error)
 	GetSensorDataByID(*gorm.DB, uint) (*model.SensorData, error)
 	GetSensorDataByTime(*gorm.DB, string) (*model.SensorData, error)
 }
 
+// SensorFilter - field được dùng trong tham số search, ví dụ "temperature>30 and humidity between 60..80"
+var SensorFilter = &filter.Schema{
+	Fields: map[string]filter.Field{
+		"id":          {Column: "sensor_data.id", Kind: filter.KindInteger},
+		"temperature": {Column: "sensor_data.temperature", Kind: filter.KindNumber, Units: []string{"°C", "C"}},
+		"humidity":    {Column: "sensor_data.humidity", Kind: filter.KindNumber, Units: []string{"%"}},
+		"light":       {Column: "sensor_data.light", Kind: filter.KindInteger, Units: []string{"lux", "lx"}},
+		"time":        {Column: "sensor_data.created_at", Kind: filter.KindTime},
+	},
+	Time:     "time",
+	Location: localTimezone(),
+}
+
+// SensorSort - field được sort, mặc định mới nhất trước
+var SensorSort = &pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":          {Column: "sensor_data.id", Kind: pagination.KindInteger},
+		"created_at":  {Column: "sensor_data.created_at", Kind: pagination.KindTime},
+		"temperature": {Column: "sensor_data.temperature", Kind: pagination.KindNumber},
+		"humidity":    {Column: "sensor_data.humidity", Kind: pagination.KindNumber},
+		"light":       {Column: "sensor_data.light", Kind: pagination.KindInteger},
+	},
+	Default: "created_at:desc",
+	Unique:  "id",
+}
+
+// exportBatchSize - số dòng mỗi lần đọc khi export
+const exportBatchSize = 1000
+
+// SensorRepository - dữ liệu sensor chỉ đọc/ghi trong tenant đã chọn trên db
 type SensorRepository struct{}
 
 func NewSensorRepository() SensorRepositoryInterface {
@@ -27,72 +63,123 @@ func NewSensorRepository() SensorRepositoryInterface {
 }
 
 func (r *SensorRepository) CreateSensorData(db *gorm.DB, data *model.SensorData) error {
+	if err := tenant.Stamp(db, &data.TenantID); err != nil {
+		return err
+	}
 	return db.Create(data).Error
 }
 
 func (r *SensorRepository) DeleteSensorData(db *gorm.DB, id uint) error {
+	scoped, err := tenant.Scope(db, "sensor_data")
+	if err != nil {
+		return err
+	}
 	var data model.SensorData
-	if err := db.First(&data, id).Error; err != nil {
+	if err := scoped.First(&data, id).Error; err != nil {
 		return err // không tìm thấy dữ liệu
 	}
 	return db.Unscoped().Delete(&data).Error
 }
 
-func (r *SensorRepository) GetAllSensorData(db *gorm.DB, limit, offset int, sort string, order string, startDate string, endDate string, search string) ([]model.SensorData, int, error) {
-	var sensorData []model.SensorData
+func (r *SensorRepository) GetAllSensorData(db *gorm.DB, page *pagination.Request, startDate string, endDate string, search string) ([]model.SensorData, *pagination.Page, error) {
+	query, err := sensorQuery(db, startDate, endDate, search)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pagination.Find[model.SensorData](query, SensorSort, page)
+}
+
+func (r *SensorRepository) CountSensorData(db *gorm.DB, startDate string, endDate string, search string) (int64, error) {
+	query, err := sensorQuery(db, startDate, endDate, search)
+	if err != nil {
+		return 0, err
+	}
 	var count int64
+	err = query.Count(&count).Error
+	return count, err
+}
+
+// EachSensorData - duyệt theo lô tăng dần theo id, dùng cho export không giữ cả bảng trong bộ nhớ
+func (r *SensorRepository) EachSensorData(db *gorm.DB, startDate string, endDate string, search string, batch func([]model.SensorData) error) error {
+	query, err := sensorQuery(db, startDate, endDate, search)
+	if err != nil {
+		return err
+	}
+	var rows []model.SensorData
+	return query.FindInBatches(&rows, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		return batch(rows)
+	}).Error
+}
+
+// ImportSensorData - dòng trùng fingerprint trong tenant bị bỏ qua, trả số dòng thực sự được thêm
+func (r *SensorRepository) ImportSensorData(db *gorm.DB, rows []model.SensorData) (int64, error) {
+	for i := range rows {
+		if err := tenant.Stamp(db, &rows[i].TenantID); err != nil {
+			return 0, err
+		}
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows)
+	return result.RowsAffected, result.Error
+}
 
-	// Build query
-	query := db.Model(&model.SensorData{})
+// ExistingSensorFingerprints - fingerprint nào trong danh sách đã có trong tenant, dùng cho dry-run
+func (r *SensorRepository) ExistingSensorFingerprints(db *gorm.DB, fingerprints []string) ([]string, error) {
+	scoped, err := tenant.Scope(db, "sensor_data")
+	if err != nil {
+		return nil, err
+	}
+	var existing []string
+	err = scoped.Model(&model.SensorData{}).Where("fingerprint IN ?", fingerprints).Pluck("fingerprint", &existing).Error
+	return existing, err
+}
 
-	if search != "" {
-		search = strings.TrimSpace(search)
+// MaxSensorBuckets - số khoảng tối đa một lần gộp, quá thì chỉ trả các khoảng mới nhất
+const MaxSensorBuckets = 2000
 
-		if strings.HasPrefix(search, "#") {
-			// Case 1: Search by ID (#123)
-			idStr := strings.TrimPrefix(search, "#")
-			if id, err := strconv.Atoi(idStr); err == nil {
-				query = query.Where("id = ?", id)
-			}
+type sensorBucketRow struct {
+	model.SensorBucket
+	Bucket int64
+}
 
-		} else if matched, _ := regexp.MatchString(`^\d+$`, search); matched {
-			// Case 2: Pure number
-			query = query.Where("id = ? OR sensor_id = ?", search, search)
-
-		} else if matched, _ := regexp.MatchString(`^\d{2}/\d{2}/\d{4}`, search); matched {
-			// Case 3: DD/MM/YYYY
-			convertedSearch := helper.ConvertDateFormat(search)
-			query = helper.ApplyDateTimeSearch(query, convertedSearch)
-
-		} else if matched, _ := regexp.MatchString(`^\d{4}-\d{2}-\d{2}`, search); matched {
-			// Case 4: YYYY-MM-DD
-			query = helper.ApplyDateTimeSearch(query, search)
-
-		} else if matched, _ := regexp.MatchString(`^\d+(\.\d+)?\s*°[Cc]`, search); matched {
-			// Case 5: Temperature 28.9°C -> temperature
-			re := regexp.MustCompile(`(\d+(\.\d+)?)`)
-			tempVal := re.FindString(search)
-			query = query.Where("temperature = ?", tempVal)
-
-		} else if matched, _ := regexp.MatchString(`^\d+(\.\d+)?\s*%`, search); matched {
-			// Case 6: Humidity 75% -> humidity
-			re := regexp.MustCompile(`(\d+(\.\d+)?)`)
-			humVal := re.FindString(search)
-			query = query.Where("humidity = ?", humVal)
-
-		} else if matched, _ := regexp.MatchString(`^\d+(\.\d+)?\s*(lux|lx)`, strings.ToLower(search)); matched {
-			// Case 7: Light intensity 3779 lux -> light
-			re := regexp.MustCompile(`(\d+(\.\d+)?)`)
-			lightVal := re.FindString(search)
-			query = query.Where("light = ?", lightVal)
-
-		} else {
-			// Case 8: Text search
-			query = query.Where(
-				"sensor_name LIKE ? OR sensor_type LIKE ? OR location LIKE ?",
-				"%"+search+"%", "%"+search+"%", "%"+search+"%",
-			)
-		}
+// AggregateSensorData - gộp theo khoảng thời gian với cùng bộ lọc của danh sách, khoảng cũ trước.
+// Biểu thức chia khoảng khác nhau theo dialect (TimescaleDB dùng time_bucket), xem storage.TimeBucket.
+func (r *SensorRepository) AggregateSensorData(db *gorm.DB, bucket time.Duration, startDate string, endDate string, search string) ([]model.SensorBucket, error) {
+	query, err := sensorQuery(db, startDate, endDate, search)
+	if err != nil {
+		return nil, err
+	}
+	var rows []sensorBucketRow
+	err = query.Select(storage.TimeBucket(db, "sensor_data.created_at", bucket) + " AS bucket, COUNT(*) AS count, " +
+		"AVG(sensor_data.temperature) AS avg_temperature, MIN(sensor_data.temperature) AS min_temperature, MAX(sensor_data.temperature) AS max_temperature, " +
+		"AVG(sensor_data.humidity) AS avg_humidity, MIN(sensor_data.humidity) AS min_humidity, MAX(sensor_data.humidity) AS max_humidity, " +
+		"AVG(sensor_data.light) AS avg_light, MIN(sensor_data.light) AS min_light, MAX(sensor_data.light) AS max_light").
+		Group("bucket").
+		Order("bucket DESC").
+		Limit(MaxSensorBuckets).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]model.SensorBucket, len(rows))
+	for i, row := range rows {
+		row.SensorBucket.Start = time.Unix(row.Bucket, 0)
+		buckets[len(rows)-1-i] = row.SensorBucket
+	}
+	return buckets, nil
+}
+
+// sensorQuery - bộ lọc chung của danh sách, đếm và export
+func sensorQuery(db *gorm.DB, startDate string, endDate string, search string) (*gorm.DB, error) {
+	scoped, err := tenant.Scope(db, "sensor_data")
+	if err != nil {
+		return nil, err
+	}
+	query := scoped.Model(&model.SensorData{})
+
+	query, err = SensorFilter.Where(query, search)
+	if err != nil {
+		return nil, err
 	}
 
 	// Apply date range filter
@@ -109,41 +196,28 @@ func (r *SensorRepository) GetAllSensorData(db *gorm.DB, limit, offset int, sort
 			}
 		}
 	}
-
-	// Get total count
-	if err := query.Count(&count).Error; err != nil {
-		return nil, 0, err
-	}
-
-	// Apply sorting - use the sort parameter for field name
-	sortBy := sort
-	if sortBy == "" {
-		sortBy = "created_at" // default sort field
-	}
-	orderBy := order
-	if orderBy == "" {
-		orderBy = "desc" // default order
-	}
-
-	// Get paginated results with ordering
-	if err := query.Order(sortBy + " " + orderBy).Limit(limit).Offset(offset).Find(&sensorData).Error; err != nil {
-		return nil, 0, err
-	}
-
-	return sensorData, int(count), nil
+	return query, nil
 }
 
 func (r *SensorRepository) GetLastSensorData(db *gorm.DB) (*model.SensorData, error) {
+	scoped, err := tenant.Scope(db, "sensor_data")
+	if err != nil {
+		return nil, err
+	}
 	var data model.SensorData
-	if err := db.Order("created_at desc").First(&data).Error; err != nil {
+	if err := scoped.Order("created_at desc").First(&data).Error; err != nil {
 		return nil, err
 	}
 	return &data, nil
 }
 
 func (r *SensorRepository) GetSensorDataByID(db *gorm.DB, id uint) (*model.SensorData, error) {
+	scoped, err := tenant.Scope(db, "sensor_data")
+	if err != nil {
+		return nil, err
+	}
 	var data model.SensorData
-	if err := db.First(&data, id).Error; err != nil {
+	if err := scoped.First(&data, id).Error; err != nil {
 		return nil, err
 	}
 	return &data, nil
@@ -157,8 +231,12 @@ func (r *SensorRepository) GetSensorDataByTime(db *gorm.DB, timestampStr string)
 	}
 
 	// 2. Query GORM
+	scoped, err := tenant.Scope(db, "sensor_data")
+	if err != nil {
+		return nil, err
+	}
 	var data model.SensorData
-	if err := db.Where("timestamp = ?", t).First(&data).Error; err != nil {
+	if err := scoped.Where("timestamp = ?", t).First(&data).Error; err != nil {
 		return nil, err
 	}
 
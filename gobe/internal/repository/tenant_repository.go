@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"iot/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TenantMember - membership kèm thông tin user để hiển thị danh sách thành viên
+type TenantMember struct {
+	UserID uint
+	Name   string
+	Email  string
+	Role   string
+}
+
+// TenantWithRole - tenant user thuộc về và role của user trong đó
+type TenantWithRole struct {
+	model.Tenant
+	Role string
+}
+
+// TenantInvitationWithTenant - lời mời kèm tên tenant để user được mời biết mình được mời vào đâu
+type TenantInvitationWithTenant struct {
+	model.TenantInvitation
+	TenantName string
+}
+
+type TenantRepositoryInterface interface {
+	CreateTenant(db *gorm.DB, tenant *model.Tenant) error
+	GetByID(db *gorm.DB, id uint) (*model.Tenant, error)
+	GetBySlug(db *gorm.DB, slug string) (*model.Tenant, error)
+	ListByUser(db *gorm.DB, userID uint) ([]TenantWithRole, error)
+	GetMembership(db *gorm.DB, tenantID, userID uint) (*model.TenantMembership, error)
+	FirstMembership(db *gorm.DB, userID uint) (*model.TenantMembership, error)
+	CreateMembership(db *gorm.DB, membership *model.TenantMembership) error
+	UpdateMembershipRole(db *gorm.DB, tenantID, userID uint, role string) error
+	DeleteMembership(db *gorm.DB, tenantID, userID uint) error
+	ListMembers(db *gorm.DB, tenantID uint) ([]TenantMember, error)
+	CountMembersWithRole(db *gorm.DB, tenantID uint, role string) (int64, error)
+	CreateInvitation(db *gorm.DB, invitation *model.TenantInvitation) error
+	GetInvitation(db *gorm.DB, tenantID, userID uint) (*model.TenantInvitation, error)
+	ListInvitationsByUser(db *gorm.DB, userID uint, now time.Time) ([]TenantInvitationWithTenant, error)
+	DeleteInvitation(db *gorm.DB, tenantID, userID uint) error
+}
+
+type TenantRepository struct{}
+
+func NewTenantRepository() TenantRepositoryInterface {
+	return &TenantRepository{}
+}
+
+func (r *TenantRepository) CreateTenant(db *gorm.DB, tenant *model.Tenant) error {
+	return db.Create(tenant).Error
+}
+
+func (r *TenantRepository) GetByID(db *gorm.DB, id uint) (*model.Tenant, error) {
+	var tenant model.Tenant
+	if err := db.First(&tenant, id).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (r *TenantRepository) GetBySlug(db *gorm.DB, slug string) (*model.Tenant, error) {
+	var tenant model.Tenant
+	if err := db.Where("slug = ?", slug).First(&tenant).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (r *TenantRepository) ListByUser(db *gorm.DB, userID uint) ([]TenantWithRole, error) {
+	var tenants []TenantWithRole
+	err := db.Model(&model.Tenant{}).
+		Select("tenants.*, tenant_memberships.role AS role").
+		Joins("JOIN tenant_memberships ON tenant_memberships.tenant_id = tenants.id AND tenant_memberships.deleted_at IS NULL").
+		Where("tenant_memberships.user_id = ?", userID).
+		Order("tenant_memberships.id").
+		Scan(&tenants).Error
+	return tenants, err
+}
+
+func (r *TenantRepository) GetMembership(db *gorm.DB, tenantID, userID uint) (*model.TenantMembership, error) {
+	var membership model.TenantMembership
+	if err := db.Where("tenant_id = ? AND user_id = ?", tenantID, userID).First(&membership).Error; err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// FirstMembership - tenant mặc định khi đăng nhập là tenant user tham gia sớm nhất
+func (r *TenantRepository) FirstMembership(db *gorm.DB, userID uint) (*model.TenantMembership, error) {
+	var membership model.TenantMembership
+	if err := db.Where("user_id = ?", userID).Order("id").First(&membership).Error; err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (r *TenantRepository) CreateMembership(db *gorm.DB, membership *model.TenantMembership) error {
+	return db.Create(membership).Error
+}
+
+func (r *TenantRepository) UpdateMembershipRole(db *gorm.DB, tenantID, userID uint, role string) error {
+	result := db.Model(&model.TenantMembership{}).
+		Where("tenant_id = ? AND user_id = ?", tenantID, userID).
+		Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteMembership - xóa hẳn để user có thể được mời lại (unique tenant_id + user_id)
+func (r *TenantRepository) DeleteMembership(db *gorm.DB, tenantID, userID uint) error {
+	result := db.Unscoped().Where("tenant_id = ? AND user_id = ?", tenantID, userID).Delete(&model.TenantMembership{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *TenantRepository) ListMembers(db *gorm.DB, tenantID uint) ([]TenantMember, error) {
+	var members []TenantMember
+	err := db.Model(&model.TenantMembership{}).
+		Select("tenant_memberships.user_id, users.name, users.email, tenant_memberships.role").
+		Joins("JOIN users ON users.id = tenant_memberships.user_id AND users.deleted_at IS NULL").
+		Where("tenant_memberships.tenant_id = ?", tenantID).
+		Order("tenant_memberships.id").
+		Scan(&members).Error
+	return members, err
+}
+
+func (r *TenantRepository) CountMembersWithRole(db *gorm.DB, tenantID uint, role string) (int64, error) {
+	var count int64
+	err := db.Model(&model.TenantMembership{}).
+		Where("tenant_id = ? AND role = ?", tenantID, role).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *TenantRepository) CreateInvitation(db *gorm.DB, invitation *model.TenantInvitation) error {
+	return db.Create(invitation).Error
+}
+
+func (r *TenantRepository) GetInvitation(db *gorm.DB, tenantID, userID uint) (*model.TenantInvitation, error) {
+	var invitation model.TenantInvitation
+	if err := db.Where("tenant_id = ? AND user_id = ?", tenantID, userID).First(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ListInvitationsByUser - lời mời còn hạn của user, tenant đã bị xóa thì bỏ qua
+func (r *TenantRepository) ListInvitationsByUser(db *gorm.DB, userID uint, now time.Time) ([]TenantInvitationWithTenant, error) {
+	var invitations []TenantInvitationWithTenant
+	err := db.Model(&model.TenantInvitation{}).
+		Select("tenant_invitations.*, tenants.name AS tenant_name").
+		Joins("JOIN tenants ON tenants.id = tenant_invitations.tenant_id AND tenants.deleted_at IS NULL").
+		Where("tenant_invitations.user_id = ? AND tenant_invitations.expires_at > ?", userID, now).
+		Order("tenant_invitations.id").
+		Scan(&invitations).Error
+	return invitations, err
+}
+
+func (r *TenantRepository) DeleteInvitation(db *gorm.DB, tenantID, userID uint) error {
+	result := db.Where("tenant_id = ? AND user_id = ?", tenantID, userID).Delete(&model.TenantInvitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
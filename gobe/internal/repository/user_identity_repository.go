@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"iot/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserIdentityRepositoryInterface interface {
+	CreateIdentity(db *gorm.DB, identity *model.UserIdentity) error
+	GetByIssuerSubject(db *gorm.DB, issuer, subject string) (*model.UserIdentity, error)
+	TouchLogin(db *gorm.DB, id uint, email string, now time.Time) error
+}
+
+type UserIdentityRepository struct{}
+
+func NewUserIdentityRepository() UserIdentityRepositoryInterface {
+	return &UserIdentityRepository{}
+}
+
+func (r *UserIdentityRepository) CreateIdentity(db *gorm.DB, identity *model.UserIdentity) error {
+	return db.Create(identity).Error
+}
+
+func (r *UserIdentityRepository) GetByIssuerSubject(db *gorm.DB, issuer, subject string) (*model.UserIdentity, error) {
+	var identity model.UserIdentity
+	if err := db.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// TouchLogin - cập nhật email mới nhất provider trả về và thời điểm đăng nhập
+func (r *UserIdentityRepository) TouchLogin(db *gorm.DB, id uint, email string, now time.Time) error {
+	return db.Model(&model.UserIdentity{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"email":         email,
+		"last_login_at": now,
+	}).Error
+}
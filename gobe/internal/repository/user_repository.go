@@ -3,21 +3,40 @@ package repository
 import (
 	"iot/internal/dto"
 	"iot/internal/model"
+	"iot/internal/tenant"
+	"iot/pkg/pagination"
 	"log"
 
 	"gorm.io/gorm"
 )
 
+// UserSort - field được sort, mặc định theo id tăng dần như trước
+var UserSort = &pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":         {Column: "users.id", Kind: pagination.KindInteger},
+		"name":       {Column: "users.name", Kind: pagination.KindString},
+		"email":      {Column: "users.email", Kind: pagination.KindString},
+		"created_at": {Column: "users.created_at", Kind: pagination.KindTime},
+	},
+	Default: "id:asc",
+	Unique:  "id",
+}
+
 type UserRepositoryInterface interface {
 	CreateUser(db *gorm.DB, user *model.User) error
 	GetByID(db *gorm.DB, id uint) (*model.User, error)
 	GetByEmail(db *gorm.DB, email string) (*model.User, error)
-	GetAllUsers(db *gorm.DB, limit, offset int) ([]model.User, error)
+	GetAllUsers(db *gorm.DB, page *pagination.Request) ([]model.User, *pagination.Page, error)
 	UpdateUser(db *gorm.DB, Id uint, data *dto.UpdateUserRequest) error
 	DeleteUser(db *gorm.DB, id uint) error
 	CheckEmailExists(db *gorm.DB, email string) (bool, error)
+	UpdateRole(db *gorm.DB, id uint, role string) error
+	UpdatePassword(db *gorm.DB, id uint, hashedPassword string) error
+	UpdateTwoFactor(db *gorm.DB, id uint, method, totpSecret string) error
 }
 
+// UserRepository - tài khoản dùng chung giữa các tenant. db chưa chọn tenant (đăng nhập, đăng ký...)
+// thấy mọi user, db đã chọn tenant chỉ thấy thành viên của tenant đó.
 type UserRepository struct{}
 
 func NewUserRepository() UserRepositoryInterface {
@@ -33,7 +52,7 @@ func (r *UserRepository) CreateUser(db *gorm.DB, user *model.User) error {
 // GetByID - tìm user theo ID
 func (r *UserRepository) GetByID(db *gorm.DB, id uint) (*model.User, error) {
 	var user model.User
-	if err := db.First(&user, id).Error; err != nil {
+	if err := scopeUsers(db).First(&user, id).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -43,7 +62,7 @@ func (r *UserRepository) GetByID(db *gorm.DB, id uint) (*model.User, error) {
 // GetByEmail - tìm user theo email
 func (r *UserRepository) GetByEmail(db *gorm.DB, email string) (*model.User, error) {
 	var user model.User
-	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := scopeUsers(db).Where("email = ?", email).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -56,30 +75,36 @@ func UpdateUser(db *gorm.DB, user *model.User) error {
 func (r *UserRepository) UpdateUser(db *gorm.DB, id uint, data *dto.UpdateUserRequest) error {
 	// 1. Tìm user theo ID
 	var user model.User
-	if err := db.First(&user, id).Error; err != nil {
+	if err := scopeUsers(db).First(&user, id).Error; err != nil {
 		return err // không tìm thấy user
 	}
-	user.Name = data.Name
-	user.Email = data.Email
+	if data.Name != "" {
+		user.Name = data.Name
+	}
+	if data.Email != "" {
+		user.Email = data.Email
+	}
 
 	return db.Save(&user).Error
 }
 
+// DeleteUser - xóa user cùng membership của user ở mọi tenant
 func (r *UserRepository) DeleteUser(db *gorm.DB, id uint) error {
-	result := db.Unscoped().Where("id = ?", id).Delete(&model.User{})
-	if result.RowsAffected == 0 {
-		log.Println("No rows affected, user may not exist")
-		return gorm.ErrRecordNotFound
-	}
-	return result.Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := scopeUsers(tx).Unscoped().Where("id = ?", id).Delete(&model.User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			log.Println("No rows affected, user may not exist")
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Unscoped().Where("user_id = ?", id).Delete(&model.TenantMembership{}).Error
+	})
 }
 
-func (r *UserRepository) GetAllUsers(db *gorm.DB, limit, offset int) ([]model.User, error) {
-	var users []model.User
-	if err := db.Limit(limit).Offset(offset).Find(&users).Error; err != nil {
-		return nil, err
-	}
-	return users, nil
+func (r *UserRepository) GetAllUsers(db *gorm.DB, page *pagination.Request) ([]model.User, *pagination.Page, error) {
+	return pagination.Find[model.User](scopeUsers(db).Model(&model.User{}), UserSort, page)
 }
 
 func (r *UserRepository) CheckEmailExists(db *gorm.DB, email string) (bool, error) {
@@ -89,3 +114,45 @@ func (r *UserRepository) CheckEmailExists(db *gorm.DB, email string) (bool, erro
 	}
 	return count > 0, nil
 }
+
+// UpdateRole - gán role cho user
+func (r *UserRepository) UpdateRole(db *gorm.DB, id uint, role string) error {
+	result := db.Model(&model.User{}).Where("id = ?", id).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdatePassword - lưu mật khẩu đã hash
+func (r *UserRepository) UpdatePassword(db *gorm.DB, id uint, hashedPassword string) error {
+	result := db.Model(&model.User{}).Where("id = ?", id).Update("password", hashedPassword)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateTwoFactor - bật/tắt 2FA, method rỗng là tắt
+func (r *UserRepository) UpdateTwoFactor(db *gorm.DB, id uint, method, totpSecret string) error {
+	return db.Model(&model.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"two_factor_method": method,
+		"totp_secret":       totpSecret,
+	}).Error
+}
+
+// scopeUsers - giới hạn theo membership khi db đã chọn tenant
+func scopeUsers(db *gorm.DB) *gorm.DB {
+	id := tenant.ID(db)
+	if id == 0 {
+		return db
+	}
+	members := db.Model(&model.TenantMembership{}).Select("user_id").Where("tenant_id = ?", id)
+	return db.Where("users.id IN (?)", members)
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyRoute struct {
+	APIKeyHandler handler.APIKeyHandlerInterface
+}
+
+func (r *APIKeyRoute) Setup(api *gin.RouterGroup) {
+	// Key của chính user, chỉ quản lý được khi đăng nhập bằng session để key không tự sinh key
+	keys := api.Group("/user/api-keys")
+	{
+		keys.Use(middlewares.Authen(), middlewares.SessionOnly())
+		{
+			keys.POST("", r.APIKeyHandler.CreateAPIKey)
+			keys.GET("", r.APIKeyHandler.ListAPIKeys)
+			keys.DELETE("/:keyId", r.APIKeyHandler.RevokeAPIKey)
+		}
+	}
+
+	admin := api.Group("/admin/users/:id/api-keys")
+	{
+		admin.Use(middlewares.Authen(), middlewares.Authorize(middlewares.PermUserManage))
+		{
+			admin.GET("", r.APIKeyHandler.ListUserAPIKeys)
+			admin.DELETE("/:keyId", r.APIKeyHandler.RevokeUserAPIKey)
+		}
+	}
+}
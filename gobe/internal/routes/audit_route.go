@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditRoute struct {
+	AuditHandler handler.AuditHandlerInterface
+}
+
+func (r *AuditRoute) Setup(api *gin.RouterGroup) {
+	audit := api.Group("/admin/audit-logs")
+	{
+		audit.Use(middlewares.Authen(), middlewares.Authorize(middlewares.PermAuditRead))
+		{
+			audit.GET("", r.AuditHandler.ListAuditLogs)
+			audit.GET("/export", r.AuditHandler.ExportAuditLogs)
+			audit.GET("/verify", r.AuditHandler.VerifyAuditChain)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BrokerAuthRoute struct {
+	BrokerAuthHandler handler.BrokerAuthHandlerInterface
+	AllowedIPs        []string
+}
+
+// Setup - chỉ broker gọi các endpoint này, giới hạn bằng MQTT_AUTH_ALLOWED_IPS.
+// Danh sách rỗng thì chặn hết để không ai dò được credential/ACL qua các endpoint này.
+func (r *BrokerAuthRoute) Setup(api *gin.RouterGroup) {
+	broker := api.Group("/mqtt/auth")
+	{
+		broker.Use(middlewares.RequireIPs(r.AllowedIPs))
+		{
+			broker.POST("/user", r.BrokerAuthHandler.User)
+			broker.POST("/superuser", r.BrokerAuthHandler.Superuser)
+			broker.POST("/acl", r.BrokerAuthHandler.ACL)
+		}
+	}
+}
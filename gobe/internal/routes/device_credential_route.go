@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+	"iot/pkg/ratelimit"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeviceCredentialRoute struct {
+	DeviceCredentialHandler handler.DeviceCredentialHandlerInterface
+	Limiter                 *ratelimit.Limiter
+}
+
+func (r *DeviceCredentialRoute) Setup(api *gin.RouterGroup) {
+	credentials := api.Group("/device/:id/credentials")
+	{
+		credentials.Use(middlewares.Authen(), middlewares.Authorize(middlewares.PermDeviceManage))
+		{
+			credentials.POST("", r.DeviceCredentialHandler.IssueCredential)
+			credentials.GET("", r.DeviceCredentialHandler.GetCredential)
+			credentials.POST("/rotate", r.DeviceCredentialHandler.RotateCredential)
+			credentials.DELETE("", r.DeviceCredentialHandler.RevokeCredential)
+		}
+	}
+
+	// Board đổi claim code lấy secret, không có user token nên giới hạn theo IP
+	claimLimit := middlewares.RateLimit(r.Limiter, "device_claim",
+		middlewares.RateRule{Scope: "ip", Limit: 10, Window: 10 * time.Minute, Key: middlewares.ByIP},
+	)
+	api.POST("/provision/claim", claimLimit, r.DeviceCredentialHandler.ClaimDevice)
+}
@@ -16,9 +16,9 @@ func (r *DeviceHistoryRoute) Setup(api *gin.RouterGroup) {
 	{
 		DeviceHistory.Use(middlewares.Authen())
 		{
-			DeviceHistory.POST("/", r.DeviceHistoryHandler.CreateDeviceHistory)
-			DeviceHistory.GET("", r.DeviceHistoryHandler.GetAllDeviceHistories)
-			DeviceHistory.GET("/:deviceID", r.DeviceHistoryHandler.GetDeviceHistoryByDeviceID)
+			DeviceHistory.POST("/", middlewares.Authorize(middlewares.PermHistoryWrite), r.DeviceHistoryHandler.CreateDeviceHistory)
+			DeviceHistory.GET("", middlewares.Authorize(middlewares.PermHistoryRead), r.DeviceHistoryHandler.GetAllDeviceHistories)
+			DeviceHistory.GET("/:deviceID", middlewares.Authorize(middlewares.PermHistoryRead), r.DeviceHistoryHandler.GetDeviceHistoryByDeviceID)
 		}
 	}
 }
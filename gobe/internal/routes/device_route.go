@@ -16,12 +16,15 @@ func (r *DeviceRoute) Setup(api *gin.RouterGroup) {
 	{
 		Device.Use(middlewares.Authen())
 		{
-			Device.POST("/", r.DeviceHandler.CreateDevice)
-			Device.GET("/:id", r.DeviceHandler.GetDeviceByID)
-			Device.GET("/all", r.DeviceHandler.GetAllDevices)
-			Device.PUT("/:id", r.DeviceHandler.UpdateDevice)
-			Device.DELETE("/:id", r.DeviceHandler.DeleteDevice)
-			Device.POST("/control", r.DeviceHandler.DeviceController)
+			Device.POST("/", middlewares.Authorize(middlewares.PermDeviceManage), r.DeviceHandler.CreateDevice)
+			Device.GET("/:id", middlewares.Authorize(middlewares.PermDeviceRead), r.DeviceHandler.GetDeviceByID)
+			Device.GET("/all", middlewares.Authorize(middlewares.PermDeviceRead), r.DeviceHandler.GetAllDevices)
+			Device.PUT("/:id", middlewares.Authorize(middlewares.PermDeviceManage), r.DeviceHandler.UpdateDevice)
+			Device.DELETE("/:id", middlewares.Authorize(middlewares.PermDeviceManage), r.DeviceHandler.DeleteDevice)
+			Device.POST("/control", middlewares.Authorize(middlewares.PermDeviceControl), r.DeviceHandler.DeviceController)
+			Device.GET("/:id/share", middlewares.Authorize(middlewares.PermDeviceManage), r.DeviceHandler.GetShares)
+			Device.POST("/:id/share", middlewares.Authorize(middlewares.PermDeviceManage), r.DeviceHandler.ShareDevice)
+			Device.DELETE("/:id/share/:userId", middlewares.Authorize(middlewares.PermDeviceManage), r.DeviceHandler.RevokeShare)
 		}
 	}
 }
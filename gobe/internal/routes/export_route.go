@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportRoute struct {
+	ExportHandler handler.ExportHandlerInterface
+}
+
+func (r *ExportRoute) Setup(api *gin.RouterGroup) {
+	auth := middlewares.Authen()
+	api.GET("/sensor/export", auth, middlewares.Authorize(middlewares.PermSensorRead), r.ExportHandler.ExportSensorData)
+	api.GET("/device_history/export", auth, middlewares.Authorize(middlewares.PermHistoryRead), r.ExportHandler.ExportDeviceHistories)
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"iot/internal/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthRoute struct {
+	HealthHandler handler.HealthHandlerInterface
+}
+
+// Setup - /healthz, /readyz nằm ngoài /api/v1 và không cần đăng nhập cho probe của orchestrator
+func (r *HealthRoute) Setup(engine *gin.Engine) {
+	engine.GET("/healthz", r.HealthHandler.Liveness)
+	engine.GET("/readyz", r.HealthHandler.Readiness)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImportRoute struct {
+	ImportHandler handler.ImportHandlerInterface
+}
+
+func (r *ImportRoute) Setup(api *gin.RouterGroup) {
+	auth := middlewares.Authen()
+	api.POST("/sensor/import", auth, middlewares.Authorize(middlewares.PermSensorWrite), r.ImportHandler.ImportSensorData)
+	api.POST("/device_history/import", auth, middlewares.Authorize(middlewares.PermHistoryWrite), r.ImportHandler.ImportDeviceHistories)
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobRoute struct {
+	JobHandler handler.JobHandlerInterface
+}
+
+func (r *JobRoute) Setup(api *gin.RouterGroup) {
+	jobs := api.Group("/jobs")
+	{
+		jobs.Use(middlewares.Authen())
+		{
+			jobs.GET("", r.JobHandler.ListJobs)
+			jobs.GET("/:id", r.JobHandler.GetJob)
+			jobs.GET("/:id/download", r.JobHandler.DownloadJobResult)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"iot/internal/middlewares"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type MetricsRoute struct {
+	AllowedIPs []string
+}
+
+// Setup - /metrics nằm ngoài /api/v1 cho Prometheus scrape, giới hạn bằng METRICS_ALLOWED_IPS
+func (r *MetricsRoute) Setup(engine *gin.Engine) {
+	engine.GET("/metrics", middlewares.AllowIPs(r.AllowedIPs), gin.WrapH(promhttp.Handler()))
+}
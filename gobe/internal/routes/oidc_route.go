@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+	"iot/pkg/ratelimit"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCRoute struct {
+	OIDCHandler handler.OIDCHandlerInterface
+	Limiter     *ratelimit.Limiter
+}
+
+func (r *OIDCRoute) Setup(api *gin.RouterGroup) {
+	oidcLimit := middlewares.RateLimit(r.Limiter, "oidc",
+		middlewares.RateRule{Scope: "ip", Limit: 20, Window: time.Minute, Key: middlewares.ByIP},
+	)
+
+	oidc := api.Group("/auth/oidc")
+	{
+		oidc.GET("/login", oidcLimit, r.OIDCHandler.Login)
+		oidc.GET("/callback", oidcLimit, r.OIDCHandler.Callback)
+	}
+}
@@ -2,13 +2,26 @@ package routes
 
 import (
 	"context"
+	"errors"
+	"iot/internal/dto"
 	"iot/internal/handler"
 	"iot/internal/helper/mailer"
+	"iot/internal/middlewares"
 
 	"iot/internal/repository"
 	"iot/internal/services"
+	"iot/internal/storage"
 
+	"iot/pkg/config"
+	"iot/pkg/health"
 	"iot/pkg/logger"
+	"iot/pkg/metrics"
+	"iot/pkg/ratelimit"
+	"iot/pkg/stream"
+	"iot/pkg/tracing"
+	"net"
+	"strconv"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gin-gonic/gin"
@@ -16,48 +29,184 @@ import (
 	"gorm.io/gorm"
 )
 
-func InitRouter(r *gin.Engine, db *gorm.DB, mailer *mailer.MailService, redis *redis.Client, ctx context.Context, mqtt mqtt.Client) *gin.Engine {
+func InitRouter(r *gin.Engine, db *gorm.DB, mailer *mailer.MailService, redis *redis.Client, ctx context.Context, mqtt mqtt.Client, events *stream.Broker, realtime services.RealtimeServiceInterface) *gin.Engine {
 	// Middleware
+	// tracing, metrics đứng ngoài recovery để request panic vẫn được ghi với status 500.
+	// gin chỉ áp middleware cho route đăng ký sau r.Use, nên InitRouter phải chạy trước mọi route khác (/ws)
+	r.Use(tracing.GinMiddleware(config.GetConfig().TracingConfig.ServiceName)...)
 	r.Use(logger.GinLogger())
+	r.Use(metrics.GinMiddleware())
 	r.Use(logger.GinRecovery(true))
 
+	sessionService := services.NewSessionService(redis)
+	middlewares.SetSessionChecker(sessionService)
+	limiter := ratelimit.NewLimiter(redis)
+	auditService := services.NewAuditService(repository.NewAuditLogRepository())
+	tenantService := services.NewTenantService(repository.NewTenantRepository(), repository.NewUserRepository(), sessionService)
+	apiKeyService := services.NewAPIKeyService(repository.NewAPIKeyRepository(), repository.NewUserRepository(), tenantService)
+	middlewares.SetAPIKeyAuthenticator(&apiKeyAuthenticator{db: db, keys: apiKeyService})
+
+	(&MetricsRoute{AllowedIPs: config.GetConfig().MetricsAllowedIPs}).Setup(r)
+	SetupHealthRoute(r, db, redis, mqtt)
+
 	api := r.Group("/api/v1")
-	SetupUserRoute(api, db, mailer, redis, ctx)
-	SetupDeviceRoute(api, db, redis, ctx, mqtt)
+	twoFactorService := services.NewTwoFactorService(redis, repository.NewUserRepository(), repository.NewRecoveryCodeRepository(), sessionService, tenantService)
+	SetupUserRoute(api, db, mailer, redis, ctx, limiter, sessionService, twoFactorService, tenantService, auditService)
+	SetupTwoFactorRoute(api, db, limiter, twoFactorService, auditService)
+	SetupOIDCRoute(api, db, mailer, redis, limiter, sessionService, twoFactorService, tenantService, auditService)
+	SetupTenantRoute(api, db, tenantService, auditService)
+	SetupAPIKeyRoute(api, db, apiKeyService, auditService)
+	SetupDeviceRoute(api, db, redis, ctx, mqtt, auditService)
+	SetupDeviceCredentialRoute(api, db, limiter, auditService)
+	SetupBrokerAuthRoute(api, db)
 	SetupSensorRoute(api, db, redis)
 	SetupDeviceHistoryRoute(api, db)
+	SetupStreamRoute(api, db, events, realtime)
+	SetupAuditRoute(api, db, auditService, tenantService)
+	jobService := services.NewJobService(ctx, db, repository.NewJobRepository(), config.GetConfig().ExportConfig)
+	go jobService.RunMaintenance()
+	SetupJobRoute(api, db, jobService)
+	SetupExportRoute(api, db, jobService)
+	SetupImportRoute(api, db, jobService)
 	return r
 }
 
-func SetupUserRoute(api *gin.RouterGroup, db *gorm.DB, mailer *mailer.MailService, redis *redis.Client, ctx context.Context) {
+// SetupHealthRoute - database (tên check theo driver: mysql, postgres, sqlite, down cả khi schema lệch), Redis là bắt buộc;
+// MQTT, SMTP thiếu thì API vẫn chạy ở chế độ degraded
+func SetupHealthRoute(r *gin.Engine, db *gorm.DB, redis *redis.Client, mqtt mqtt.Client) {
+	checker := health.NewChecker(2*time.Second, 2*time.Second)
+	checker.Add(db.Dialector.Name(), true, func(ctx context.Context) error {
+		if err := storage.SchemaError(); err != nil {
+			return err
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	checker.Add("redis", true, func(ctx context.Context) error {
+		if redis == nil {
+			return health.ErrNotConfigured
+		}
+		return redis.Ping(ctx).Err()
+	})
+	checker.Add("mqtt", false, func(ctx context.Context) error {
+		if mqtt == nil {
+			return health.ErrNotConfigured
+		}
+		if !mqtt.IsConnectionOpen() {
+			return errors.New("not connected to broker")
+		}
+		return nil
+	})
+	smtpAddress := ""
+	if emailConfig := config.GetConfig().EmailConfig; emailConfig.Host != "" {
+		smtpAddress = net.JoinHostPort(emailConfig.Host, strconv.Itoa(emailConfig.Port))
+	}
+	checker.Add("smtp", false, health.TCPCheck(smtpAddress))
+	checker.OnResult(func(name string, result health.Result) {
+		up := 0.0
+		if result.Status != health.StatusDown {
+			up = 1
+		}
+		metrics.DependencyUp.WithLabelValues(name).Set(up)
+		metrics.DependencyLatency.WithLabelValues(name).Set(result.LatencyMs / 1000)
+	})
+
+	healthHandler := handler.NewHealthHandler(checker)
+	(&HealthRoute{HealthHandler: healthHandler}).Setup(r)
+}
+
+func SetupUserRoute(api *gin.RouterGroup, db *gorm.DB, mailer *mailer.MailService, redis *redis.Client, ctx context.Context, limiter *ratelimit.Limiter, sessions services.SessionServiceInterface, twoFactor services.TwoFactorServiceInterface, tenants services.TenantServiceInterface, audit services.AuditServiceInterface) {
 	userRepo := repository.NewUserRepository()
 	// Khởi tạo service
-	userService := services.NewUserService(userRepo) // service thực hiện logic
+	userService := services.NewUserService(userRepo, sessions, twoFactor, services.NewLockoutService(redis), tenants) // service thực hiện logic
 	// Khởi tạo handler
-	userHandler := handler.NewUserHandler(db, userService, mailer, redis, ctx)
+	userHandler := handler.NewUserHandler(db, userService, mailer, redis, ctx, audit)
 
 	// Setup route
-	(&UserRoute{UserHandler: userHandler}).Setup(api)
+	(&UserRoute{UserHandler: userHandler, Limiter: limiter}).Setup(api)
+}
+
+func SetupTwoFactorRoute(api *gin.RouterGroup, db *gorm.DB, limiter *ratelimit.Limiter, twoFactor services.TwoFactorServiceInterface, audit services.AuditServiceInterface) {
+	twoFactorHandler := handler.NewTwoFactorHandler(db, twoFactor, audit)
+
+	(&TwoFactorRoute{TwoFactorHandler: twoFactorHandler, Limiter: limiter}).Setup(api)
+}
+
+// SetupOIDCRoute - chỉ bật khi đã cấu hình OIDC_ISSUER và OIDC_CLIENT_ID
+func SetupOIDCRoute(api *gin.RouterGroup, db *gorm.DB, mailer *mailer.MailService, redis *redis.Client, limiter *ratelimit.Limiter, sessions services.SessionServiceInterface, twoFactor services.TwoFactorServiceInterface, tenants services.TenantServiceInterface, audit services.AuditServiceInterface) {
+	oidcConfig := config.GetConfig().OIDCConfig
+	if !oidcConfig.Enabled() {
+		return
+	}
+	oidcService := services.NewOIDCService(oidcConfig, redis, repository.NewUserRepository(), repository.NewUserIdentityRepository(), sessions, twoFactor, tenants, repository.NewTenantRepository())
+	oidcHandler := handler.NewOIDCHandler(db, mailer, oidcService, audit)
+
+	(&OIDCRoute{OIDCHandler: oidcHandler, Limiter: limiter}).Setup(api)
 }
 
-func SetupDeviceRoute(api *gin.RouterGroup, db *gorm.DB, redis *redis.Client, ctx context.Context, mqtt mqtt.Client) {
+func SetupTenantRoute(api *gin.RouterGroup, db *gorm.DB, tenants services.TenantServiceInterface, audit services.AuditServiceInterface) {
+	tenantHandler := handler.NewTenantHandler(db, tenants, audit)
+
+	(&TenantRoute{TenantHandler: tenantHandler}).Setup(api)
+}
+
+func SetupAPIKeyRoute(api *gin.RouterGroup, db *gorm.DB, keys services.APIKeyServiceInterface, audit services.AuditServiceInterface) {
+	apiKeyHandler := handler.NewAPIKeyHandler(db, keys, audit)
+
+	(&APIKeyRoute{APIKeyHandler: apiKeyHandler}).Setup(api)
+}
+
+func SetupDeviceRoute(api *gin.RouterGroup, db *gorm.DB, redis *redis.Client, ctx context.Context, mqtt mqtt.Client, audit services.AuditServiceInterface) {
 	deviceRepo := repository.NewDeviceRepository()
 	historyRepo := repository.NewDeviceHistoryRepository()
+	grantRepo := repository.NewDeviceGrantRepository()
+	credentialRepo := repository.NewDeviceCredentialRepository()
+	tenantRepo := repository.NewTenantRepository()
 	// Khởi tạo service
-	deviceService := services.NewDeviceService(deviceRepo, historyRepo) // service thực hiện logic
+	deviceService := services.NewDeviceService(deviceRepo, historyRepo, grantRepo, credentialRepo, tenantRepo) // service thực hiện logic
 	// Khởi tạo handler
-	deviceHandler := handler.NewDeviceHandler(db, redis, deviceService, ctx, mqtt)
+	deviceHandler := handler.NewDeviceHandler(db, redis, deviceService, ctx, mqtt, audit)
 
 	// Setup route
 	(&DeviceRoute{DeviceHandler: deviceHandler}).Setup(api)
 }
 
+func SetupDeviceCredentialRoute(api *gin.RouterGroup, db *gorm.DB, limiter *ratelimit.Limiter, audit services.AuditServiceInterface) {
+	deviceService := newDeviceService()
+	credentialService := services.NewDeviceCredentialService(repository.NewDeviceCredentialRepository(), deviceService)
+	credentialHandler := handler.NewDeviceCredentialHandler(db, credentialService, audit)
+
+	(&DeviceCredentialRoute{DeviceCredentialHandler: credentialHandler, Limiter: limiter}).Setup(api)
+}
+
+func SetupBrokerAuthRoute(api *gin.RouterGroup, db *gorm.DB) {
+	mqttConfig := config.GetConfig().MQTTConfig
+	backend := services.BrokerAccount{
+		Username: mqttConfig.Username,
+		Password: mqttConfig.Password,
+		ClientID: mqttConfig.ClientID,
+	}
+	credentialRepo := repository.NewDeviceCredentialRepository()
+	credentialService := services.NewDeviceCredentialService(credentialRepo, newDeviceService())
+	brokerAuthService := services.NewBrokerAuthService(backend, credentialRepo, credentialService)
+	brokerAuthHandler := handler.NewBrokerAuthHandler(db, brokerAuthService)
+
+	if len(mqttConfig.AuthAllowedIPs) == 0 {
+		logger.Log.Warn("mqtt.auth_allowed_ips is empty, broker auth endpoints reject every request")
+	}
+	(&BrokerAuthRoute{BrokerAuthHandler: brokerAuthHandler, AllowedIPs: mqttConfig.AuthAllowedIPs}).Setup(api)
+}
+
 func SetupSensorRoute(api *gin.RouterGroup, db *gorm.DB, redis *redis.Client) {
 	sensorRepo := repository.NewSensorRepository()
 	// Khởi tạo service
 	sensorService := services.NewSensorService(sensorRepo) // service thực hiện logic
 	// Khởi tạo handler
-	sensorHandler := handler.NewSensorHandler(sensorService, redis, db)
+	deviceService := newDeviceService()
+	sensorHandler := handler.NewSensorHandler(sensorService, deviceService, redis, db)
 
 	// Setup route
 	(&SensorRoute{SensorHandler: sensorHandler}).Setup(api)
@@ -73,3 +222,57 @@ func SetupDeviceHistoryRoute(api *gin.RouterGroup, db *gorm.DB) {
 	// Setup route
 	(&DeviceHistoryRoute{DeviceHistoryHandler: historyHandler}).Setup(api)
 }
+
+func SetupStreamRoute(api *gin.RouterGroup, db *gorm.DB, events *stream.Broker, realtime services.RealtimeServiceInterface) {
+	deviceService := newDeviceService()
+	streamHandler := handler.NewStreamHandler(db, events, realtime, deviceService)
+
+	// Setup route
+	(&StreamRoute{StreamHandler: streamHandler}).Setup(api)
+}
+
+func SetupAuditRoute(api *gin.RouterGroup, db *gorm.DB, audit services.AuditServiceInterface, tenants services.TenantServiceInterface) {
+	auditHandler := handler.NewAuditHandler(db, audit, tenants)
+
+	(&AuditRoute{AuditHandler: auditHandler}).Setup(api)
+}
+
+func SetupJobRoute(api *gin.RouterGroup, db *gorm.DB, jobs services.JobServiceInterface) {
+	jobHandler := handler.NewJobHandler(db, jobs)
+
+	(&JobRoute{JobHandler: jobHandler}).Setup(api)
+}
+
+func SetupExportRoute(api *gin.RouterGroup, db *gorm.DB, jobs services.JobServiceInterface) {
+	exportService := services.NewExportService(repository.NewSensorRepository(), repository.NewDeviceHistoryRepository(), jobs, config.GetConfig().ExportConfig)
+	exportHandler := handler.NewExportHandler(db, exportService, newDeviceService())
+
+	(&ExportRoute{ExportHandler: exportHandler}).Setup(api)
+}
+
+func SetupImportRoute(api *gin.RouterGroup, db *gorm.DB, jobs services.JobServiceInterface) {
+	exportConfig := config.GetConfig().ExportConfig
+	importService := services.NewImportService(repository.NewSensorRepository(), repository.NewDeviceHistoryRepository(), repository.NewDeviceRepository(), repository.NewTenantRepository(), jobs, exportConfig)
+	importHandler := handler.NewImportHandler(db, importService, exportConfig.ImportMaxBytes)
+
+	(&ImportRoute{ImportHandler: importHandler}).Setup(api)
+}
+
+// newDeviceService - DeviceService dùng chung cho các route cần kiểm tra quyền trên device
+func newDeviceService() services.DeviceServiceInterface {
+	return services.NewDeviceService(repository.NewDeviceRepository(), repository.NewDeviceHistoryRepository(), repository.NewDeviceGrantRepository(), repository.NewDeviceCredentialRepository(), repository.NewTenantRepository())
+}
+
+// apiKeyAuthenticator - gắn db cho APIKeyService để middleware không phụ thuộc gorm
+type apiKeyAuthenticator struct {
+	db   *gorm.DB
+	keys services.APIKeyServiceInterface
+}
+
+func (a *apiKeyAuthenticator) AuthenticateAPIKey(ctx context.Context, rawKey, ip string) (*dto.APIKeyPrincipal, error) {
+	principal, err := a.keys.Authenticate(a.db.WithContext(ctx), rawKey, ip)
+	if errors.Is(err, services.ErrInvalidAPIKey) {
+		return nil, nil
+	}
+	return principal, err
+}
@@ -17,9 +17,10 @@ func (r *SensorRoute) Setup(api *gin.RouterGroup) {
 	{
 		sensor.Use(auth)
 		{
-			sensor.GET("/all", r.SensorHandler.GetAllSensorData)
-			sensor.GET("/:id", r.SensorHandler.GetSensorDataByID)
-			sensor.GET("/last", r.SensorHandler.GetLastSensorData)
+			sensor.GET("/all", middlewares.Authorize(middlewares.PermSensorRead), r.SensorHandler.GetAllSensorData)
+			sensor.GET("/:id", middlewares.Authorize(middlewares.PermSensorRead), r.SensorHandler.GetSensorDataByID)
+			sensor.GET("/last", middlewares.Authorize(middlewares.PermSensorRead), r.SensorHandler.GetLastSensorData)
+			sensor.GET("/aggregate", middlewares.Authorize(middlewares.PermSensorRead), r.SensorHandler.AggregateSensorData)
 		}
 	}
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StreamRoute struct {
+	StreamHandler handler.StreamHandlerInterface
+}
+
+func (r *StreamRoute) Setup(api *gin.RouterGroup) {
+	api.GET("/stream", middlewares.Authen(), middlewares.Authorize(middlewares.PermSensorRead), r.StreamHandler.Stream)
+}
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TenantRoute struct {
+	TenantHandler handler.TenantHandlerInterface
+}
+
+func (r *TenantRoute) Setup(api *gin.RouterGroup) {
+	// Tenant của chính user, đổi tenant cấp session mới nên API key không dùng được
+	tenants := api.Group("/tenants")
+	{
+		tenants.Use(middlewares.Authen())
+		{
+			tenants.GET("", middlewares.SessionOnly(), r.TenantHandler.ListTenants)
+			tenants.POST("", middlewares.SessionOnly(), r.TenantHandler.CreateTenant)
+			tenants.POST("/switch", middlewares.SessionOnly(), r.TenantHandler.SwitchTenant)
+
+			// Lời mời gửi tới chính user, chỉ user được mời mới tạo được membership cho mình
+			tenants.GET("/invitations", middlewares.SessionOnly(), r.TenantHandler.ListInvitations)
+			tenants.POST("/invitations/:tenantId/accept", middlewares.SessionOnly(), r.TenantHandler.AcceptInvitation)
+			tenants.DELETE("/invitations/:tenantId", middlewares.SessionOnly(), r.TenantHandler.DeclineInvitation)
+
+			// Thành viên của tenant đang chọn, quyền theo role trong tenant
+			members := tenants.Group("/current/members")
+			{
+				members.GET("", middlewares.Authorize(middlewares.PermUserRead), r.TenantHandler.ListMembers)
+				members.POST("/invitations", middlewares.Authorize(middlewares.PermUserManage), r.TenantHandler.InviteMember)
+				members.PUT("/:userId", middlewares.Authorize(middlewares.PermRoleAssign), r.TenantHandler.UpdateMember)
+				members.DELETE("/:userId", middlewares.Authorize(middlewares.PermUserManage), r.TenantHandler.RemoveMember)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"iot/internal/handler"
+	"iot/internal/middlewares"
+	"iot/pkg/ratelimit"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorRoute struct {
+	TwoFactorHandler handler.TwoFactorHandlerInterface
+	Limiter          *ratelimit.Limiter
+}
+
+func (r *TwoFactorRoute) Setup(api *gin.RouterGroup) {
+	verifyLimit := middlewares.RateLimit(r.Limiter, "login_2fa",
+		middlewares.RateRule{Scope: "ip", Limit: 10, Window: 5 * time.Minute, Key: middlewares.ByIP},
+	)
+
+	user := api.Group("/user")
+	{
+		user.POST("/login/2fa", verifyLimit, r.TwoFactorHandler.VerifyLogin)
+	}
+
+	// Cấu hình 2FA của chính user, không cần permission riêng
+	twoFactor := api.Group("/user/2fa")
+	{
+		twoFactor.Use(middlewares.Authen(), middlewares.SessionOnly())
+		{
+			twoFactor.POST("/totp/setup", r.TwoFactorHandler.SetupTOTP)
+			twoFactor.POST("/totp/enable", r.TwoFactorHandler.EnableTOTP)
+			twoFactor.POST("/email/enable", r.TwoFactorHandler.EnableEmail)
+			twoFactor.POST("/disable", r.TwoFactorHandler.Disable)
+			twoFactor.POST("/recovery-codes", r.TwoFactorHandler.RegenerateRecoveryCodes)
+		}
+	}
+}
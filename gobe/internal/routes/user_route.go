@@ -3,32 +3,77 @@ package routes
 import (
 	"iot/internal/handler"
 	"iot/internal/middlewares"
+	"iot/pkg/ratelimit"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type UserRoute struct {
 	UserHandler handler.UserHandlerInterface
+	Limiter     *ratelimit.Limiter
 }
 
 func (r *UserRoute) Setup(api *gin.RouterGroup) {
 	auth := middlewares.Authen()
 
+	// Giới hạn theo IP và theo email để chống dò mật khẩu/OTP và spam email
+	loginLimit := middlewares.RateLimit(r.Limiter, "login",
+		middlewares.RateRule{Scope: "ip", Limit: 20, Window: time.Minute, Key: middlewares.ByIP},
+		middlewares.RateRule{Scope: "email", Limit: 10, Window: 15 * time.Minute, Key: middlewares.ByEmail},
+	)
+	registerOTPLimit := middlewares.RateLimit(r.Limiter, "register_otp",
+		middlewares.RateRule{Scope: "ip", Limit: 5, Window: 10 * time.Minute, Key: middlewares.ByIP},
+		middlewares.RateRule{Scope: "email", Limit: 3, Window: 10 * time.Minute, Key: middlewares.ByEmail},
+	)
+	registerLimit := middlewares.RateLimit(r.Limiter, "register",
+		middlewares.RateRule{Scope: "ip", Limit: 10, Window: 10 * time.Minute, Key: middlewares.ByIP},
+		middlewares.RateRule{Scope: "email", Limit: 10, Window: 10 * time.Minute, Key: middlewares.ByEmail},
+	)
+	forgotLimit := middlewares.RateLimit(r.Limiter, "password_forgot",
+		middlewares.RateRule{Scope: "ip", Limit: 5, Window: 10 * time.Minute, Key: middlewares.ByIP},
+		middlewares.RateRule{Scope: "email", Limit: 3, Window: time.Hour, Key: middlewares.ByEmail},
+	)
+	resetLimit := middlewares.RateLimit(r.Limiter, "password_reset",
+		middlewares.RateRule{Scope: "ip", Limit: 10, Window: 10 * time.Minute, Key: middlewares.ByIP},
+	)
+	refreshLimit := middlewares.RateLimit(r.Limiter, "refresh",
+		middlewares.RateRule{Scope: "ip", Limit: 60, Window: time.Minute, Key: middlewares.ByIP},
+	)
+
 	user := api.Group("/user")
 	{
-		user.POST("/register/otp", r.UserHandler.RegisterOTP)
-		user.POST("/register", r.UserHandler.Register)
-		user.POST("/login", r.UserHandler.Login)
-		user.POST("/refresh-token", r.UserHandler.RefreshToken)
+		user.POST("/register/otp", registerOTPLimit, r.UserHandler.RegisterOTP)
+		user.POST("/register", registerLimit, r.UserHandler.Register)
+		user.POST("/login", loginLimit, r.UserHandler.Login)
+		user.POST("/refresh-token", refreshLimit, r.UserHandler.RefreshToken)
 		user.POST("/logout", r.UserHandler.Logout)
+		user.POST("/password/forgot", forgotLimit, r.UserHandler.ForgotPassword)
+		user.POST("/password/reset", resetLimit, r.UserHandler.ResetPassword)
 
 		// Các route yêu cầu auth
 		user.Use(auth)
 		{
-			user.GET("/:id", r.UserHandler.GetUserByID)
-			user.GET("/all", r.UserHandler.GetAllUsers)
-			user.PUT("/:id", r.UserHandler.UpdateUser)
-			user.DELETE("/:id", r.UserHandler.DeleteUser)
+			// Session của chính user, không cần permission riêng
+			sessionOnly := middlewares.SessionOnly()
+			user.GET("/sessions", sessionOnly, r.UserHandler.ListSessions)
+			user.DELETE("/sessions/:sid", sessionOnly, r.UserHandler.RevokeSession)
+			user.POST("/logout-all", sessionOnly, r.UserHandler.LogoutAll)
+
+			user.GET("/:id", middlewares.AuthorizeSelfOr("id", middlewares.PermUserRead), r.UserHandler.GetUserByID)
+			user.GET("/all", middlewares.Authorize(middlewares.PermUserRead), r.UserHandler.GetAllUsers)
+			user.PUT("/:id", middlewares.AuthorizeSelfOr("id", middlewares.PermUserManage), r.UserHandler.UpdateUser)
+			// Xóa khỏi tenant đang chọn là DELETE /tenants/current/members/:userId
+		}
+	}
+
+	admin := api.Group("/admin")
+	{
+		admin.Use(auth)
+		{
+			admin.PUT("/users/:id/role", middlewares.Authorize(middlewares.PermRoleAssign), r.UserHandler.AssignRole)
+			// Xóa hẳn tài khoản, service chỉ cho admin hệ thống
+			admin.DELETE("/users/:id", middlewares.SessionOnly(), r.UserHandler.DeleteUser)
 		}
 	}
 }
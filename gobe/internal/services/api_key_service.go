@@ -0,0 +1,210 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid, expired or revoked API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrTooManyAPIKeys = errors.New("too many active API keys, revoke an unused key first")
+)
+
+const (
+	apiKeyDefaultTTL    = 90 * 24 * time.Hour
+	apiKeyMaxActive     = 20
+	apiKeyTouchInterval = time.Minute
+)
+
+type APIKeyServiceInterface interface {
+	Create(db *gorm.DB, userID uint, req *dto.CreateAPIKeyRequest) (*dto.CreateAPIKeyResponse, error)
+	List(db *gorm.DB, userID uint) ([]dto.APIKeyDTO, error)
+	Revoke(db *gorm.DB, userID, keyID uint) error
+	Authenticate(db *gorm.DB, rawKey, ip string) (*dto.APIKeyPrincipal, error)
+}
+
+type APIKeyService struct {
+	repo     repository.APIKeyRepositoryInterface
+	userRepo repository.UserRepositoryInterface
+	tenants  TenantServiceInterface
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepositoryInterface, userRepo repository.UserRepositoryInterface, tenants TenantServiceInterface) APIKeyServiceInterface {
+	return &APIKeyService{
+		repo:     repo,
+		userRepo: userRepo,
+		tenants:  tenants,
+	}
+}
+
+// Create - sinh key mới, bản rõ chỉ có trong response này
+func (s *APIKeyService) Create(db *gorm.DB, userID uint, req *dto.CreateAPIKeyRequest) (*dto.CreateAPIKeyResponse, error) {
+	now := time.Now()
+	count, err := s.repo.CountActive(db, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if count >= apiKeyMaxActive {
+		return nil, ErrTooManyAPIKeys
+	}
+
+	prefix, raw, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	ttl := apiKeyDefaultTTL
+	if req.ExpiresInDays > 0 {
+		ttl = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+	expiresAt := now.Add(ttl)
+	key := &model.APIKey{
+		UserID:    userID,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    prefix,
+		KeyHash:   hashSecret(raw),
+		Scopes:    strings.Join(uniqueScopes(req.Scopes), ","),
+		ExpiresAt: &expiresAt,
+	}
+	if err := s.repo.CreateAPIKey(db, key); err != nil {
+		return nil, err
+	}
+	return &dto.CreateAPIKeyResponse{APIKeyDTO: apiKeyToDTO(key, now), Key: raw}, nil
+}
+
+func (s *APIKeyService) List(db *gorm.DB, userID uint) ([]dto.APIKeyDTO, error) {
+	keys, err := s.repo.ListByUser(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := make([]dto.APIKeyDTO, 0, len(keys))
+	for i := range keys {
+		result = append(result, apiKeyToDTO(&keys[i], now))
+	}
+	return result, nil
+}
+
+func (s *APIKeyService) Revoke(db *gorm.DB, userID, keyID uint) error {
+	ok, err := s.repo.Revoke(db, userID, keyID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate - tìm theo prefix rồi so hash; role lấy từ membership hiện tại của chủ key trong tenant của key
+// nên hạ role hoặc xóa khỏi tenant có hiệu lực ngay
+func (s *APIKeyService) Authenticate(db *gorm.DB, rawKey, ip string) (*dto.APIKeyPrincipal, error) {
+	prefix, ok := parseAPIKey(rawKey)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.repo.GetByPrefix(db, prefix)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashSecret(rawKey))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetByID(db, key.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	selection, err := s.tenants.Select(db, user, key.TenantID)
+	if errors.Is(err, ErrNotTenantMember) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.TouchLastUsed(db, key.ID, ip, now, apiKeyTouchInterval); err != nil {
+		return nil, err
+	}
+	return &dto.APIKeyPrincipal{
+		KeyID:    key.ID,
+		UserID:   user.ID,
+		TenantID: selection.TenantID,
+		Name:     user.Name,
+		Email:    user.Email,
+		Role:     selection.Role,
+		Scopes:   key.ScopeList(),
+	}, nil
+}
+
+func generateAPIKey() (string, string, error) {
+	b := make([]byte, 6+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix := hex.EncodeToString(b[:6])
+	return prefix, model.APIKeyTokenPrefix + prefix + "_" + hex.EncodeToString(b[6:]), nil
+}
+
+func parseAPIKey(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, model.APIKeyTokenPrefix) {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(raw, model.APIKeyTokenPrefix), "_")
+	if !ok || len(prefix) != 12 || len(secret) != 64 {
+		return "", false
+	}
+	return prefix, true
+}
+
+func uniqueScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	var result []string
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return result
+}
+
+func apiKeyToDTO(key *model.APIKey, now time.Time) dto.APIKeyDTO {
+	status := "active"
+	switch {
+	case key.RevokedAt != nil:
+		status = "revoked"
+	case !key.Active(now):
+		status = "expired"
+	}
+	return dto.APIKeyDTO{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     model.APIKeyTokenPrefix + key.Prefix,
+		Scopes:     key.ScopeList(),
+		Status:     status,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		RevokedAt:  key.RevokedAt,
+	}
+}
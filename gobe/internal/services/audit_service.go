@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// auditAppendRetries - số lần thử lại khi tiến trình khác vừa nối vào chuỗi trước (trùng prev_hash)
+const auditAppendRetries = 5
+
+// auditChainMu - mọi AuditService trong tiến trình ghi tuần tự để không tranh nhau cuối chuỗi
+var auditChainMu sync.Mutex
+
+type AuditServiceInterface interface {
+	Record(ctx context.Context, db *gorm.DB, entry *dto.AuditEntry) error
+	List(db *gorm.DB, query *dto.AuditLogQuery) ([]dto.AuditLogDTO, int64, error)
+	Export(db *gorm.DB, query *dto.AuditLogQuery, fn func(dto.AuditLogDTO) error) error
+	Verify(db *gorm.DB) (*dto.AuditVerifyResult, error)
+}
+
+type AuditService struct {
+	repo repository.AuditLogRepositoryInterface
+}
+
+func NewAuditService(repo repository.AuditLogRepositoryInterface) AuditServiceInterface {
+	return &AuditService{repo: repo}
+}
+
+// Record - ghi cả khi request đã bị client hủy để không mất dấu vết hành động đã xảy ra
+func (s *AuditService) Record(ctx context.Context, db *gorm.DB, entry *dto.AuditEntry) error {
+	var details string
+	if len(entry.Details) > 0 {
+		raw, err := json.Marshal(entry.Details)
+		if err != nil {
+			return err
+		}
+		details = string(raw)
+	}
+	db = db.WithContext(context.WithoutCancel(ctx))
+
+	auditChainMu.Lock()
+	defer auditChainMu.Unlock()
+	var err error
+	for attempt := 0; attempt < auditAppendRetries; attempt++ {
+		log := &model.AuditLog{
+			CreatedAt:  time.Now().UTC().Truncate(time.Millisecond),
+			TenantID:   entry.TenantID,
+			ActorID:    entry.ActorID,
+			ActorEmail: truncate(entry.ActorEmail, 255),
+			APIKeyID:   entry.APIKeyID,
+			Action:     entry.Action,
+			Outcome:    entry.Outcome,
+			TargetType: truncate(entry.TargetType, 32),
+			TargetID:   truncate(entry.TargetID, 64),
+			IP:         truncate(entry.IP, 45),
+			UserAgent:  truncate(entry.UserAgent, 255),
+			Details:    details,
+		}
+		err = s.repo.Append(db, log)
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *AuditService) List(db *gorm.DB, query *dto.AuditLogQuery) ([]dto.AuditLogDTO, int64, error) {
+	logs, total, err := s.repo.List(db, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]dto.AuditLogDTO, 0, len(logs))
+	for i := range logs {
+		result = append(result, auditLogResponse(&logs[i]))
+	}
+	return result, total, nil
+}
+
+func (s *AuditService) Export(db *gorm.DB, query *dto.AuditLogQuery, fn func(dto.AuditLogDTO) error) error {
+	return s.repo.Each(db, query, func(batch []model.AuditLog) error {
+		for i := range batch {
+			if err := fn(auditLogResponse(&batch[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Verify - duyệt toàn bộ chuỗi, dừng ở bản ghi đầu tiên bị sửa (hash không khớp nội dung)
+// hoặc có bản ghi bị xóa/chèn phía trước (prev_hash không khớp hash bản ghi liền trước)
+func (s *AuditService) Verify(db *gorm.DB) (*dto.AuditVerifyResult, error) {
+	result := &dto.AuditVerifyResult{Valid: true}
+	prevHash := ""
+	errBroken := errors.New("audit chain broken")
+	err := s.repo.EachInChain(db, func(batch []model.AuditLog) error {
+		for i := range batch {
+			log := &batch[i]
+			switch {
+			case log.PrevHash != prevHash:
+				result.Reason = "prev_hash does not match the previous entry"
+			case log.ComputeHash() != log.Hash:
+				result.Reason = "hash does not match the entry content"
+			default:
+				prevHash = log.Hash
+				result.Checked++
+				continue
+			}
+			result.Valid = false
+			result.BrokenAt = log.ID
+			return errBroken
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBroken) {
+		return nil, err
+	}
+	return result, nil
+}
+
+func auditLogResponse(log *model.AuditLog) dto.AuditLogDTO {
+	response := dto.AuditLogDTO{
+		ID:         log.ID,
+		CreatedAt:  log.CreatedAt,
+		TenantID:   log.TenantID,
+		ActorID:    log.ActorID,
+		ActorEmail: log.ActorEmail,
+		APIKeyID:   log.APIKeyID,
+		Action:     log.Action,
+		Outcome:    log.Outcome,
+		TargetType: log.TargetType,
+		TargetID:   log.TargetID,
+		IP:         log.IP,
+		UserAgent:  log.UserAgent,
+		PrevHash:   log.PrevHash,
+		Hash:       log.Hash,
+	}
+	if log.Details != "" {
+		response.Details = json.RawMessage(log.Details)
+	}
+	return response
+}
+
+// truncate - cắt theo rune cho vừa cột varchar
+func truncate(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/internal/tenant"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestAuditVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(db *gorm.DB)
+		want   dto.AuditVerifyResult
+	}{
+		{"intact chain", func(db *gorm.DB) {}, dto.AuditVerifyResult{Valid: true, Checked: 4}},
+		{"edited entry", func(db *gorm.DB) {
+			db.Exec("UPDATE audit_logs SET outcome = 'success' WHERE id = 2")
+		}, dto.AuditVerifyResult{Checked: 1, BrokenAt: 2, Reason: "hash does not match the entry content"}},
+		{"edited entry with recomputed hash", func(db *gorm.DB) {
+			var entry model.AuditLog
+			db.First(&entry, 2)
+			entry.Outcome = "success"
+			db.Model(&entry).Updates(map[string]interface{}{"outcome": entry.Outcome, "hash": entry.ComputeHash()})
+		}, dto.AuditVerifyResult{Checked: 2, BrokenAt: 3, Reason: "prev_hash does not match the previous entry"}},
+		{"deleted entry", func(db *gorm.DB) {
+			db.Exec("DELETE FROM audit_logs WHERE id = 2")
+		}, dto.AuditVerifyResult{Checked: 1, BrokenAt: 3, Reason: "prev_hash does not match the previous entry"}},
+		{"deleted first entry", func(db *gorm.DB) {
+			db.Exec("DELETE FROM audit_logs WHERE id = 1")
+		}, dto.AuditVerifyResult{BrokenAt: 2, Reason: "prev_hash does not match the previous entry"}},
+		{"truncated tail is not detected", func(db *gorm.DB) {
+			db.Exec("DELETE FROM audit_logs WHERE id = 4")
+		}, dto.AuditVerifyResult{Valid: true, Checked: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			repo := repository.NewAuditLogRepository()
+			start := time.Date(2025, 10, 1, 8, 0, 0, 0, time.UTC)
+			for i, outcome := range []string{"success", "failure", "success", "success"} {
+				entry := &model.AuditLog{
+					CreatedAt: start.Add(time.Duration(i) * time.Minute),
+					TenantID:  testTenantID,
+					ActorID:   uint(i + 1),
+					Action:    model.AuditLogin,
+					Outcome:   outcome,
+				}
+				if err := repo.Append(db, entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := db.Exec("UPDATE audit_logs SET outcome = 'success' WHERE id = 2").Error; err == nil {
+				t.Fatal("audit_logs accepted an UPDATE, want the append-only trigger to reject it")
+			}
+			// người có quyền trên database bỏ được trigger, verify phải phát hiện phần còn lại
+			db.Exec("DROP TRIGGER audit_logs_no_update")
+			db.Exec("DROP TRIGGER audit_logs_no_delete")
+			tt.tamper(db)
+
+			got, err := NewAuditService(repo).Verify(tenant.System(db))
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
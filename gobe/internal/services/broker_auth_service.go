@@ -0,0 +1,102 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/repository"
+	mymqtt "iot/pkg/mqtt"
+
+	"gorm.io/gorm"
+)
+
+// Giá trị acc do plugin auth của Mosquitto gửi lên
+const (
+	MQTTAccRead      = 1
+	MQTTAccWrite     = 2
+	MQTTAccReadWrite = 3
+	MQTTAccSubscribe = 4
+)
+
+// BrokerAccount - tài khoản MQTT của chính backend, có toàn quyền trên broker
+type BrokerAccount struct {
+	Username string
+	Password string
+	ClientID string
+}
+
+type BrokerAuthServiceInterface interface {
+	AuthenticateUser(db *gorm.DB, req *dto.BrokerUserRequest) (bool, error)
+	IsSuperuser(req *dto.BrokerSuperuserRequest) bool
+	CheckACL(db *gorm.DB, req *dto.BrokerACLRequest) (bool, error)
+}
+
+type BrokerAuthService struct {
+	backend        BrokerAccount
+	credentialRepo repository.DeviceCredentialRepositoryInterface
+	credentials    DeviceCredentialServiceInterface
+}
+
+func NewBrokerAuthService(backend BrokerAccount, credentialRepo repository.DeviceCredentialRepositoryInterface, credentials DeviceCredentialServiceInterface) BrokerAuthServiceInterface {
+	return &BrokerAuthService{
+		backend:        backend,
+		credentialRepo: credentialRepo,
+		credentials:    credentials,
+	}
+}
+
+func (s *BrokerAuthService) isBackend(username, clientID string) bool {
+	return s.backend.Username != "" && username == s.backend.Username && clientID == s.backend.ClientID
+}
+
+// AuthenticateUser - board đăng nhập bằng username = client ID và password = secret,
+// client ID của kết nối phải trùng username để không mượn được credential của board khác
+func (s *BrokerAuthService) AuthenticateUser(db *gorm.DB, req *dto.BrokerUserRequest) (bool, error) {
+	if s.isBackend(req.Username, req.ClientID) {
+		return subtle.ConstantTimeCompare([]byte(req.Password), []byte(s.backend.Password)) == 1, nil
+	}
+	if req.ClientID != req.Username {
+		return false, nil
+	}
+	_, err := s.credentials.Authenticate(db, req.Username, req.Password)
+	if errors.Is(err, ErrInvalidDeviceSecret) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// IsSuperuser - plugin chỉ gửi username; client ID của backend đã được kiểm tra lúc đăng nhập
+func (s *BrokerAuthService) IsSuperuser(req *dto.BrokerSuperuserRequest) bool {
+	return s.backend.Username != "" && req.Username == s.backend.Username
+}
+
+// CheckACL - board chỉ publish telemetry của mình và đọc/subscribe command của mình, trong tenant của credential
+func (s *BrokerAuthService) CheckACL(db *gorm.DB, req *dto.BrokerACLRequest) (bool, error) {
+	if s.isBackend(req.Username, req.ClientID) {
+		return true, nil
+	}
+	if req.ClientID != req.Username {
+		return false, nil
+	}
+
+	// Credential bị revoke sau khi board đã kết nối thì chặn ngay ở lần publish/subscribe kế tiếp
+	credential, err := s.credentialRepo.GetByClientID(db, req.ClientID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !credential.Active() {
+		return false, nil
+	}
+
+	switch req.Acc {
+	case MQTTAccWrite:
+		return req.Topic == mymqtt.TelemetryTopic(credential.TenantID, req.ClientID), nil
+	case MQTTAccRead, MQTTAccSubscribe:
+		return req.Topic == mymqtt.CommandTopic(credential.TenantID, req.ClientID), nil
+	default:
+		return false, nil
+	}
+}
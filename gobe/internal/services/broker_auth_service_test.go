@@ -0,0 +1,61 @@
+package services
+
+import (
+	"iot/internal/dto"
+	"iot/internal/repository"
+	mymqtt "iot/pkg/mqtt"
+	"testing"
+)
+
+func TestCheckACL(t *testing.T) {
+	db := openTestDB(t)
+	db.Exec("INSERT INTO devices (id, name, status, tenant_id) VALUES (1, 'fan', 'OFF', ?), (2, 'lamp', 'OFF', ?), (3, 'heater', 'OFF', ?)",
+		testTenantID, testTenantID, testTenantID)
+	db.Exec("INSERT INTO device_credentials (device_id, tenant_id, client_id, secret_hash) VALUES (1, ?, 'fan-board', 'hash'), (2, ?, 'unclaimed-board', '')",
+		testTenantID, testTenantID)
+	db.Exec("INSERT INTO device_credentials (device_id, tenant_id, client_id, secret_hash, revoked_at) VALUES (3, ?, 'revoked-board', 'hash', CURRENT_TIMESTAMP)",
+		testTenantID)
+
+	s := &BrokerAuthService{
+		backend:        BrokerAccount{Username: "backend", Password: "secret", ClientID: "backend-1"},
+		credentialRepo: repository.NewDeviceCredentialRepository(),
+	}
+	telemetry := mymqtt.TelemetryTopic(testTenantID, "fan-board")
+	command := mymqtt.CommandTopic(testTenantID, "fan-board")
+
+	tests := []struct {
+		name     string
+		username string
+		clientID string
+		topic    string
+		acc      int
+		allowed  bool
+	}{
+		{"board publishes its telemetry", "fan-board", "fan-board", telemetry, MQTTAccWrite, true},
+		{"board subscribes to its commands", "fan-board", "fan-board", command, MQTTAccSubscribe, true},
+		{"board reads its commands", "fan-board", "fan-board", command, MQTTAccRead, true},
+		{"board cannot publish commands", "fan-board", "fan-board", command, MQTTAccWrite, false},
+		{"board cannot read telemetry", "fan-board", "fan-board", telemetry, MQTTAccSubscribe, false},
+		{"board cannot publish for another board", "fan-board", "fan-board", mymqtt.TelemetryTopic(testTenantID, "lamp-board"), MQTTAccWrite, false},
+		{"board cannot publish into another tenant", "fan-board", "fan-board", mymqtt.TelemetryTopic(testTenantID+1, "fan-board"), MQTTAccWrite, false},
+		{"wildcard subscription", "fan-board", "fan-board", "tenants/+/devices/+/control", MQTTAccSubscribe, false},
+		{"client id must match username", "fan-board", "other", telemetry, MQTTAccWrite, false},
+		{"read-write is not granted", "fan-board", "fan-board", command, MQTTAccReadWrite, false},
+		{"revoked credential", "revoked-board", "revoked-board", mymqtt.TelemetryTopic(testTenantID, "revoked-board"), MQTTAccWrite, false},
+		{"credential without secret", "unclaimed-board", "unclaimed-board", mymqtt.TelemetryTopic(testTenantID, "unclaimed-board"), MQTTAccWrite, false},
+		{"unknown board", "ghost", "ghost", mymqtt.TelemetryTopic(testTenantID, "ghost"), MQTTAccWrite, false},
+		{"backend has full access", "backend", "backend-1", "tenants/+/sensor/+/information", MQTTAccSubscribe, true},
+		{"backend username from another client id", "backend", "backend-2", command, MQTTAccWrite, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := s.CheckACL(db, &dto.BrokerACLRequest{Username: tt.username, ClientID: tt.clientID, Topic: tt.topic, Acc: tt.acc})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allowed != tt.allowed {
+				t.Errorf("CheckACL(%s, %s, acc=%d) = %v, want %v", tt.username, tt.topic, tt.acc, allowed, tt.allowed)
+			}
+		})
+	}
+}
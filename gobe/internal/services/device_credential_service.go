@@ -0,0 +1,290 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
+	mymqtt "iot/pkg/mqtt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCredentialExists    = errors.New("device already has active credentials, rotate or revoke them instead")
+	ErrCredentialNotFound  = errors.New("device has no credentials")
+	ErrCredentialRevoked   = errors.New("device credentials have been revoked")
+	ErrInvalidClaimCode    = errors.New("invalid or expired claim code")
+	ErrInvalidDeviceSecret = errors.New("invalid device credentials")
+)
+
+const (
+	CredentialModeSecret = "secret"
+	CredentialModeClaim  = "claim"
+
+	claimCodeTTL = 24 * time.Hour
+)
+
+type DeviceCredentialServiceInterface interface {
+	Issue(db *gorm.DB, actor dto.Actor, deviceID uint, mode string) (*dto.DeviceCredentialResponse, error)
+	Rotate(db *gorm.DB, actor dto.Actor, deviceID uint) (*dto.DeviceCredentialResponse, error)
+	Revoke(db *gorm.DB, actor dto.Actor, deviceID uint) error
+	Get(db *gorm.DB, actor dto.Actor, deviceID uint) (*dto.DeviceCredentialResponse, error)
+	Claim(db *gorm.DB, claimCode string) (*dto.DeviceCredentialResponse, error)
+	Authenticate(db *gorm.DB, clientID, secret string) (*model.DeviceCredential, error)
+}
+
+type DeviceCredentialService struct {
+	repo          repository.DeviceCredentialRepositoryInterface
+	deviceService DeviceServiceInterface
+}
+
+func NewDeviceCredentialService(repo repository.DeviceCredentialRepositoryInterface, ds DeviceServiceInterface) DeviceCredentialServiceInterface {
+	return &DeviceCredentialService{
+		repo:          repo,
+		deviceService: ds,
+	}
+}
+
+// Issue - cấp credential lần đầu (hoặc sau khi đã revoke). Client ID giữ nguyên nếu device đã từng có.
+func (s *DeviceCredentialService) Issue(db *gorm.DB, actor dto.Actor, deviceID uint, mode string) (*dto.DeviceCredentialResponse, error) {
+	if err := s.requireOwner(db, actor, deviceID); err != nil {
+		return nil, err
+	}
+	credential, err := s.repo.GetByDeviceID(db, deviceID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		clientID, err := generateClientID(deviceID)
+		if err != nil {
+			return nil, err
+		}
+		credential = &model.DeviceCredential{DeviceID: deviceID, ClientID: clientID}
+	case err != nil:
+		return nil, err
+	case credential.RevokedAt == nil:
+		return nil, ErrCredentialExists
+	}
+	credential.RevokedAt = nil
+	credential.RotatedAt = nil
+	credential.SecretHash = ""
+	credential.ClaimCodeHash = nil
+	credential.ClaimExpiresAt = nil
+
+	if mode == CredentialModeClaim {
+		code, err := generateClaimCode()
+		if err != nil {
+			return nil, err
+		}
+		hash := hashSecret(normalizeClaimCode(code))
+		expiresAt := time.Now().Add(claimCodeTTL)
+		credential.ClaimCodeHash = &hash
+		credential.ClaimExpiresAt = &expiresAt
+		if err := s.repo.SaveCredential(db, credential); err != nil {
+			return nil, err
+		}
+		response := credentialResponse(credential)
+		response.ClaimCode = code
+		return response, nil
+	}
+
+	secret, secretHash, err := generateDeviceSecret()
+	if err != nil {
+		return nil, err
+	}
+	credential.SecretHash = secretHash
+	if err := s.repo.SaveCredential(db, credential); err != nil {
+		return nil, err
+	}
+	response := credentialResponse(credential)
+	response.Secret = secret
+	return response, nil
+}
+
+// Rotate - secret mới thay secret cũ ngay lập tức, board phải được nạp lại secret
+func (s *DeviceCredentialService) Rotate(db *gorm.DB, actor dto.Actor, deviceID uint) (*dto.DeviceCredentialResponse, error) {
+	credential, err := s.ownedCredential(db, actor, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if credential.RevokedAt != nil {
+		return nil, ErrCredentialRevoked
+	}
+	secret, secretHash, err := generateDeviceSecret()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	credential.SecretHash = secretHash
+	credential.ClaimCodeHash = nil
+	credential.ClaimExpiresAt = nil
+	credential.RotatedAt = &now
+	if err := s.repo.SaveCredential(db, credential); err != nil {
+		return nil, err
+	}
+	response := credentialResponse(credential)
+	response.Secret = secret
+	return response, nil
+}
+
+func (s *DeviceCredentialService) Revoke(db *gorm.DB, actor dto.Actor, deviceID uint) error {
+	credential, err := s.ownedCredential(db, actor, deviceID)
+	if err != nil {
+		return err
+	}
+	if credential.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	credential.RevokedAt = &now
+	credential.SecretHash = ""
+	credential.ClaimCodeHash = nil
+	credential.ClaimExpiresAt = nil
+	return s.repo.SaveCredential(db, credential)
+}
+
+func (s *DeviceCredentialService) Get(db *gorm.DB, actor dto.Actor, deviceID uint) (*dto.DeviceCredentialResponse, error) {
+	credential, err := s.ownedCredential(db, actor, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return credentialResponse(credential), nil
+}
+
+// Claim - board gửi claim code (nạp lúc sản xuất/cài đặt) để nhận client ID + secret một lần duy nhất
+func (s *DeviceCredentialService) Claim(db *gorm.DB, claimCode string) (*dto.DeviceCredentialResponse, error) {
+	hash := hashSecret(normalizeClaimCode(claimCode))
+	credential, err := s.repo.GetByClaimCodeHash(db, hash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidClaimCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	if credential.RevokedAt != nil || credential.ClaimExpiresAt == nil || time.Now().After(*credential.ClaimExpiresAt) {
+		return nil, ErrInvalidClaimCode
+	}
+
+	secret, secretHash, err := generateDeviceSecret()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.repo.ConsumeClaim(db, credential.ID, hash, secretHash)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidClaimCode
+	}
+	credential.SecretHash = secretHash
+	credential.ClaimCodeHash = nil
+	credential.ClaimExpiresAt = nil
+	response := credentialResponse(credential)
+	response.Secret = secret
+	return response, nil
+}
+
+// Authenticate - kiểm tra client ID + secret của board (dùng cho broker auth)
+func (s *DeviceCredentialService) Authenticate(db *gorm.DB, clientID, secret string) (*model.DeviceCredential, error) {
+	credential, err := s.repo.GetByClientID(db, clientID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidDeviceSecret
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !credential.Active() {
+		return nil, ErrInvalidDeviceSecret
+	}
+	if bcrypt.CompareHashAndPassword([]byte(credential.SecretHash), []byte(secret)) != nil {
+		return nil, ErrInvalidDeviceSecret
+	}
+	return credential, nil
+}
+
+func (s *DeviceCredentialService) requireOwner(db *gorm.DB, actor dto.Actor, deviceID uint) error {
+	ok, err := s.deviceService.CanAccessDevice(db, actor, deviceID, "")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeviceForbidden
+	}
+	return nil
+}
+
+func (s *DeviceCredentialService) ownedCredential(db *gorm.DB, actor dto.Actor, deviceID uint) (*model.DeviceCredential, error) {
+	if err := s.requireOwner(db, actor, deviceID); err != nil {
+		return nil, err
+	}
+	credential, err := s.repo.GetByDeviceID(db, deviceID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCredentialNotFound
+	}
+	return credential, err
+}
+
+func credentialResponse(credential *model.DeviceCredential) *dto.DeviceCredentialResponse {
+	status := "active"
+	switch {
+	case credential.RevokedAt != nil:
+		status = "revoked"
+	case credential.ClaimCodeHash != nil:
+		status = "pending_claim"
+	}
+	return &dto.DeviceCredentialResponse{
+		DeviceID:       credential.DeviceID,
+		ClientID:       credential.ClientID,
+		TenantID:       credential.TenantID,
+		TelemetryTopic: mymqtt.TelemetryTopic(credential.TenantID, credential.ClientID),
+		CommandTopic:   mymqtt.CommandTopic(credential.TenantID, credential.ClientID),
+		ClaimExpiresAt: credential.ClaimExpiresAt,
+		Status:         status,
+		CreatedAt:      credential.CreatedAt,
+		RotatedAt:      credential.RotatedAt,
+		RevokedAt:      credential.RevokedAt,
+	}
+}
+
+// generateClientID - dev-<id>-<8 hex>, không đoán được từ ID device
+func generateClientID(deviceID uint) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("dev-%d-%s", deviceID, hex.EncodeToString(b)), nil
+}
+
+// generateDeviceSecret - 256 bit ngẫu nhiên, trả về bản rõ và bcrypt hash
+func generateDeviceSecret() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret := hex.EncodeToString(b)
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, string(hash), nil
+}
+
+// generateClaimCode - 12 ký tự base32 dạng XXXX-XXXX-XXXX để nhập tay được
+func generateClaimCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)[:12]
+	return code[:4] + "-" + code[4:8] + "-" + code[8:], nil
+}
+
+func normalizeClaimCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -1,10 +1,12 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"iot/internal/dto"
 	"iot/internal/model"
 	"iot/internal/repository"
+	"iot/pkg/pagination"
 
 	"gorm.io/gorm"
 )
@@ -12,7 +14,20 @@ import (
 type DeviceHistoryServiceInterface interface {
 	CreateDeviceHistory(db *gorm.DB, req dto.CreateDeviceHistoryRequest) (*model.DeviceHistory, error)
 	GetDeviceHistoryByDeviceID(db *gorm.DB, deviceID uint) (*model.DeviceHistory, error)
-	GetAllDeviceHistories(db *gorm.DB, limit int, offset int, order string, status string, deviceId string, startDate string, endDate string, search string) ([]model.DeviceHistory, int, error)
+	GetAllDeviceHistories(db *gorm.DB, page *pagination.Request, status string, deviceId string, startDate string, endDate string, search string) ([]model.DeviceHistory, *pagination.Page, error)
+}
+
+var ErrInvalidHistory = errors.New("invalid device history")
+
+// validateHistory - dùng chung cho API ghi lịch sử và import
+func validateHistory(req *dto.CreateDeviceHistoryRequest) error {
+	switch {
+	case req.Status != "ON" && req.Status != "OFF":
+		return fmt.Errorf("%w: status must be ON or OFF", ErrInvalidHistory)
+	case req.UserChange == "" || len(req.UserChange) > 100:
+		return fmt.Errorf("%w: user_change must be 1-100 characters", ErrInvalidHistory)
+	}
+	return nil
 }
 
 type DeviceHistoryService struct {
@@ -26,6 +41,9 @@ func NewDeviceHistoryService(repo repository.DeviceHistoryRepositoryInterface) D
 }
 
 func (s *DeviceHistoryService) CreateDeviceHistory(db *gorm.DB, req dto.CreateDeviceHistoryRequest) (*model.DeviceHistory, error) {
+	if err := validateHistory(&req); err != nil {
+		return nil, err
+	}
 	DeviceIdUint := uint(0)
 	UserIdUint := uint(0)
 	_, err := fmt.Sscan(req.DeviceID, &DeviceIdUint)
@@ -50,6 +68,6 @@ func (s *DeviceHistoryService) GetDeviceHistoryByDeviceID(db *gorm.DB, deviceID
 	return s.repo.GetByDeviceID(db, deviceID)
 }
 
-func (s *DeviceHistoryService) GetAllDeviceHistories(db *gorm.DB, limit int, offset int, order string, status string, deviceId string, startDate string, endDate string, search string) ([]model.DeviceHistory, int, error) {
-	return s.repo.GetAllDeviceHistories(db, limit, offset, order, status, deviceId, startDate, endDate, search)
+func (s *DeviceHistoryService) GetAllDeviceHistories(db *gorm.DB, page *pagination.Request, status string, deviceId string, startDate string, endDate string, search string) ([]model.DeviceHistory, *pagination.Page, error) {
+	return s.repo.GetAllDeviceHistories(db, page, status, deviceId, startDate, endDate, search)
 }
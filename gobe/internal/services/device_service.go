@@ -9,6 +9,7 @@ import (
 	"iot/internal/model"
 	"iot/internal/repository"
 	mymqtt "iot/pkg/mqtt"
+	"iot/pkg/pagination"
 	"log"
 	"sync"
 
@@ -17,31 +18,49 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	ErrDeviceForbidden = errors.New("you do not have access to this device")
+	ErrShareWithSelf   = errors.New("cannot share a device with its owner")
+	ErrShareNotMember  = errors.New("devices can only be shared with members of the same tenant")
+)
+
 type DeviceServiceInterface interface {
-	CreateDevice(db *gorm.DB, deviceDto *dto.CreateDeviceRequest, redis *redis.Client) error
+	CreateDevice(db *gorm.DB, deviceDto *dto.CreateDeviceRequest, actor dto.Actor, redis *redis.Client) error
 	GetByID(db *gorm.DB, id uint) (*model.Device, error)
-	GetAllDevices(db *gorm.DB, limit, offset int, redis *redis.Client) ([]model.Device, error)
+	GetAllDevices(db *gorm.DB, actor dto.Actor, page *pagination.Request, redis *redis.Client) ([]model.Device, *pagination.Page, error)
 	UpdateDevice(db *gorm.DB, id uint, deviceDto *dto.UpdateDeviceRequest) error
 	DeleteDevice(db *gorm.DB, id uint, redis *redis.Client) error
-	DeviceController(ctx context.Context, db *gorm.DB, data *dto.DevicesControlRequest, mqtt mqtt.Client) error
+	DeviceController(ctx context.Context, db *gorm.DB, data *dto.DevicesControlRequest, actor dto.Actor, mqtt mqtt.Client) error
+	CanAccessDevice(db *gorm.DB, actor dto.Actor, deviceID uint, permission string) (bool, error)
+	CanReadTelemetry(db *gorm.DB, actor dto.Actor) (bool, error)
+	ShareDevice(db *gorm.DB, actor dto.Actor, deviceID uint, req *dto.ShareDeviceRequest) error
+	RevokeShare(db *gorm.DB, actor dto.Actor, deviceID, userID uint) error
+	GetShares(db *gorm.DB, actor dto.Actor, deviceID uint) ([]model.DeviceGrant, error)
 }
 
 type DeviceService struct {
-	repo        repository.DeviceRepositoryInterface
-	historyRepo repository.DeviceHistoryRepositoryInterface
+	repo           repository.DeviceRepositoryInterface
+	historyRepo    repository.DeviceHistoryRepositoryInterface
+	grantRepo      repository.DeviceGrantRepositoryInterface
+	credentialRepo repository.DeviceCredentialRepositoryInterface
+	tenantRepo     repository.TenantRepositoryInterface
 }
 
-func NewDeviceService(repo repository.DeviceRepositoryInterface, historyRepo repository.DeviceHistoryRepositoryInterface) DeviceServiceInterface {
+func NewDeviceService(repo repository.DeviceRepositoryInterface, historyRepo repository.DeviceHistoryRepositoryInterface, grantRepo repository.DeviceGrantRepositoryInterface, credentialRepo repository.DeviceCredentialRepositoryInterface, tenantRepo repository.TenantRepositoryInterface) DeviceServiceInterface {
 	return &DeviceService{
-		repo:        repo,
-		historyRepo: historyRepo,
+		repo:           repo,
+		historyRepo:    historyRepo,
+		grantRepo:      grantRepo,
+		credentialRepo: credentialRepo,
+		tenantRepo:     tenantRepo,
 	}
 }
 
-func (s *DeviceService) CreateDevice(db *gorm.DB, deviceDto *dto.CreateDeviceRequest, redis *redis.Client) error {
+func (s *DeviceService) CreateDevice(db *gorm.DB, deviceDto *dto.CreateDeviceRequest, actor dto.Actor, redis *redis.Client) error {
 	device := &model.Device{
-		Name:   deviceDto.Name,
-		Status: deviceDto.Status,
+		Name:    deviceDto.Name,
+		Status:  deviceDto.Status,
+		OwnerID: actor.UserID,
 	}
 	err := s.repo.CreateDevice(db, device)
 	if err != nil {
@@ -54,14 +73,11 @@ func (s *DeviceService) GetByID(db *gorm.DB, id uint) (*model.Device, error) {
 	return s.repo.GetByID(db, id)
 }
 
-func (s *DeviceService) GetAllDevices(db *gorm.DB, limit, offset int, redis *redis.Client) ([]model.Device, error) {
-	if limit <= 0 || limit > 100 {
-		limit = 10
+func (s *DeviceService) GetAllDevices(db *gorm.DB, actor dto.Actor, page *pagination.Request, redis *redis.Client) ([]model.Device, *pagination.Page, error) {
+	if actor.Role == model.RoleAdmin {
+		return s.repo.GetAllDevices(db, page)
 	}
-	if offset < 0 {
-		offset = 0
-	}
-	return s.repo.GetAllDevices(db, limit, offset)
+	return s.repo.GetAccessibleDevices(db, actor.UserID, page)
 }
 
 func (s *DeviceService) UpdateDevice(db *gorm.DB, id uint, deviceDto *dto.UpdateDeviceRequest) error {
@@ -76,21 +92,140 @@ func (s *DeviceService) UpdateDevice(db *gorm.DB, id uint, deviceDto *dto.Update
 	return s.repo.UpdateDevice(db, device)
 }
 
+// DeleteDevice - credential MQTT bị xóa cùng transaction, nếu không board đã xóa vẫn qua được broker auth/ACL
 func (s *DeviceService) DeleteDevice(db *gorm.DB, id uint, redis *redis.Client) error {
-	return s.repo.DeleteDevice(db, id)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.DeleteDevice(tx, id); err != nil {
+			return err
+		}
+		return s.credentialRepo.DeleteByDeviceID(tx, id)
+	})
+}
+
+// CanAccessDevice - admin và chủ device có toàn quyền, user được chia sẻ theo permission của grant.
+// permission rỗng nghĩa là chỉ chủ device (dùng cho sửa/xóa/chia sẻ).
+func (s *DeviceService) CanAccessDevice(db *gorm.DB, actor dto.Actor, deviceID uint, permission string) (bool, error) {
+	device, err := s.repo.GetByID(db, deviceID)
+	if err != nil {
+		return false, err
+	}
+	if actor.Role == model.RoleAdmin || (actor.UserID != 0 && device.OwnerID == actor.UserID) {
+		return true, nil
+	}
+	if permission == "" || actor.UserID == 0 {
+		return false, nil
+	}
+	grant, err := s.grantRepo.GetGrant(db, deviceID, actor.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if permission == model.GrantView {
+		return true, nil
+	}
+	return grant.Permission == model.GrantControl, nil
+}
+
+// CanReadTelemetry - sensor data chưa gắn với device, nên cho phép đọc khi user có quyền trên ít nhất một device
+// của tenant hiện tại
+func (s *DeviceService) CanReadTelemetry(db *gorm.DB, actor dto.Actor) (bool, error) {
+	if actor.Role == model.RoleAdmin {
+		return true, nil
+	}
+	if actor.UserID == 0 {
+		return false, nil
+	}
+	accessible, err := s.repo.CountAccessibleDevices(db, actor.UserID)
+	if err != nil {
+		return false, err
+	}
+	return accessible > 0, nil
+}
+
+func (s *DeviceService) ShareDevice(db *gorm.DB, actor dto.Actor, deviceID uint, req *dto.ShareDeviceRequest) error {
+	if err := s.requireOwner(db, actor, deviceID); err != nil {
+		return err
+	}
+	device, err := s.repo.GetByID(db, deviceID)
+	if err != nil {
+		return err
+	}
+	if device.OwnerID == req.UserID {
+		return ErrShareWithSelf
+	}
+	if _, err := s.tenantRepo.GetMembership(db, device.TenantID, req.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrShareNotMember
+		}
+		return err
+	}
+	return s.grantRepo.UpsertGrant(db, &model.DeviceGrant{
+		DeviceID:   deviceID,
+		UserID:     req.UserID,
+		Permission: req.Permission,
+		GrantedBy:  actor.UserID,
+	})
+}
+
+func (s *DeviceService) RevokeShare(db *gorm.DB, actor dto.Actor, deviceID, userID uint) error {
+	if err := s.requireOwner(db, actor, deviceID); err != nil {
+		return err
+	}
+	return s.grantRepo.DeleteGrant(db, deviceID, userID)
+}
+
+func (s *DeviceService) GetShares(db *gorm.DB, actor dto.Actor, deviceID uint) ([]model.DeviceGrant, error) {
+	if err := s.requireOwner(db, actor, deviceID); err != nil {
+		return nil, err
+	}
+	return s.grantRepo.GetGrantsByDevice(db, deviceID)
+}
+
+func (s *DeviceService) requireOwner(db *gorm.DB, actor dto.Actor, deviceID uint) error {
+	ok, err := s.CanAccessDevice(db, actor, deviceID, "")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeviceForbidden
+	}
+	return nil
 }
 
-func (s *DeviceService) DeviceController(ctx context.Context, db *gorm.DB, data *dto.DevicesControlRequest, mqtt mqtt.Client) error {
-	// Chuẩn bị danh sách devices từ request
+func (s *DeviceService) DeviceController(ctx context.Context, db *gorm.DB, data *dto.DevicesControlRequest, actor dto.Actor, mqtt mqtt.Client) error {
+	// Chuẩn bị danh sách devices từ request, người điều khiển luôn là user của token
 	devices := []*dto.DeviceControlRequest{
-		{DeviceID: data.Device1.DeviceID, Status: data.Device1.Status, UserId: data.Device1.UserId, UserChange: data.Device1.UserChange},
-		{DeviceID: data.Device2.DeviceID, Status: data.Device2.Status, UserId: data.Device2.UserId, UserChange: data.Device2.UserChange},
-		{DeviceID: data.Device3.DeviceID, Status: data.Device3.Status, UserId: data.Device3.UserId, UserChange: data.Device3.UserChange},
+		{DeviceID: data.Device1.DeviceID, Status: data.Device1.Status, UserId: actor.UserID, UserChange: actor.Name},
+		{DeviceID: data.Device2.DeviceID, Status: data.Device2.Status, UserId: actor.UserID, UserChange: actor.Name},
+		{DeviceID: data.Device3.DeviceID, Status: data.Device3.Status, UserId: actor.UserID, UserChange: actor.Name},
+	}
+
+	dbWithCtx := db.WithContext(ctx)
+
+	// Kiểm tra quyền trước khi thay đổi bất kỳ device nào. Device giữ nguyên trạng thái
+	// chỉ cần quyền view vì payload MQTT luôn gửi đủ 3 device.
+	for _, d := range devices {
+		deviceModel, err := s.repo.GetByID(dbWithCtx, d.DeviceID)
+		if err != nil {
+			return fmt.Errorf("get device %d: %w", d.DeviceID, err)
+		}
+		permission := model.GrantView
+		if deviceModel.Status != d.Status {
+			permission = model.GrantControl
+		}
+		ok, err := s.CanAccessDevice(dbWithCtx, actor, d.DeviceID, permission)
+		if err != nil {
+			return fmt.Errorf("check access device %d: %w", d.DeviceID, err)
+		}
+		if !ok {
+			return fmt.Errorf("device %d: %w", d.DeviceID, ErrDeviceForbidden)
+		}
 	}
 
 	var wg sync.WaitGroup
 	errCh := make(chan error, len(devices))
-	dbWithCtx := db.WithContext(ctx)
 
 	// Update song song các device
 	for _, d := range devices {
@@ -155,9 +290,28 @@ func (s *DeviceService) DeviceController(ctx context.Context, db *gorm.DB, data
 		return fmt.Errorf("marshal mqtt payload: %w", err)
 	}
 
-	mqttTopic := "devices/control"
-	if err := mymqtt.Publish(mqtt, mqttTopic, 0, false, payloadBytes); err != nil {
-		return fmt.Errorf("publish mqtt: %w", err)
+	// Board chưa provisioning chỉ thuộc tenant mặc định, tenant khác không được gửi lên topic chung
+	defaultTenant, err := s.tenantRepo.GetBySlug(dbWithCtx, model.DefaultTenantSlug)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("load default tenant: %w", err)
+	}
+	if defaultTenant != nil && defaultTenant.ID == actor.TenantID {
+		if err := mymqtt.Publish(ctx, mqtt, mymqtt.LegacyCommandTopic, 0, false, payloadBytes); err != nil {
+			return fmt.Errorf("publish mqtt: %w", err)
+		}
+	}
+
+	// Board có credential riêng chỉ được subscribe command topic của chính nó
+	published := make(map[string]bool)
+	for _, d := range devices {
+		credential, err := s.credentialRepo.GetByDeviceID(dbWithCtx, d.DeviceID)
+		if err != nil || !credential.Active() || published[credential.ClientID] {
+			continue
+		}
+		published[credential.ClientID] = true
+		if err := mymqtt.Publish(ctx, mqtt, mymqtt.CommandTopic(credential.TenantID, credential.ClientID), 0, false, payloadBytes); err != nil {
+			return fmt.Errorf("publish mqtt to %s: %w", credential.ClientID, err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/repository"
+	"iot/internal/tenant"
+	mymqtt "iot/pkg/mqtt"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestDeleteDeviceRevokesCredential(t *testing.T) {
+	const secret = "board-secret"
+
+	tests := []struct {
+		name     string
+		deleteID uint
+		err      error
+		// board nào còn đăng nhập broker được sau khi xóa
+		fanAllowed, lampAllowed bool
+	}{
+		{"deleted device loses broker access", 1, nil, false, true},
+		{"unknown device changes nothing", 9, gorm.ErrRecordNotFound, true, true},
+		{"device of another tenant changes nothing", 3, gorm.ErrRecordNotFound, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+			if err != nil {
+				t.Fatal(err)
+			}
+			db.Exec("INSERT INTO devices (id, name, status, tenant_id) VALUES (1, 'fan', 'OFF', ?), (2, 'lamp', 'OFF', ?), (3, 'heater', 'OFF', ?)",
+				testTenantID, testTenantID, testTenantID+1)
+			db.Exec("INSERT INTO device_credentials (device_id, tenant_id, client_id, secret_hash) VALUES (1, ?, 'fan-board', ?), (2, ?, 'lamp-board', ?)",
+				testTenantID, string(hash), testTenantID, string(hash))
+
+			credentialRepo := repository.NewDeviceCredentialRepository()
+			devices := NewDeviceService(repository.NewDeviceRepository(), repository.NewDeviceHistoryRepository(),
+				repository.NewDeviceGrantRepository(), credentialRepo, repository.NewTenantRepository())
+			broker := NewBrokerAuthService(BrokerAccount{}, credentialRepo, NewDeviceCredentialService(credentialRepo, devices))
+
+			err = devices.DeleteDevice(tenant.WithTenant(db, testTenantID), tt.deleteID, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("DeleteDevice = %v, want %v", err, tt.err)
+			}
+
+			for clientID, want := range map[string]bool{"fan-board": tt.fanAllowed, "lamp-board": tt.lampAllowed} {
+				system := tenant.System(db)
+				ok, err := broker.AuthenticateUser(system, &dto.BrokerUserRequest{Username: clientID, Password: secret, ClientID: clientID})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok != want {
+					t.Errorf("%s: AuthenticateUser = %v, want %v", clientID, ok, want)
+				}
+				ok, err = broker.CheckACL(system, &dto.BrokerACLRequest{
+					Username: clientID,
+					ClientID: clientID,
+					Topic:    mymqtt.TelemetryTopic(testTenantID, clientID),
+					Acc:      MQTTAccWrite,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok != want {
+					t.Errorf("%s: CheckACL = %v, want %v", clientID, ok, want)
+				}
+			}
+		})
+	}
+}
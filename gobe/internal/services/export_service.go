@@ -0,0 +1,181 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/pkg/config"
+	"iot/pkg/export"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidTimezone = errors.New("unknown timezone")
+
+var sensorExportColumns = []export.Column{
+	{Name: "id", Type: export.Int64},
+	{Name: "created_at", Type: export.Timestamp},
+	{Name: "temperature", Type: export.Double},
+	{Name: "humidity", Type: export.Double},
+	{Name: "light", Type: export.Int64},
+}
+
+var deviceHistoryExportColumns = []export.Column{
+	{Name: "id", Type: export.Int64},
+	{Name: "created_at", Type: export.Timestamp},
+	{Name: "device_id", Type: export.Int64},
+	{Name: "user_id", Type: export.Int64},
+	{Name: "user_change", Type: export.String},
+	{Name: "status", Type: export.String},
+}
+
+type ExportServiceInterface interface {
+	Prepare(db *gorm.DB, kind string, query *dto.ExportQuery) (int64, bool, error)
+	Write(db *gorm.DB, kind string, query *dto.ExportQuery, w io.Writer, progress func(int64)) (int64, error)
+	StartJob(db *gorm.DB, actor dto.Actor, kind string, query *dto.ExportQuery, total int64) (*dto.JobDTO, error)
+	FileName(kind string, query *dto.ExportQuery, at time.Time) string
+}
+
+// ExportService - kind là model.JobExportSensorData hoặc model.JobExportDeviceHistory
+type ExportService struct {
+	sensors     repository.SensorRepositoryInterface
+	histories   repository.DeviceHistoryRepositoryInterface
+	jobs        JobServiceInterface
+	dir         string
+	syncMaxRows int64
+}
+
+func NewExportService(sensors repository.SensorRepositoryInterface, histories repository.DeviceHistoryRepositoryInterface, jobs JobServiceInterface, cfg *config.ExportConfig) ExportServiceInterface {
+	return &ExportService{
+		sensors:     sensors,
+		histories:   histories,
+		jobs:        jobs,
+		dir:         cfg.Dir,
+		syncMaxRows: cfg.SyncMaxRows,
+	}
+}
+
+// Prepare - kiểm tra múi giờ và bộ lọc rồi đếm số dòng; chạy nền khi client yêu cầu hoặc vượt ngưỡng
+func (s *ExportService) Prepare(db *gorm.DB, kind string, query *dto.ExportQuery) (int64, bool, error) {
+	if _, err := exportLocation(query.Timezone); err != nil {
+		return 0, false, err
+	}
+	var total int64
+	var err error
+	switch kind {
+	case model.JobExportSensorData:
+		total, err = s.sensors.CountSensorData(db, query.StartDate, query.EndDate, query.Search)
+	case model.JobExportDeviceHistory:
+		total, err = s.histories.CountDeviceHistories(db, query.Status, query.DeviceID, query.StartDate, query.EndDate, query.Search)
+	default:
+		return 0, false, fmt.Errorf("unknown export %q", kind)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return total, query.Async || total > s.syncMaxRows, nil
+}
+
+// Write - ghi theo lô ra w, trả số dòng đã ghi. progress có thể nil.
+func (s *ExportService) Write(db *gorm.DB, kind string, query *dto.ExportQuery, w io.Writer, progress func(int64)) (int64, error) {
+	loc, err := exportLocation(query.Timezone)
+	if err != nil {
+		return 0, err
+	}
+	columns := sensorExportColumns
+	if kind == model.JobExportDeviceHistory {
+		columns = deviceHistoryExportColumns
+	}
+	writer, err := export.NewWriter(query.Format, w, columns, loc)
+	if err != nil {
+		return 0, err
+	}
+
+	var written int64
+	switch kind {
+	case model.JobExportSensorData:
+		err = s.sensors.EachSensorData(db, query.StartDate, query.EndDate, query.Search, func(rows []model.SensorData) error {
+			return writeRows(writer, rows, &written, progress, func(d *model.SensorData) []interface{} {
+				return []interface{}{int64(d.ID), d.CreatedAt, d.Temperature, d.Humidity, int64(d.Light)}
+			})
+		})
+	case model.JobExportDeviceHistory:
+		err = s.histories.EachDeviceHistory(db, query.Status, query.DeviceID, query.StartDate, query.EndDate, query.Search, func(rows []model.DeviceHistory) error {
+			return writeRows(writer, rows, &written, progress, func(h *model.DeviceHistory) []interface{} {
+				return []interface{}{int64(h.ID), h.CreatedAt, int64(h.DeviceID), int64(h.UserID), h.UserChange, h.Status}
+			})
+		})
+	default:
+		err = fmt.Errorf("unknown export %q", kind)
+	}
+	if err != nil {
+		return written, err
+	}
+	return written, writer.Close()
+}
+
+func writeRows[T any](writer export.Writer, rows []T, written *int64, progress func(int64), row func(*T) []interface{}) error {
+	for i := range rows {
+		if err := writer.Write(row(&rows[i])); err != nil {
+			return err
+		}
+	}
+	*written += int64(len(rows))
+	if progress != nil {
+		progress(*written)
+	}
+	return nil
+}
+
+// StartJob - ghi ra file trong EXPORT_DIR, tải qua link download của job
+func (s *ExportService) StartJob(db *gorm.DB, actor dto.Actor, kind string, query *dto.ExportQuery, total int64) (*dto.JobDTO, error) {
+	params := *query
+	return s.jobs.Start(db, actor, kind, params, total, func(ctx context.Context, db *gorm.DB, job *model.Job, progress func(int64)) (*JobOutcome, error) {
+		if err := os.MkdirAll(s.dir, 0o700); err != nil {
+			return nil, err
+		}
+		file, err := os.CreateTemp(s.dir, fmt.Sprintf("job-%d-*%s", job.ID, export.Extension(params.Format)))
+		if err != nil {
+			return nil, err
+		}
+		buffered := bufio.NewWriterSize(file, 64<<10)
+		written, err := s.Write(db, kind, &params, buffered, progress)
+		if err == nil {
+			err = buffered.Flush()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(file.Name())
+			return nil, err
+		}
+		return &JobOutcome{Processed: written, FilePath: file.Name(), FileName: s.FileName(kind, &params, job.CreatedAt)}, nil
+	})
+}
+
+// FileName - ví dụ sensor-data-20251001T080000Z.csv
+func (s *ExportService) FileName(kind string, query *dto.ExportQuery, at time.Time) string {
+	name := strings.ReplaceAll(strings.TrimPrefix(kind, "export."), "_", "-")
+	return name + "-" + at.UTC().Format("20060102T150405Z") + export.Extension(query.Format)
+}
+
+// exportLocation - rỗng là UTC
+func exportLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q", ErrInvalidTimezone, name)
+	}
+	return loc, nil
+}
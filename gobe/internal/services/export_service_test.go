@@ -0,0 +1,62 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/internal/tenant"
+	"iot/pkg/export"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestExportTimezone(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		timezone string
+		err      error
+		want     string
+	}{
+		{"default is utc", export.FormatCSV, "", nil, "1,2025-10-01T20:30:15.000Z,28.5,70,3000"},
+		{"iana name", export.FormatCSV, "Asia/Ho_Chi_Minh", nil, "1,2025-10-02T03:30:15.000+07:00,28.5,70,3000"},
+		{"dst is applied per row", export.FormatCSV, "America/New_York", nil, "1,2025-10-01T16:30:15.000-04:00,28.5,70,3000"},
+		{"ndjson", export.FormatNDJSON, "Asia/Ho_Chi_Minh", nil, `{"id":1,"created_at":"2025-10-02T03:30:15.000+07:00","temperature":28.5,"humidity":70,"light":3000}`},
+		{"unknown timezone", export.FormatCSV, "Mars/Olympus_Mons", ErrInvalidTimezone, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := tenant.WithTenant(openTestDB(t), testTenantID)
+			at := time.Date(2025, 10, 1, 20, 30, 15, 0, time.UTC)
+			if err := repository.NewSensorRepository().CreateSensorData(db, &model.SensorData{
+				Model: gorm.Model{CreatedAt: at}, Temperature: 28.5, Humidity: 70, Light: 3000,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			s := &ExportService{sensors: repository.NewSensorRepository(), histories: repository.NewDeviceHistoryRepository(), syncMaxRows: 100}
+			query := &dto.ExportQuery{Format: tt.format, Timezone: tt.timezone}
+			// Prepare chặn múi giờ sai trước khi tạo job, Write cũng kiểm tra lại
+			if _, _, err := s.Prepare(db, model.JobExportSensorData, query); !errors.Is(err, tt.err) {
+				t.Fatalf("Prepare = %v, want %v", err, tt.err)
+			}
+			var buf bytes.Buffer
+			written, err := s.Write(db, model.JobExportSensorData, query, &buf, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Write = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if written != 1 || lines[len(lines)-1] != tt.want {
+				t.Errorf("wrote %d rows, last line %q, want %q", written, lines[len(lines)-1], tt.want)
+			}
+		})
+	}
+}
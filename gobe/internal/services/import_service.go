@@ -0,0 +1,424 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/internal/tenant"
+	"iot/pkg/config"
+	"iot/pkg/export"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// importBatchSize - số dòng hợp lệ mỗi lần insert (hoặc tra fingerprint khi dry-run)
+	importBatchSize = 500
+	// maxImportErrors - số lỗi theo dòng giữ lại trong báo cáo
+	maxImportErrors = 100
+	// uploadFilePrefix - file upload chờ import trong thư mục job, JobService dọn file sót lại khi server dừng giữa chừng
+	uploadFilePrefix = "upload-"
+)
+
+// importTimeLayouts - timestamp không có múi giờ thì hiểu theo tz của request
+var importTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+type ImportServiceInterface interface {
+	Start(db *gorm.DB, actor dto.Actor, kind string, upload io.Reader, req *dto.ImportRequest) (*dto.JobDTO, error)
+}
+
+// ImportService - kind là model.JobImportSensorData hoặc model.JobImportDeviceHistory.
+// Mỗi dòng qua cùng bước kiểm tra với dữ liệu ghi trực tiếp; fingerprint của dòng giúp import lại
+// cùng file (kể cả sau khi job lỗi giữa chừng) không tạo bản ghi trùng.
+type ImportService struct {
+	sensors   repository.SensorRepositoryInterface
+	histories repository.DeviceHistoryRepositoryInterface
+	devices   repository.DeviceRepositoryInterface
+	tenants   repository.TenantRepositoryInterface
+	jobs      JobServiceInterface
+	dir       string
+}
+
+func NewImportService(sensors repository.SensorRepositoryInterface, histories repository.DeviceHistoryRepositoryInterface, devices repository.DeviceRepositoryInterface, tenants repository.TenantRepositoryInterface, jobs JobServiceInterface, cfg *config.ExportConfig) ImportServiceInterface {
+	return &ImportService{
+		sensors:   sensors,
+		histories: histories,
+		devices:   devices,
+		tenants:   tenants,
+		jobs:      jobs,
+		dir:       cfg.Dir,
+	}
+}
+
+// Start - lưu file upload ra đĩa rồi xử lý trong job nền, báo cáo nằm trong result của job
+func (s *ImportService) Start(db *gorm.DB, actor dto.Actor, kind string, upload io.Reader, req *dto.ImportRequest) (*dto.JobDTO, error) {
+	if kind != model.JobImportSensorData && kind != model.JobImportDeviceHistory {
+		return nil, fmt.Errorf("unknown import %q", kind)
+	}
+	if _, err := exportLocation(req.Timezone); err != nil {
+		return nil, err
+	}
+	params := *req
+	if params.Format == "" {
+		params.Format = importFormat(params.FileName)
+	}
+
+	path, lines, err := s.save(upload)
+	if err != nil {
+		return nil, err
+	}
+	total := lines
+	if params.Format == export.FormatCSV && total > 0 {
+		total-- // header
+	}
+	job, err := s.jobs.Start(db, actor, kind, params, total, s.runner(kind, path, params, actor.UserID))
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return job, nil
+}
+
+// save - chép file upload ra thư mục job và đếm số dòng để job có tổng ước lượng cho tiến độ
+func (s *ImportService) save(upload io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", 0, err
+	}
+	file, err := os.CreateTemp(s.dir, uploadFilePrefix+"*")
+	if err != nil {
+		return "", 0, err
+	}
+	counter := &lineCounter{w: file}
+	_, err = io.Copy(counter, upload)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", 0, err
+	}
+	lines := counter.lines
+	if counter.last != 0 && counter.last != '\n' {
+		lines++ // dòng cuối không có xuống dòng
+	}
+	return file.Name(), lines, nil
+}
+
+func (s *ImportService) runner(kind, path string, params dto.ImportRequest, userID uint) JobRunner {
+	return func(ctx context.Context, db *gorm.DB, job *model.Job, progress func(int64)) (*JobOutcome, error) {
+		defer os.Remove(path)
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader, err := export.NewReader(params.Format, bufio.NewReaderSize(file, 64<<10))
+		if err != nil {
+			return nil, err
+		}
+		loc, err := exportLocation(params.Timezone)
+		if err != nil {
+			return nil, err
+		}
+
+		var report *dto.ImportReport
+		if kind == model.JobImportSensorData {
+			report, err = runImport(db, reader, loc, params.DryRun, s.sensorImporter(), progress)
+		} else {
+			report, err = runImport(db, reader, loc, params.DryRun, s.historyImporter(userID), progress)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("stopped after %d rows: %w", report.Rows, err)
+		}
+		return &JobOutcome{Processed: report.Rows, Result: report}, nil
+	}
+}
+
+// importer - phần riêng của từng bảng: dựng bản ghi (kèm fingerprint) từ một dòng và ghi theo lô
+type importer[T any] struct {
+	parse    func(db *gorm.DB, values map[string]string, loc *time.Location) (T, string, error)
+	insert   func(db *gorm.DB, rows []T) (int64, error)
+	existing func(db *gorm.DB, fingerprints []string) ([]string, error)
+}
+
+func runImport[T any](db *gorm.DB, reader export.Reader, loc *time.Location, dryRun bool, imp importer[T], progress func(int64)) (*dto.ImportReport, error) {
+	report := &dto.ImportReport{DryRun: dryRun, Errors: []dto.ImportRowError{}}
+	reject := func(line int, err error) {
+		report.Invalid++
+		if len(report.Errors) < maxImportErrors {
+			report.Errors = append(report.Errors, dto.ImportRowError{Line: line, Message: err.Error()})
+		} else {
+			report.ErrorsTruncated = true
+		}
+	}
+
+	var rows []T
+	var fingerprints []string
+	// seen - dry-run không insert nên phải tự nhận ra dòng trùng trong cùng file
+	seen := map[string]struct{}{}
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		if dryRun {
+			existing, err := imp.existing(db, fingerprints)
+			if err != nil {
+				return err
+			}
+			report.Duplicates += int64(len(existing))
+		} else {
+			inserted, err := imp.insert(db, rows)
+			if err != nil {
+				return err
+			}
+			report.Inserted += inserted
+			report.Duplicates += int64(len(rows)) - inserted
+		}
+		rows = rows[:0]
+		fingerprints = fingerprints[:0]
+		progress(report.Rows)
+		return nil
+	}
+
+	for {
+		values, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *export.RowError
+		if errors.As(err, &rowErr) {
+			report.Rows++
+			reject(rowErr.Line, rowErr.Err)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Rows++
+
+		row, fingerprint, err := imp.parse(db, values, loc)
+		if err != nil {
+			reject(reader.Line(), err)
+			continue
+		}
+		report.Valid++
+		if dryRun {
+			if _, dup := seen[fingerprint]; dup {
+				report.Duplicates++
+				continue
+			}
+			seen[fingerprint] = struct{}{}
+		}
+		rows = append(rows, row)
+		fingerprints = append(fingerprints, fingerprint)
+		if len(rows) == importBatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// sensorImporter - cột created_at, temperature, humidity, light (hoặc light_raw như payload MQTT)
+func (s *ImportService) sensorImporter() importer[model.SensorData] {
+	return importer[model.SensorData]{
+		parse: func(db *gorm.DB, values map[string]string, loc *time.Location) (model.SensorData, string, error) {
+			createdAt, err := parseImportTime(values["created_at"], loc)
+			if err != nil {
+				return model.SensorData{}, "", err
+			}
+			reading := &dto.CreateSensorDTO{}
+			if reading.Temperature, err = parseImportFloat(values, "temperature"); err != nil {
+				return model.SensorData{}, "", err
+			}
+			if reading.Humidity, err = parseImportFloat(values, "humidity"); err != nil {
+				return model.SensorData{}, "", err
+			}
+			light := values["light"]
+			if light == "" {
+				light = values["light_raw"]
+			}
+			if reading.Light, err = strconv.Atoi(light); err != nil {
+				return model.SensorData{}, "", fmt.Errorf("invalid light %q", light)
+			}
+			if err := ValidateReading(reading); err != nil {
+				return model.SensorData{}, "", err
+			}
+
+			fingerprint := rowFingerprint(model.JobImportSensorData, createdAt,
+				strconv.FormatFloat(reading.Temperature, 'f', 2, 64),
+				strconv.FormatFloat(reading.Humidity, 'f', 2, 64),
+				strconv.Itoa(reading.Light))
+			data := model.SensorData{
+				Temperature: reading.Temperature,
+				Humidity:    reading.Humidity,
+				Light:       reading.Light,
+				Fingerprint: &fingerprint,
+			}
+			data.CreatedAt = createdAt
+			return data, fingerprint, nil
+		},
+		insert:   s.sensors.ImportSensorData,
+		existing: s.sensors.ExistingSensorFingerprints,
+	}
+}
+
+// historyImporter - cột created_at, device_id, user_change, status; user_id bỏ trống thì là người import.
+// Device và user_id trong file đều phải thuộc tenant đang import, tránh gán lịch sử cho user ngoài tenant.
+func (s *ImportService) historyImporter(userID uint) importer[model.DeviceHistory] {
+	devices := map[uint]bool{}
+	members := map[uint]bool{userID: true}
+	return importer[model.DeviceHistory]{
+		parse: func(db *gorm.DB, values map[string]string, loc *time.Location) (model.DeviceHistory, string, error) {
+			createdAt, err := parseImportTime(values["created_at"], loc)
+			if err != nil {
+				return model.DeviceHistory{}, "", err
+			}
+			req := &dto.CreateDeviceHistoryRequest{
+				UserID:     values["user_id"],
+				DeviceID:   values["device_id"],
+				UserChange: values["user_change"],
+				Status:     strings.ToUpper(values["status"]),
+			}
+			if req.UserID == "" {
+				req.UserID = strconv.FormatUint(uint64(userID), 10)
+			}
+			if err := validateHistory(req); err != nil {
+				return model.DeviceHistory{}, "", err
+			}
+			deviceID, err := strconv.ParseUint(req.DeviceID, 10, 32)
+			if err != nil || deviceID == 0 {
+				return model.DeviceHistory{}, "", fmt.Errorf("invalid device_id %q", req.DeviceID)
+			}
+			historyUserID, err := strconv.ParseUint(req.UserID, 10, 32)
+			if err != nil {
+				return model.DeviceHistory{}, "", fmt.Errorf("invalid user_id %q", req.UserID)
+			}
+			exists, checked := devices[uint(deviceID)]
+			if !checked {
+				_, err := s.devices.GetByID(db, uint(deviceID))
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return model.DeviceHistory{}, "", err
+				}
+				exists = err == nil
+				devices[uint(deviceID)] = exists
+			}
+			if !exists {
+				return model.DeviceHistory{}, "", fmt.Errorf("device %d not found", deviceID)
+			}
+			member, checked := members[uint(historyUserID)]
+			if !checked {
+				_, err := s.tenants.GetMembership(db, tenant.ID(db), uint(historyUserID))
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return model.DeviceHistory{}, "", err
+				}
+				member = err == nil
+				members[uint(historyUserID)] = member
+			}
+			if !member {
+				return model.DeviceHistory{}, "", fmt.Errorf("user %d is not a member of this tenant", historyUserID)
+			}
+
+			fingerprint := rowFingerprint(model.JobImportDeviceHistory, createdAt, req.DeviceID, req.UserID, req.UserChange, req.Status)
+			history := model.DeviceHistory{
+				UserID:      uint(historyUserID),
+				DeviceID:    uint(deviceID),
+				UserChange:  req.UserChange,
+				Status:      req.Status,
+				Fingerprint: &fingerprint,
+			}
+			history.CreatedAt = createdAt
+			return history, fingerprint, nil
+		},
+		insert:   s.histories.ImportDeviceHistories,
+		existing: s.histories.ExistingDeviceHistoryFingerprints,
+	}
+}
+
+// parseImportFloat - làm tròn 2 chữ số như cột decimal(5,2) để fingerprint khớp với giá trị được lưu
+func parseImportFloat(values map[string]string, column string) (float64, error) {
+	v, err := strconv.ParseFloat(values[column], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", column, values[column])
+	}
+	return math.Round(v*100) / 100, nil
+}
+
+// parseImportTime - RFC3339, "2006-01-02 15:04:05" theo loc hoặc unix epoch (giây/mili giây)
+func parseImportTime(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("created_at is required")
+	}
+	var t time.Time
+	if epoch, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if len(value) >= 13 {
+			t = time.UnixMilli(epoch)
+		} else {
+			t = time.Unix(epoch, 0)
+		}
+	} else if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		t = parsed
+	} else {
+		for _, layout := range importTimeLayouts {
+			if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+				t = parsed
+				break
+			}
+		}
+		if t.IsZero() {
+			return time.Time{}, fmt.Errorf("invalid created_at %q", value)
+		}
+	}
+	if t.After(time.Now().Add(24 * time.Hour)) {
+		return time.Time{}, fmt.Errorf("created_at %q is in the future", value)
+	}
+	return t.Truncate(time.Millisecond), nil
+}
+
+func rowFingerprint(kind string, createdAt time.Time, fields ...string) string {
+	sum := sha256.Sum256([]byte(kind + "\x1f" + strconv.FormatInt(createdAt.UnixMilli(), 10) + "\x1f" + strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+func importFormat(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".ndjson", ".jsonl":
+		return export.FormatNDJSON
+	}
+	return export.FormatCSV
+}
+
+type lineCounter struct {
+	w     io.Writer
+	lines int64
+	last  byte
+}
+
+func (l *lineCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\n' {
+			l.lines++
+		}
+	}
+	if len(p) > 0 {
+		l.last = p[len(p)-1]
+	}
+	return l.w.Write(p)
+}
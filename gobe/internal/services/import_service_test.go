@@ -0,0 +1,136 @@
+package services
+
+import (
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/internal/tenant"
+	"iot/pkg/export"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func newTestImportService() *ImportService {
+	return &ImportService{
+		sensors:   repository.NewSensorRepository(),
+		histories: repository.NewDeviceHistoryRepository(),
+		devices:   repository.NewDeviceRepository(),
+		tenants:   repository.NewTenantRepository(),
+	}
+}
+
+func importCSV[T any](t *testing.T, db *gorm.DB, file string, dryRun bool, imp importer[T]) *dto.ImportReport {
+	t.Helper()
+	reader, err := export.NewReader(export.FormatCSV, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := runImport(tenant.WithTenant(db, testTenantID), reader, time.UTC, dryRun, imp, func(int64) {})
+	if err != nil {
+		t.Fatalf("runImport: %v", err)
+	}
+	return report
+}
+
+type importCounts struct {
+	rows, valid, invalid, inserted, duplicates int64
+}
+
+func countsOf(report *dto.ImportReport) importCounts {
+	return importCounts{report.Rows, report.Valid, report.Invalid, report.Inserted, report.Duplicates}
+}
+
+func TestRunImportSensorData(t *testing.T) {
+	const header = "created_at,temperature,humidity,light\n"
+	const rowA = "2025-10-01 08:00:00,28.5,70,3000\n"
+	const rowB = "2025-10-01 08:01:00,28.6,71,3010\n"
+
+	tests := []struct {
+		name     string
+		existing string
+		file     string
+		dryRun   bool
+		want     importCounts
+		stored   int64
+	}{
+		{"new rows", "", header + rowA + rowB, false, importCounts{2, 2, 0, 2, 0}, 2},
+		{"invalid rows are reported", "", header + rowA + "2025-10-01 08:02:00,999,70,3000\n,28,70,3000\n", false, importCounts{3, 1, 2, 1, 0}, 1},
+		{"duplicate inside file", "", header + rowA + rowA + rowB, false, importCounts{3, 3, 0, 2, 1}, 2},
+		{"reimport skips stored rows", header + rowA, header + rowA + rowB, false, importCounts{2, 2, 0, 1, 1}, 2},
+		{"same time other values is not a duplicate", header + rowA, header + "2025-10-01 08:00:00,28.5,70,3001\n", false, importCounts{1, 1, 0, 1, 0}, 2},
+		{"dry run inserts nothing", "", header + rowA + rowB, true, importCounts{2, 2, 0, 0, 0}, 0},
+		{"dry run counts stored rows", header + rowA, header + rowA + rowB, true, importCounts{2, 2, 0, 0, 1}, 1},
+		{"dry run counts duplicates inside file", "", header + rowA + rowA, true, importCounts{2, 2, 0, 0, 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			s := newTestImportService()
+			if tt.existing != "" {
+				importCSV(t, db, tt.existing, false, s.sensorImporter())
+			}
+			report := importCSV(t, db, tt.file, tt.dryRun, s.sensorImporter())
+			if got := countsOf(report); got != tt.want {
+				t.Errorf("report = %+v, want %+v (errors %v)", got, tt.want, report.Errors)
+			}
+			if report.DryRun != tt.dryRun {
+				t.Errorf("DryRun = %v, want %v", report.DryRun, tt.dryRun)
+			}
+			var stored int64
+			db.Model(&model.SensorData{}).Where("tenant_id = ?", testTenantID).Count(&stored)
+			if stored != tt.stored {
+				t.Errorf("stored = %d, want %d", stored, tt.stored)
+			}
+		})
+	}
+}
+
+func TestRunImportDeviceHistory(t *testing.T) {
+	const header = "created_at,device_id,user_id,user_change,status\n"
+	const (
+		actorID    = 1
+		memberID   = 2
+		outsiderID = 3
+	)
+
+	tests := []struct {
+		name   string
+		file   string
+		want   importCounts
+		userID []uint
+	}{
+		{"blank user is the importer", header + "2025-10-01 08:00:00,1,,alice,on\n", importCounts{1, 1, 0, 1, 0}, []uint{actorID}},
+		{"member of the tenant", header + "2025-10-01 08:00:00,1,2,bob,OFF\n", importCounts{1, 1, 0, 1, 0}, []uint{memberID}},
+		{"user outside the tenant", header + "2025-10-01 08:00:00,1,3,mallory,ON\n", importCounts{1, 0, 1, 0, 0}, nil},
+		{"device of another tenant", header + "2025-10-01 08:00:00,2,,alice,ON\n", importCounts{1, 0, 1, 0, 0}, nil},
+		{"invalid status", header + "2025-10-01 08:00:00,1,,alice,maybe\n", importCounts{1, 0, 1, 0, 0}, nil},
+		{"duplicate inside file", header + "2025-10-01 08:00:00,1,,alice,ON\n2025-10-01 08:00:00,1,1,alice,ON\n", importCounts{2, 2, 0, 1, 1}, []uint{actorID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			db.Exec("INSERT INTO tenant_memberships (tenant_id, user_id, role) VALUES (?, ?, 'admin'), (?, ?, 'viewer'), (?, ?, 'admin')",
+				testTenantID, actorID, testTenantID, memberID, testTenantID+1, outsiderID)
+			db.Exec("INSERT INTO devices (id, name, status, tenant_id) VALUES (1, 'fan', 'OFF', ?), (2, 'lamp', 'OFF', ?)", testTenantID, testTenantID+1)
+
+			s := newTestImportService()
+			report := importCSV(t, db, tt.file, false, s.historyImporter(actorID))
+			if got := countsOf(report); got != tt.want {
+				t.Errorf("report = %+v, want %+v (errors %v)", got, tt.want, report.Errors)
+			}
+			var userIDs []uint
+			db.Model(&model.DeviceHistory{}).Where("tenant_id = ?", testTenantID).Order("id").Pluck("user_id", &userIDs)
+			if len(userIDs) != len(tt.userID) {
+				t.Fatalf("stored users = %v, want %v", userIDs, tt.userID)
+			}
+			for i := range userIDs {
+				if userIDs[i] != tt.userID[i] {
+					t.Errorf("stored users = %v, want %v", userIDs, tt.userID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/internal/tenant"
+	"iot/pkg/config"
+	"iot/pkg/logger"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrJobNotReady = errors.New("job has not finished successfully")
+	ErrJobExpired  = errors.New("job result has expired")
+)
+
+// jobCleanupInterval - chu kỳ xóa file và bản ghi job quá hạn
+const jobCleanupInterval = 10 * time.Minute
+
+// JobOutcome - kết quả runner trả về: file (export) và/hoặc Result lưu dạng JSON
+type JobOutcome struct {
+	Processed int64
+	FilePath  string
+	FileName  string
+	Result    interface{}
+}
+
+// JobRunner - phần việc của job, db đã chọn tenant của job. progress được gọi thoải mái,
+// JobService tự giãn nhịp ghi xuống database.
+type JobRunner func(ctx context.Context, db *gorm.DB, job *model.Job, progress func(processed int64)) (*JobOutcome, error)
+
+type JobServiceInterface interface {
+	Start(db *gorm.DB, actor dto.Actor, kind string, params interface{}, total int64, run JobRunner) (*dto.JobDTO, error)
+	List(db *gorm.DB, userID uint) ([]dto.JobDTO, error)
+	Get(db *gorm.DB, userID, id uint) (*dto.JobDTO, error)
+	ResultFile(db *gorm.DB, userID, id uint) (*model.Job, error)
+	RunMaintenance()
+}
+
+// JobService - chạy job trong goroutine với số worker giới hạn. Job sống trong tiến trình,
+// server khởi động lại thì job dở dang bị đánh dấu lỗi.
+type JobService struct {
+	ctx   context.Context
+	db    *gorm.DB
+	repo  repository.JobRepositoryInterface
+	dir   string
+	ttl   time.Duration
+	slots chan struct{}
+}
+
+func NewJobService(ctx context.Context, db *gorm.DB, repo repository.JobRepositoryInterface, cfg *config.ExportConfig) JobServiceInterface {
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	return &JobService{
+		ctx:   ctx,
+		db:    db,
+		repo:  repo,
+		dir:   cfg.Dir,
+		ttl:   cfg.TTL,
+		slots: make(chan struct{}, workers),
+	}
+}
+
+func (s *JobService) Start(db *gorm.DB, actor dto.Actor, kind string, params interface{}, total int64, run JobRunner) (*dto.JobDTO, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	job := &model.Job{
+		UserID: actor.UserID,
+		Kind:   kind,
+		Status: model.JobPending,
+		Params: string(raw),
+		Total:  total,
+	}
+	if err := s.repo.CreateJob(db, job); err != nil {
+		return nil, err
+	}
+	go s.execute(job, run)
+	return jobResponse(job, time.Now()), nil
+}
+
+func (s *JobService) execute(job *model.Job, run JobRunner) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	system := tenant.System(s.db.WithContext(s.ctx))
+	s.update(system, job.ID, map[string]interface{}{"status": model.JobRunning})
+
+	var outcome *JobOutcome
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		var reported time.Time
+		outcome, err = run(s.ctx, tenant.WithTenant(s.db.WithContext(s.ctx), job.TenantID), job, func(processed int64) {
+			if time.Since(reported) < time.Second {
+				return
+			}
+			reported = time.Now()
+			s.update(system, job.ID, map[string]interface{}{"processed": processed})
+		})
+	}()
+
+	now := time.Now()
+	fields := map[string]interface{}{"finished_at": now, "expires_at": now.Add(s.ttl)}
+	if err != nil {
+		fields["status"] = model.JobFailed
+		fields["error"] = truncate(err.Error(), 500)
+		logger.Log.Warn("Job failed", zap.Uint("job_id", job.ID), zap.String("kind", job.Kind), zap.Error(err))
+	} else {
+		fields["status"] = model.JobSucceeded
+		fields["processed"] = outcome.Processed
+		if outcome.FilePath != "" {
+			fields["file_path"] = outcome.FilePath
+			fields["file_name"] = outcome.FileName
+			if info, statErr := os.Stat(outcome.FilePath); statErr == nil {
+				fields["file_size"] = info.Size()
+			}
+		}
+		if outcome.Result != nil {
+			raw, _ := json.Marshal(outcome.Result)
+			fields["result"] = string(raw)
+		}
+	}
+	s.update(system, job.ID, fields)
+}
+
+func (s *JobService) update(db *gorm.DB, id uint, fields map[string]interface{}) {
+	if err := s.repo.UpdateJob(db, id, fields); err != nil {
+		logger.Log.Error("Job update failed", zap.Uint("job_id", id), zap.Error(err))
+	}
+}
+
+func (s *JobService) List(db *gorm.DB, userID uint) ([]dto.JobDTO, error) {
+	jobs, err := s.repo.ListByUser(db, userID, 50)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := make([]dto.JobDTO, 0, len(jobs))
+	for i := range jobs {
+		result = append(result, *jobResponse(&jobs[i], now))
+	}
+	return result, nil
+}
+
+func (s *JobService) Get(db *gorm.DB, userID, id uint) (*dto.JobDTO, error) {
+	job, err := s.repo.GetByID(db, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return jobResponse(job, time.Now()), nil
+}
+
+// ResultFile - job của chính user, đã xong và file còn hạn
+func (s *JobService) ResultFile(db *gorm.DB, userID, id uint) (*model.Job, error) {
+	job, err := s.repo.GetByID(db, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != model.JobSucceeded || job.FilePath == "" {
+		return nil, ErrJobNotReady
+	}
+	if job.ExpiresAt != nil && time.Now().After(*job.ExpiresAt) {
+		return nil, ErrJobExpired
+	}
+	return job, nil
+}
+
+// RunMaintenance - đánh dấu lỗi job dở dang của lần chạy trước rồi định kỳ xóa kết quả quá hạn, dừng khi ctx hủy
+func (s *JobService) RunMaintenance() {
+	system := tenant.System(s.db.WithContext(s.ctx))
+	now := time.Now()
+	if n, err := s.repo.FailUnfinished(system, "interrupted by server restart", now, now.Add(s.ttl)); err != nil {
+		logger.Log.Error("Failed to close unfinished jobs", zap.Error(err))
+	} else if n > 0 {
+		logger.Log.Info("Closed unfinished jobs", zap.Int64("count", n))
+	}
+
+	ticker := time.NewTicker(jobCleanupInterval)
+	defer ticker.Stop()
+	for {
+		s.cleanup(system)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *JobService) cleanup(db *gorm.DB) {
+	jobs, err := s.repo.ListExpired(db, time.Now())
+	if err != nil {
+		logger.Log.Error("Failed to list expired jobs", zap.Error(err))
+		return
+	}
+	for _, job := range jobs {
+		if job.FilePath != "" {
+			if err := os.Remove(job.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.Log.Warn("Failed to remove job file", zap.Uint("job_id", job.ID), zap.Error(err))
+				continue
+			}
+		}
+		if err := s.repo.DeleteJob(db, job.ID); err != nil {
+			logger.Log.Error("Failed to delete expired job", zap.Uint("job_id", job.ID), zap.Error(err))
+		}
+	}
+
+	// File upload của import bị bỏ dở khi server dừng
+	uploads, _ := filepath.Glob(filepath.Join(s.dir, uploadFilePrefix+"*"))
+	for _, path := range uploads {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > s.ttl {
+			os.Remove(path)
+		}
+	}
+}
+
+func jobResponse(job *model.Job, now time.Time) *dto.JobDTO {
+	response := &dto.JobDTO{
+		ID:         job.ID,
+		Kind:       job.Kind,
+		Status:     job.Status,
+		Processed:  job.Processed,
+		Total:      job.Total,
+		Error:      job.Error,
+		FileName:   job.FileName,
+		FileSize:   job.FileSize,
+		CreatedAt:  job.CreatedAt,
+		FinishedAt: job.FinishedAt,
+		ExpiresAt:  job.ExpiresAt,
+	}
+	if job.Result != "" {
+		response.Result = json.RawMessage(job.Result)
+	}
+	if job.Status == model.JobSucceeded && job.FilePath != "" && (job.ExpiresAt == nil || now.Before(*job.ExpiresAt)) {
+		response.DownloadURL = fmt.Sprintf("/api/v1/jobs/%d/download", job.ID)
+	}
+	return response
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iot/internal/dto"
+	"iot/internal/helper/mailer"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/pkg/config"
+	"iot/pkg/oidc"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOIDCInvalidState     = errors.New("invalid or expired login state, please start the login again")
+	ErrOIDCEmailNotVerified = errors.New("the identity provider did not return a verified email")
+	ErrOIDCSignupDisabled   = errors.New("no account is linked to this identity and sign-up is disabled")
+	ErrOIDCProvider         = errors.New("identity provider login failed")
+)
+
+const (
+	oidcStateKeyPrefix = "oidc:state:"
+	oidcStateTTL       = 10 * time.Minute
+)
+
+// oidcState - lưu trong Redis theo state, lấy ra bằng GETDEL nên chỉ dùng được một lần
+type oidcState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+}
+
+type OIDCServiceInterface interface {
+	Begin(ctx context.Context, redirectPath string) (string, error)
+	Complete(ctx context.Context, db *gorm.DB, code, state string, meta *dto.SessionMeta, mailer_service *mailer.MailService) (*dto.OIDCLoginResult, error)
+}
+
+type OIDCService struct {
+	provider     *oidc.Provider
+	cfg          *config.OIDCConfig
+	redis        *redis.Client
+	userRepo     repository.UserRepositoryInterface
+	identityRepo repository.UserIdentityRepositoryInterface
+	sessions     SessionServiceInterface
+	twoFactor    TwoFactorServiceInterface
+	tenants      TenantServiceInterface
+	tenantRepo   repository.TenantRepositoryInterface
+}
+
+func NewOIDCService(cfg *config.OIDCConfig, redis *redis.Client, userRepo repository.UserRepositoryInterface, identityRepo repository.UserIdentityRepositoryInterface, sessions SessionServiceInterface, twoFactor TwoFactorServiceInterface, tenants TenantServiceInterface, tenantRepo repository.TenantRepositoryInterface) OIDCServiceInterface {
+	return &OIDCService{
+		provider: oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.Issuer,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		}),
+		cfg:          cfg,
+		redis:        redis,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		sessions:     sessions,
+		twoFactor:    twoFactor,
+		tenants:      tenants,
+		tenantRepo:   tenantRepo,
+	}
+}
+
+// Begin - tạo state, nonce, PKCE verifier rồi trả về URL đăng nhập của provider
+func (s *OIDCService) Begin(ctx context.Context, redirectPath string) (string, error) {
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(oidcState{Nonce: nonce, Verifier: verifier, Redirect: safeRedirectPath(redirectPath)})
+	if err != nil {
+		return "", err
+	}
+	if err := s.redis.Set(ctx, oidcStateKeyPrefix+state, payload, oidcStateTTL).Err(); err != nil {
+		return "", err
+	}
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, oidc.S256Challenge(verifier))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+	return authURL, nil
+}
+
+// Complete - đổi code lấy ID token, tìm/liên kết/tạo user rồi cấp session như đăng nhập mật khẩu
+func (s *OIDCService) Complete(ctx context.Context, db *gorm.DB, code, state string, meta *dto.SessionMeta, mailer_service *mailer.MailService) (*dto.OIDCLoginResult, error) {
+	raw, err := s.redis.GetDel(ctx, oidcStateKeyPrefix+state).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrOIDCInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved oidcState
+	if err := json.Unmarshal([]byte(raw), &saved); err != nil {
+		return nil, ErrOIDCInvalidState
+	}
+
+	token, err := s.provider.Exchange(ctx, code, saved.Verifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+	idToken, err := s.provider.Verify(ctx, token.IDToken, saved.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+
+	user, err := s.resolveUser(ctx, db, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.OIDCLoginResult{Redirect: s.redirectURL(saved.Redirect)}
+	userResponse := &dto.UserDTO{
+		ID:              user.ID,
+		Name:            user.Name,
+		Email:           user.Email,
+		TwoFactorMethod: user.TwoFactorMethod,
+	}
+	// 2FA của hệ thống vẫn áp dụng, provider chỉ thay thế bước mật khẩu
+	if user.TwoFactorMethod != model.TwoFactorNone {
+		challenge, err := s.twoFactor.StartChallenge(ctx, user, mailer_service)
+		if err != nil {
+			return nil, err
+		}
+		result.Login = &dto.LoginResponse{User: userResponse, Challenge: challenge}
+		return result, nil
+	}
+	selection, err := s.tenants.Resolve(db, user, 0)
+	if err != nil {
+		return nil, err
+	}
+	userResponse.Role = selection.Role
+	userResponse.TenantID = selection.TenantID
+	tokenPair, err := s.sessions.CreateSession(ctx, user, selection, meta)
+	if err != nil {
+		return nil, err
+	}
+	result.Login = &dto.LoginResponse{User: userResponse, TokenPair: tokenPair}
+	return result, nil
+}
+
+// resolveUser - identity đã liên kết -> user; chưa thì liên kết theo email đã xác minh hoặc tạo mới (JIT)
+func (s *OIDCService) resolveUser(ctx context.Context, db *gorm.DB, idToken *oidc.IDToken) (*model.User, error) {
+	now := time.Now()
+	email := strings.TrimSpace(idToken.Email)
+	role, mapped := s.mapRole(idToken.Claims)
+
+	identity, err := s.identityRepo.GetByIssuerSubject(db, idToken.Issuer, idToken.Subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if identity != nil {
+		user, err := s.userRepo.GetByID(db, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.identityRepo.TouchLogin(db, identity.ID, email, now); err != nil {
+			return nil, err
+		}
+		return user, s.syncMembership(ctx, db, user, role, mapped, false)
+	}
+
+	if email == "" || !idToken.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	user, err := s.userRepo.GetByEmail(db, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if user == nil && !s.cfg.JITProvisioning {
+		return nil, ErrOIDCSignupDisabled
+	}
+
+	created := user == nil
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if created {
+			user, err = s.provisionUser(tx, idToken, email)
+			if err != nil {
+				return err
+			}
+		}
+		return s.identityRepo.CreateIdentity(tx, &model.UserIdentity{
+			UserID:      user.ID,
+			Issuer:      idToken.Issuer,
+			Subject:     idToken.Subject,
+			Email:       email,
+			LastLoginAt: &now,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, s.syncMembership(ctx, db, user, role, mapped, created)
+}
+
+// provisionUser - mật khẩu ngẫu nhiên không ai biết, user muốn đăng nhập bằng mật khẩu thì dùng quên mật khẩu.
+// Role hệ thống luôn là mặc định, quyền đến từ membership do syncMembership tạo.
+func (s *OIDCService) provisionUser(db *gorm.DB, idToken *oidc.IDToken, email string) (*model.User, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(b)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(idToken.Name)
+	if name == "" {
+		name = strings.Split(email, "@")[0]
+	}
+	user := &model.User{
+		Name:     name,
+		Email:    email,
+		Password: string(hashedPassword),
+		Role:     model.RoleViewer,
+	}
+	if err := s.userRepo.CreateUser(db, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// syncMembership - provider chỉ quyết định role thành viên trong tenant oidc.tenant, không bao giờ đổi role hệ thống
+// (users.role). User vừa tạo vào tenant với role map được hoặc default_role; user cũ chỉ đổi khi claim map được,
+// claim không có giá trị nào khớp role_map thì giữ nguyên role đang có. Role đổi thì session đang mang tenant này
+// bị thu hồi để access token cũ không giữ quyền cũ.
+func (s *OIDCService) syncMembership(ctx context.Context, db *gorm.DB, user *model.User, role string, mapped, created bool) error {
+	if s.cfg.Tenant == "" || (!mapped && !created) {
+		return nil
+	}
+	target, err := s.tenantRepo.GetBySlug(db, s.cfg.Tenant)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !mapped {
+		role = s.defaultRole()
+	}
+
+	membership, err := s.tenantRepo.GetMembership(db, target.ID, user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.tenantRepo.CreateMembership(db, &model.TenantMembership{TenantID: target.ID, UserID: user.ID, Role: role})
+	}
+	if err != nil || membership.Role == role {
+		return err
+	}
+	// actor 0 là provider, không phải chính user nên không bị chặn tự hạ quyền; admin cuối cùng của tenant vẫn được giữ
+	err = s.tenants.UpdateMemberRole(db, target.ID, 0, user.ID, role)
+	if errors.Is(err, ErrLastTenantAdmin) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.sessions.RevokeTenantSessions(ctx, user.ID, target.ID)
+}
+
+// mapRole - claim có thể là chuỗi hoặc mảng, nhiều giá trị khớp thì lấy role cao nhất.
+// false khi không có claim hoặc không giá trị nào có trong role_map.
+func (s *OIDCService) mapRole(claims map[string]interface{}) (string, bool) {
+	if len(s.cfg.RoleMap) == 0 {
+		return "", false
+	}
+	raw, ok := claims[s.cfg.RoleClaim]
+	if !ok {
+		return "", false
+	}
+
+	var values []string
+	switch v := raw.(type) {
+	case string:
+		values = strings.Fields(strings.ReplaceAll(v, ",", " "))
+	case []interface{}:
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+
+	role := ""
+	for _, value := range values {
+		mapped, ok := s.cfg.RoleMap[value]
+		if ok && model.IsValidRole(mapped) && roleRank(mapped) > roleRank(role) {
+			role = mapped
+		}
+	}
+	return role, role != ""
+}
+
+func (s *OIDCService) defaultRole() string {
+	if model.IsValidRole(s.cfg.DefaultRole) {
+		return s.cfg.DefaultRole
+	}
+	return model.RoleViewer
+}
+
+func (s *OIDCService) redirectURL(path string) string {
+	return strings.TrimRight(s.cfg.PostLoginRedirect, "/") + path
+}
+
+func roleRank(role string) int {
+	switch role {
+	case model.RoleAdmin:
+		return 3
+	case model.RoleOperator:
+		return 2
+	case model.RoleViewer:
+		return 1
+	}
+	return 0
+}
+
+// safeRedirectPath - chỉ nhận path tương đối trên frontend để callback không thành open redirect
+func safeRedirectPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.ContainsAny(path, "\\\r\n") {
+		return "/"
+	}
+	if u, err := url.Parse(path); err != nil || u.Host != "" || u.Scheme != "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/pkg/config"
+	"testing"
+)
+
+// revokeRecorder - chỉ ghi lại lần thu hồi session, method khác không được gọi trong test
+type revokeRecorder struct {
+	SessionServiceInterface
+	revoked []uint
+}
+
+func (r *revokeRecorder) RevokeTenantSessions(ctx context.Context, userID, tenantID uint) error {
+	r.revoked = append(r.revoked, userID)
+	return nil
+}
+
+func (r *revokeRecorder) RevokeAllSessions(ctx context.Context, userID uint) error {
+	r.revoked = append(r.revoked, userID)
+	return nil
+}
+
+func TestOIDCSyncMembership(t *testing.T) {
+	const (
+		userID  = 1
+		otherID = 2
+	)
+	roleMap := map[string]string{"iot-admins": model.RoleAdmin, "iot-ops": model.RoleOperator, "iot-users": model.RoleViewer}
+
+	tests := []struct {
+		name        string
+		systemRole  string
+		member      string // role hiện tại trong tenant, rỗng là chưa là thành viên
+		otherAdmin  bool
+		claims      map[string]interface{}
+		created     bool
+		want        string
+		wantRevoked bool
+	}{
+		{"new user without claim gets default role", model.RoleViewer, "", false, nil, true, model.RoleViewer, false},
+		{"new user with mapped claim", model.RoleViewer, "", false, map[string]interface{}{"roles": []interface{}{"iot-ops"}}, true, model.RoleOperator, false},
+		{"highest mapped value wins", model.RoleViewer, "", false, map[string]interface{}{"roles": "iot-users, iot-admins"}, true, model.RoleAdmin, false},
+		{"existing user without claim is not added", model.RoleViewer, "", false, nil, false, "", false},
+		{"unmapped claim keeps local admin", model.RoleViewer, model.RoleAdmin, false, map[string]interface{}{"roles": []interface{}{"staff"}}, false, model.RoleAdmin, false},
+		{"mapped claim demotes and revokes sessions", model.RoleAdmin, model.RoleAdmin, true, map[string]interface{}{"roles": "iot-users"}, false, model.RoleViewer, true},
+		{"mapped claim promotes member only", model.RoleViewer, model.RoleViewer, false, map[string]interface{}{"roles": "iot-admins"}, false, model.RoleAdmin, true},
+		{"same role changes nothing", model.RoleViewer, model.RoleOperator, false, map[string]interface{}{"roles": "iot-ops"}, false, model.RoleOperator, false},
+		{"last tenant admin is kept", model.RoleViewer, model.RoleAdmin, false, map[string]interface{}{"roles": "iot-users"}, false, model.RoleAdmin, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			db.Exec("INSERT INTO users (id, name, email, password, role) VALUES (?, 'alice', 'alice@example.com', 'x', ?), (?, 'bob', 'bob@example.com', 'x', 'viewer')",
+				userID, tt.systemRole, otherID)
+			if tt.member != "" {
+				db.Exec("INSERT INTO tenant_memberships (tenant_id, user_id, role) VALUES (?, ?, ?)", testTenantID, userID, tt.member)
+			}
+			if tt.otherAdmin {
+				db.Exec("INSERT INTO tenant_memberships (tenant_id, user_id, role) VALUES (?, ?, 'admin')", testTenantID, otherID)
+			}
+
+			sessions := &revokeRecorder{}
+			tenantRepo := repository.NewTenantRepository()
+			userRepo := repository.NewUserRepository()
+			s := &OIDCService{
+				cfg:        &config.OIDCConfig{RoleClaim: "roles", RoleMap: roleMap, DefaultRole: model.RoleViewer, Tenant: "default"},
+				userRepo:   userRepo,
+				sessions:   sessions,
+				tenants:    NewTenantService(tenantRepo, userRepo, sessions),
+				tenantRepo: tenantRepo,
+			}
+			user, err := userRepo.GetByID(db, userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			role, mapped := s.mapRole(tt.claims)
+			if err := s.syncMembership(context.Background(), db, user, role, mapped, tt.created); err != nil {
+				t.Fatalf("syncMembership: %v", err)
+			}
+
+			var got string
+			db.Model(&model.TenantMembership{}).Where("tenant_id = ? AND user_id = ?", testTenantID, userID).Pluck("role", &got)
+			if got != tt.want {
+				t.Errorf("membership role = %q, want %q", got, tt.want)
+			}
+			if revoked := len(sessions.revoked) > 0; revoked != tt.wantRevoked {
+				t.Errorf("sessions revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+			var systemRole string
+			db.Model(&model.User{}).Where("id = ?", userID).Pluck("role", &systemRole)
+			if systemRole != tt.systemRole {
+				t.Errorf("system role = %q, want it unchanged (%q)", systemRole, tt.systemRole)
+			}
+		})
+	}
+}
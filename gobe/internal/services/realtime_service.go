@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iot/internal/dto"
+	"iot/internal/repository"
+	"iot/internal/tenant"
+	"iot/pkg/pagination"
+	"iot/pkg/socket"
+	"iot/pkg/stream"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// Redis stream theo tenant giữ các bản ghi sensor gần nhất để client reconnect lấy lại
+	sensorStreamPrefix = "sensor:stream:"
+	sensorStreamMaxLen = 1000
+	snapshotReadings   = 20
+	catchUpMaxReadings = 500
+)
+
+var ErrTelemetryForbidden = errors.New("no access to telemetry")
+
+type RealtimeServiceInterface interface {
+	AppendReading(ctx context.Context, tenantID uint, payload []byte) (string, error)
+	Snapshot(ctx context.Context, identity *socket.Identity, readings bool) (any, []string, error)
+	Since(ctx context.Context, identity *socket.Identity, cursor string) (any, error)
+}
+
+type realtimeService struct {
+	db            *gorm.DB
+	redis         *redis.Client
+	deviceService DeviceServiceInterface
+	sensorRepo    repository.SensorRepositoryInterface
+}
+
+func NewRealtimeService(db *gorm.DB, redis *redis.Client, deviceService DeviceServiceInterface, sensorRepo repository.SensorRepositoryInterface) RealtimeServiceInterface {
+	return &realtimeService{
+		db:            db,
+		redis:         redis,
+		deviceService: deviceService,
+		sensorRepo:    sensorRepo,
+	}
+}
+
+func actorOf(identity *socket.Identity) dto.Actor {
+	if identity == nil {
+		return dto.Actor{}
+	}
+	return dto.Actor{UserID: identity.UserID, TenantID: identity.TenantID, Role: identity.Role, Name: dto.ActorName(identity.Username, identity.Email)}
+}
+
+func sensorStreamKey(tenantID uint) string {
+	return fmt.Sprintf("%s%d", sensorStreamPrefix, tenantID)
+}
+
+// tenantDB - db chỉ thấy dữ liệu tenant của identity
+func (s *realtimeService) tenantDB(ctx context.Context, identity *socket.Identity) *gorm.DB {
+	return tenant.WithTenant(s.db.WithContext(ctx), identity.TenantID)
+}
+
+// AppendReading - ghi payload vào Redis stream, trả về stream ID dùng làm cursor
+func (s *realtimeService) AppendReading(ctx context.Context, tenantID uint, payload []byte) (string, error) {
+	if s.redis == nil {
+		return "", errors.New("redis client is nil")
+	}
+	return s.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: sensorStreamKey(tenantID),
+		MaxLen: sensorStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"payload": payload},
+	}).Result()
+}
+
+// Snapshot - trạng thái hiện tại của các device và các bản ghi sensor mới nhất mà identity được xem,
+// kèm danh sách group socket tương ứng. readings=false bỏ phần bản ghi sensor (client đã có từ catch-up).
+func (s *realtimeService) Snapshot(ctx context.Context, identity *socket.Identity, readings bool) (any, []string, error) {
+	snapshot := &dto.LiveSnapshot{
+		Type:     "snapshot",
+		Devices:  []dto.DeviceStateDTO{},
+		Readings: []dto.LiveReading{},
+	}
+	if identity == nil || identity.TenantID == 0 {
+		return snapshot, nil, nil
+	}
+
+	db := s.tenantDB(ctx, identity)
+	actor := actorOf(identity)
+	devices, _, err := s.deviceService.GetAllDevices(db, actor, &pagination.Request{Limit: pagination.MaxLimit}, s.redis)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load devices: %w", err)
+	}
+	groups := make([]string, 0, len(devices)+1)
+	for _, d := range devices {
+		snapshot.Devices = append(snapshot.Devices, dto.DeviceStateDTO{ID: d.ID, Name: d.Name, Status: d.Status})
+		groups = append(groups, socket.DeviceGroup(d.ID))
+	}
+
+	allowed, err := s.deviceService.CanReadTelemetry(db, actor)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !allowed {
+		return snapshot, groups, nil
+	}
+	groups = append(groups, socket.TelemetryGroup(identity.TenantID))
+	if !readings {
+		return snapshot, groups, nil
+	}
+
+	if s.redis != nil {
+		msgs, err := s.redis.XRevRangeN(ctx, sensorStreamKey(identity.TenantID), "+", "-", snapshotReadings).Result()
+		if err == nil && len(msgs) > 0 {
+			// XREVRANGE trả về mới nhất trước, đảo lại để client nhận theo thứ tự thời gian
+			for i := len(msgs) - 1; i >= 0; i-- {
+				snapshot.Readings = append(snapshot.Readings, toLiveReading(msgs[i]))
+			}
+			snapshot.Cursor = msgs[0].ID
+			return snapshot, groups, nil
+		}
+	}
+
+	// Stream trống hoặc Redis lỗi - fallback về bản ghi cuối trong DB
+	last, err := s.sensorRepo.GetLastSensorData(db)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("load last sensor data: %w", err)
+	}
+	if last != nil {
+		data, _ := json.Marshal(map[string]interface{}{
+			"temperature": last.Temperature,
+			"humidity":    last.Humidity,
+			"light_raw":   last.Light,
+		})
+		snapshot.Readings = append(snapshot.Readings, dto.LiveReading{Data: data})
+	}
+	return snapshot, groups, nil
+}
+
+// Since - các bản ghi sau cursor mà client đã bỏ lỡ
+func (s *realtimeService) Since(ctx context.Context, identity *socket.Identity, cursor string) (any, error) {
+	if s.redis == nil {
+		return nil, errors.New("redis client is nil")
+	}
+	if identity == nil || identity.TenantID == 0 {
+		return nil, ErrTelemetryForbidden
+	}
+	allowed, err := s.deviceService.CanReadTelemetry(s.tenantDB(ctx, identity), actorOf(identity))
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrTelemetryForbidden
+	}
+	if _, _, err := stream.ParseID(cursor); err != nil {
+		return nil, err
+	}
+
+	result := &dto.LiveCatchUp{
+		Type:     "catchup",
+		Readings: []dto.LiveReading{},
+		Cursor:   cursor,
+	}
+
+	key := sensorStreamKey(identity.TenantID)
+	oldest, err := s.redis.XRangeN(ctx, key, "-", "+", 1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(oldest) > 0 && stream.CompareIDs(cursor, oldest[0].ID) < 0 {
+		// Cursor cũ hơn phần còn giữ trong buffer, client đã mất một phần dữ liệu
+		result.Truncated = true
+	}
+
+	msgs, err := s.redis.XRangeN(ctx, key, "("+cursor, "+", catchUpMaxReadings).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range msgs {
+		result.Readings = append(result.Readings, toLiveReading(m))
+	}
+	if len(msgs) > 0 {
+		result.Cursor = msgs[len(msgs)-1].ID
+	}
+	if len(msgs) == catchUpMaxReadings {
+		result.Truncated = true
+	}
+	return result, nil
+}
+
+func toLiveReading(m redis.XMessage) dto.LiveReading {
+	reading := dto.LiveReading{Cursor: m.ID}
+	if raw, ok := m.Values["payload"].(string); ok && json.Valid([]byte(raw)) {
+		reading.Data = json.RawMessage(raw)
+	} else {
+		reading.Data = json.RawMessage("null")
+	}
+	return reading
+}
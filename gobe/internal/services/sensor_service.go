@@ -3,9 +3,16 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"iot/internal/dto"
 	"iot/internal/model"
 	"iot/internal/repository"
+	"iot/internal/tenant"
+	"iot/pkg/metrics"
+	"iot/pkg/pagination"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -14,11 +21,46 @@ import (
 
 type SensorServiceInterface interface {
 	CreateSensorData(*gorm.DB, *dto.CreateSensorDTO, *redis.Client) error
-	GetAllSensorData(*gorm.DB, int, int, string, string, string, string, string) ([]model.SensorData, int, error)
+	GetAllSensorData(*gorm.DB, *pagination.Request, string, string, string) ([]model.SensorData, *pagination.Page, error)
 	DeleteSensorData(*gorm.DB, uint) error
 	GetSensorDataByID(*gorm.DB, uint) (*model.SensorData, error)
 	GetSensorDataByTime(*gorm.DB, string) (*model.SensorData, error)
 	GetLastSensorData(*gorm.DB, *redis.Client, context.Context) (*model.SensorData, error)
+	AggregateSensorData(*gorm.DB, string, string, string, string) ([]model.SensorBucket, error)
+}
+
+var ErrInvalidReading = errors.New("invalid sensor reading")
+
+var ErrInvalidBucket = errors.New("bucket must be a duration between 1m and 31d such as 5m, 1h or 1d")
+
+const (
+	minBucket = time.Minute
+	maxBucket = 31 * 24 * time.Hour
+	// MaxSensorBuckets - đạt số này thì các khoảng cũ hơn đã bị bỏ bớt
+	MaxSensorBuckets = repository.MaxSensorBuckets
+)
+
+// Ngưỡng hợp lệ: dải đo rộng của cảm biến nhiệt/ẩm và ADC 12 bit của ESP32 (light_raw)
+const (
+	minTemperature = -40
+	maxTemperature = 80
+	minHumidity    = 0
+	maxHumidity    = 100
+	minLight       = 0
+	maxLight       = 4095
+)
+
+// ValidateReading - dùng chung cho dữ liệu MQTT (trước khi phát cho client) và import, NaN cũng bị loại
+func ValidateReading(d *dto.CreateSensorDTO) error {
+	switch {
+	case !(d.Temperature >= minTemperature && d.Temperature <= maxTemperature):
+		return fmt.Errorf("%w: temperature %v outside [%d, %d]", ErrInvalidReading, d.Temperature, minTemperature, maxTemperature)
+	case !(d.Humidity >= minHumidity && d.Humidity <= maxHumidity):
+		return fmt.Errorf("%w: humidity %v outside [%d, %d]", ErrInvalidReading, d.Humidity, minHumidity, maxHumidity)
+	case d.Light < minLight || d.Light > maxLight:
+		return fmt.Errorf("%w: light %d outside [%d, %d]", ErrInvalidReading, d.Light, minLight, maxLight)
+	}
+	return nil
 }
 
 type sensorService struct {
@@ -31,6 +73,9 @@ func NewSensorService(repo repository.SensorRepositoryInterface) SensorServiceIn
 	}
 }
 func (s *sensorService) CreateSensorData(db *gorm.DB, dto *dto.CreateSensorDTO, redis *redis.Client) error {
+	if err := ValidateReading(dto); err != nil {
+		return err
+	}
 	sensorData := &model.SensorData{
 		Temperature: dto.Temperature,
 		Humidity:    dto.Humidity,
@@ -39,17 +84,8 @@ func (s *sensorService) CreateSensorData(db *gorm.DB, dto *dto.CreateSensorDTO,
 	return s.repo.CreateSensorData(db, sensorData)
 }
 
-func (s *sensorService) GetAllSensorData(db *gorm.DB, limit, offset int, sort string, order string, startDate string, endDate string, search string) ([]model.SensorData, int, error) {
-	if limit <= 0 || limit > 100 {
-		limit = 10
-	}
-	if offset < 0 {
-		offset = 0
-	}
-	if sort == "" {
-		sort = "created_at desc"
-	}
-	return s.repo.GetAllSensorData(db, limit, offset, sort, order, startDate, endDate, search)
+func (s *sensorService) GetAllSensorData(db *gorm.DB, page *pagination.Request, startDate string, endDate string, search string) ([]model.SensorData, *pagination.Page, error) {
+	return s.repo.GetAllSensorData(db, page, startDate, endDate, search)
 }
 
 func (s *sensorService) DeleteSensorData(db *gorm.DB, id uint) error {
@@ -60,17 +96,52 @@ func (s *sensorService) GetSensorDataByID(db *gorm.DB, id uint) (*model.SensorDa
 	return s.repo.GetSensorDataByID(db, id)
 }
 
+// AggregateSensorData - bucket dạng 5m, 1h, 1d...
+func (s *sensorService) AggregateSensorData(db *gorm.DB, bucket string, startDate string, endDate string, search string) ([]model.SensorBucket, error) {
+	size, err := parseBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.AggregateSensorData(db, size, startDate, endDate, search)
+}
+
+// parseBucket - time.ParseDuration cộng thêm đơn vị ngày "d", làm tròn tới giây
+func parseBucket(raw string) (time.Duration, error) {
+	var size time.Duration
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, ErrInvalidBucket
+		}
+		size = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, ErrInvalidBucket
+		}
+		size = d.Truncate(time.Second)
+	}
+	if size < minBucket || size > maxBucket {
+		return 0, ErrInvalidBucket
+	}
+	return size, nil
+}
+
 func (s *sensorService) GetSensorDataByTime(db *gorm.DB, timestampStr string) (*model.SensorData, error) {
 	return s.repo.GetSensorDataByTime(db, timestampStr)
 }
 
+// GetLastSensorData - cache ngắn theo tenant của db
 func (s *sensorService) GetLastSensorData(db *gorm.DB, redis *redis.Client, ctx context.Context) (*model.SensorData, error) {
-	if data, err := redis.Get(ctx, "last_sensor_data").Result(); err == nil {
+	cacheKey := fmt.Sprintf("last_sensor_data:%d", tenant.ID(db))
+	if data, err := redis.Get(ctx, cacheKey).Result(); err == nil {
 		var sensorData model.SensorData
 		if err := json.Unmarshal([]byte(data), &sensorData); err == nil {
+			metrics.CacheRequests.WithLabelValues("last_sensor_data", "hit").Inc()
 			return &sensorData, nil
 		}
 	}
+	metrics.CacheRequests.WithLabelValues("last_sensor_data", "miss").Inc()
 
 	data, err := s.repo.GetLastSensorData(db)
 	if err != nil {
@@ -78,7 +149,7 @@ func (s *sensorService) GetLastSensorData(db *gorm.DB, redis *redis.Client, ctx
 	}
 
 	if bytes, err := json.Marshal(data); err == nil {
-		redis.Set(ctx, "last_sensor_data", bytes, 2*time.Second)
+		redis.Set(ctx, cacheKey, bytes, 2*time.Second)
 	}
 
 	return data, nil
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iot/internal/dto"
+	"iot/internal/jwt_utils"
+	"iot/internal/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrSessionRevoked     = errors.New("session has been revoked")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected, session revoked")
+)
+
+// Mỗi session là một token family: session:<sid> giữ jti hiện tại của refresh token và tenant
+// của token, sessions:user:<id> là set các sid của user.
+const (
+	sessionKeyPrefix     = "session:"
+	userSessionKeyPrefix = "sessions:user:"
+)
+
+// rotateScript - đổi jti nguyên tử. Trả về 1 nếu rotate thành công,
+// 0 nếu jti không khớp (token cũ bị dùng lại), -1 nếu session không còn.
+var rotateScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'jti')
+if not current then
+	return -1
+end
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'jti', ARGV[2], 'last_used_at', ARGV[3], 'ip', ARGV[4], 'user_agent', ARGV[5], 'tenant_id', ARGV[7])
+redis.call('EXPIRE', KEYS[1], ARGV[6])
+return 1
+`)
+
+type SessionServiceInterface interface {
+	CreateSession(ctx context.Context, user *model.User, selection *dto.TenantSelection, meta *dto.SessionMeta) (*jwt_utils.TokenPair, error)
+	RotateSession(ctx context.Context, user *model.User, selection *dto.TenantSelection, claims *jwt_utils.Claims, meta *dto.SessionMeta) (*jwt_utils.TokenPair, error)
+	ListSessions(ctx context.Context, userID uint, currentID string) ([]dto.SessionDTO, error)
+	RevokeSession(ctx context.Context, userID uint, sessionID string) error
+	RevokeAllSessions(ctx context.Context, userID uint) error
+	RevokeTenantSessions(ctx context.Context, userID, tenantID uint) error
+	IsSessionActive(ctx context.Context, userID uint, sessionID string) (bool, error)
+}
+
+type SessionService struct {
+	redis *redis.Client
+}
+
+func NewSessionService(redis *redis.Client) SessionServiceInterface {
+	return &SessionService{redis: redis}
+}
+
+func sessionKey(sessionID string) string {
+	return sessionKeyPrefix + sessionID
+}
+
+func userSessionsKey(userID uint) string {
+	return userSessionKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+}
+
+// CreateSession - token mang tenant đã chọn và role của user trong tenant đó (xem TenantService.Resolve)
+func (s *SessionService) CreateSession(ctx context.Context, user *model.User, selection *dto.TenantSelection, meta *dto.SessionMeta) (*jwt_utils.TokenPair, error) {
+	if meta == nil {
+		meta = &dto.SessionMeta{}
+	}
+	sessionID := uuid.NewString()
+	jti := uuid.NewString()
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	device := meta.Device
+	if device == "" {
+		device = deviceFromUserAgent(meta.UserAgent)
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, sessionKey(sessionID), map[string]interface{}{
+		"user_id":      user.ID,
+		"tenant_id":    selection.TenantID,
+		"jti":          jti,
+		"device":       device,
+		"user_agent":   meta.UserAgent,
+		"ip":           meta.IP,
+		"created_at":   now,
+		"last_used_at": now,
+	})
+	pipe.Expire(ctx, sessionKey(sessionID), jwt_utils.RefreshTokenTTL)
+	pipe.SAdd(ctx, userSessionsKey(user.ID), sessionID)
+	pipe.Expire(ctx, userSessionsKey(user.ID), jwt_utils.RefreshTokenTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+
+	return jwt_utils.GenerateTokenPair(user.ID, user.Name, user.Email, selection.Role, selection.TenantID, sessionID, jti)
+}
+
+// RotateSession - refresh token chỉ dùng được một lần. Dùng lại token cũ nghĩa là token đã
+// bị lộ, cả family (session) bị thu hồi để kẻ giữ token mới cũng mất quyền.
+func (s *SessionService) RotateSession(ctx context.Context, user *model.User, selection *dto.TenantSelection, claims *jwt_utils.Claims, meta *dto.SessionMeta) (*jwt_utils.TokenPair, error) {
+	if claims.SessionID == "" || claims.ID == "" {
+		return nil, ErrSessionRevoked
+	}
+	if meta == nil {
+		meta = &dto.SessionMeta{}
+	}
+	newJti := uuid.NewString()
+	res, err := rotateScript.Run(ctx, s.redis, []string{sessionKey(claims.SessionID)},
+		claims.ID,
+		newJti,
+		time.Now().Unix(),
+		meta.IP,
+		meta.UserAgent,
+		int64(jwt_utils.RefreshTokenTTL/time.Second),
+		selection.TenantID,
+	).Int()
+	if err != nil {
+		return nil, fmt.Errorf("rotate session: %w", err)
+	}
+
+	switch res {
+	case 1:
+		s.redis.Expire(ctx, userSessionsKey(user.ID), jwt_utils.RefreshTokenTTL)
+		return jwt_utils.GenerateTokenPair(user.ID, user.Name, user.Email, selection.Role, selection.TenantID, claims.SessionID, newJti)
+	case 0:
+		if err := s.RevokeSession(ctx, claims.Id, claims.SessionID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	default:
+		return nil, ErrSessionRevoked
+	}
+}
+
+func (s *SessionService) ListSessions(ctx context.Context, userID uint, currentID string) ([]dto.SessionDTO, error) {
+	ids, err := s.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]dto.SessionDTO, 0, len(ids))
+	var expired []interface{}
+	for _, id := range ids {
+		data, err := s.redis.HGetAll(ctx, sessionKey(id)).Result()
+		if err != nil {
+			return nil, err
+		}
+		// Session hết hạn tự mất khỏi Redis nhưng sid vẫn còn trong set
+		if len(data) == 0 {
+			expired = append(expired, id)
+			continue
+		}
+		sessions = append(sessions, dto.SessionDTO{
+			ID:         id,
+			Device:     data["device"],
+			UserAgent:  data["user_agent"],
+			IP:         data["ip"],
+			CreatedAt:  unixField(data["created_at"]),
+			LastUsedAt: unixField(data["last_used_at"]),
+			Current:    id == currentID,
+		})
+	}
+	if len(expired) > 0 {
+		s.redis.SRem(ctx, userSessionsKey(userID), expired...)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (s *SessionService) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	isMember, err := s.redis.SIsMember(ctx, userSessionsKey(userID), sessionID).Result()
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return ErrSessionNotFound
+	}
+	pipe := s.redis.TxPipeline()
+	pipe.Del(ctx, sessionKey(sessionID))
+	pipe.SRem(ctx, userSessionsKey(userID), sessionID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID uint) error {
+	ids, err := s.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, sessionKey(id))
+	}
+	keys = append(keys, userSessionsKey(userID))
+	return s.redis.Del(ctx, keys...).Err()
+}
+
+// RevokeTenantSessions - chỉ thu hồi session đang mang tenantID, session ở tenant khác giữ nguyên.
+// Session tạo trước khi lưu tenant_id không biết tenant nên cũng bị thu hồi.
+func (s *SessionService) RevokeTenantSessions(ctx context.Context, userID, tenantID uint) error {
+	ids, err := s.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	target := strconv.FormatUint(uint64(tenantID), 10)
+	var keys []string
+	var members []interface{}
+	for _, id := range ids {
+		value, err := s.redis.HGet(ctx, sessionKey(id), "tenant_id").Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if err == nil && value != target {
+			continue
+		}
+		keys = append(keys, sessionKey(id))
+		members = append(members, id)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := s.redis.TxPipeline()
+	pipe.Del(ctx, keys...)
+	pipe.SRem(ctx, userSessionsKey(userID), members...)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// IsSessionActive - access token hợp lệ chỉ khi session của nó chưa bị logout/revoke
+func (s *SessionService) IsSessionActive(ctx context.Context, userID uint, sessionID string) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+	owner, err := s.redis.HGet(ctx, sessionKey(sessionID), "user_id").Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return owner == strconv.FormatUint(uint64(userID), 10), nil
+}
+
+func unixField(v string) time.Time {
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// deviceFromUserAgent - tên thiết bị gần đúng khi client không gửi X-Device-Name
+func deviceFromUserAgent(ua string) string {
+	lower := strings.ToLower(ua)
+	switch {
+	case lower == "":
+		return "Unknown"
+	case strings.Contains(lower, "esp32"), strings.Contains(lower, "esp8266"):
+		return "ESP device"
+	case strings.Contains(lower, "android"):
+		return "Android"
+	case strings.Contains(lower, "iphone"), strings.Contains(lower, "ipad"):
+		return "iOS"
+	case strings.Contains(lower, "windows"):
+		return "Windows"
+	case strings.Contains(lower, "mac os"):
+		return "macOS"
+	case strings.Contains(lower, "linux"):
+		return "Linux"
+	case strings.Contains(lower, "curl"), strings.Contains(lower, "postman"):
+		return "API client"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/jwt_utils"
+	"iot/internal/model"
+	"iot/pkg/config"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+func newTestSessionService(t *testing.T) (*SessionService, *miniredis.Miniredis) {
+	t.Helper()
+	jwt_utils.Configure(&config.JWTConfig{AccessSecret: "access-secret", RefreshSecret: "refresh-secret", AccessTTL: time.Hour, RefreshTTL: 24 * time.Hour})
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &SessionService{redis: client}, mr
+}
+
+func refreshClaims(t *testing.T, pair *jwt_utils.TokenPair) *jwt_utils.Claims {
+	t.Helper()
+	claims, err := jwt_utils.VerifyToken(pair.RefreshToken, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestRotateSession(t *testing.T) {
+	ctx := context.Background()
+	user := &model.User{Model: gorm.Model{ID: 1}, Name: "alice", Email: "alice@example.com"}
+	home := &dto.TenantSelection{TenantID: 1, Role: model.RoleOperator}
+
+	t.Run("refresh token works once", func(t *testing.T) {
+		s, mr := newTestSessionService(t)
+		pair, err := s.CreateSession(ctx, user, home, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := refreshClaims(t, pair)
+
+		rotated, err := s.RotateSession(ctx, user, &dto.TenantSelection{TenantID: 2, Role: model.RoleViewer}, first, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second := refreshClaims(t, rotated)
+		if second.SessionID != first.SessionID || second.ID == first.ID {
+			t.Errorf("rotated sid %s jti %s, want same session with a new jti", second.SessionID, second.ID)
+		}
+		if got := mr.HGet(sessionKey(first.SessionID), "tenant_id"); got != "2" {
+			t.Errorf("session tenant_id = %q, want the newly selected tenant", got)
+		}
+		if _, err := s.RotateSession(ctx, user, home, second, nil); err != nil {
+			t.Errorf("rotating the new token = %v", err)
+		}
+	})
+
+	t.Run("reuse revokes the whole session", func(t *testing.T) {
+		s, _ := newTestSessionService(t)
+		pair, err := s.CreateSession(ctx, user, home, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stolen := refreshClaims(t, pair)
+		rotated, err := s.RotateSession(ctx, user, home, stolen, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.RotateSession(ctx, user, home, stolen, nil); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Fatalf("reusing the old token = %v, want ErrRefreshTokenReused", err)
+		}
+		// kẻ giữ token mới cũng mất quyền
+		if _, err := s.RotateSession(ctx, user, home, refreshClaims(t, rotated), nil); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("rotating after reuse = %v, want ErrSessionRevoked", err)
+		}
+		if active, _ := s.IsSessionActive(ctx, user.ID, stolen.SessionID); active {
+			t.Error("access tokens of the session still active")
+		}
+	})
+
+	t.Run("expired or logged out session", func(t *testing.T) {
+		s, mr := newTestSessionService(t)
+		pair, err := s.CreateSession(ctx, user, home, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := refreshClaims(t, pair)
+		mr.FastForward(jwt_utils.RefreshTokenTTL + time.Second)
+		if _, err := s.RotateSession(ctx, user, home, claims, nil); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("rotating an expired session = %v, want ErrSessionRevoked", err)
+		}
+		if _, err := s.RotateSession(ctx, user, home, &jwt_utils.Claims{}, nil); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("token without sid/jti = %v, want ErrSessionRevoked", err)
+		}
+	})
+}
+
+func TestRevokeTenantSessions(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestSessionService(t)
+	user := &model.User{Model: gorm.Model{ID: 1}, Name: "alice", Email: "alice@example.com"}
+
+	sessions := map[uint]string{}
+	for _, tenantID := range []uint{1, 2} {
+		pair, err := s.CreateSession(ctx, user, &dto.TenantSelection{TenantID: tenantID, Role: model.RoleViewer}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions[tenantID] = refreshClaims(t, pair).SessionID
+	}
+
+	if err := s.RevokeTenantSessions(ctx, user.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if active, _ := s.IsSessionActive(ctx, user.ID, sessions[1]); active {
+		t.Error("session in the removed tenant still active")
+	}
+	if active, _ := s.IsSessionActive(ctx, user.ID, sessions[2]); !active {
+		t.Error("session in another tenant revoked")
+	}
+	if active, _ := s.IsSessionActive(ctx, 2, sessions[2]); active {
+		t.Error("session accepted for another user")
+	}
+}
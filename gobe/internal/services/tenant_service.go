@@ -0,0 +1,376 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/jwt_utils"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/internal/tenant"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotTenantMember    = errors.New("user is not a member of this tenant")
+	ErrTenantMemberExists = errors.New("user is already a member of this tenant")
+	ErrLastTenantAdmin    = errors.New("tenant must keep at least one admin")
+	ErrInvitationExists   = errors.New("user already has a pending invitation to this tenant")
+	ErrInvitationNotFound = errors.New("invitation not found or expired")
+)
+
+// tenantInvitationTTL - lời mời chưa được chấp nhận sau thời gian này thì admin phải mời lại
+const tenantInvitationTTL = 7 * 24 * time.Hour
+
+type TenantServiceInterface interface {
+	Resolve(db *gorm.DB, user *model.User, preferredTenantID uint) (*dto.TenantSelection, error)
+	Select(db *gorm.DB, user *model.User, tenantID uint) (*dto.TenantSelection, error)
+	Switch(ctx context.Context, db *gorm.DB, claims *jwt_utils.Claims, tenantID uint, meta *dto.SessionMeta) (*jwt_utils.TokenPair, error)
+	Create(db *gorm.DB, userID uint, req *dto.CreateTenantRequest) (*dto.TenantDTO, error)
+	ListMine(db *gorm.DB, userID, currentTenantID uint) ([]dto.TenantDTO, error)
+	ListMembers(db *gorm.DB, tenantID uint) ([]dto.TenantMemberDTO, error)
+	InviteMember(db *gorm.DB, tenantID, actorID uint, req *dto.InviteTenantMemberRequest) (*dto.TenantInvitationDTO, error)
+	ListInvitations(db *gorm.DB, userID uint) ([]dto.TenantInvitationDTO, error)
+	AcceptInvitation(db *gorm.DB, userID, tenantID uint) (*dto.TenantDTO, error)
+	DeclineInvitation(db *gorm.DB, userID, tenantID uint) error
+	UpdateMemberRole(db *gorm.DB, tenantID, actorID, userID uint, role string) error
+	RemoveMember(ctx context.Context, db *gorm.DB, tenantID, userID uint) error
+	IsSystemAdmin(db *gorm.DB, userID uint) (bool, error)
+}
+
+type TenantService struct {
+	repo     repository.TenantRepositoryInterface
+	userRepo repository.UserRepositoryInterface
+	sessions SessionServiceInterface
+}
+
+func NewTenantService(repo repository.TenantRepositoryInterface, userRepo repository.UserRepositoryInterface, sessions SessionServiceInterface) TenantServiceInterface {
+	return &TenantService{
+		repo:     repo,
+		userRepo: userRepo,
+		sessions: sessions,
+	}
+}
+
+// Resolve - tenant cho session mới: tenant client đang dùng nếu còn quyền, không thì tenant tham gia sớm nhất.
+// User chưa thuộc tenant nào (vừa đăng ký) được tạo một nhà riêng với role admin.
+func (s *TenantService) Resolve(db *gorm.DB, user *model.User, preferredTenantID uint) (*dto.TenantSelection, error) {
+	if preferredTenantID != 0 {
+		selection, err := s.Select(db, user, preferredTenantID)
+		if !errors.Is(err, ErrNotTenantMember) {
+			return selection, err
+		}
+	}
+	membership, err := s.repo.FirstMembership(db, user.ID)
+	if err == nil {
+		return &dto.TenantSelection{TenantID: membership.TenantID, Role: membership.Role}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	created, err := s.createTenant(db, user.ID, user.Name+"'s home")
+	if err != nil {
+		return nil, err
+	}
+	return &dto.TenantSelection{TenantID: created.ID, Role: model.RoleAdmin}, nil
+}
+
+// Select - role của user trong tenant; admin hệ thống vào được mọi tenant với quyền admin
+func (s *TenantService) Select(db *gorm.DB, user *model.User, tenantID uint) (*dto.TenantSelection, error) {
+	membership, err := s.repo.GetMembership(db, tenantID, user.ID)
+	if err == nil {
+		return &dto.TenantSelection{TenantID: tenantID, Role: membership.Role}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if user.Role != model.RoleAdmin {
+		return nil, ErrNotTenantMember
+	}
+	if _, err := s.repo.GetByID(db, tenantID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotTenantMember
+		}
+		return nil, err
+	}
+	return &dto.TenantSelection{TenantID: tenantID, Role: model.RoleAdmin}, nil
+}
+
+// Switch - đổi tenant bằng cách cấp session mới rồi thu hồi session cũ
+func (s *TenantService) Switch(ctx context.Context, db *gorm.DB, claims *jwt_utils.Claims, tenantID uint, meta *dto.SessionMeta) (*jwt_utils.TokenPair, error) {
+	user, err := s.userRepo.GetByID(db, claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	selection, err := s.Select(db, user, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	pair, err := s.sessions.CreateSession(ctx, user, selection, meta)
+	if err != nil {
+		return nil, err
+	}
+	if claims.SessionID != "" {
+		if err := s.sessions.RevokeSession(ctx, claims.Id, claims.SessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return nil, err
+		}
+	}
+	return pair, nil
+}
+
+// Create - người tạo là admin của tenant mới
+func (s *TenantService) Create(db *gorm.DB, userID uint, req *dto.CreateTenantRequest) (*dto.TenantDTO, error) {
+	created, err := s.createTenant(db, userID, strings.TrimSpace(req.Name))
+	if err != nil {
+		return nil, err
+	}
+	return &dto.TenantDTO{ID: created.ID, Name: created.Name, Slug: created.Slug, Role: model.RoleAdmin}, nil
+}
+
+func (s *TenantService) ListMine(db *gorm.DB, userID, currentTenantID uint) ([]dto.TenantDTO, error) {
+	tenants, err := s.repo.ListByUser(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]dto.TenantDTO, 0, len(tenants))
+	for _, t := range tenants {
+		result = append(result, dto.TenantDTO{
+			ID:      t.ID,
+			Name:    t.Name,
+			Slug:    t.Slug,
+			Role:    t.Role,
+			Current: t.ID == currentTenantID,
+		})
+	}
+	return result, nil
+}
+
+func (s *TenantService) ListMembers(db *gorm.DB, tenantID uint) ([]dto.TenantMemberDTO, error) {
+	members, err := s.repo.ListMembers(db, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]dto.TenantMemberDTO, 0, len(members))
+	for _, m := range members {
+		result = append(result, dto.TenantMemberDTO{UserID: m.UserID, Name: m.Name, Email: m.Email, Role: m.Role})
+	}
+	return result, nil
+}
+
+// InviteMember - mời user đã có tài khoản vào tenant theo email. User chỉ thành thành viên khi
+// tự chấp nhận (AcceptInvitation), admin tenant không kéo được tài khoản của người khác vào tenant mình.
+func (s *TenantService) InviteMember(db *gorm.DB, tenantID, actorID uint, req *dto.InviteTenantMemberRequest) (*dto.TenantInvitationDTO, error) {
+	user, err := s.userRepo.GetByEmail(db, strings.TrimSpace(req.Email))
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.repo.GetMembership(db, tenantID, user.ID)
+	if err == nil {
+		return nil, ErrTenantMemberExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	existing, err := s.repo.GetInvitation(db, tenantID, user.ID)
+	switch {
+	case err == nil && existing.ExpiresAt.After(now):
+		return nil, ErrInvitationExists
+	case err == nil:
+		if err := s.repo.DeleteInvitation(db, tenantID, user.ID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	invitation := &model.TenantInvitation{
+		TenantID:  tenantID,
+		UserID:    user.ID,
+		Role:      req.Role,
+		InvitedBy: actorID,
+		ExpiresAt: now.Add(tenantInvitationTTL),
+	}
+	if err := s.repo.CreateInvitation(db, invitation); err != nil {
+		return nil, err
+	}
+	return &dto.TenantInvitationDTO{TenantID: tenantID, UserID: user.ID, Email: user.Email, Role: invitation.Role, ExpiresAt: invitation.ExpiresAt}, nil
+}
+
+// ListInvitations - lời mời còn hạn gửi tới user
+func (s *TenantService) ListInvitations(db *gorm.DB, userID uint) ([]dto.TenantInvitationDTO, error) {
+	invitations, err := s.repo.ListInvitationsByUser(db, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	result := make([]dto.TenantInvitationDTO, 0, len(invitations))
+	for _, inv := range invitations {
+		result = append(result, dto.TenantInvitationDTO{
+			TenantID:   inv.TenantID,
+			TenantName: inv.TenantName,
+			UserID:     inv.UserID,
+			Role:       inv.Role,
+			ExpiresAt:  inv.ExpiresAt,
+		})
+	}
+	return result, nil
+}
+
+// AcceptInvitation - user được mời tự tạo membership với role trong lời mời
+func (s *TenantService) AcceptInvitation(db *gorm.DB, userID, tenantID uint) (*dto.TenantDTO, error) {
+	invitation, err := s.repo.GetInvitation(db, tenantID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !invitation.ExpiresAt.After(time.Now()) {
+		_ = s.repo.DeleteInvitation(db, tenantID, userID)
+		return nil, ErrInvitationNotFound
+	}
+	joined, err := s.repo.GetByID(db, tenantID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Xóa trước để hai request chấp nhận cùng lúc chỉ một bên tạo được membership
+		if err := s.repo.DeleteInvitation(tx, tenantID, userID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationNotFound
+			}
+			return err
+		}
+		if _, err := s.repo.GetMembership(tx, tenantID, userID); err == nil {
+			return ErrTenantMemberExists
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return s.repo.CreateMembership(tx, &model.TenantMembership{TenantID: tenantID, UserID: userID, Role: invitation.Role})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dto.TenantDTO{ID: joined.ID, Name: joined.Name, Slug: joined.Slug, Role: invitation.Role}, nil
+}
+
+// DeclineInvitation - từ chối lời mời, admin tenant có thể mời lại
+func (s *TenantService) DeclineInvitation(db *gorm.DB, userID, tenantID uint) error {
+	err := s.repo.DeleteInvitation(db, tenantID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvitationNotFound
+	}
+	return err
+}
+
+// UpdateMemberRole - không tự hạ quyền mình và không hạ admin cuối cùng của tenant
+func (s *TenantService) UpdateMemberRole(db *gorm.DB, tenantID, actorID, userID uint, role string) error {
+	if !model.IsValidRole(role) {
+		return gorm.ErrInvalidData
+	}
+	if actorID == userID && role != model.RoleAdmin {
+		return ErrSelfDemotion
+	}
+	if role != model.RoleAdmin {
+		if err := s.guardLastAdmin(db, tenantID, userID); err != nil {
+			return err
+		}
+	}
+	return s.repo.UpdateMembershipRole(db, tenantID, userID, role)
+}
+
+// RemoveMember - xóa user khỏi tenant và thu hồi session đang mang tenant này, session ở tenant khác vẫn dùng được
+func (s *TenantService) RemoveMember(ctx context.Context, db *gorm.DB, tenantID, userID uint) error {
+	if err := s.guardLastAdmin(db, tenantID, userID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteMembership(db, tenantID, userID); err != nil {
+		return err
+	}
+	return s.sessions.RevokeTenantSessions(ctx, userID, tenantID)
+}
+
+// IsSystemAdmin - admin hệ thống (users.role), khác admin trong từng tenant mà user nào cũng có thể là
+// (tự tạo tenant). Chỉ admin hệ thống được làm việc trên dữ liệu chung của mọi tenant.
+func (s *TenantService) IsSystemAdmin(db *gorm.DB, userID uint) (bool, error) {
+	user, err := s.userRepo.GetByID(tenant.Shared(db), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.Role == model.RoleAdmin, nil
+}
+
+// guardLastAdmin - ErrLastTenantAdmin nếu userID là admin duy nhất còn lại của tenant
+func (s *TenantService) guardLastAdmin(db *gorm.DB, tenantID, userID uint) error {
+	membership, err := s.repo.GetMembership(db, tenantID, userID)
+	if err != nil {
+		return err
+	}
+	if membership.Role != model.RoleAdmin {
+		return nil
+	}
+	admins, err := s.repo.CountMembersWithRole(db, tenantID, model.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastTenantAdmin
+	}
+	return nil
+}
+
+func (s *TenantService) createTenant(db *gorm.DB, userID uint, name string) (*model.Tenant, error) {
+	slug, err := newTenantSlug(name)
+	if err != nil {
+		return nil, err
+	}
+	created := &model.Tenant{Name: name, Slug: slug}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.CreateTenant(tx, created); err != nil {
+			return err
+		}
+		return s.repo.CreateMembership(tx, &model.TenantMembership{TenantID: created.ID, UserID: userID, Role: model.RoleAdmin})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// newTenantSlug - tên viết thường chỉ giữ chữ/số ASCII, thêm hậu tố ngẫu nhiên để không trùng
+func newTenantSlug(name string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+		if b.Len() >= 40 {
+			break
+		}
+	}
+	base := strings.Trim(b.String(), "-")
+	if base == "" {
+		base = "home"
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return base + "-" + hex.EncodeToString(suffix), nil
+}
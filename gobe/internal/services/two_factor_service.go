@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"iot/internal/dto"
+	"iot/internal/helper/mailer"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/pkg/totp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidChallenge        = errors.New("invalid or expired two-factor challenge")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled, disable it first")
+	ErrTOTPSetupExpired        = errors.New("totp setup expired, start again")
+	ErrInvalidPassword         = errors.New("invalid password")
+)
+
+const (
+	challengeKeyPrefix   = "2fa:challenge:"
+	totpPendingKeyPrefix = "2fa:totp:pending:"
+	totpUsedKeyPrefix    = "2fa:totp:used:"
+
+	challengeTTL         = 5 * time.Minute
+	totpSetupTTL         = 10 * time.Minute
+	challengeMaxAttempts = 5
+	recoveryCodeCount    = 10
+	totpIssuer           = "IOT"
+)
+
+type TwoFactorServiceInterface interface {
+	StartChallenge(ctx context.Context, user *model.User, mailer_service *mailer.MailService) (*dto.TwoFactorChallenge, error)
+	VerifyChallenge(ctx context.Context, db *gorm.DB, req *dto.TwoFactorVerifyRequest, meta *dto.SessionMeta) (*dto.LoginResponse, error)
+	SetupTOTP(ctx context.Context, db *gorm.DB, userID uint, password string) (*dto.TOTPSetupResponse, error)
+	EnableTOTP(ctx context.Context, db *gorm.DB, userID uint, password, code string) ([]string, error)
+	EnableEmail(ctx context.Context, db *gorm.DB, userID uint, password string) ([]string, error)
+	Disable(ctx context.Context, db *gorm.DB, userID uint, password string) error
+	RegenerateRecoveryCodes(ctx context.Context, db *gorm.DB, userID uint, password string) ([]string, error)
+}
+
+type TwoFactorService struct {
+	redis        *redis.Client
+	userRepo     repository.UserRepositoryInterface
+	recoveryRepo repository.RecoveryCodeRepositoryInterface
+	sessions     SessionServiceInterface
+	tenants      TenantServiceInterface
+}
+
+func NewTwoFactorService(redis *redis.Client, userRepo repository.UserRepositoryInterface, recoveryRepo repository.RecoveryCodeRepositoryInterface, sessions SessionServiceInterface, tenants TenantServiceInterface) TwoFactorServiceInterface {
+	return &TwoFactorService{
+		redis:        redis,
+		userRepo:     userRepo,
+		recoveryRepo: recoveryRepo,
+		sessions:     sessions,
+		tenants:      tenants,
+	}
+}
+
+// StartChallenge - gọi sau khi mật khẩu đúng, với email OTP thì gửi mã ngay
+func (s *TwoFactorService) StartChallenge(ctx context.Context, user *model.User, mailer_service *mailer.MailService) (*dto.TwoFactorChallenge, error) {
+	token := uuid.NewString()
+	key := challengeKeyPrefix + token
+	fields := map[string]interface{}{
+		"user_id":  user.ID,
+		"method":   user.TwoFactorMethod,
+		"attempts": 0,
+	}
+
+	var code string
+	if user.TwoFactorMethod == model.TwoFactorEmail {
+		code = mailer_service.GenerateOTP()
+		fields["code"] = hashSecret(code)
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, key, fields)
+	pipe.Expire(ctx, key, challengeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	if code != "" {
+		go func() {
+			mailer_service.Send(
+				context.Background(),
+				&mailer.LoginOTPEmail{From: "no-reply@myapp.com"},
+				user.Email,
+				map[string]string{"otp": code},
+			)
+		}()
+	}
+
+	return &dto.TwoFactorChallenge{
+		Token:     token,
+		Method:    user.TwoFactorMethod,
+		ExpiresAt: time.Now().Add(challengeTTL).Unix(),
+	}, nil
+}
+
+// VerifyChallenge - đổi challenge token + mã lấy TokenPair. Challenge dùng một lần và bị hủy sau 5 lần sai.
+func (s *TwoFactorService) VerifyChallenge(ctx context.Context, db *gorm.DB, req *dto.TwoFactorVerifyRequest, meta *dto.SessionMeta) (*dto.LoginResponse, error) {
+	key := challengeKeyPrefix + req.ChallengeToken
+	data, err := s.redis.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrInvalidChallenge
+	}
+	userID, err := strconv.ParseUint(data["user_id"], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	user, err := s.userRepo.GetByID(db, uint(userID))
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	ok, err := s.verifyCode(ctx, db, user, data["method"], data["code"], req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := countFailedAttempt(ctx, s.redis, key, challengeMaxAttempts); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	deleted, err := s.redis.Del(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if deleted == 0 {
+		return nil, ErrInvalidChallenge
+	}
+
+	selection, err := s.tenants.Resolve(db, user, 0)
+	if err != nil {
+		return nil, err
+	}
+	tokenPair, err := s.sessions.CreateSession(ctx, user, selection, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.LoginResponse{
+		User: &dto.UserDTO{
+			ID:              user.ID,
+			Name:            user.Name,
+			Email:           user.Email,
+			Role:            selection.Role,
+			TwoFactorMethod: user.TwoFactorMethod,
+			TenantID:        selection.TenantID,
+		},
+		TokenPair: tokenPair,
+	}, nil
+}
+
+// verifyCode - mã 6 số theo method của challenge, hoặc recovery code dạng xxxxx-xxxxx
+func (s *TwoFactorService) verifyCode(ctx context.Context, db *gorm.DB, user *model.User, method, emailHash, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != totp.Digits {
+		return s.recoveryRepo.UseCode(db, user.ID, hashSecret(normalizeRecoveryCode(code)))
+	}
+
+	switch method {
+	case model.TwoFactorEmail:
+		return subtle.ConstantTimeCompare([]byte(emailHash), []byte(hashSecret(code))) == 1, nil
+	case model.TwoFactorTOTP:
+		return s.checkTOTP(ctx, user.ID, user.TOTPSecret, code)
+	default:
+		return false, nil
+	}
+}
+
+// checkTOTP - mỗi mã TOTP chỉ được chấp nhận một lần trong khung thời gian hiệu lực
+func (s *TwoFactorService) checkTOTP(ctx context.Context, userID uint, secret, code string) (bool, error) {
+	step, ok := totp.Validate(secret, code, time.Now(), 1)
+	if !ok {
+		return false, nil
+	}
+	usedKey := fmt.Sprintf("%s%d:%d", totpUsedKeyPrefix, userID, step)
+	fresh, err := s.redis.SetNX(ctx, usedKey, 1, 3*totp.Period*time.Second).Result()
+	if err != nil {
+		return false, err
+	}
+	return fresh, nil
+}
+
+// SetupTOTP - tạo secret chờ xác nhận, chỉ lưu vào user khi EnableTOTP nhận được mã đúng
+func (s *TwoFactorService) SetupTOTP(ctx context.Context, db *gorm.DB, userID uint, password string) (*dto.TOTPSetupResponse, error) {
+	user, err := s.checkPassword(db, userID, password)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorMethod != model.TwoFactorNone {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redis.Set(ctx, totpPendingKey(userID), secret, totpSetupTTL).Err(); err != nil {
+		return nil, err
+	}
+	return &dto.TOTPSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// EnableTOTP - hỏi lại mật khẩu để token bị lộ không tự gắn được TOTP của kẻ khác vào tài khoản
+func (s *TwoFactorService) EnableTOTP(ctx context.Context, db *gorm.DB, userID uint, password, code string) ([]string, error) {
+	user, err := s.checkPassword(db, userID, password)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorMethod != model.TwoFactorNone {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	secret, err := s.redis.Get(ctx, totpPendingKey(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrTOTPSetupExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.checkTOTP(ctx, userID, secret, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	if err := s.userRepo.UpdateTwoFactor(db, userID, model.TwoFactorTOTP, secret); err != nil {
+		return nil, err
+	}
+	s.redis.Del(ctx, totpPendingKey(userID))
+	return s.issueRecoveryCodes(db, userID)
+}
+
+func (s *TwoFactorService) EnableEmail(ctx context.Context, db *gorm.DB, userID uint, password string) ([]string, error) {
+	user, err := s.checkPassword(db, userID, password)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorMethod != model.TwoFactorNone {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if err := s.userRepo.UpdateTwoFactor(db, userID, model.TwoFactorEmail, ""); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(db, userID)
+}
+
+func (s *TwoFactorService) Disable(ctx context.Context, db *gorm.DB, userID uint, password string) error {
+	user, err := s.checkPassword(db, userID, password)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactorMethod == model.TwoFactorNone {
+		return ErrTwoFactorNotEnabled
+	}
+	if err := s.userRepo.UpdateTwoFactor(db, userID, model.TwoFactorNone, ""); err != nil {
+		return err
+	}
+	return s.recoveryRepo.DeleteCodes(db, userID)
+}
+
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, db *gorm.DB, userID uint, password string) ([]string, error) {
+	user, err := s.checkPassword(db, userID, password)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorMethod == model.TwoFactorNone {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	return s.issueRecoveryCodes(db, userID)
+}
+
+func (s *TwoFactorService) checkPassword(db *gorm.DB, userID uint, password string) (*model.User, error) {
+	user, err := s.userRepo.GetByID(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return nil, ErrInvalidPassword
+	}
+	return user, nil
+}
+
+// issueRecoveryCodes - thay toàn bộ recovery code cũ, bản rõ chỉ trả về cho user một lần
+func (s *TwoFactorService) issueRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashSecret(normalizeRecoveryCode(code)))
+	}
+	if err := s.recoveryRepo.ReplaceCodes(db, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func totpPendingKey(userID uint) string {
+	return totpPendingKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+}
+
+// generateRecoveryCode - 10 ký tự base32 chữ thường, dạng xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/jwt_utils"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/pkg/config"
+	"iot/pkg/totp"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const testPassword = "correct horse"
+
+// newTestTwoFactorService - user id 1 có mật khẩu testPassword, Redis là miniredis
+func newTestTwoFactorService(t *testing.T) (*TwoFactorService, *gorm.DB, *miniredis.Miniredis) {
+	t.Helper()
+	db := openTestDB(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("INSERT INTO users (id, name, email, password, role) VALUES (1, 'alice', 'alice@example.com', ?, 'viewer')", string(hash))
+	db.Exec("INSERT INTO tenant_memberships (tenant_id, user_id, role) VALUES (?, 1, 'operator')", testTenantID)
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	userRepo := repository.NewUserRepository()
+	sessions := NewSessionService(client)
+	s := &TwoFactorService{
+		redis:        client,
+		userRepo:     userRepo,
+		recoveryRepo: repository.NewRecoveryCodeRepository(),
+		sessions:     sessions,
+		tenants:      NewTenantService(repository.NewTenantRepository(), userRepo, sessions),
+	}
+	return s, db, mr
+}
+
+func currentCode(t *testing.T, secret string) string {
+	t.Helper()
+	code, err := totp.CodeAt(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func twoFactorOf(t *testing.T, db *gorm.DB) (method, secret string) {
+	t.Helper()
+	var user model.User
+	if err := db.First(&user, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user.TwoFactorMethod, user.TOTPSecret
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("setup needs the password", func(t *testing.T) {
+		s, db, mr := newTestTwoFactorService(t)
+		if _, err := s.SetupTOTP(ctx, db, 1, "guess"); !errors.Is(err, ErrInvalidPassword) {
+			t.Fatalf("SetupTOTP = %v, want ErrInvalidPassword", err)
+		}
+		if mr.Exists(totpPendingKey(1)) {
+			t.Error("pending secret stored without the password")
+		}
+	})
+
+	t.Run("enable needs the password", func(t *testing.T) {
+		s, db, _ := newTestTwoFactorService(t)
+		setup, err := s.SetupTOTP(ctx, db, 1, testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.EnableTOTP(ctx, db, 1, "guess", currentCode(t, setup.Secret)); !errors.Is(err, ErrInvalidPassword) {
+			t.Fatalf("EnableTOTP = %v, want ErrInvalidPassword", err)
+		}
+		if method, _ := twoFactorOf(t, db); method != model.TwoFactorNone {
+			t.Errorf("two_factor_method = %q after a wrong password", method)
+		}
+	})
+
+	t.Run("enable without setup", func(t *testing.T) {
+		s, db, _ := newTestTwoFactorService(t)
+		if _, err := s.EnableTOTP(ctx, db, 1, testPassword, "123456"); !errors.Is(err, ErrTOTPSetupExpired) {
+			t.Fatalf("EnableTOTP = %v, want ErrTOTPSetupExpired", err)
+		}
+	})
+
+	t.Run("setup expires", func(t *testing.T) {
+		s, db, mr := newTestTwoFactorService(t)
+		setup, err := s.SetupTOTP(ctx, db, 1, testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mr.FastForward(totpSetupTTL + time.Second)
+		if _, err := s.EnableTOTP(ctx, db, 1, testPassword, currentCode(t, setup.Secret)); !errors.Is(err, ErrTOTPSetupExpired) {
+			t.Fatalf("EnableTOTP = %v, want ErrTOTPSetupExpired", err)
+		}
+	})
+
+	t.Run("wrong code keeps 2fa off", func(t *testing.T) {
+		s, db, _ := newTestTwoFactorService(t)
+		setup, err := s.SetupTOTP(ctx, db, 1, testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrong := "000000"
+		if currentCode(t, setup.Secret) == wrong {
+			wrong = "000001"
+		}
+		if _, err := s.EnableTOTP(ctx, db, 1, testPassword, wrong); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Fatalf("EnableTOTP = %v, want ErrInvalidTwoFactorCode", err)
+		}
+		if method, _ := twoFactorOf(t, db); method != model.TwoFactorNone {
+			t.Errorf("two_factor_method = %q after a wrong code", method)
+		}
+	})
+
+	t.Run("enabled with password and code", func(t *testing.T) {
+		s, db, mr := newTestTwoFactorService(t)
+		setup, err := s.SetupTOTP(ctx, db, 1, testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes, err := s.EnableTOTP(ctx, db, 1, testPassword, currentCode(t, setup.Secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(codes) != recoveryCodeCount {
+			t.Errorf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+		}
+		if method, secret := twoFactorOf(t, db); method != model.TwoFactorTOTP || secret != setup.Secret {
+			t.Errorf("two_factor_method = %q, secret stored = %v", method, secret == setup.Secret)
+		}
+		if mr.Exists(totpPendingKey(1)) {
+			t.Error("pending secret not removed")
+		}
+		if _, err := s.SetupTOTP(ctx, db, 1, testPassword); !errors.Is(err, ErrTwoFactorAlreadyEnabled) {
+			t.Errorf("second SetupTOTP = %v, want ErrTwoFactorAlreadyEnabled", err)
+		}
+	})
+}
+
+func TestVerifyChallengeAttempts(t *testing.T) {
+	ctx := context.Background()
+
+	// start - bật TOTP cho user 1 rồi mở challenge như sau bước nhập mật khẩu
+	start := func(t *testing.T) (*TwoFactorService, *gorm.DB, *miniredis.Miniredis, string, string) {
+		t.Helper()
+		s, db, mr := newTestTwoFactorService(t)
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Exec("UPDATE users SET two_factor_method = ?, totp_secret = ? WHERE id = 1", model.TwoFactorTOTP, secret)
+		var user model.User
+		db.First(&user, 1)
+		challenge, err := s.StartChallenge(ctx, &user, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrong := "000000"
+		if currentCode(t, secret) == wrong {
+			wrong = "000001"
+		}
+		return s, db, mr, challenge.Token, wrong
+	}
+	verify := func(s *TwoFactorService, db *gorm.DB, token, code string) error {
+		_, err := s.VerifyChallenge(ctx, db, &dto.TwoFactorVerifyRequest{ChallengeToken: token, Code: code}, &dto.SessionMeta{})
+		return err
+	}
+
+	t.Run("wrong code is counted and keeps the ttl", func(t *testing.T) {
+		s, db, mr, token, wrong := start(t)
+		if err := verify(s, db, token, wrong); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Fatalf("VerifyChallenge = %v, want ErrInvalidTwoFactorCode", err)
+		}
+		key := challengeKeyPrefix + token
+		if got := mr.HGet(key, "attempts"); got != "1" {
+			t.Errorf("attempts = %q, want 1", got)
+		}
+		if ttl := mr.TTL(key); ttl <= 0 || ttl > challengeTTL {
+			t.Errorf("ttl = %v, want the original expiry", ttl)
+		}
+	})
+
+	t.Run("expired challenge is not recreated", func(t *testing.T) {
+		s, db, mr, token, wrong := start(t)
+		mr.FastForward(challengeTTL + time.Second)
+		if err := verify(s, db, token, wrong); !errors.Is(err, ErrInvalidChallenge) {
+			t.Fatalf("VerifyChallenge = %v, want ErrInvalidChallenge", err)
+		}
+		if mr.Exists(challengeKeyPrefix + token) {
+			t.Error("expired challenge recreated without a ttl")
+		}
+	})
+
+	t.Run("challenge is dropped after the attempt limit", func(t *testing.T) {
+		s, db, mr, token, wrong := start(t)
+		for i := 0; i < challengeMaxAttempts; i++ {
+			if err := verify(s, db, token, wrong); !errors.Is(err, ErrInvalidTwoFactorCode) {
+				t.Fatalf("attempt %d: VerifyChallenge = %v, want ErrInvalidTwoFactorCode", i+1, err)
+			}
+		}
+		if mr.Exists(challengeKeyPrefix + token) {
+			t.Fatal("challenge kept after the attempt limit")
+		}
+		_, secret := twoFactorOf(t, db)
+		if err := verify(s, db, token, currentCode(t, secret)); !errors.Is(err, ErrInvalidChallenge) {
+			t.Errorf("correct code after the limit = %v, want ErrInvalidChallenge", err)
+		}
+	})
+}
+
+func TestVerifyChallenge(t *testing.T) {
+	ctx := context.Background()
+	jwt_utils.Configure(&config.JWTConfig{AccessSecret: "access-secret", RefreshSecret: "refresh-secret", AccessTTL: time.Hour, RefreshTTL: 24 * time.Hour})
+
+	// enroll - bật TOTP qua luồng thật, trả về mã đã dùng để bật và recovery code
+	enroll := func(t *testing.T) (*TwoFactorService, *gorm.DB, string, []string) {
+		t.Helper()
+		s, db, _ := newTestTwoFactorService(t)
+		setup, err := s.SetupTOTP(ctx, db, 1, testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		used := currentCode(t, setup.Secret)
+		codes, err := s.EnableTOTP(ctx, db, 1, testPassword, used)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s, db, used, codes
+	}
+	challenge := func(t *testing.T, s *TwoFactorService, db *gorm.DB) string {
+		t.Helper()
+		var user model.User
+		db.First(&user, 1)
+		c, err := s.StartChallenge(ctx, &user, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.Token
+	}
+	verify := func(s *TwoFactorService, db *gorm.DB, token, code string) (*dto.LoginResponse, error) {
+		return s.VerifyChallenge(ctx, db, &dto.TwoFactorVerifyRequest{ChallengeToken: token, Code: code}, &dto.SessionMeta{})
+	}
+
+	t.Run("challenge issues tokens once", func(t *testing.T) {
+		s, db, _, codes := enroll(t)
+		token := challenge(t, s, db)
+		resp, err := verify(s, db, token, codes[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.TokenPair == nil || resp.User == nil {
+			t.Fatalf("response %+v, want user and token pair", resp)
+		}
+		if _, err := verify(s, db, token, codes[1]); !errors.Is(err, ErrInvalidChallenge) {
+			t.Errorf("second use of the challenge = %v, want ErrInvalidChallenge", err)
+		}
+	})
+
+	t.Run("totp code cannot be replayed", func(t *testing.T) {
+		s, db, used, _ := enroll(t)
+		if _, err := verify(s, db, challenge(t, s, db), used); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Errorf("replayed totp code = %v, want ErrInvalidTwoFactorCode", err)
+		}
+	})
+
+	t.Run("recovery code is single use", func(t *testing.T) {
+		s, db, _, codes := enroll(t)
+		if _, err := verify(s, db, challenge(t, s, db), codes[2]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verify(s, db, challenge(t, s, db), codes[2]); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Errorf("reused recovery code = %v, want ErrInvalidTwoFactorCode", err)
+		}
+	})
+}
@@ -2,12 +2,18 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"iot/internal/dto"
 	"iot/internal/helper/mailer"
 	"iot/internal/jwt_utils"
 	"iot/internal/model"
 	"iot/internal/repository"
+	"iot/internal/tenant"
+	"iot/pkg/pagination"
 	"strconv"
 	"time"
 
@@ -16,27 +22,61 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	ErrSelfDemotion       = errors.New("admins cannot change their own role")
+	ErrInvalidResetCode   = errors.New("invalid or expired reset code")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrOTPExhausted       = errors.New("too many invalid OTP attempts, request a new code")
+	ErrAccountForbidden   = errors.New("only the account owner or a system admin can change this account")
+	ErrAdminOnly          = errors.New("only a system admin can delete accounts")
+)
+
+// Số lần nhập sai OTP đăng ký trước khi mã bị hủy
+const registerOTPMaxAttempts = 5
+
+// Mã reset mật khẩu: dùng một lần, hết hạn sau 10 phút, sai quá 5 lần thì bị hủy
+const (
+	resetKeyPrefix   = "reset:"
+	resetCodeTTL     = 10 * time.Minute
+	resetMaxAttempts = 5
+)
+
 // Định nghĩa interface cho UserService
 type UserServiceInterface interface {
 	RegisterOTP(db *gorm.DB, userDto *dto.CreateUserRequest, mailer_service *mailer.MailService, redis *redis.Client, ctx context.Context) (*dto.CreateUserResponseWithOTP, error)
-	Register(db *gorm.DB, userDto *dto.RegisterRequest, mailer_service *mailer.MailService, redis *redis.Client) (*dto.RegisterResponse, error)
-	Login(db *gorm.DB, email, password string) (*dto.LoginResponse, error)
-	GetUserByID(db *gorm.DB, id uint) (*model.User, error)
-	GetAllUsers(db *gorm.DB, limit, offset int, redis *redis.Client) ([]model.User, error)
-	UpdateUser(db *gorm.DB, Id uint, data *dto.UpdateUserRequest, redis *redis.Client) error
-	DeleteUser(db *gorm.DB, id uint, redis *redis.Client) error
-	RefreshToken(db *gorm.DB, refreshToken string) (*dto.LoginResponse, error)
+	Register(db *gorm.DB, userDto *dto.RegisterRequest, mailer_service *mailer.MailService, redis *redis.Client, meta *dto.SessionMeta) (*dto.RegisterResponse, error)
+	Login(ctx context.Context, db *gorm.DB, email, password string, meta *dto.SessionMeta, mailer_service *mailer.MailService) (*dto.LoginResponse, error)
+	GetUserByID(db *gorm.DB, id uint) (*dto.UserDTO, error)
+	GetAllUsers(db *gorm.DB, page *pagination.Request, redis *redis.Client) ([]dto.UserDTO, *pagination.Page, error)
+	UpdateUser(db *gorm.DB, actorID, Id uint, data *dto.UpdateUserRequest, redis *redis.Client) error
+	DeleteUser(db *gorm.DB, actorID, id uint, redis *redis.Client) error
+	RefreshToken(ctx context.Context, db *gorm.DB, refreshToken string, meta *dto.SessionMeta) (*dto.LoginResponse, error)
+	AssignRole(db *gorm.DB, actorID, id uint, role string) error
+	Logout(ctx context.Context, refreshToken string) error
+	LogoutAll(ctx context.Context, userID uint) error
+	ListSessions(ctx context.Context, userID uint, currentID string) ([]dto.SessionDTO, error)
+	RevokeSession(ctx context.Context, userID uint, sessionID string) error
+	ForgotPassword(ctx context.Context, db *gorm.DB, email string, mailer_service *mailer.MailService, redis *redis.Client) error
+	ResetPassword(ctx context.Context, db *gorm.DB, req *dto.ResetPasswordRequest, redis *redis.Client) error
 }
 
 // Struct UserService (có thể mở rộng thêm field nếu cần)
 type UserService struct {
-	repo repository.UserRepositoryInterface
+	repo      repository.UserRepositoryInterface
+	sessions  SessionServiceInterface
+	twoFactor TwoFactorServiceInterface
+	lockout   LockoutServiceInterface
+	tenants   TenantServiceInterface
 }
 
 // NewUserService - constructor để tạo UserService mới
-func NewUserService(ur repository.UserRepositoryInterface) UserServiceInterface {
+func NewUserService(ur repository.UserRepositoryInterface, sessions SessionServiceInterface, twoFactor TwoFactorServiceInterface, lockout LockoutServiceInterface, tenants TenantServiceInterface) UserServiceInterface {
 	return &UserService{
-		repo: ur,
+		repo:      ur,
+		sessions:  sessions,
+		twoFactor: twoFactor,
+		lockout:   lockout,
+		tenants:   tenants,
 	}
 }
 
@@ -83,7 +123,7 @@ func (s *UserService) RegisterOTP(db *gorm.DB, userDto *dto.CreateUserRequest, m
 	return response, nil
 }
 
-func (s *UserService) Register(db *gorm.DB, userDto *dto.RegisterRequest, mailer_service *mailer.MailService, redis *redis.Client) (*dto.RegisterResponse, error) {
+func (s *UserService) Register(db *gorm.DB, userDto *dto.RegisterRequest, mailer_service *mailer.MailService, redis *redis.Client, meta *dto.SessionMeta) (*dto.RegisterResponse, error) {
 	email := userDto.Email
 
 	ok, err := s.repo.CheckEmailExists(db, email)
@@ -102,8 +142,18 @@ func (s *UserService) Register(db *gorm.DB, userDto *dto.RegisterRequest, mailer
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("otp: ", otp)
-	if otp != userDto.OTP {
+	if subtle.ConstantTimeCompare([]byte(otp), []byte(userDto.OTP)) != 1 {
+		// Đếm số lần sai, quá giới hạn thì hủy mã để không thể dò hết 10^6 giá trị trong 5 phút
+		redis_key_attempts := "otp:attempts:" + email + time_register
+		attempts, err := redis.Incr(context.Background(), redis_key_attempts).Result()
+		if err != nil {
+			return nil, err
+		}
+		redis.Expire(context.Background(), redis_key_attempts, 5*time.Minute)
+		if attempts >= registerOTPMaxAttempts {
+			redis.Del(context.Background(), redis_key_otp, redis_key_pass, redis_key_attempts)
+			return nil, ErrOTPExhausted
+		}
 		return nil, gorm.ErrInvalidData
 	}
 	hashedPassword, err = redis.Get(context.Background(), redis_key_pass).Result()
@@ -122,23 +172,26 @@ func (s *UserService) Register(db *gorm.DB, userDto *dto.RegisterRequest, mailer
 		Name:     name,
 		Email:    email,
 		Password: hashedPassword,
+		Role:     model.RoleViewer,
 	}
 	err = s.repo.CreateUser(db, newUser)
 	if err != nil {
 		return nil, err
 	}
-	tokenPair, err := jwt_utils.GenerateTokenPair(
-		newUser.ID,
-		newUser.Name,
-		newUser.Email,
-	)
+	selection, err := s.tenants.Resolve(db, newUser, 0)
+	if err != nil {
+		return nil, err
+	}
+	tokenPair, err := s.sessions.CreateSession(context.Background(), newUser, selection, meta)
 	if err != nil {
 		return nil, err
 	}
 	userResponse := &dto.UserDTO{
-		ID:    newUser.ID,
-		Name:  newUser.Name,
-		Email: newUser.Email,
+		ID:       newUser.ID,
+		Name:     newUser.Name,
+		Email:    newUser.Email,
+		Role:     selection.Role,
+		TenantID: selection.TenantID,
 	}
 	go func() {
 		mailer_service.Send(
@@ -156,28 +209,49 @@ func (s *UserService) Register(db *gorm.DB, userDto *dto.RegisterRequest, mailer
 	return response, nil
 }
 
-func (s *UserService) Login(db *gorm.DB, email, password string) (*dto.LoginResponse, error) {
+func (s *UserService) Login(ctx context.Context, db *gorm.DB, email, password string, meta *dto.SessionMeta, mailer_service *mailer.MailService) (*dto.LoginResponse, error) {
+	if err := s.lockout.Check(ctx, email); err != nil {
+		return nil, err
+	}
 	user, err := s.repo.GetByEmail(db, email)
-	if err != nil {
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
+	// Email không tồn tại cũng tính là một lần sai để không lộ tài khoản qua lockout
+	if user == nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		if err := s.lockout.RegisterFailure(ctx, email); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+	if err := s.lockout.Reset(ctx, email); err != nil {
 		return nil, err
 	}
+	userResponse := &dto.UserDTO{
+		ID:              user.ID,
+		Name:            user.Name,
+		Email:           user.Email,
+		TwoFactorMethod: user.TwoFactorMethod,
+	}
 
-	tokenPair, err := jwt_utils.GenerateTokenPair(
-		user.ID,
-		user.Name,
-		user.Email,
-	)
+	// Bật 2FA thì chưa cấp token, client phải gửi mã kèm challenge token
+	if user.TwoFactorMethod != model.TwoFactorNone {
+		challenge, err := s.twoFactor.StartChallenge(ctx, user, mailer_service)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.LoginResponse{User: userResponse, Challenge: challenge}, nil
+	}
+
+	selection, err := s.tenants.Resolve(db, user, 0)
 	if err != nil {
 		return nil, err
 	}
-	userResponse := &dto.UserDTO{
-		ID:    user.ID,
-		Name:  user.Name,
-		Email: user.Email,
+	userResponse.Role = selection.Role
+	userResponse.TenantID = selection.TenantID
+	tokenPair, err := s.sessions.CreateSession(ctx, user, selection, meta)
+	if err != nil {
+		return nil, err
 	}
 
 	response := &dto.LoginResponse{
@@ -187,27 +261,30 @@ func (s *UserService) Login(db *gorm.DB, email, password string) (*dto.LoginResp
 	return response, nil
 }
 
-func (s *UserService) RefreshToken(db *gorm.DB, refreshToken string) (*dto.LoginResponse, error) {
+func (s *UserService) RefreshToken(ctx context.Context, db *gorm.DB, refreshToken string, meta *dto.SessionMeta) (*dto.LoginResponse, error) {
 	data, err := jwt_utils.VerifyToken(refreshToken, false)
 	if err != nil {
 		return nil, err
 	}
-	_, err = s.repo.GetByID(db, data.Id)
+	// Lấy lại user và membership từ DB để token mới phản ánh role hiện tại trong tenant
+	user, err := s.repo.GetByID(db, data.Id)
+	if err != nil {
+		return nil, err
+	}
+	selection, err := s.tenants.Resolve(db, user, data.TenantID)
 	if err != nil {
 		return nil, err
 	}
-	tokenPair, err := jwt_utils.GenerateTokenPair(
-		data.Id,
-		data.Username,
-		data.Email,
-	)
+	tokenPair, err := s.sessions.RotateSession(ctx, user, selection, data, meta)
 	if err != nil {
 		return nil, err
 	}
 	userResponse := &dto.UserDTO{
-		ID:    data.Id,
-		Name:  data.Username,
-		Email: data.Email,
+		ID:       user.ID,
+		Name:     user.Name,
+		Email:    user.Email,
+		Role:     selection.Role,
+		TenantID: selection.TenantID,
 	}
 
 	response := &dto.LoginResponse{
@@ -216,25 +293,201 @@ func (s *UserService) RefreshToken(db *gorm.DB, refreshToken string) (*dto.Login
 	}
 	return response, nil
 }
-func (s *UserService) GetUserByID(db *gorm.DB, id uint) (*model.User, error) {
-	return s.repo.GetByID(db, id)
+func (s *UserService) GetUserByID(db *gorm.DB, id uint) (*dto.UserDTO, error) {
+	user, err := s.repo.GetByID(db, id)
+	if err != nil {
+		return nil, err
+	}
+	return toUserDTO(user), nil
+}
+
+func (s *UserService) GetAllUsers(db *gorm.DB, page *pagination.Request, redis *redis.Client) ([]dto.UserDTO, *pagination.Page, error) {
+	users, pageInfo, err := s.repo.GetAllUsers(db, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := make([]dto.UserDTO, 0, len(users))
+	for i := range users {
+		result = append(result, *toUserDTO(&users[i]))
+	}
+	return result, pageInfo, nil
 }
 
-func (s *UserService) GetAllUsers(db *gorm.DB, limit, offset int, redis *redis.Client) ([]model.User, error) {
-	if limit <= 0 || limit > 100 {
-		limit = 10
+// toUserDTO - không trả model ra API để hash mật khẩu và secret 2FA không bị lộ
+func toUserDTO(user *model.User) *dto.UserDTO {
+	return &dto.UserDTO{
+		ID:              user.ID,
+		Name:            user.Name,
+		Email:           user.Email,
+		Role:            user.Role,
+		TwoFactorMethod: user.TwoFactorMethod,
 	}
-	if offset < 0 {
-		offset = 0
+}
+
+// UpdateUser - tài khoản dùng chung giữa các tenant (email dùng để đăng nhập và reset mật khẩu),
+// admin tenant không được sửa, chỉ chủ tài khoản hoặc admin hệ thống
+func (s *UserService) UpdateUser(db *gorm.DB, actorID, Id uint, data *dto.UpdateUserRequest, redis *redis.Client) error {
+	if err := s.authorizeAccount(db, actorID, Id); err != nil {
+		return err
 	}
-	return s.repo.GetAllUsers(db, limit, offset)
+	return s.repo.UpdateUser(tenant.Shared(db), Id, data)
 }
 
-func (s *UserService) UpdateUser(db *gorm.DB, Id uint, data *dto.UpdateUserRequest, redis *redis.Client) error {
-	return s.repo.UpdateUser(db, Id, data)
+// DeleteUser - xóa hẳn tài khoản khỏi mọi tenant, chỉ admin hệ thống. Xóa khỏi một tenant là TenantService.RemoveMember.
+func (s *UserService) DeleteUser(db *gorm.DB, actorID, id uint, redis *redis.Client) error {
+	db = tenant.Shared(db)
+	admin, err := s.tenants.IsSystemAdmin(db, actorID)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return ErrAdminOnly
+	}
+	if err := s.repo.DeleteUser(db, id); err != nil {
+		return err
+	}
+	return s.sessions.RevokeAllSessions(context.Background(), id)
+}
+
+// authorizeAccount - ErrAccountForbidden nếu actor không phải chủ tài khoản và không phải admin hệ thống (users.role)
+func (s *UserService) authorizeAccount(db *gorm.DB, actorID, id uint) error {
+	if actorID == id {
+		return nil
+	}
+	admin, err := s.tenants.IsSystemAdmin(db, actorID)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return ErrAccountForbidden
+	}
+	return nil
+}
+
+// AssignRole - đổi role của user trong tenant đã chọn trên db
+func (s *UserService) AssignRole(db *gorm.DB, actorID, id uint, role string) error {
+	tenantID := tenant.ID(db)
+	if tenantID == 0 {
+		return tenant.ErrMissingTenant
+	}
+	return s.tenants.UpdateMemberRole(db, tenantID, actorID, id, role)
 }
 
-func (s *UserService) DeleteUser(db *gorm.DB, id uint, redis *redis.Client) error {
+// Logout - thu hồi session của refresh token hiện tại, token hết hạn/sai chữ ký thì bỏ qua
+func (s *UserService) Logout(ctx context.Context, refreshToken string) error {
+	data, err := jwt_utils.VerifyToken(refreshToken, false)
+	if err != nil || data.SessionID == "" {
+		return nil
+	}
+	err = s.sessions.RevokeSession(ctx, data.Id, data.SessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *UserService) LogoutAll(ctx context.Context, userID uint) error {
+	return s.sessions.RevokeAllSessions(ctx, userID)
+}
+
+func (s *UserService) ListSessions(ctx context.Context, userID uint, currentID string) ([]dto.SessionDTO, error) {
+	return s.sessions.ListSessions(ctx, userID, currentID)
+}
+
+func (s *UserService) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	return s.sessions.RevokeSession(ctx, userID, sessionID)
+}
+
+// ForgotPassword - gửi mã reset qua email. Email không tồn tại vẫn trả về nil để không lộ tài khoản nào đã đăng ký.
+func (s *UserService) ForgotPassword(ctx context.Context, db *gorm.DB, email string, mailer_service *mailer.MailService, redis *redis.Client) error {
+	user, err := s.repo.GetByEmail(db, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	code := mailer_service.GenerateOTP()
+	key := resetKeyPrefix + email
+	// Mã mới thay thế mã cũ và reset số lần thử. Chỉ lưu hash của mã.
+	pipe := redis.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "code", hashSecret(code), "attempts", 0)
+	pipe.Expire(ctx, key, resetCodeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		mailer_service.Send(
+			context.Background(),
+			&mailer.PasswordResetEmail{From: "no-reply@myapp.com"},
+			email,
+			map[string]string{"name": user.Name, "otp": code, "ttl": strconv.Itoa(int(resetCodeTTL.Minutes()))},
+		)
+	}()
+	return nil
+}
+
+// ResetPassword - đổi mật khẩu bằng mã reset rồi thu hồi mọi session đang đăng nhập
+func (s *UserService) ResetPassword(ctx context.Context, db *gorm.DB, req *dto.ResetPasswordRequest, redis *redis.Client) error {
+	key := resetKeyPrefix + req.Email
+	stored, err := redis.HGet(ctx, key, "code").Result()
+	if err != nil {
+		return ErrInvalidResetCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(hashSecret(req.OTP))) != 1 {
+		if err := countFailedAttempt(ctx, redis, key, resetMaxAttempts); err != nil {
+			return err
+		}
+		return ErrInvalidResetCode
+	}
+
+	// Del trả về 0 nghĩa là request khác đã dùng mã này
+	deleted, err := redis.Del(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrInvalidResetCode
+	}
+
+	user, err := s.repo.GetByEmail(db, req.Email)
+	if err != nil {
+		return ErrInvalidResetCode
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(db, user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+	return s.sessions.RevokeAllSessions(ctx, user.ID)
+}
+
+// failedAttemptScript - tăng bộ đếm nhập sai, đủ ARGV[1] lần thì xóa mã.
+// Key đã hết hạn thì trả về -1 và không tạo lại (HINCRBY sẽ tạo key không có TTL).
+var failedAttemptScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts >= tonumber(ARGV[1]) then
+	redis.call('DEL', KEYS[1])
+end
+return attempts
+`)
+
+// countFailedAttempt - ghi nhận một lần nhập sai cho mã ngắn hạn lưu ở key
+func countFailedAttempt(ctx context.Context, rdb *redis.Client, key string, maxAttempts int) error {
+	return failedAttemptScript.Run(ctx, rdb, []string{key}, maxAttempts).Err()
+}
 
-	return s.repo.DeleteUser(db, id)
+// hashSecret - SHA-256 cho mã ngắn hạn (reset, OTP, recovery code) lưu trong Redis/DB
+func hashSecret(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
 }
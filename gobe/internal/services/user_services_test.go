@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"iot/internal/dto"
+	"iot/internal/model"
+	"iot/internal/repository"
+	"iot/internal/tenant"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestDeleteUser(t *testing.T) {
+	const (
+		adminID  = 1
+		memberID = 2
+	)
+	tests := []struct {
+		name    string
+		actorID uint
+		target  uint
+		err     error
+		deleted bool
+	}{
+		{"system admin deletes the account", adminID, memberID, nil, true},
+		{"tenant member cannot delete accounts", memberID, adminID, ErrAdminOnly, false},
+		{"account owner cannot delete itself", memberID, memberID, ErrAdminOnly, false},
+		{"unknown account", adminID, 9, gorm.ErrRecordNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			db.Exec("INSERT INTO users (id, name, email, password, role) VALUES (?, 'root', 'root@example.com', 'x', 'admin'), (?, 'bob', 'bob@example.com', 'x', 'viewer')",
+				adminID, memberID)
+			db.Exec("INSERT INTO tenant_memberships (tenant_id, user_id, role) VALUES (?, ?, 'admin'), (?, ?, 'admin')",
+				testTenantID, adminID, testTenantID, memberID)
+
+			sessions := &revokeRecorder{}
+			userRepo := repository.NewUserRepository()
+			s := &UserService{
+				repo:     userRepo,
+				sessions: sessions,
+				tenants:  NewTenantService(repository.NewTenantRepository(), userRepo, sessions),
+			}
+			// db đang chọn tenant như request thật, xóa tài khoản vẫn phải xóa ở mọi tenant
+			err := s.DeleteUser(tenant.WithTenant(db, testTenantID), tt.actorID, tt.target, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("DeleteUser = %v, want %v", err, tt.err)
+			}
+
+			var users, memberships int64
+			db.Model(&model.User{}).Where("id = ?", tt.target).Count(&users)
+			db.Model(&model.TenantMembership{}).Where("user_id = ?", tt.target).Count(&memberships)
+			if tt.deleted && (users != 0 || memberships != 0) {
+				t.Errorf("after delete users = %d memberships = %d, want 0", users, memberships)
+			}
+			if !tt.deleted && tt.err != gorm.ErrRecordNotFound && (users != 1 || memberships != 1) {
+				t.Errorf("users = %d memberships = %d, want the account untouched", users, memberships)
+			}
+			if revoked := len(sessions.revoked) > 0; revoked != tt.deleted {
+				t.Errorf("sessions revoked = %v, want %v", revoked, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestResetPasswordAttempts(t *testing.T) {
+	const (
+		email = "alice@example.com"
+		code  = "123456"
+	)
+	ctx := context.Background()
+	key := resetKeyPrefix + email
+
+	// setup - mã reset được lưu giống ForgotPassword
+	setup := func(t *testing.T) (*UserService, *gorm.DB, *redis.Client, *miniredis.Miniredis, *revokeRecorder) {
+		t.Helper()
+		db := openTestDB(t)
+		db.Exec("INSERT INTO users (id, name, email, password, role) VALUES (1, 'alice', ?, 'x', 'viewer')", email)
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		mr.HSet(key, "code", hashSecret(code), "attempts", "0")
+		mr.SetTTL(key, resetCodeTTL)
+		sessions := &revokeRecorder{}
+		return &UserService{repo: repository.NewUserRepository(), sessions: sessions}, db, client, mr, sessions
+	}
+	reset := func(otp string) *dto.ResetPasswordRequest {
+		return &dto.ResetPasswordRequest{Email: email, OTP: otp, NewPassword: "new password"}
+	}
+
+	t.Run("wrong code is counted and keeps the ttl", func(t *testing.T) {
+		s, db, client, mr, _ := setup(t)
+		if err := s.ResetPassword(ctx, db, reset("000000"), client); !errors.Is(err, ErrInvalidResetCode) {
+			t.Fatalf("ResetPassword = %v, want ErrInvalidResetCode", err)
+		}
+		if got := mr.HGet(key, "attempts"); got != "1" {
+			t.Errorf("attempts = %q, want 1", got)
+		}
+		if ttl := mr.TTL(key); ttl <= 0 || ttl > resetCodeTTL {
+			t.Errorf("ttl = %v, want the original expiry", ttl)
+		}
+	})
+
+	t.Run("expired code is not recreated", func(t *testing.T) {
+		s, db, client, mr, _ := setup(t)
+		mr.FastForward(resetCodeTTL + time.Second)
+		if err := s.ResetPassword(ctx, db, reset("000000"), client); !errors.Is(err, ErrInvalidResetCode) {
+			t.Fatalf("ResetPassword = %v, want ErrInvalidResetCode", err)
+		}
+		// mã có thể hết hạn giữa HGet và lúc đếm lần sai
+		if err := countFailedAttempt(ctx, client, key, resetMaxAttempts); err != nil {
+			t.Fatal(err)
+		}
+		if mr.Exists(key) {
+			t.Error("expired reset key recreated without a ttl")
+		}
+	})
+
+	t.Run("code is dropped after the attempt limit", func(t *testing.T) {
+		s, db, client, mr, _ := setup(t)
+		for i := 0; i < resetMaxAttempts; i++ {
+			if err := s.ResetPassword(ctx, db, reset("000000"), client); !errors.Is(err, ErrInvalidResetCode) {
+				t.Fatalf("attempt %d: ResetPassword = %v, want ErrInvalidResetCode", i+1, err)
+			}
+		}
+		if mr.Exists(key) {
+			t.Fatal("reset key kept after the attempt limit")
+		}
+		if err := s.ResetPassword(ctx, db, reset(code), client); !errors.Is(err, ErrInvalidResetCode) {
+			t.Errorf("correct code after the limit = %v, want ErrInvalidResetCode", err)
+		}
+	})
+
+	t.Run("correct code changes the password", func(t *testing.T) {
+		s, db, client, mr, sessions := setup(t)
+		if err := s.ResetPassword(ctx, db, reset(code), client); err != nil {
+			t.Fatal(err)
+		}
+		var user model.User
+		db.First(&user, 1)
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("new password")) != nil {
+			t.Error("password not updated")
+		}
+		if mr.Exists(key) || len(sessions.revoked) == 0 {
+			t.Errorf("reset key left = %v, sessions revoked = %v", mr.Exists(key), sessions.revoked)
+		}
+	})
+}
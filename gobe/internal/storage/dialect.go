@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"fmt"
+	"iot/pkg/config"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Câu SQL khác nhau giữa MySQL, PostgreSQL và SQLite gom ở đây, repository chỉ gọi qua hàm.
+// Dialect lấy theo gorm.DB (Dialector.Name() trùng tên driver trong config).
+
+var timescale atomic.Bool
+
+// SetTimescale - bật khi database PostgreSQL có extension timescaledb, initialize tự phát hiện lúc kết nối
+func SetTimescale(enabled bool) {
+	timescale.Store(enabled)
+}
+
+func Timescale() bool {
+	return timescale.Load()
+}
+
+// TimeBucket - biểu thức trả đầu khoảng chứa column, dạng Unix giây (số nguyên), để mọi dialect scan
+// giống nhau. Khoảng được căn theo mốc Unix (UTC); bucket làm tròn xuống tới giây, tối thiểu 1 giây.
+// MySQL lưu DATETIME không kèm múi giờ nên kết quả theo time_zone của phiên, phải trùng loc của DSN.
+func TimeBucket(db *gorm.DB, column string, bucket time.Duration) string {
+	seconds := int64(bucket / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	switch db.Dialector.Name() {
+	case config.DriverPostgres:
+		if Timescale() {
+			return fmt.Sprintf("CAST(EXTRACT(EPOCH FROM time_bucket(INTERVAL '%d seconds', %s)) AS BIGINT)", seconds, column)
+		}
+		return fmt.Sprintf("CAST(FLOOR(EXTRACT(EPOCH FROM %s) / %d) * %d AS BIGINT)", column, seconds, seconds)
+	case config.DriverSQLite:
+		return fmt.Sprintf("(CAST(strftime('%%s', %s) AS INTEGER) / %d) * %d", column, seconds, seconds)
+	default:
+		return fmt.Sprintf("CAST(FLOOR(UNIX_TIMESTAMP(%s) / %d) * %d AS SIGNED)", column, seconds, seconds)
+	}
+}
@@ -0,0 +1,19 @@
+package storage
+
+import "sync/atomic"
+
+var schemaErr atomic.Pointer[error]
+
+// SetSchemaError - initialize ghi lại khi database lên muộn (chế độ degraded) nhưng schema không khớp binary,
+// /readyz báo database down kèm lỗi này
+func SetSchemaError(err error) {
+	schemaErr.Store(&err)
+}
+
+// SchemaError - nil khi schema đã kiểm tra xong hoặc chưa kiểm tra
+func SchemaError() error {
+	if err := schemaErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package tenant
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrMissingTenant - repository của dữ liệu theo tenant được gọi với db chưa chọn tenant
+var ErrMissingTenant = errors.New("no tenant selected")
+
+const (
+	tenantKey = "tenant:id"
+	systemKey = "tenant:system"
+)
+
+// WithTenant - db chỉ thấy dữ liệu của tenantID. Trả về session mới nên dùng lại được cho nhiều query.
+func WithTenant(db *gorm.DB, tenantID uint) *gorm.DB {
+	return db.Set(tenantKey, tenantID).Session(&gorm.Session{})
+}
+
+// System - db cho tác vụ nội bộ không thuộc request của user (bridge MQTT, broker auth, migrate).
+// Đọc thấy mọi tenant, ghi thì record phải tự mang TenantID.
+func System(db *gorm.DB) *gorm.DB {
+	return db.Set(systemKey, true).Session(&gorm.Session{})
+}
+
+// Shared - bỏ tenant đã chọn trên db, dùng cho bảng dùng chung giữa các tenant như tài khoản user
+func Shared(db *gorm.DB) *gorm.DB {
+	return db.Set(tenantKey, uint(0)).Session(&gorm.Session{})
+}
+
+// ID - tenant đã chọn trên db, 0 nếu chưa chọn
+func ID(db *gorm.DB) uint {
+	if value, ok := db.Get(tenantKey); ok {
+		if id, ok := value.(uint); ok {
+			return id
+		}
+	}
+	return 0
+}
+
+func IsSystem(db *gorm.DB) bool {
+	value, ok := db.Get(systemKey)
+	return ok && value == true
+}
+
+// Scope - thêm điều kiện <table>.tenant_id, db không chọn tenant và không phải System thì trả lỗi
+func Scope(db *gorm.DB, table string) (*gorm.DB, error) {
+	if id := ID(db); id != 0 {
+		return db.Where(table+".tenant_id = ?", id).Session(&gorm.Session{}), nil
+	}
+	if IsSystem(db) {
+		return db, nil
+	}
+	return nil, ErrMissingTenant
+}
+
+// Stamp - gán tenant của db cho record sắp tạo; db System thì record phải có sẵn tenant
+func Stamp(db *gorm.DB, tenantID *uint) error {
+	if id := ID(db); id != 0 {
+		if *tenantID != 0 && *tenantID != id {
+			return ErrMissingTenant
+		}
+		*tenantID = id
+		return nil
+	}
+	if IsSystem(db) && *tenantID != 0 {
+		return nil
+	}
+	return ErrMissingTenant
+}
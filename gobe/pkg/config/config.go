@@ -3,54 +3,150 @@ package config
 import (
 	"log"
 	"os"
-
-	"github.com/joho/godotenv"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Config - nạp một lần lúc khởi động theo thứ tự: giá trị mặc định, file YAML/TOML (CONFIG_FILE hoặc
+// config.yaml/config.yml/config.toml ở thư mục chạy), biến môi trường (kể cả từ .env). Tên key trong file
+// lấy theo tag yaml (TOML dùng cùng tên), tên biến môi trường theo tag env.
 type Config struct {
-	DbConfig    *MysqlConfig
-	RedisConfig *RedisConfig
-	EmailConfig *EmailConfig
-	MQTTConfig  *MQTTConfig
-}
+	Server        *ServerConfig   `yaml:"server"`
+	Storage       *StorageConfig  `yaml:"storage"`
+	DbConfig      *MysqlConfig    `yaml:"mysql"`
+	Postgres      *PostgresConfig `yaml:"postgres"`
+	SQLite        *SQLiteConfig   `yaml:"sqlite"`
+	RedisConfig   *RedisConfig    `yaml:"redis"`
+	EmailConfig   *EmailConfig    `yaml:"smtp"`
+	MQTTConfig    *MQTTConfig     `yaml:"mqtt"`
+	OIDCConfig    *OIDCConfig     `yaml:"oidc"`
+	ExportConfig  *ExportConfig   `yaml:"export"`
+	TracingConfig *TracingConfig  `yaml:"tracing"`
+	JWTConfig     *JWTConfig      `yaml:"jwt"`
+	Log           *LogConfig      `yaml:"log"`
+	CORS          *CORSConfig     `yaml:"cors"`
+	Alerts        *AlertConfig    `yaml:"alerts"`
+	AdminEmail    string          `yaml:"admin_email" env:"ADMIN_EMAIL"`
+	// MetricsAllowedIPs - IP/CIDR được scrape /metrics, rỗng thì không giới hạn
+	MetricsAllowedIPs []string `yaml:"metrics_allowed_ips" env:"METRICS_ALLOWED_IPS"`
+	// StartupRetries - số lần thử kết nối database/Redis/MQTT lúc khởi động trước khi chuyển sang chế độ degraded,
+	// 0 là thử mãi
+	StartupRetries int `yaml:"startup_retries" env:"STARTUP_RETRIES"`
 
-func GetConfig() *Config {
-	err := godotenv.Load()
-	if err != nil {
-		log.Print("env not found")
-	}
+	// source - file đã đọc, rỗng nếu chỉ dùng env
+	source string
+}
 
+func defaults() *Config {
 	return &Config{
+		Server: &ServerConfig{
+			Addr:            ":8080",
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Storage: &StorageConfig{
+			Driver: DriverMySQL,
+		},
 		DbConfig: &MysqlConfig{
-			Host:   os.Getenv("MYSQL_HOST"),
-			Port:   os.Getenv("MYSQL_PORT"),
-			User:   os.Getenv("MYSQL_USER"),
-			Pass:   os.Getenv("MYSQL_PASS"),
-			Dbname: os.Getenv("MYSQL_DBNAME"),
+			Host: "localhost",
+			Port: "3306",
+		},
+		Postgres: &PostgresConfig{
+			Host:    "localhost",
+			Port:    "5432",
+			SSLMode: "disable",
+		},
+		SQLite: &SQLiteConfig{
+			Path: "iot.db",
 		},
 		RedisConfig: &RedisConfig{
-			Host:   os.Getenv("REDIS_HOST"),
-			Port:   os.Getenv("REDIS_PORT"),
-			User:   os.Getenv("REDIS_USER"),
-			Pass:   os.Getenv("REDIS_PASS"),
-			Dbname: os.Getenv("REDIS_DBNAME"),
+			Host: "localhost",
+			Port: "6379",
 		},
 		EmailConfig: &EmailConfig{
-			Host:     os.Getenv("SMTP_HOST"),
-			Port:     587,
-			Username: os.Getenv("SMTP_USERNAME"),
-			Password: os.Getenv("SMTP_PASSWORD"),
-			From:     os.Getenv("FROM_EMAIL"),
-			Timeout:  30,    // Increased timeout for SMTP
-			UseTLS:   false, // Enable TLS for Gmail
+			Port:    587,
+			Timeout: 30 * time.Second,
 		},
 		MQTTConfig: &MQTTConfig{
-			Broker:       os.Getenv("MQTT_BROKER"),
-			ClientID:     os.Getenv("MQTT_CLIENT_ID"),
-			Username:     os.Getenv("MQTT_USERNAME"),
-			Password:     os.Getenv("MQTT_PASSWORD"),
-			DefaultTopic: os.Getenv("MQTT_DEFAULT_TOPIC"),
-			QoS:          1,
+			QoS: 1,
+		},
+		OIDCConfig: &OIDCConfig{
+			Scopes:            []string{"openid", "email", "profile"},
+			RoleClaim:         "roles",
+			RoleMap:           map[string]string{},
+			DefaultRole:       "viewer",
+			Tenant:            "default",
+			JITProvisioning:   true,
+			PostLoginRedirect: "http://localhost:5173",
+		},
+		ExportConfig: &ExportConfig{
+			Dir:            filepath.Join(os.TempDir(), "iot-exports"),
+			SyncMaxRows:    100000,
+			TTL:            24 * time.Hour,
+			Workers:        2,
+			ImportMaxBytes: 64 << 20,
+		},
+		TracingConfig: &TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "iot-backend",
+			SampleRatio: 1,
+		},
+		JWTConfig: &JWTConfig{
+			AccessTTL:  3 * time.Hour,
+			RefreshTTL: 7 * 24 * time.Hour,
 		},
+		Log: &LogConfig{
+			Level: "info",
+		},
+		CORS: &CORSConfig{
+			AllowedOrigins: []string{
+				"http://localhost:5173", // Vite port
+				"http://localhost:3000",
+				"http://127.0.0.1:5173",
+				"http://127.0.0.1:3000",
+			},
+		},
+		Alerts: &AlertConfig{
+			TemperatureMin: 0,
+			TemperatureMax: 40,
+			HumidityMin:    20,
+			HumidityMax:    90,
+			LightMin:       0,
+			LightMax:       4095,
+		},
+		StartupRetries: 5,
+	}
+}
+
+var (
+	current  atomic.Pointer[Config]
+	loadOnce sync.Once
+)
+
+// Init - nạp và giữ cấu hình cho cả process, lỗi liệt kê mọi vấn đề tìm thấy
+func Init() (*Config, error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	current.Store(cfg)
+	return cfg, nil
+}
+
+// GetConfig - cấu hình đang dùng, không đọc lại file/env. Gọi trước Init (tool, script) thì tự nạp,
+// cấu hình lỗi chỉ ghi log.
+func GetConfig() *Config {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
 	}
+	loadOnce.Do(func() {
+		cfg, err := Load()
+		if err != nil {
+			log.Printf("invalid configuration: %v", err)
+		}
+		current.CompareAndSwap(nil, cfg)
+	})
+	return current.Load()
 }
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig - mặc định cộng các giá trị bắt buộc không có mặc định
+func validConfig() *Config {
+	cfg := defaults()
+	cfg.DbConfig.User = "iot"
+	cfg.DbConfig.Dbname = "iot"
+	cfg.JWTConfig.AccessSecret = "access"
+	cfg.JWTConfig.RefreshSecret = "refresh"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string // rỗng = hợp lệ
+	}{
+		{"defaults with secrets", func(*Config) {}, ""},
+		{"missing jwt secret", func(c *Config) { c.JWTConfig.AccessSecret = "" }, "jwt.access_secret: is required"},
+		{"refresh shorter than access", func(c *Config) { c.JWTConfig.RefreshTTL = time.Minute }, "jwt.refresh_ttl: must not be shorter than jwt.access_ttl"},
+		{"bad server addr", func(c *Config) { c.Server.Addr = "8080" }, `server.addr: must be host:port, got "8080"`},
+		{"bad trusted proxy", func(c *Config) { c.Server.TrustedProxies = []string{"10.0.0.0/33"} }, `server.trusted_proxies: "10.0.0.0/33" is not an IP or CIDR`},
+		{"unknown driver", func(c *Config) { c.Storage.Driver = "oracle" }, `storage.driver: must be mysql, postgres or sqlite, got "oracle"`},
+		{"only the selected driver is checked", func(c *Config) { c.Storage.Driver = DriverSQLite; c.DbConfig.User = "" }, ""},
+		{"postgres sslmode", func(c *Config) {
+			c.Storage.Driver = DriverPostgres
+			c.Postgres.User = "iot"
+			c.Postgres.Dbname = "iot"
+			c.Postgres.SSLMode = "on"
+		}, `postgres.sslmode: must be`},
+		{"redis port", func(c *Config) { c.RedisConfig.Port = "70000" }, `redis.port: must be between 1 and 65535, got "70000"`},
+		{"smtp from", func(c *Config) { c.EmailConfig.Host = "smtp.example.com" }, "smtp.from: is required when smtp.host is set"},
+		{"mqtt broker url", func(c *Config) { c.MQTTConfig.Broker = "localhost:1883" }, `mqtt.broker: must be a URL such as tcp://host:1883, got "localhost:1883"`},
+		{"oidc half configured", func(c *Config) { c.OIDCConfig.ClientID, c.OIDCConfig.RedirectURL = "iot", "https://app.example.com/cb" }, "oidc.issuer: is required when oidc.client_id is set"},
+		{"oidc role map", func(c *Config) {
+			c.OIDCConfig.Issuer, c.OIDCConfig.ClientID, c.OIDCConfig.RedirectURL = "https://idp.example.com", "iot", "https://app.example.com/cb"
+			c.OIDCConfig.RoleMap = map[string]string{"iot-admins": "root"}
+		}, `oidc.role_map: "iot-admins" maps to unknown role "root"`},
+		{"otlp endpoint", func(c *Config) { c.TracingConfig.Exporter = "otlp"; c.TracingConfig.Endpoint = "localhost:4318" }, "tracing.endpoint: must be an http(s) URL"},
+		{"sample ratio", func(c *Config) { c.TracingConfig.SampleRatio = 2 }, "tracing.sample_ratio: must be between 0 and 1"},
+		{"wildcard cors origin", func(c *Config) { c.CORS.AllowedOrigins = []string{"*"} }, `cors.allowed_origins: "*" is not an origin`},
+		{"alert bounds", func(c *Config) { c.Alerts.HumidityMin = 95 }, "alerts.humidity_min: must be below alerts.humidity_max"},
+		{"log level", func(c *Config) { c.Log.Level = "verbose" }, `log.level: must be debug, info, warn or error, got "verbose"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			problems := cfg.validate()
+			if tt.want == "" {
+				if len(problems) != 0 {
+					t.Errorf("problems %q, want none", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.HasPrefix(problems[0], tt.want) {
+				t.Errorf("problems %q, want only %q", problems, tt.want)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("file then env", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Chdir(dir)
+		writeFile(t, dir, "config.yaml", `
+mysql:
+  user: iot
+  dbname: iot
+  port: "3307"
+jwt:
+  access_secret: from-file
+  refresh_secret: from-file
+  access_ttl: 1h
+log:
+  level: debug
+`)
+		t.Setenv("JWT_ACCESS_SECRET", "from-env")
+
+		cfg, err := Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.JWTConfig.AccessSecret != "from-env" || cfg.JWTConfig.RefreshSecret != "from-file" {
+			t.Errorf("jwt secrets %q %q, want env over file", cfg.JWTConfig.AccessSecret, cfg.JWTConfig.RefreshSecret)
+		}
+		if cfg.DbConfig.Port != "3307" || cfg.JWTConfig.AccessTTL != time.Hour || cfg.Log.Level != "debug" {
+			t.Errorf("file values not applied: port %s ttl %v level %s", cfg.DbConfig.Port, cfg.JWTConfig.AccessTTL, cfg.Log.Level)
+		}
+		if cfg.RedisConfig.Port != "6379" {
+			t.Errorf("redis.port = %s, want the default", cfg.RedisConfig.Port)
+		}
+		if strings.Contains(cfg.String(), "from-env") || strings.Contains(cfg.String(), "from-file") {
+			t.Errorf("String() leaks secrets: %s", cfg)
+		}
+	})
+
+	t.Run("all problems reported together", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Chdir(dir)
+		t.Setenv(FileEnv, writeFile(t, dir, "iot.toml", `
+startup_retries = -1
+
+[mysql]
+user = "iot"
+dbname = "iot"
+hots = "db"
+
+[jwt]
+access_secret = "a"
+refresh_secret = "r"
+access_ttl = "soon"
+`))
+		t.Setenv("REDIS_PORT", "redis")
+
+		_, err := Load()
+		var validation *ValidationError
+		if !errors.As(err, &validation) {
+			t.Fatalf("Load = %v, want *ValidationError", err)
+		}
+		want := []string{"mysql.hots: unknown setting", "jwt.access_ttl:", "redis.port:", "startup_retries: must not be negative"}
+		if len(validation.Problems) != len(want) {
+			t.Fatalf("problems %q, want %d", validation.Problems, len(want))
+		}
+		for _, w := range want {
+			found := false
+			for _, p := range validation.Problems {
+				found = found || strings.HasPrefix(p, w)
+			}
+			if !found {
+				t.Errorf("no problem starting with %q in %q", w, validation.Problems)
+			}
+		}
+	})
+
+	t.Run("missing config file", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		t.Setenv(FileEnv, "nope.yaml")
+		t.Setenv("JWT_ACCESS_SECRET", "a")
+		t.Setenv("JWT_REFRESH_SECRET", "r")
+		t.Setenv("MYSQL_USER", "iot")
+		t.Setenv("MYSQL_DBNAME", "iot")
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), FileEnv) {
+			t.Errorf("Load = %v, want a %s problem", err, FileEnv)
+		}
+	})
+}
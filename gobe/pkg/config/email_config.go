@@ -3,13 +3,14 @@ package config
 import "time"
 
 type EmailConfig struct {
-	Host     string
-	Port     int
-	Username string
-	Password string
-	From     string
-	Timeout  time.Duration
-	UseTLS   bool
+	Host     string        `yaml:"host" env:"SMTP_HOST"`
+	Port     int           `yaml:"port" env:"SMTP_PORT"`
+	Username string        `yaml:"username" env:"SMTP_USERNAME"`
+	Password string        `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string        `yaml:"from" env:"FROM_EMAIL"`
+	Timeout  time.Duration `yaml:"timeout" env:"SMTP_TIMEOUT"`
+	// UseTLS - TLS ngay từ đầu (cổng 465), false thì dùng STARTTLS nếu server hỗ trợ
+	UseTLS bool `yaml:"use_tls" env:"SMTP_USE_TLS"`
 }
 
 type MailMessageConfig struct {
@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+// ExportConfig - job nền export/import và file của chúng
+type ExportConfig struct {
+	// Dir - thư mục chứa file của export chạy nền và file upload chờ import
+	Dir string `yaml:"dir" env:"EXPORT_DIR"`
+	// SyncMaxRows - export nhiều dòng hơn thì tự chuyển sang chạy nền
+	SyncMaxRows int64 `yaml:"sync_max_rows" env:"EXPORT_SYNC_MAX_ROWS"`
+	// TTL - file export nền bị xóa sau thời gian này
+	TTL time.Duration `yaml:"ttl" env:"EXPORT_TTL"`
+	// Workers - số job export/import chạy đồng thời
+	Workers int `yaml:"workers" env:"EXPORT_WORKERS"`
+	// ImportMaxBytes - giới hạn kích thước file upload để import
+	ImportMaxBytes int64 `yaml:"import_max_bytes" env:"IMPORT_MAX_BYTES"`
+}
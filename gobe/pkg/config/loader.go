@@ -0,0 +1,351 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// FileEnv - biến môi trường chỉ định file cấu hình
+const FileEnv = "CONFIG_FILE"
+
+var defaultFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// ValidationError - tất cả vấn đề của cấu hình, báo một lần thay vì dừng ở lỗi đầu tiên
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d configuration problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Load - đọc cấu hình mới mà không thay cấu hình đang dùng. Có lỗi vẫn trả cấu hình đọc được
+// kèm *ValidationError.
+func Load() (*Config, error) {
+	var problems []string
+	// .env không ghi đè biến môi trường đã có
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		problems = append(problems, fmt.Sprintf(".env: %v", err))
+	}
+
+	cfg := defaults()
+	path, err := configFile()
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	if path != "" {
+		cfg.source = path
+		problems = append(problems, loadFile(cfg, path)...)
+	}
+	problems = append(problems, applyEnv(reflect.ValueOf(cfg).Elem())...)
+	problems = append(problems, cfg.validate()...)
+
+	if len(problems) > 0 {
+		return cfg, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+func configFile() (string, error) {
+	if path := os.Getenv(FileEnv); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("%s: %v", FileEnv, err)
+		}
+		return path, nil
+	}
+	for _, path := range defaultFiles {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+func loadFile(cfg *Config, path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return []string{fmt.Sprintf("%s: unsupported config file type, use .yaml, .yml or .toml", path)}
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+	return applyMap(reflect.ValueOf(cfg).Elem(), raw, "")
+}
+
+// applyMap - gán giá trị trong file vào struct theo tag yaml, key lạ cũng là lỗi để bắt gõ nhầm
+func applyMap(target reflect.Value, raw map[string]interface{}, prefix string) []string {
+	fields := map[string]reflect.Value{}
+	for i := 0; i < target.NumField(); i++ {
+		if name := keyName(target.Type().Field(i)); name != "" {
+			fields[name] = target.Field(i)
+		}
+	}
+
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []string
+	for _, key := range keys {
+		path := prefix + key
+		field, ok := fields[key]
+		if !ok {
+			problems = append(problems, path+": unknown setting")
+			continue
+		}
+		if isSection(field) {
+			section, ok := raw[key].(map[string]interface{})
+			if !ok {
+				problems = append(problems, path+": must be a section")
+				continue
+			}
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			problems = append(problems, applyMap(field.Elem(), section, path+".")...)
+			continue
+		}
+		if err := assign(field, raw[key]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+	return problems
+}
+
+// applyEnv - biến môi trường ghi đè file; biến rỗng chỉ có tác dụng với field chuỗi
+func applyEnv(target reflect.Value) []string {
+	var problems []string
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Field(i)
+		if keyName(target.Type().Field(i)) == "" {
+			continue
+		}
+		if isSection(field) {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			problems = append(problems, applyEnv(field.Elem())...)
+			continue
+		}
+		name := target.Type().Field(i).Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok || (value == "" && field.Kind() != reflect.String) {
+			continue
+		}
+		if err := assign(field, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	return problems
+}
+
+// keyName - rỗng với field không cấu hình được (chưa export hoặc yaml:"-")
+func keyName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func isSection(field reflect.Value) bool {
+	return field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// assign - raw là chuỗi (env) hoặc giá trị đã decode từ YAML/TOML
+func assign(field reflect.Value, raw interface{}) error {
+	if field.Type() == durationType {
+		text, ok := raw.(string)
+		if !ok {
+			return errors.New(`expected a duration such as "30s" or "3h"`)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(text))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", text)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		switch v := raw.(type) {
+		case string:
+			field.SetString(v)
+		case bool, map[string]interface{}, []interface{}, nil:
+			return errors.New("expected a string")
+		default:
+			// port viết dạng số trong file
+			field.SetString(fmt.Sprint(v))
+		}
+	case reflect.Int, reflect.Int64:
+		n, err := toInt(raw)
+		if err != nil {
+			return err
+		}
+		if field.OverflowInt(n) {
+			return fmt.Errorf("%d is out of range", n)
+		}
+		field.SetInt(n)
+	case reflect.Uint8:
+		n, err := toInt(raw)
+		if err != nil {
+			return err
+		}
+		if n < 0 || field.OverflowUint(uint64(n)) {
+			return fmt.Errorf("%d is out of range", n)
+		}
+		field.SetUint(uint64(n))
+	case reflect.Float64:
+		f, err := toFloat(raw)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		switch v := raw.(type) {
+		case bool:
+			field.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", v)
+			}
+			field.SetBool(b)
+		default:
+			return errors.New("expected true or false")
+		}
+	case reflect.Slice:
+		switch v := raw.(type) {
+		case string:
+			field.Set(reflect.ValueOf(splitList(v)))
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				switch item.(type) {
+				case map[string]interface{}, []interface{}, nil:
+					return errors.New("expected a list of strings")
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			field.Set(reflect.ValueOf(items))
+		default:
+			return errors.New("expected a list of strings")
+		}
+	case reflect.Map:
+		switch v := raw.(type) {
+		case string:
+			field.Set(reflect.ValueOf(splitMap(v)))
+		case map[string]interface{}:
+			items := make(map[string]string, len(v))
+			for key, value := range v {
+				items[key] = fmt.Sprint(value)
+			}
+			field.Set(reflect.ValueOf(items))
+		default:
+			return errors.New("expected a map of strings")
+		}
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+func toInt(raw interface{}) (int64, error) {
+	if text, ok := raw.(string); ok {
+		n, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer %q", text)
+		}
+		return n, nil
+	}
+	value := reflect.ValueOf(raw)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value.Uint() > 1<<63-1 {
+			return 0, fmt.Errorf("%d is out of range", value.Uint())
+		}
+		return int64(value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if f := value.Float(); f == float64(int64(f)) {
+			return int64(f), nil
+		}
+	}
+	return 0, errors.New("expected an integer")
+}
+
+func toFloat(raw interface{}) (float64, error) {
+	if text, ok := raw.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", text)
+		}
+		return f, nil
+	}
+	value := reflect.ValueOf(raw)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	}
+	return 0, errors.New("expected a number")
+}
+
+// splitList - tách biến môi trường dạng "a, b, c"
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitMap - tách biến môi trường dạng "k1=v1,k2=v2"
+func splitMap(raw string) map[string]string {
+	items := map[string]string{}
+	for _, item := range splitList(raw) {
+		key, value, ok := strings.Cut(item, "=")
+		if ok && strings.TrimSpace(key) != "" {
+			items[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return items
+}
@@ -1,11 +1,12 @@
 package config
 
 type MQTTConfig struct {
-	Broker       string
-	ClientID     string
-	Username     string
-	Password     string
-	DefaultTopic string
-	QoS          byte 
+	Broker       string `yaml:"broker" env:"MQTT_BROKER"`
+	ClientID     string `yaml:"client_id" env:"MQTT_CLIENT_ID"`
+	Username     string `yaml:"username" env:"MQTT_USERNAME"`
+	Password     string `yaml:"password" env:"MQTT_PASSWORD" secret:"true"`
+	DefaultTopic string `yaml:"default_topic" env:"MQTT_DEFAULT_TOPIC"`
+	QoS          byte   `yaml:"qos" env:"MQTT_QOS"`
+	// AuthAllowedIPs - IP/CIDR được gọi các endpoint auth của broker, rỗng là chặn hết
+	AuthAllowedIPs []string `yaml:"auth_allowed_ips" env:"MQTT_AUTH_ALLOWED_IPS"`
 }
-
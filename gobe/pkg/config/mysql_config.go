@@ -1,14 +1,9 @@
 package config
 
-import (
-	"gorm.io/gorm"
-)
-
 type MysqlConfig struct {
-	Host   string
-	Port   string
-	User   string
-	Pass   string
-	Dbname string
-	DB     *gorm.DB
+	Host   string `yaml:"host" env:"MYSQL_HOST"`
+	Port   string `yaml:"port" env:"MYSQL_PORT"`
+	User   string `yaml:"user" env:"MYSQL_USER"`
+	Pass   string `yaml:"pass" env:"MYSQL_PASS" secret:"true"`
+	Dbname string `yaml:"dbname" env:"MYSQL_DBNAME"`
 }
@@ -0,0 +1,26 @@
+package config
+
+type OIDCConfig struct {
+	Issuer       string   `yaml:"issuer" env:"OIDC_ISSUER"`
+	ClientID     string   `yaml:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret string   `yaml:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL  string   `yaml:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	Scopes       []string `yaml:"scopes" env:"OIDC_SCOPES"`
+	// RoleClaim - claim trong ID token chứa role/group (vd "roles", "groups")
+	RoleClaim string `yaml:"role_claim" env:"OIDC_ROLE_CLAIM"`
+	// RoleMap - giá trị claim -> role nội bộ, đọc từ "iot-admins=admin,iot-ops=operator"
+	RoleMap     map[string]string `yaml:"role_map" env:"OIDC_ROLE_MAP"`
+	DefaultRole string            `yaml:"default_role" env:"OIDC_DEFAULT_ROLE"`
+	// Tenant - slug của tenant mà role từ provider áp vào (role thành viên, không phải role hệ thống),
+	// user tạo qua JIT vào tenant này; rỗng thì provider không quyết định role
+	Tenant string `yaml:"tenant" env:"OIDC_TENANT"`
+	// JITProvisioning - tự tạo user khi email đã xác minh chưa có tài khoản
+	JITProvisioning bool `yaml:"jit_provisioning" env:"OIDC_JIT_PROVISIONING"`
+	// PostLoginRedirect - origin của frontend, callback chuyển hướng về đây sau khi đăng nhập
+	PostLoginRedirect string `yaml:"post_login_redirect" env:"OIDC_POST_LOGIN_REDIRECT"`
+}
+
+// Enabled - chưa cấu hình issuer thì không đăng ký route OIDC
+func (c *OIDCConfig) Enabled() bool {
+	return c != nil && c.Issuer != "" && c.ClientID != ""
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+const redacted = "******"
+
+// Redacted - cấu hình dạng map theo tên key trong file, field có tag secret được che
+func (c *Config) Redacted() map[string]interface{} {
+	return values(reflect.ValueOf(c).Elem(), true)
+}
+
+// String - dùng khi in/log cấu hình, không lộ mật khẩu và secret
+func (c *Config) String() string {
+	out, err := json.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+func values(target reflect.Value, redact bool) map[string]interface{} {
+	out := map[string]interface{}{}
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Type().Field(i)
+		name := keyName(field)
+		if name == "" {
+			continue
+		}
+		value := target.Field(i)
+		switch {
+		case isSection(value):
+			if !value.IsNil() {
+				out[name] = values(value.Elem(), redact)
+			}
+		case redact && field.Tag.Get("secret") == "true" && !value.IsZero():
+			out[name] = redacted
+		case value.Type() == durationType:
+			out[name] = time.Duration(value.Int()).String()
+		default:
+			out[name] = value.Interface()
+		}
+	}
+	return out
+}
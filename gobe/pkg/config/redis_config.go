@@ -5,10 +5,10 @@ import (
 )
 
 type RedisConfig struct {
-	Host   string
-	Port   string
-	User   string
-	Pass   string
-	Dbname string
-	DB     *redis.Client
+	Host   string        `yaml:"host" env:"REDIS_HOST"`
+	Port   string        `yaml:"port" env:"REDIS_PORT"`
+	User   string        `yaml:"user" env:"REDIS_USER"`
+	Pass   string        `yaml:"pass" env:"REDIS_PASS" secret:"true"`
+	Dbname string        `yaml:"dbname" env:"REDIS_DBNAME"`
+	DB     *redis.Client `yaml:"-"`
 }
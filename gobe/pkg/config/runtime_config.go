@@ -0,0 +1,24 @@
+package config
+
+// Các phần dưới đây đổi được khi server đang chạy (xem Watch), phần còn lại cần restart.
+
+type LogConfig struct {
+	// Level - debug, info, warn, error
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins - origin không nằm trong danh sách nhận origin đầu tiên
+	AllowedOrigins []string `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+}
+
+// AlertConfig - ngưỡng cảnh báo của telemetry, giá trị ra ngoài [min, max] thì phát event alert
+type AlertConfig struct {
+	Enabled        bool    `yaml:"enabled" env:"ALERTS_ENABLED"`
+	TemperatureMin float64 `yaml:"temperature_min" env:"ALERT_TEMPERATURE_MIN"`
+	TemperatureMax float64 `yaml:"temperature_max" env:"ALERT_TEMPERATURE_MAX"`
+	HumidityMin    float64 `yaml:"humidity_min" env:"ALERT_HUMIDITY_MIN"`
+	HumidityMax    float64 `yaml:"humidity_max" env:"ALERT_HUMIDITY_MAX"`
+	LightMin       float64 `yaml:"light_min" env:"ALERT_LIGHT_MIN"`
+	LightMax       float64 `yaml:"light_max" env:"ALERT_LIGHT_MAX"`
+}
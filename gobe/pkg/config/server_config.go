@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+type ServerConfig struct {
+	// Addr - địa chỉ HTTP server lắng nghe, dạng host:port
+	Addr string `yaml:"addr" env:"HTTP_ADDR"`
+	// ShutdownTimeout - thời gian chờ request đang chạy khi tắt server
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// TrustedProxies - IP/CIDR của reverse proxy được tin X-Forwarded-For, rỗng là không tin proxy nào
+	// và IP client là địa chỉ kết nối (rate limit, AllowIPs, audit đều dựa vào IP này)
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+type JWTConfig struct {
+	AccessSecret  string        `yaml:"access_secret" env:"JWT_ACCESS_SECRET" secret:"true"`
+	RefreshSecret string        `yaml:"refresh_secret" env:"JWT_REFRESH_SECRET" secret:"true"`
+	AccessTTL     time.Duration `yaml:"access_ttl" env:"JWT_ACCESS_TTL"`
+	// RefreshTTL - cũng là thời gian sống của session trong Redis
+	RefreshTTL time.Duration `yaml:"refresh_ttl" env:"JWT_REFRESH_TTL"`
+}
@@ -0,0 +1,26 @@
+package config
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+type StorageConfig struct {
+	// Driver - mysql, postgres (kể cả TimescaleDB) hoặc sqlite; thông số kết nối nằm ở section cùng tên
+	Driver string `yaml:"driver" env:"STORAGE_DRIVER"`
+}
+
+type PostgresConfig struct {
+	Host    string `yaml:"host" env:"POSTGRES_HOST"`
+	Port    string `yaml:"port" env:"POSTGRES_PORT"`
+	User    string `yaml:"user" env:"POSTGRES_USER"`
+	Pass    string `yaml:"pass" env:"POSTGRES_PASS" secret:"true"`
+	Dbname  string `yaml:"dbname" env:"POSTGRES_DBNAME"`
+	SSLMode string `yaml:"sslmode" env:"POSTGRES_SSLMODE"`
+}
+
+// SQLiteConfig - một file cạnh binary, dùng cho gateway ở biên và khi chạy thử
+type SQLiteConfig struct {
+	Path string `yaml:"path" env:"SQLITE_PATH"`
+}
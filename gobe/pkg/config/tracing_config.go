@@ -0,0 +1,12 @@
+package config
+
+// TracingConfig - đọc theo tên biến môi trường chuẩn của OpenTelemetry
+type TracingConfig struct {
+	// Exporter - none, stdout hoặc otlp (OTLP/HTTP JSON)
+	Exporter string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+	// Endpoint - collector OTLP/HTTP, span gửi tới <Endpoint>/v1/traces
+	Endpoint    string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	// SampleRatio - tỉ lệ trace gốc được ghi (0..1), trace có parent theo quyết định của parent
+	SampleRatio float64 `yaml:"sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG"`
+}
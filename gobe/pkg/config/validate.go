@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+// validate - trả mọi vấn đề, mỗi vấn đề ghi theo đường dẫn key trong file (mysql.host...)
+func (c *Config) validate() []string {
+	var v validator
+
+	v.check(c.Server.Addr != "", "server.addr: is required")
+	if _, port, err := net.SplitHostPort(c.Server.Addr); c.Server.Addr != "" && (err != nil || !validPort(port)) {
+		v.add("server.addr: must be host:port, got %q", c.Server.Addr)
+	}
+	v.check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout: must be positive")
+	v.cidrs("server.trusted_proxies", c.Server.TrustedProxies)
+
+	// chỉ kiểm tra section của driver đang chọn
+	switch c.Storage.Driver {
+	case DriverMySQL:
+		v.check(c.DbConfig.Host != "", "mysql.host: is required")
+		v.check(validPort(c.DbConfig.Port), "mysql.port: must be between 1 and 65535, got %q", c.DbConfig.Port)
+		v.check(c.DbConfig.User != "", "mysql.user: is required")
+		v.check(c.DbConfig.Dbname != "", "mysql.dbname: is required")
+	case DriverPostgres:
+		v.check(c.Postgres.Host != "", "postgres.host: is required")
+		v.check(validPort(c.Postgres.Port), "postgres.port: must be between 1 and 65535, got %q", c.Postgres.Port)
+		v.check(c.Postgres.User != "", "postgres.user: is required")
+		v.check(c.Postgres.Dbname != "", "postgres.dbname: is required")
+		v.check(oneOf(c.Postgres.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+			"postgres.sslmode: must be disable, allow, prefer, require, verify-ca or verify-full, got %q", c.Postgres.SSLMode)
+	case DriverSQLite:
+		v.check(c.SQLite.Path != "", "sqlite.path: is required")
+	default:
+		v.add("storage.driver: must be mysql, postgres or sqlite, got %q", c.Storage.Driver)
+	}
+
+	v.check(c.RedisConfig.Host != "", "redis.host: is required")
+	v.check(validPort(c.RedisConfig.Port), "redis.port: must be between 1 and 65535, got %q", c.RedisConfig.Port)
+
+	v.check(c.EmailConfig.Port > 0 && c.EmailConfig.Port <= 65535, "smtp.port: must be between 1 and 65535")
+	v.check(c.EmailConfig.Timeout > 0, "smtp.timeout: must be positive")
+	v.check(c.EmailConfig.Host == "" || c.EmailConfig.From != "", "smtp.from: is required when smtp.host is set")
+
+	v.check(c.MQTTConfig.QoS <= 2, "mqtt.qos: must be 0, 1 or 2")
+	if c.MQTTConfig.Broker != "" {
+		broker, err := url.Parse(c.MQTTConfig.Broker)
+		v.check(err == nil && broker.Host != "" && oneOf(broker.Scheme, "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss"),
+			"mqtt.broker: must be a URL such as tcp://host:1883, got %q", c.MQTTConfig.Broker)
+	}
+	v.cidrs("mqtt.auth_allowed_ips", c.MQTTConfig.AuthAllowedIPs)
+
+	if c.OIDCConfig.Issuer != "" || c.OIDCConfig.ClientID != "" {
+		v.check(c.OIDCConfig.Issuer != "", "oidc.issuer: is required when oidc.client_id is set")
+		v.check(c.OIDCConfig.ClientID != "", "oidc.client_id: is required when oidc.issuer is set")
+		v.check(c.OIDCConfig.RedirectURL != "", "oidc.redirect_url: is required when OIDC is enabled")
+		v.check(oneOf(c.OIDCConfig.DefaultRole, "admin", "operator", "viewer"),
+			"oidc.default_role: must be admin, operator or viewer, got %q", c.OIDCConfig.DefaultRole)
+		for value, role := range c.OIDCConfig.RoleMap {
+			v.check(oneOf(role, "admin", "operator", "viewer"), "oidc.role_map: %q maps to unknown role %q", value, role)
+		}
+	}
+
+	v.check(c.ExportConfig.Dir != "", "export.dir: is required")
+	v.check(c.ExportConfig.SyncMaxRows > 0, "export.sync_max_rows: must be positive")
+	v.check(c.ExportConfig.TTL > 0, "export.ttl: must be positive")
+	v.check(c.ExportConfig.Workers > 0, "export.workers: must be positive")
+	v.check(c.ExportConfig.ImportMaxBytes > 0, "export.import_max_bytes: must be positive")
+
+	v.check(oneOf(c.TracingConfig.Exporter, "none", "stdout", "otlp"), "tracing.exporter: must be none, stdout or otlp, got %q", c.TracingConfig.Exporter)
+	if c.TracingConfig.Exporter == "otlp" {
+		endpoint, err := url.Parse(c.TracingConfig.Endpoint)
+		v.check(err == nil && endpoint.Host != "" && oneOf(endpoint.Scheme, "http", "https"),
+			"tracing.endpoint: must be an http(s) URL, got %q", c.TracingConfig.Endpoint)
+	}
+	v.check(c.TracingConfig.SampleRatio >= 0 && c.TracingConfig.SampleRatio <= 1, "tracing.sample_ratio: must be between 0 and 1")
+
+	v.check(c.JWTConfig.AccessSecret != "", "jwt.access_secret: is required")
+	v.check(c.JWTConfig.RefreshSecret != "", "jwt.refresh_secret: is required")
+	v.check(c.JWTConfig.AccessTTL > 0, "jwt.access_ttl: must be positive")
+	v.check(c.JWTConfig.RefreshTTL >= c.JWTConfig.AccessTTL, "jwt.refresh_ttl: must not be shorter than jwt.access_ttl")
+
+	v.check(oneOf(c.Log.Level, "debug", "info", "warn", "error"), "log.level: must be debug, info, warn or error, got %q", c.Log.Level)
+
+	v.check(len(c.CORS.AllowedOrigins) > 0, "cors.allowed_origins: needs at least one origin")
+	for _, origin := range c.CORS.AllowedOrigins {
+		parsed, err := url.Parse(origin)
+		// cookie đăng nhập cần Allow-Credentials, trình duyệt không chấp nhận "*" đi kèm
+		v.check(err == nil && oneOf(parsed.Scheme, "http", "https") && parsed.Host != "" && (parsed.Path == "" || parsed.Path == "/"),
+			"cors.allowed_origins: %q is not an origin such as https://app.example.com", origin)
+	}
+
+	v.check(c.Alerts.TemperatureMin < c.Alerts.TemperatureMax, "alerts.temperature_min: must be below alerts.temperature_max")
+	v.check(c.Alerts.HumidityMin < c.Alerts.HumidityMax, "alerts.humidity_min: must be below alerts.humidity_max")
+	v.check(c.Alerts.LightMin < c.Alerts.LightMax, "alerts.light_min: must be below alerts.light_max")
+
+	v.cidrs("metrics_allowed_ips", c.MetricsAllowedIPs)
+	v.check(c.StartupRetries >= 0, "startup_retries: must not be negative")
+
+	return v.problems
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.add(format, args...)
+	}
+}
+
+// cidrs - cùng định dạng với middlewares.AllowIPs
+func (v *validator) cidrs(key string, items []string) {
+	for _, item := range items {
+		if net.ParseIP(item) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(item); err != nil {
+			v.add("%s: %q is not an IP or CIDR", key, item)
+		}
+	}
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, item := range allowed {
+		if value == item {
+			return true
+		}
+	}
+	return false
+}
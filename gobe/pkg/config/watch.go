@@ -0,0 +1,94 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// reloadable - section đổi được khi đang chạy, các section khác cần restart
+var reloadable = map[string]bool{"log": true, "cors": true, "alerts": true}
+
+// Watch - nạp lại cấu hình khi file đổi (kiểm tra mỗi interval) hoặc khi nhận SIGHUP, tới khi ctx bị hủy.
+// Chỉ log, cors, alerts được áp dụng, onReload nhận cấu hình mới để cập nhật logger/middleware.
+// Cấu hình mới không hợp lệ thì giữ nguyên cấu hình đang chạy.
+func Watch(ctx context.Context, interval time.Duration, onReload func(*Config)) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	source := GetConfig().source
+	lastMod := modTime(source)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			lastMod = modTime(source)
+			reload(onReload)
+		case <-ticker.C:
+			if source == "" {
+				continue
+			}
+			if mod := modTime(source); !mod.Equal(lastMod) {
+				lastMod = mod
+				reload(onReload)
+			}
+		}
+	}
+}
+
+func reload(onReload func(*Config)) {
+	fresh, err := Load()
+	if err != nil {
+		log.Printf("config reload rejected, keeping current settings: %v", err)
+		return
+	}
+	old := GetConfig()
+	next := *old
+	next.Log = fresh.Log
+	next.CORS = fresh.CORS
+	next.Alerts = fresh.Alerts
+	current.Store(&next)
+
+	if pending := restartRequired(old, fresh); len(pending) > 0 {
+		log.Printf("config reloaded, changes to %s need a restart", strings.Join(pending, ", "))
+	} else {
+		log.Print("config reloaded")
+	}
+	onReload(&next)
+}
+
+// restartRequired - section ngoài danh sách reloadable có giá trị khác với cấu hình đang chạy
+func restartRequired(old, fresh *Config) []string {
+	before := values(reflect.ValueOf(old).Elem(), false)
+	after := values(reflect.ValueOf(fresh).Elem(), false)
+	var changed []string
+	for key, value := range after {
+		if !reloadable[key] && !reflect.DeepEqual(before[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func modTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,138 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+type Type int
+
+const (
+	Int64 Type = iota
+	Double
+	String
+	// Timestamp - CSV/NDJSON ghi RFC3339 theo múi giờ được chọn, Parquet ghi mốc UTC (TIMESTAMP_MILLIS)
+	Timestamp
+)
+
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Writer - ghi từng dòng, giá trị theo thứ tự và kiểu của Column: int64, float64, string, time.Time.
+// Close ghi phần còn đệm (footer Parquet) nhưng không đóng io.Writer bên dưới.
+type Writer interface {
+	Write(values []interface{}) error
+	Close() error
+}
+
+// NewWriter - loc là múi giờ in timestamp cho CSV/NDJSON
+func NewWriter(format string, w io.Writer, columns []Column, loc *time.Location) (Writer, error) {
+	switch format {
+	case FormatCSV, "":
+		cw := &csvWriter{w: csv.NewWriter(w), columns: columns, loc: loc}
+		names := make([]string, len(columns))
+		for i, col := range columns {
+			names[i] = col.Name
+		}
+		return cw, cw.w.Write(names)
+	case FormatNDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), columns: columns, loc: loc}, nil
+	case FormatParquet:
+		return newParquetWriter(w, columns), nil
+	}
+	return nil, fmt.Errorf("export: unsupported format %q", format)
+}
+
+// ContentType, Extension - header và đuôi file khi trả về cho client
+func ContentType(format string) string {
+	switch format {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+func Extension(format string) string {
+	if format == "" {
+		return "." + FormatCSV
+	}
+	return "." + format
+}
+
+const timeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []Column
+	loc     *time.Location
+}
+
+func (c *csvWriter) Write(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case time.Time:
+			record[i] = v.In(c.loc).Format(timeLayout)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter - ghi key theo thứ tự cột thay vì thứ tự alphabet của map
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []Column
+	loc     *time.Location
+}
+
+func (n *ndjsonWriter) Write(values []interface{}) error {
+	n.w.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		if t, ok := value.(time.Time); ok {
+			value = t.In(n.loc).Format(timeLayout)
+		}
+		key, _ := json.Marshal(n.columns[i].Name)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		n.w.Write(key)
+		n.w.WriteByte(':')
+		n.w.Write(raw)
+	}
+	n.w.WriteByte('}')
+	return n.w.WriteByte('\n')
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
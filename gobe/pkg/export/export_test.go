@@ -0,0 +1,349 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testColumns = []Column{
+	{Name: "id", Type: Int64},
+	{Name: "created_at", Type: Timestamp},
+	{Name: "temperature", Type: Double},
+	{Name: "user_change", Type: String},
+}
+
+// testRows - chuỗi có dấu phẩy, nháy kép, xuống dòng và tiếng Việt để kiểm tra escape
+func testRows() [][]interface{} {
+	at := time.Date(2025, 10, 1, 20, 30, 15, 123e6, time.UTC)
+	return [][]interface{}{
+		{int64(1), at, 25.5, "alice"},
+		{int64(2), at.Add(time.Hour), -3.25, `bob, "the builder"`},
+		{int64(3), at.Add(4 * time.Hour), 0.0, "dòng 1\ndòng 2"},
+	}
+}
+
+func writeAll(t *testing.T, format string, columns []Column, rows [][]interface{}, loc *time.Location) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf, columns, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	saigon := time.FixedZone("ICT", 7*3600)
+	tests := []struct {
+		name   string
+		format string
+		loc    *time.Location
+		// created_at của 3 dòng theo múi giờ đã chọn
+		times []string
+	}{
+		{"csv utc", FormatCSV, time.UTC, []string{"2025-10-01T20:30:15.123Z", "2025-10-01T21:30:15.123Z", "2025-10-02T00:30:15.123Z"}},
+		{"csv +07:00 crosses midnight", FormatCSV, saigon, []string{"2025-10-02T03:30:15.123+07:00", "2025-10-02T04:30:15.123+07:00", "2025-10-02T07:30:15.123+07:00"}},
+		{"ndjson utc", FormatNDJSON, time.UTC, []string{"2025-10-01T20:30:15.123Z", "2025-10-01T21:30:15.123Z", "2025-10-02T00:30:15.123Z"}},
+		{"ndjson +07:00", FormatNDJSON, saigon, []string{"2025-10-02T03:30:15.123+07:00", "2025-10-02T04:30:15.123+07:00", "2025-10-02T07:30:15.123+07:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := writeAll(t, tt.format, testColumns, testRows(), tt.loc)
+			r, err := NewReader(tt.format, bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, row := range testRows() {
+				got, err := r.Next()
+				if err != nil {
+					t.Fatalf("row %d: %v", i, err)
+				}
+				want := map[string]string{
+					"id":          fmt.Sprint(row[0]),
+					"created_at":  tt.times[i],
+					"temperature": fmt.Sprint(row[2]),
+					"user_change": row[3].(string),
+				}
+				// Reader trim khoảng trắng hai đầu, giá trị test không có nên phải giữ nguyên
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("row %d = %q, want %q", i, got, want)
+				}
+				// cùng một thời điểm dù in theo múi giờ nào
+				parsed, err := time.Parse(time.RFC3339Nano, got["created_at"])
+				if err != nil || !parsed.Equal(row[1].(time.Time)) {
+					t.Errorf("row %d: created_at %q is not %v", i, got["created_at"], row[1])
+				}
+			}
+			if _, err := r.Next(); err != io.EOF {
+				t.Errorf("after last row: %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	saigon := time.FixedZone("ICT", 7*3600)
+	many := make([][]interface{}, rowGroupRows+5)
+	for i := range many {
+		many[i] = []interface{}{int64(i), time.UnixMilli(1_700_000_000_000 + int64(i)).UTC(), float64(i) / 4, fmt.Sprintf("user-%d", i)}
+	}
+	tests := []struct {
+		name   string
+		rows   [][]interface{}
+		loc    *time.Location
+		groups int
+	}{
+		{"small file", testRows(), time.UTC, 1},
+		// Parquet luôn lưu mốc UTC, múi giờ chỉ áp dụng cho CSV/NDJSON
+		{"timezone does not change values", testRows(), saigon, 1},
+		{"split into row groups", many, time.UTC, 2},
+		{"no rows", nil, time.UTC, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := readParquet(t, writeAll(t, FormatParquet, testColumns, tt.rows, tt.loc))
+
+			wantSchema := []schemaColumn{
+				{"id", parquetInt64, -1},
+				{"created_at", parquetInt64, parquetTimestampMillis},
+				{"temperature", parquetDouble, -1},
+				{"user_change", parquetByteArray, parquetUTF8},
+			}
+			if !reflect.DeepEqual(file.columns, wantSchema) {
+				t.Errorf("schema = %+v, want %+v", file.columns, wantSchema)
+			}
+			if file.numRows != int64(len(tt.rows)) || file.groups != tt.groups {
+				t.Errorf("num_rows = %d in %d row groups, want %d in %d", file.numRows, file.groups, len(tt.rows), tt.groups)
+			}
+			if len(file.rows) != len(tt.rows) {
+				t.Fatalf("decoded %d rows, want %d", len(file.rows), len(tt.rows))
+			}
+			for i, row := range tt.rows {
+				want := []interface{}{row[0], row[1].(time.Time).UnixMilli(), row[2], row[3]}
+				if !reflect.DeepEqual(file.rows[i], want) {
+					t.Fatalf("row %d = %v, want %v", i, file.rows[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriterRejectsWrongType(t *testing.T) {
+	w, err := NewWriter(FormatParquet, io.Discard, testColumns, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]interface{}{"1", time.Now(), 1.0, "x"}); err == nil {
+		t.Error("string in int64 column accepted")
+	}
+	if _, err := NewWriter("xlsx", io.Discard, testColumns, time.UTC); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+// Phần dưới đọc lại file Parquet độc lập với writer: decoder Thrift compact chung rồi lấy field theo id
+// trong parquet.thrift, nên lỗi encode của writer không bị che bởi chính code của nó.
+
+type schemaColumn struct {
+	Name          string
+	Type          int64
+	ConvertedType int64 // -1 khi không có
+}
+
+type parquetFile struct {
+	columns []schemaColumn
+	numRows int64
+	groups  int
+	rows    [][]interface{}
+}
+
+func readParquet(t *testing.T, data []byte) *parquetFile {
+	t.Helper()
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatalf("missing PAR1 magic")
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := data[len(data)-8-size : len(data)-8]
+	meta, rest, err := decodeStruct(footer)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decode footer: %v (%d bytes left)", err, len(rest))
+	}
+
+	file := &parquetFile{numRows: meta[3].(int64)}
+	schema := meta[2].([]interface{})
+	if root := schema[0].(map[int16]interface{}); root[5].(int64) != int64(len(schema)-1) {
+		t.Fatalf("root num_children = %v, want %d", root[5], len(schema)-1)
+	}
+	for _, element := range schema[1:] {
+		field := element.(map[int16]interface{})
+		if field[3].(int64) != parquetRequired {
+			t.Errorf("column %s is not REQUIRED", field[4])
+		}
+		converted := int64(-1)
+		if v, ok := field[6]; ok {
+			converted = v.(int64)
+		}
+		file.columns = append(file.columns, schemaColumn{string(field[4].([]byte)), field[1].(int64), converted})
+	}
+
+	groups, _ := meta[4].([]interface{})
+	file.groups = len(groups)
+	for _, g := range groups {
+		group := g.(map[int16]interface{})
+		rows := int(group[3].(int64))
+		values := make([][]interface{}, rows)
+		for i := range values {
+			values[i] = make([]interface{}, len(file.columns))
+		}
+		for c, chunk := range group[1].([]interface{}) {
+			columnMeta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+			offset := columnMeta[9].(int64)
+			header, page, err := decodeStruct(data[offset:])
+			if err != nil {
+				t.Fatalf("decode page header: %v", err)
+			}
+			if header[1].(int64) != parquetDataPage || header[5].(map[int16]interface{})[1].(int64) != int64(rows) {
+				t.Fatalf("column %d: unexpected page header %v", c, header)
+			}
+			page = page[:header[3].(int64)]
+			for r := 0; r < rows; r++ {
+				switch file.columns[c].Type {
+				case parquetInt64:
+					values[r][c] = int64(binary.LittleEndian.Uint64(page))
+					page = page[8:]
+				case parquetDouble:
+					values[r][c] = math.Float64frombits(binary.LittleEndian.Uint64(page))
+					page = page[8:]
+				default:
+					n := binary.LittleEndian.Uint32(page)
+					values[r][c] = string(page[4 : 4+n])
+					page = page[4+n:]
+				}
+			}
+			if len(page) != 0 {
+				t.Fatalf("column %d: %d bytes left in page", c, len(page))
+			}
+		}
+		file.rows = append(file.rows, values...)
+	}
+	return file
+}
+
+var errTruncated = errors.New("truncated thrift data")
+
+// decodeStruct - struct Thrift compact thành map field id -> giá trị: số nguyên là int64,
+// binary là []byte, list là []interface{}, struct lồng là map
+func decodeStruct(data []byte) (map[int16]interface{}, []byte, error) {
+	fields := map[int16]interface{}{}
+	var last int16
+	for {
+		if len(data) == 0 {
+			return nil, nil, errTruncated
+		}
+		header := data[0]
+		data = data[1:]
+		if header == 0 {
+			return fields, data, nil
+		}
+		kind := header & 0x0f
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			v, n := binary.Varint(data)
+			if n <= 0 {
+				return nil, nil, errTruncated
+			}
+			id, data = int16(v), data[n:]
+		}
+		last = id
+		var value interface{}
+		var err error
+		switch kind {
+		case 1, 2: // bool nằm luôn trong kiểu field
+			value = kind == 1
+		default:
+			value, data, err = decodeValue(kind, data)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		fields[id] = value
+	}
+}
+
+func decodeValue(kind byte, data []byte) (interface{}, []byte, error) {
+	switch kind {
+	case 3:
+		if len(data) == 0 {
+			return nil, nil, errTruncated
+		}
+		return int64(int8(data[0])), data[1:], nil
+	case 4, 5, 6:
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, nil, errTruncated
+		}
+		return v, data[n:], nil
+	case 7:
+		if len(data) < 8 {
+			return nil, nil, errTruncated
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), data[8:], nil
+	case 8:
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			return nil, nil, errTruncated
+		}
+		return data[n : n+int(size)], data[n+int(size):], nil
+	case 9, 10:
+		if len(data) == 0 {
+			return nil, nil, errTruncated
+		}
+		size, element := int(data[0]>>4), data[0]&0x0f
+		data = data[1:]
+		if size == 15 {
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, nil, errTruncated
+			}
+			size, data = int(v), data[n:]
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			var err error
+			if list[i], data, err = decodeValue(element, data); err != nil {
+				return nil, nil, err
+			}
+		}
+		return list, data, nil
+	case 12:
+		return decodeStruct(data)
+	}
+	return nil, nil, fmt.Errorf("unsupported thrift type %d", kind)
+}
+
+func TestContentTypeAndExtension(t *testing.T) {
+	for format, want := range map[string]string{FormatCSV: "text/csv", FormatNDJSON: "application/x-ndjson", FormatParquet: "application/vnd.apache.parquet", "": "text/csv"} {
+		if got := ContentType(format); !strings.HasPrefix(got, want) {
+			t.Errorf("ContentType(%q) = %q, want %q", format, got, want)
+		}
+	}
+	if got := Extension(""); got != ".csv" {
+		t.Errorf("Extension(\"\") = %q, want .csv", got)
+	}
+}
@@ -0,0 +1,217 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// rowGroupRows - số dòng đệm trong bộ nhớ trước khi ghi một row group, giới hạn RAM khi xuất bảng lớn
+const rowGroupRows = 10000
+
+// Mã enum trong parquet.thrift
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired        = 0
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+	parquetPlain           = 0
+	parquetRLE             = 3
+	parquetUncompressed    = 0
+	parquetDataPage        = 0
+)
+
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+type rowGroup struct {
+	chunks []columnChunk
+	rows   int64
+	size   int64
+}
+
+// parquetWriter - Parquet tối giản: mọi cột REQUIRED, mã hóa PLAIN, không nén, mỗi cột một data page
+// trên một row group. Ghi tuần tự nên stream thẳng ra HTTP response được.
+type parquetWriter struct {
+	w       io.Writer
+	offset  int64
+	columns []Column
+	pages   []bytes.Buffer
+	rows    int
+	total   int64
+	groups  []rowGroup
+}
+
+func newParquetWriter(w io.Writer, columns []Column) *parquetWriter {
+	return &parquetWriter{w: w, columns: columns, pages: make([]bytes.Buffer, len(columns))}
+}
+
+func (p *parquetWriter) write(data []byte) error {
+	n, err := p.w.Write(data)
+	p.offset += int64(n)
+	return err
+}
+
+func (p *parquetWriter) Write(values []interface{}) error {
+	if p.offset == 0 {
+		if err := p.write([]byte("PAR1")); err != nil {
+			return err
+		}
+	}
+	var tmp [8]byte
+	for i, value := range values {
+		page := &p.pages[i]
+		switch p.columns[i].Type {
+		case Int64:
+			v, ok := value.(int64)
+			if !ok {
+				return fmt.Errorf("export: column %s expects int64, got %T", p.columns[i].Name, value)
+			}
+			binary.LittleEndian.PutUint64(tmp[:], uint64(v))
+			page.Write(tmp[:])
+		case Double:
+			v, ok := value.(float64)
+			if !ok {
+				return fmt.Errorf("export: column %s expects float64, got %T", p.columns[i].Name, value)
+			}
+			binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+			page.Write(tmp[:])
+		case Timestamp:
+			v, ok := value.(time.Time)
+			if !ok {
+				return fmt.Errorf("export: column %s expects time.Time, got %T", p.columns[i].Name, value)
+			}
+			binary.LittleEndian.PutUint64(tmp[:], uint64(v.UnixMilli()))
+			page.Write(tmp[:])
+		default:
+			v := fmt.Sprint(value)
+			binary.LittleEndian.PutUint32(tmp[:4], uint32(len(v)))
+			page.Write(tmp[:4])
+			page.WriteString(v)
+		}
+	}
+	p.rows++
+	if p.rows == rowGroupRows {
+		return p.flushGroup()
+	}
+	return nil
+}
+
+func (p *parquetWriter) flushGroup() error {
+	group := rowGroup{rows: int64(p.rows)}
+	for i := range p.pages {
+		page := &p.pages[i]
+		header := &thriftWriter{}
+		header.beginStruct()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(page.Len()))
+		header.structField(5, func() {
+			header.i32(1, int32(p.rows))
+			header.i32(2, parquetPlain)
+			header.i32(3, parquetRLE)
+			header.i32(4, parquetRLE)
+		})
+		header.endStruct()
+
+		chunk := columnChunk{offset: p.offset, size: int64(header.buf.Len() + page.Len())}
+		if err := p.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := p.write(page.Bytes()); err != nil {
+			return err
+		}
+		page.Reset()
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+	}
+	p.groups = append(p.groups, group)
+	p.total += group.rows
+	p.rows = 0
+	return nil
+}
+
+// Close - ghi row group cuối và footer FileMetaData
+func (p *parquetWriter) Close() error {
+	if p.offset == 0 {
+		if err := p.write([]byte("PAR1")); err != nil {
+			return err
+		}
+	}
+	if p.rows > 0 {
+		if err := p.flushGroup(); err != nil {
+			return err
+		}
+	}
+
+	meta := &thriftWriter{}
+	meta.beginStruct()
+	meta.i32(1, 1)
+	meta.structList(2, len(p.columns)+1, func(i int) {
+		if i == 0 {
+			meta.str(4, "schema")
+			meta.i32(5, int32(len(p.columns)))
+			return
+		}
+		col := p.columns[i-1]
+		meta.i32(1, physicalType(col.Type))
+		meta.i32(3, parquetRequired)
+		meta.str(4, col.Name)
+		switch col.Type {
+		case String:
+			meta.i32(6, parquetUTF8)
+		case Timestamp:
+			meta.i32(6, parquetTimestampMillis)
+		}
+	})
+	meta.i64(3, p.total)
+	meta.structList(4, len(p.groups), func(g int) {
+		group := p.groups[g]
+		meta.structList(1, len(group.chunks), func(i int) {
+			chunk := group.chunks[i]
+			meta.i64(2, chunk.offset)
+			meta.structField(3, func() {
+				meta.i32(1, physicalType(p.columns[i].Type))
+				meta.i32List(2, parquetPlain, parquetRLE)
+				meta.strList(3, p.columns[i].Name)
+				meta.i32(4, parquetUncompressed)
+				meta.i64(5, group.rows)
+				meta.i64(6, chunk.size)
+				meta.i64(7, chunk.size)
+				meta.i64(9, chunk.offset)
+			})
+		})
+		meta.i64(2, group.size)
+		meta.i64(3, group.rows)
+	})
+	meta.str(6, "iot export")
+	meta.endStruct()
+
+	if err := p.write(meta.buf.Bytes()); err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(meta.buf.Len()))
+	if err := p.write(size[:]); err != nil {
+		return err
+	}
+	return p.write([]byte("PAR1"))
+}
+
+func physicalType(t Type) int32 {
+	switch t {
+	case Int64, Timestamp:
+		return parquetInt64
+	case Double:
+		return parquetDouble
+	}
+	return parquetByteArray
+}
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxLineBytes - dòng NDJSON dài hơn bị coi là file hỏng
+const maxLineBytes = 1 << 20
+
+// RowError - một dòng không đọc được, Reader vẫn đọc tiếp được các dòng sau
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Reader - chiều ngược lại của Writer, dùng cho import. Next trả giá trị dạng chuỗi theo tên cột
+// (CSV lấy từ dòng header), io.EOF khi hết file, *RowError khi riêng dòng đó hỏng.
+type Reader interface {
+	Next() (map[string]string, error)
+	// Line - số dòng trong file của bản ghi vừa đọc
+	Line() int
+}
+
+// NewReader - Parquet chỉ hỗ trợ ghi
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV, "":
+		cr := csv.NewReader(r)
+		cr.ReuseRecord = true
+		return &csvReader{r: cr}, nil
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64<<10), maxLineBytes)
+		return &ndjsonReader{s: scanner}, nil
+	}
+	return nil, fmt.Errorf("export: unsupported import format %q", format)
+}
+
+type csvReader struct {
+	r      *csv.Reader
+	header []string
+	line   int
+}
+
+func (c *csvReader) Next() (map[string]string, error) {
+	if c.header == nil {
+		header, err := c.r.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return nil, fmt.Errorf("export: read csv header: %w", err)
+		}
+		c.header = make([]string, len(header))
+		for i, name := range header {
+			// Excel hay ghi BOM ở đầu file
+			c.header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		}
+	}
+	record, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			c.line = parseErr.StartLine
+			return nil, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		return nil, err
+	}
+	c.line, _ = c.r.FieldPos(0)
+	values := make(map[string]string, len(c.header))
+	for i, name := range c.header {
+		values[name] = strings.TrimSpace(record[i])
+	}
+	return values, nil
+}
+
+func (c *csvReader) Line() int {
+	return c.line
+}
+
+type ndjsonReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (n *ndjsonReader) Next() (map[string]string, error) {
+	for n.s.Scan() {
+		n.line++
+		raw := bytes.TrimSpace(n.s.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			return nil, &RowError{Line: n.line, Err: err}
+		}
+		values := make(map[string]string, len(object))
+		for key, value := range object {
+			switch v := value.(type) {
+			case nil:
+				values[strings.ToLower(key)] = ""
+			case string:
+				values[strings.ToLower(key)] = strings.TrimSpace(v)
+			case json.Number, bool:
+				values[strings.ToLower(key)] = fmt.Sprint(v)
+			default:
+				return nil, &RowError{Line: n.line, Err: fmt.Errorf("field %q must be a scalar", key)}
+			}
+		}
+		return values, nil
+	}
+	if err := n.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (n *ndjsonReader) Line() int {
+	return n.line
+}
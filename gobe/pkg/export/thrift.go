@@ -0,0 +1,110 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Kiểu field của Thrift compact protocol, chỉ những kiểu metadata Parquet cần
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter - encoder Thrift compact protocol tối giản để ghi PageHeader và FileMetaData của Parquet
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // field id cuối của từng struct đang mở, field header ghi theo delta
+}
+
+func (t *thriftWriter) uvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	t.buf.Write(tmp[:n])
+}
+
+func (t *thriftWriter) varint(v int64) {
+	t.uvarint(uint64((v << 1) ^ (v >> 63))) // zigzag
+}
+
+func (t *thriftWriter) field(id int16, kind byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.buf.WriteByte(kind)
+		t.varint(int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) beginStruct() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0) // stop
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) str(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.uvarint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) listHeader(id int16, kind byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | kind)
+	} else {
+		t.buf.WriteByte(0xF0 | kind)
+		t.uvarint(uint64(size))
+	}
+}
+
+// structField - field kiểu struct, body ghi các field con
+func (t *thriftWriter) structField(id int16, body func()) {
+	t.field(id, thriftStruct)
+	t.beginStruct()
+	body()
+	t.endStruct()
+}
+
+// structList - list<struct> với n phần tử, body(i) ghi field của phần tử i
+func (t *thriftWriter) structList(id int16, n int, body func(i int)) {
+	t.listHeader(id, thriftStruct, n)
+	for i := 0; i < n; i++ {
+		t.beginStruct()
+		body(i)
+		t.endStruct()
+	}
+}
+
+func (t *thriftWriter) i32List(id int16, values ...int32) {
+	t.listHeader(id, thriftI32, len(values))
+	for _, v := range values {
+		t.varint(int64(v))
+	}
+}
+
+func (t *thriftWriter) strList(id int16, values ...string) {
+	t.listHeader(id, thriftBinary, len(values))
+	for _, v := range values {
+		t.uvarint(uint64(len(v)))
+		t.buf.WriteString(v)
+	}
+}
@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testSchema = &Schema{
+	Fields: map[string]Field{
+		"id":          {Column: "sensor_data.id", Kind: KindInteger},
+		"temperature": {Column: "sensor_data.temperature", Kind: KindNumber, Units: []string{"°C", "C"}},
+		"humidity":    {Column: "sensor_data.humidity", Kind: KindNumber, Units: []string{"%"}},
+		"light":       {Column: "sensor_data.light", Kind: KindInteger, Units: []string{"lux"}},
+		"status":      {Column: "device_histories.status", Kind: KindEnum, Values: []string{"ON", "OFF"}},
+		"user":        {Column: "device_histories.user_change", Kind: KindString},
+		"time":        {Column: "sensor_data.created_at", Kind: KindTime},
+	},
+	Time: "time",
+	Text: []string{"user"},
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		sql  string
+		args []interface{}
+	}{
+		{"compare", "temperature>30", "sensor_data.temperature > ?", []interface{}{30.0}},
+		{"normalized operator", "light<>100", "sensor_data.light != ?", []interface{}{int64(100)}},
+		{"unit suffix", "temperature>=28.9°C", "sensor_data.temperature >= ?", []interface{}{28.9}},
+		{"and binds tighter than or", "temperature>30 or humidity<40 and light=1",
+			"(sensor_data.temperature > ? OR (sensor_data.humidity < ? AND sensor_data.light = ?))", []interface{}{30.0, 40.0, int64(1)}},
+		{"parentheses", "(temperature>30 or humidity<40) and light=1",
+			"((sensor_data.temperature > ? OR sensor_data.humidity < ?) AND sensor_data.light = ?)", []interface{}{30.0, 40.0, int64(1)}},
+		{"not", "not status=on", "NOT device_histories.status = ?", []interface{}{"ON"}},
+		{"between with range", "humidity between 60..80", "sensor_data.humidity BETWEEN ? AND ?", []interface{}{60.0, 80.0}},
+		{"between with and", "humidity between 60 and 80", "sensor_data.humidity BETWEEN ? AND ?", []interface{}{60.0, 80.0}},
+		{"in list", "status in (on, OFF)", "device_histories.status IN ?", []interface{}{[]interface{}{"ON", "OFF"}}},
+		{"contains escapes like wildcards", "user contains '50%_a!'",
+			"LOWER(device_histories.user_change) LIKE LOWER(?) ESCAPE '!'", []interface{}{"%50!%!_a!!%"}},
+		{"quoted keyword value", `user="and"`, "device_histories.user_change = ?", []interface{}{"and"}},
+		{"field names are case insensitive", "Temperature=20", "sensor_data.temperature = ?", []interface{}{20.0}},
+		{"time equal is the whole day", "time=2025-10-01",
+			"(sensor_data.created_at >= ? AND sensor_data.created_at < ?)", []interface{}{day(2025, 10, 1), day(2025, 10, 2)}},
+		{"time after excludes the day", "time>2025-10-01", "sensor_data.created_at >= ?", []interface{}{day(2025, 10, 2)}},
+		{"time up to includes the month", "time<=2025-10", "sensor_data.created_at < ?", []interface{}{day(2025, 11, 1)}},
+		{"time between", "time between 2025-10-01..2025-10-03",
+			"(sensor_data.created_at >= ? AND sensor_data.created_at < ?)", []interface{}{day(2025, 10, 1), day(2025, 10, 4)}},
+		{"bare id", "#12", "sensor_data.id = ?", []interface{}{int64(12)}},
+		{"bare value with unit", "3779 lux", "sensor_data.light = ?", []interface{}{int64(3779)}},
+		{"bare date", "2025-10-01",
+			"(sensor_data.created_at >= ? AND sensor_data.created_at < ?)", []interface{}{day(2025, 10, 1), day(2025, 10, 2)}},
+		{"bare word searches text fields", "alice",
+			"(LOWER(device_histories.user_change) LIKE LOWER(?) ESCAPE '!')", []interface{}{"%alice%"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			sql, args, err := testSchema.Compile(node)
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", tt.expr, err)
+			}
+			if sql != tt.sql {
+				t.Errorf("sql = %q, want %q", sql, tt.sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %#v, want %#v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		pos   int
+		token string
+	}{
+		{"unterminated string", `user="abc`, 6, `"abc`},
+		{"unknown operator", "temperature!30", 12, "!"},
+		{"missing value", "temperature>", 13, ""},
+		{"missing connective", "temperature>30 humidity<40", 16, "humidity"},
+		{"unit not glued to next field", "75 humidity<40", 4, "humidity"},
+		{"unclosed parenthesis", "(temperature>30", 1, "("},
+		{"unexpected closing", "temperature>30)", 15, ")"},
+		{"between without range", "humidity between 60 80", 21, "80"},
+		{"in without parenthesis", "status in on", 11, "on"},
+		{"unknown field", "pressure>1", 1, "pressure"},
+		{"operator not allowed", "status>on", 7, ">"},
+		{"not a number", "temperature>hot", 13, "hot"},
+		{"enum value", "status=maybe", 8, "maybe"},
+		{"bad date", "time>yesterday", 6, "yesterday"},
+		{"bare id on integer", "#abc", 1, "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := Parse(tt.expr)
+			if err == nil {
+				_, _, err = testSchema.Compile(node)
+			}
+			var filterErr *Error
+			if !errors.As(err, &filterErr) {
+				t.Fatalf("%q: err = %v, want *Error", tt.expr, err)
+			}
+			if filterErr.Pos != tt.pos || filterErr.Token != tt.token {
+				t.Errorf("%q: error at %d near %q, want %d near %q (%v)", tt.expr, filterErr.Pos, filterErr.Token, tt.pos, tt.token, err)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	long := make([]byte, MaxLength+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+	terms := "id=1"
+	for i := 0; i < maxTerms; i++ {
+		terms += " or id=1"
+	}
+	list := "id in (1"
+	for i := 0; i < maxInList; i++ {
+		list += ",1"
+	}
+	list += ")"
+
+	for name, expr := range map[string]string{"length": string(long), "terms": terms, "in list": list} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(expr); err == nil {
+				t.Fatalf("Parse succeeded, want limit error")
+			}
+		})
+	}
+}
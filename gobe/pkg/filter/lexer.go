@@ -0,0 +1,127 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokRange
+	tokLParen
+	tokRParen
+	tokComma
+	tokAnd
+	tokOr
+	tokNot
+	tokBetween
+	tokIn
+	tokContains
+)
+
+// Token - Pos tính theo ký tự, bắt đầu từ 1, để báo lỗi chỉ đúng chỗ trong chuỗi người dùng nhập
+type Token struct {
+	kind tokenKind
+	Text string
+	Pos  int
+}
+
+var keywords = map[string]tokenKind{
+	"and":      tokAnd,
+	"or":       tokOr,
+	"not":      tokNot,
+	"between":  tokBetween,
+	"in":       tokIn,
+	"contains": tokContains,
+}
+
+// operators - dài trước ngắn sau để ">=" không bị đọc thành ">"
+var operators = []string{">=", "<=", "!=", "<>", "==", ">", "<", "=", "~"}
+
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()=!<>~,"'`, r)
+}
+
+func lex(input string) ([]Token, error) {
+	runes := []rune(input)
+	var tokens []Token
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, Token{kind: tokLParen, Text: "(", Pos: pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, Token{kind: tokRParen, Text: ")", Pos: pos})
+			i++
+		case r == ',':
+			tokens = append(tokens, Token{kind: tokComma, Text: ",", Pos: pos})
+			i++
+		case r == '.' && i+1 < len(runes) && runes[i+1] == '.':
+			tokens = append(tokens, Token{kind: tokRange, Text: "..", Pos: pos})
+			i += 2
+		case r == '"' || r == '\'':
+			text, next, ok := readQuoted(runes, i)
+			if !ok {
+				return nil, &Error{Pos: pos, Token: string(runes[i:]), Message: "unterminated string"}
+			}
+			tokens = append(tokens, Token{kind: tokString, Text: text, Pos: pos})
+			i = next
+		case strings.ContainsRune("=!<>~", r):
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &Error{Pos: pos, Token: string(r), Message: "unknown operator"}
+			}
+			tokens = append(tokens, Token{kind: tokOp, Text: op, Pos: pos})
+			i += len([]rune(op))
+		default:
+			start := i
+			// ".." kết thúc một từ để "60..80" tách được thành hai giá trị
+			for i < len(runes) && isWordRune(runes[i]) && !(runes[i] == '.' && i+1 < len(runes) && runes[i+1] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			kind := tokWord
+			if keyword, ok := keywords[strings.ToLower(text)]; ok {
+				kind = keyword
+			}
+			tokens = append(tokens, Token{kind: kind, Text: text, Pos: pos})
+		}
+	}
+	return append(tokens, Token{kind: tokEOF, Pos: len(runes) + 1}), nil
+}
+
+// readQuoted - chuỗi trong nháy đơn/kép, \ để escape ký tự kế tiếp
+func readQuoted(runes []rune, start int) (string, int, bool) {
+	quote := runes[start]
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				b.WriteRune(runes[i])
+			}
+		case quote:
+			return b.String(), i + 1, true
+		default:
+			b.WriteRune(runes[i])
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,301 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxLength, maxTerms - chặn biểu thức quá dài sinh ra câu SQL khổng lồ
+	MaxLength = 500
+	maxTerms  = 32
+	maxInList = 50
+)
+
+// Error - lỗi cú pháp hoặc ngữ nghĩa, Pos/Token chỉ vào chỗ sai trong biểu thức
+type Error struct {
+	Pos     int    `json:"position"`
+	Token   string `json:"token,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("filter: %s at position %d", e.Message, e.Pos)
+	}
+	return fmt.Sprintf("filter: %s at position %d near %q", e.Message, e.Pos, e.Token)
+}
+
+func errorAt(tok Token, format string, args ...interface{}) *Error {
+	text := tok.Text
+	if tok.kind == tokEOF {
+		text = ""
+	}
+	return &Error{Pos: tok.Pos, Token: text, Message: fmt.Sprintf(format, args...)}
+}
+
+// Node - AST của biểu thức: Logical, Not, Compare hoặc Bare
+type Node interface {
+	node()
+}
+
+// Logical - Op là "and" hoặc "or"
+type Logical struct {
+	Op          string
+	Left, Right Node
+}
+
+type Not struct {
+	Expr Node
+}
+
+// Compare - Op là một trong = != > >= < <= ~ between in; between có đúng 2 giá trị
+type Compare struct {
+	Field  Token
+	Op     Token
+	Values []Token
+}
+
+// Bare - giá trị đứng một mình không kèm field ("28.9°C", "#12", "2025-10-01"), Schema tự suy ra field
+type Bare struct {
+	Value Token
+}
+
+func (*Logical) node() {}
+func (*Not) node()     {}
+func (*Compare) node() {}
+func (*Bare) node()    {}
+
+type parser struct {
+	tokens []Token
+	pos    int
+	terms  int
+}
+
+// Parse - cú pháp:
+//
+//	expr    = and { "or" and }
+//	and     = unary { "and" unary }
+//	unary   = "not" unary | "(" expr ")" | term
+//	term    = field op value | field "between" value (".." | "and") value
+//	        | field "in" "(" value { "," value } ")" | field "contains" value | value
+func Parse(input string) (Node, error) {
+	if len([]rune(input)) > MaxLength {
+		return nil, &Error{Pos: MaxLength + 1, Message: fmt.Sprintf("expression is longer than %d characters", MaxLength)}
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		if startsTerm(tok) {
+			return nil, errorAt(tok, "expected 'and' or 'or' before this condition")
+		}
+		return nil, errorAt(tok, "unexpected token")
+	}
+	return node, nil
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() Token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func startsTerm(tok Token) bool {
+	switch tok.kind {
+	case tokWord, tokString, tokLParen, tokNot:
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokNot:
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: expr}, nil
+	case tokLParen:
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			if closing.kind == tokEOF {
+				return nil, errorAt(tok, "unclosed '('")
+			}
+			return nil, errorAt(closing, "expected ')'")
+		}
+		return expr, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *parser) parseTerm() (Node, error) {
+	first := p.next()
+	if first.kind != tokWord && first.kind != tokString {
+		return nil, errorAt(first, "expected a condition such as temperature>30")
+	}
+	p.terms++
+	if p.terms > maxTerms {
+		return nil, errorAt(first, "too many conditions, at most %d are allowed", maxTerms)
+	}
+
+	op := p.peek()
+	if first.kind == tokString || !isCompareOp(op) {
+		return &Bare{Value: p.withUnit(first)}, nil
+	}
+	p.next()
+	cmp := &Compare{Field: first, Op: op}
+
+	switch op.kind {
+	case tokOp:
+		cmp.Op.Text = normalizeOp(op.Text)
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		cmp.Values = []Token{value}
+	case tokContains:
+		cmp.Op.Text = "~"
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		cmp.Values = []Token{value}
+	case tokBetween:
+		cmp.Op.Text = "between"
+		low, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if sep := p.next(); sep.kind != tokRange && sep.kind != tokAnd {
+			return nil, errorAt(sep, "expected '..' between the two bounds")
+		}
+		high, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		cmp.Values = []Token{low, high}
+	case tokIn:
+		cmp.Op.Text = "in"
+		if open := p.next(); open.kind != tokLParen {
+			return nil, errorAt(open, "expected '(' after in")
+		}
+		for {
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			cmp.Values = append(cmp.Values, value)
+			if len(cmp.Values) > maxInList {
+				return nil, errorAt(value, "too many values, at most %d are allowed", maxInList)
+			}
+			sep := p.next()
+			if sep.kind == tokRParen {
+				break
+			}
+			if sep.kind != tokComma {
+				return nil, errorAt(sep, "expected ',' or ')' in value list")
+			}
+		}
+	}
+	return cmp, nil
+}
+
+// value - từ khóa dùng làm giá trị phải đặt trong nháy, ví dụ user="and"
+func (p *parser) value() (Token, error) {
+	tok := p.next()
+	if tok.kind != tokWord && tok.kind != tokString {
+		return tok, errorAt(tok, "expected a value")
+	}
+	return p.withUnit(tok), nil
+}
+
+func isCompareOp(tok Token) bool {
+	switch tok.kind {
+	case tokOp, tokBetween, tokIn, tokContains:
+		return true
+	}
+	return false
+}
+
+// withUnit - ghép số với đơn vị viết tách ("3779 lux", "28.9 °C") thành một giá trị,
+// trừ khi từ phía sau là tên field của điều kiện kế tiếp (đứng trước một toán tử)
+func (p *parser) withUnit(tok Token) Token {
+	unit := p.peek()
+	if tok.kind != tokWord || unit.kind != tokWord || !isNumeric(tok.Text) || strings.ContainsAny(unit.Text, "0123456789") {
+		return tok
+	}
+	if isCompareOp(p.tokens[p.pos+1]) {
+		return tok
+	}
+	p.next()
+	tok.Text += unit.Text
+	return tok
+}
+
+func isNumeric(text string) bool {
+	_, err := strconv.ParseFloat(text, 64)
+	return err == nil
+}
+
+func normalizeOp(op string) string {
+	switch op {
+	case "==":
+		return "="
+	case "<>":
+		return "!="
+	}
+	return op
+}
@@ -0,0 +1,280 @@
+package filter
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Kind int
+
+const (
+	KindNumber Kind = iota
+	KindInteger
+	KindString
+	KindEnum
+	KindTime
+)
+
+// Field - field được phép lọc. Column do code khai báo nên là thứ duy nhất ghép thẳng vào SQL,
+// mọi giá trị người dùng nhập đều đi qua tham số "?"
+type Field struct {
+	Column string
+	Kind   Kind
+	// Units - hậu tố được bỏ đi khi đọc số ("28.9°C", "75%"), cũng dùng để đoán field cho giá trị đứng một mình
+	Units []string
+	// Values - giá trị hợp lệ của KindEnum, so khớp không phân biệt hoa thường
+	Values []string
+}
+
+// Schema - whitelist field của một bảng
+type Schema struct {
+	Fields map[string]Field
+	// Time - field dùng cho ngày giờ đứng một mình, ví dụ "2025-10-01"
+	Time string
+	// Text - field so khớp chứa cho từ đứng một mình, rỗng thì từ đứng một mình là lỗi
+	Text []string
+	// Location - múi giờ của giá trị ngày giờ không ghi offset
+	Location *time.Location
+}
+
+// Where - parse expr rồi thêm điều kiện vào db, expr rỗng thì trả db nguyên vẹn
+func (s *Schema) Where(db *gorm.DB, expr string) (*gorm.DB, error) {
+	if strings.TrimSpace(expr) == "" {
+		return db, nil
+	}
+	node, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	sql, args, err := s.Compile(node)
+	if err != nil {
+		return nil, err
+	}
+	return db.Where(sql, args...), nil
+}
+
+// Compile - AST thành SQL có tham số, field không có trong whitelist trả lỗi chỉ vào tên field
+func (s *Schema) Compile(node Node) (string, []interface{}, error) {
+	switch n := node.(type) {
+	case *Logical:
+		left, leftArgs, err := s.Compile(n.Left)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := s.Compile(n.Right)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + left + " " + strings.ToUpper(n.Op) + " " + right + ")", append(leftArgs, rightArgs...), nil
+	case *Not:
+		inner, args, err := s.Compile(n.Expr)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT " + inner, args, nil
+	case *Compare:
+		field, ok := s.Fields[strings.ToLower(n.Field.Text)]
+		if !ok {
+			return "", nil, errorAt(n.Field, "unknown field, allowed fields are %s", strings.Join(s.fieldNames(), ", "))
+		}
+		return s.compare(field, n)
+	case *Bare:
+		return s.bare(n.Value)
+	}
+	return "", nil, &Error{Message: "unsupported expression"}
+}
+
+func (s *Schema) fieldNames() []string {
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var allowedOps = map[Kind]map[string]bool{
+	KindNumber:  {"=": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true, "between": true, "in": true},
+	KindInteger: {"=": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true, "between": true, "in": true},
+	KindString:  {"=": true, "!=": true, "~": true, "in": true},
+	KindEnum:    {"=": true, "!=": true, "in": true},
+	KindTime:    {"=": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true, "between": true},
+}
+
+func (s *Schema) compare(field Field, cmp *Compare) (string, []interface{}, error) {
+	op := cmp.Op.Text
+	if !allowedOps[field.Kind][op] {
+		return "", nil, errorAt(cmp.Op, "operator %s is not supported for field %s", op, cmp.Field.Text)
+	}
+	if field.Kind == KindTime {
+		return s.compareTime(field, cmp)
+	}
+
+	args := make([]interface{}, 0, len(cmp.Values))
+	for _, tok := range cmp.Values {
+		value, err := s.convert(field, tok)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, value)
+	}
+	switch op {
+	case "between":
+		return field.Column + " BETWEEN ? AND ?", args, nil
+	case "in":
+		return field.Column + " IN ?", []interface{}{args}, nil
+	case "~":
+		return containsExpr(field.Column), []interface{}{"%" + escapeLike(args[0].(string)) + "%"}, nil
+	}
+	return field.Column + " " + op + " ?", args, nil
+}
+
+// compareTime - giá trị ngày giờ là một khoảng theo độ chính xác đã nhập:
+// time=2025-10-01 là cả ngày, time>2025-10-01 là sau ngày đó, time<=2025-10-01 là hết ngày đó
+func (s *Schema) compareTime(field Field, cmp *Compare) (string, []interface{}, error) {
+	start, end, err := s.parseTime(cmp.Values[0])
+	if err != nil {
+		return "", nil, err
+	}
+	col := field.Column
+	switch cmp.Op.Text {
+	case "=":
+		return "(" + col + " >= ? AND " + col + " < ?)", []interface{}{start, end}, nil
+	case "!=":
+		return "(" + col + " < ? OR " + col + " >= ?)", []interface{}{start, end}, nil
+	case ">":
+		return col + " >= ?", []interface{}{end}, nil
+	case ">=":
+		return col + " >= ?", []interface{}{start}, nil
+	case "<":
+		return col + " < ?", []interface{}{start}, nil
+	case "<=":
+		return col + " < ?", []interface{}{end}, nil
+	}
+	_, upper, err := s.parseTime(cmp.Values[1])
+	if err != nil {
+		return "", nil, err
+	}
+	return "(" + col + " >= ? AND " + col + " < ?)", []interface{}{start, upper}, nil
+}
+
+// bare - "#12" là id, số có đơn vị là field khai báo đơn vị đó, ngày giờ là Schema.Time, còn lại tìm trong Schema.Text
+func (s *Schema) bare(tok Token) (string, []interface{}, error) {
+	text := strings.TrimSpace(tok.Text)
+	if id, ok := strings.CutPrefix(text, "#"); ok {
+		field, exists := s.Fields["id"]
+		if !exists {
+			return "", nil, errorAt(tok, "this table has no id field")
+		}
+		value, err := s.convert(field, Token{kind: tok.kind, Text: id, Pos: tok.Pos})
+		if err != nil {
+			return "", nil, err
+		}
+		return field.Column + " = ?", []interface{}{value}, nil
+	}
+	if tok.kind == tokWord {
+		for _, name := range s.fieldNames() {
+			field := s.Fields[name]
+			if _, ok := trimUnit(text, field.Units); ok {
+				return s.compare(field, &Compare{Field: Token{Text: name, Pos: tok.Pos}, Op: Token{Text: "=", Pos: tok.Pos}, Values: []Token{tok}})
+			}
+		}
+	}
+	if s.Time != "" {
+		if _, _, err := s.parseTime(tok); err == nil {
+			return s.compare(s.Fields[s.Time], &Compare{Field: Token{Text: s.Time, Pos: tok.Pos}, Op: Token{Text: "=", Pos: tok.Pos}, Values: []Token{tok}})
+		}
+	}
+	if len(s.Text) == 0 {
+		return "", nil, errorAt(tok, "expected a field name, for example %s=%s", s.fieldNames()[0], text)
+	}
+	parts := make([]string, 0, len(s.Text))
+	args := make([]interface{}, 0, len(s.Text))
+	for _, name := range s.Text {
+		parts = append(parts, containsExpr(s.Fields[name].Column))
+		args = append(args, "%"+escapeLike(text)+"%")
+	}
+	return "(" + strings.Join(parts, " OR ") + ")", args, nil
+}
+
+func (s *Schema) convert(field Field, tok Token) (interface{}, error) {
+	switch field.Kind {
+	case KindNumber:
+		text, _ := trimUnit(tok.Text, field.Units)
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, errorAt(tok, "expected a number")
+		}
+		return value, nil
+	case KindInteger:
+		text, _ := trimUnit(tok.Text, field.Units)
+		value, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, errorAt(tok, "expected a whole number")
+		}
+		return value, nil
+	case KindEnum:
+		for _, allowed := range field.Values {
+			if strings.EqualFold(allowed, tok.Text) {
+				return allowed, nil
+			}
+		}
+		return nil, errorAt(tok, "expected one of %s", strings.Join(field.Values, ", "))
+	}
+	return tok.Text, nil
+}
+
+// trimUnit - bỏ hậu tố đơn vị (không phân biệt hoa thường), ok = true nếu có hậu tố và phần còn lại khác rỗng
+func trimUnit(text string, units []string) (string, bool) {
+	lower := strings.ToLower(text)
+	for _, unit := range units {
+		if strings.HasSuffix(lower, strings.ToLower(unit)) && len(text) > len(unit) {
+			return strings.TrimSpace(text[:len(text)-len(unit)]), true
+		}
+	}
+	return text, false
+}
+
+var timeLayouts = []struct {
+	layout    string
+	precision func(time.Time) time.Time
+}{
+	{time.RFC3339, func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{"2006-01-02T15:04:05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{"2006-01-02 15:04:05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{"2006-01-02T15:04", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{"2006-01-02 15:04", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"02/01/2006", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+}
+
+// parseTime - [start, end) theo độ chính xác của giá trị nhập
+func (s *Schema) parseTime(tok Token) (time.Time, time.Time, error) {
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, format := range timeLayouts {
+		if t, err := time.ParseInLocation(format.layout, tok.Text, loc); err == nil {
+			return t, format.precision(t), nil
+		}
+	}
+	return time.Time{}, time.Time{}, errorAt(tok, "expected a date such as 2025-10-01 or 2025-10-01T08:30")
+}
+
+// containsExpr - không phân biệt hoa thường và cùng một cú pháp trên MySQL, PostgreSQL, SQLite:
+// LIKE của PostgreSQL phân biệt hoa thường, SQLite không có ký tự escape mặc định
+func containsExpr(column string) string {
+	return "LOWER(" + column + ") LIKE LOWER(?) ESCAPE '!'"
+}
+
+// escapeLike - % và _ người dùng nhập được hiểu đúng nghĩa đen. Escape bằng "!" vì dấu \ trong
+// string literal mỗi database hiểu một kiểu
+func escapeLike(value string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(value)
+}
@@ -0,0 +1,146 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp      = "up"
+	StatusDown    = "down"
+	StatusSkipped = "skipped"
+)
+
+// Trạng thái chung: unavailable khi một dependency bắt buộc hỏng, degraded khi chỉ dependency phụ hỏng
+const (
+	ReportOK          = "ok"
+	ReportDegraded    = "degraded"
+	ReportUnavailable = "unavailable"
+)
+
+// ErrNotConfigured - check trả lỗi này thì dependency được báo "skipped" thay vì "down"
+var ErrNotConfigured = errors.New("not configured")
+
+type CheckFunc func(ctx context.Context) error
+
+type Result struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status    string            `json:"status"`
+	Checks    map[string]Result `json:"checks"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+// Ready - còn phục vụ được request, kể cả khi degraded
+func (r Report) Ready() bool {
+	return r.Status != ReportUnavailable
+}
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// Checker - chạy song song các check, mỗi check có timeout riêng. Kết quả giữ lại cacheFor
+// để probe dồn dập không đổ tải sang MySQL/Redis/SMTP.
+type Checker struct {
+	timeout  time.Duration
+	cacheFor time.Duration
+	checks   []check
+	observer func(name string, result Result)
+
+	mu   sync.Mutex
+	last *Report
+}
+
+func NewChecker(timeout, cacheFor time.Duration) *Checker {
+	return &Checker{timeout: timeout, cacheFor: cacheFor}
+}
+
+// Add - critical = API không chạy được khi thiếu dependency này
+func (c *Checker) Add(name string, critical bool, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// OnResult - được gọi sau mỗi lần check một dependency, ví dụ để cập nhật metric
+func (c *Checker) OnResult(fn func(name string, result Result)) {
+	c.observer = fn
+}
+
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last != nil && time.Since(c.last.CheckedAt) < c.cacheFor {
+		return *c.last
+	}
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, ch)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: ReportOK, Checks: make(map[string]Result, len(c.checks)), CheckedAt: time.Now()}
+	for i, ch := range c.checks {
+		result := results[i]
+		report.Checks[ch.name] = result
+		if c.observer != nil {
+			c.observer(ch.name, result)
+		}
+		if result.Status != StatusDown {
+			continue
+		}
+		if ch.critical {
+			report.Status = ReportUnavailable
+		} else if report.Status == ReportOK {
+			report.Status = ReportDegraded
+		}
+	}
+	c.last = &report
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, ch check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	err := ch.fn(ctx)
+	result := Result{Status: StatusUp, Critical: ch.critical, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	switch {
+	case errors.Is(err, ErrNotConfigured):
+		result.Status = StatusSkipped
+	case err != nil:
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// TCPCheck - chỉ kiểm tra mở được kết nối TCP, không bắt tay giao thức (dùng cho SMTP)
+func TCPCheck(address string) CheckFunc {
+	return func(ctx context.Context) error {
+		if address == "" {
+			return ErrNotConfigured
+		}
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
@@ -11,17 +11,25 @@ import (
 var (
 	Log   *zap.Logger
 	Sugar *zap.SugaredLogger
+	level = zap.NewAtomicLevelAt(zap.InfoLevel)
 )
 
 func InitLogger() {
 	var err error
-	Log, err = zap.NewProduction()
+	cfg := zap.NewProductionConfig()
+	cfg.Level = level
+	Log, err = cfg.Build()
 	if err != nil {
 		panic(err)
 	}
 	Sugar = Log.Sugar()
 }
 
+// SetLevel - đổi mức log khi đang chạy, dùng khi nạp lại cấu hình
+func SetLevel(name string) error {
+	return level.UnmarshalText([]byte(name))
+}
+
 func GinLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const insertStartKey = "metrics:insert_start"
+
+// RegisterGormCallbacks - đo thời gian câu INSERT của mọi lệnh Create (kể cả import theo lô)
+func RegisterGormCallbacks(db *gorm.DB) error {
+	create := db.Callback().Create()
+	if err := create.Before("gorm:create").Register("metrics:insert_start", func(tx *gorm.DB) {
+		tx.InstanceSet(insertStartKey, time.Now())
+	}); err != nil {
+		return err
+	}
+	return create.After("gorm:create").Register("metrics:insert_observe", func(tx *gorm.DB) {
+		value, ok := tx.InstanceGet(insertStartKey)
+		if !ok {
+			return
+		}
+		table := tx.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		DBInsertDuration.WithLabelValues(table).Observe(time.Since(value.(time.Time)).Seconds())
+	})
+}
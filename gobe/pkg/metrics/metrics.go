@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metric đăng ký vào registry mặc định của client_golang, registry này đã có sẵn Go collector
+// (go_goroutines, go_memstats_*...) và process collector (process_start_time_seconds, process_cpu_seconds_total...)
+
+// Kết quả xử lý message MQTT telemetry
+const (
+	MQTTReceived = "received"
+	MQTTParsed   = "parsed"
+	MQTTRejected = "rejected" // payload hỏng hoặc giá trị ngoài ngưỡng (có thể sau khi đã parsed)
+	MQTTDropped  = "dropped"  // không xác định được tenant, không lưu
+)
+
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route template and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	MQTTMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mqtt_messages_total",
+		Help: "Telemetry MQTT messages by subscribed topic filter and result (received, parsed, rejected, dropped).",
+	}, []string{"topic", "result"})
+
+	DBInsertDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_insert_duration_seconds",
+		Help:    "Latency of INSERT statements by table.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"table"})
+
+	WebSocketClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "websocket_clients",
+		Help: "WebSocket clients currently connected to the hub.",
+	})
+
+	WebSocketDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "websocket_dropped_messages_total",
+		Help: "WebSocket messages dropped because a client or hub buffer was full, by message kind.",
+	}, []string{"kind"})
+
+	MailSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mail_sent_total",
+		Help: "Outgoing mail by result (success, failure).",
+	}, []string{"result"})
+
+	// CacheRequests - hit ratio: sum(rate(cache_requests_total{result="hit"}[5m])) / sum(rate(cache_requests_total[5m]))
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Redis cache lookups by cache name and result (hit, miss).",
+	}, []string{"cache", "result"})
+
+	// DependencyUp - cập nhật mỗi lần /healthz, /readyz chạy check
+	DependencyUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dependency_up",
+		Help: "Whether a dependency (mysql|postgres|sqlite, redis, mqtt, smtp) passed its last health check.",
+	}, []string{"dependency"})
+
+	DependencyLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dependency_check_latency_seconds",
+		Help: "Latency of the last health check by dependency.",
+	}, []string{"dependency"})
+)
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// histogramCount - số lần Observe của một series
+func histogramCount(t *testing.T, vec *prometheus.HistogramVec, labels ...string) uint64 {
+	t.Helper()
+	observer, err := vec.GetMetricWithLabelValues(labels...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metric := &dto.Metric{}
+	if err := observer.(prometheus.Histogram).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinMiddleware())
+	r.GET("/devices/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	tests := []struct {
+		path   string
+		labels []string
+	}{
+		{"/devices/1", []string{http.MethodGet, "/devices/:id", "204"}},
+		{"/devices/2", []string{http.MethodGet, "/devices/:id", "204"}},
+		{"/missing", []string{http.MethodGet, "unmatched", "404"}},
+	}
+	before := map[string]uint64{}
+	for _, tt := range tests {
+		before[strings.Join(tt.labels, " ")] = histogramCount(t, HTTPRequestDuration, tt.labels...)
+	}
+	for _, tt := range tests {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+	}
+
+	// id trong path không tạo series mới, hai request cùng route dồn vào một series
+	want := map[string]uint64{"GET /devices/:id 204": 2, "GET unmatched 404": 1}
+	for key, delta := range want {
+		labels := strings.SplitN(key, " ", 3)
+		if got := histogramCount(t, HTTPRequestDuration, labels...) - before[key]; got != delta {
+			t.Errorf("%s: observed %d requests, want %d", key, got, delta)
+		}
+	}
+}
+
+func TestRegisterGormCallbacks(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterGormCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	type device struct {
+		ID   uint
+		Name string
+	}
+	if err := db.AutoMigrate(&device{}); err != nil {
+		t.Fatal(err)
+	}
+
+	before := histogramCount(t, DBInsertDuration, "devices")
+	db.Create(&device{Name: "fan"})
+	db.Create(&[]device{{Name: "lamp"}, {Name: "heater"}})
+	db.Where("name = ?", "fan").Find(&[]device{})
+
+	// mỗi lệnh Create một lần đo, kể cả tạo theo lô; SELECT không tính
+	if got := histogramCount(t, DBInsertDuration, "devices") - before; got != 2 {
+		t.Errorf("observed %d inserts, want 2", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	MQTTMessages.WithLabelValues("iot/+/sensor", MQTTRejected).Inc()
+	if got := testutil.ToFloat64(MQTTMessages.WithLabelValues("iot/+/sensor", MQTTRejected)); got < 1 {
+		t.Fatalf("mqtt_messages_total = %v, want at least 1", got)
+	}
+
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+
+	for _, want := range []string{
+		"# TYPE mqtt_messages_total counter",
+		`mqtt_messages_total{result="rejected",topic="iot/+/sensor"}`,
+		"# TYPE http_request_duration_seconds histogram",
+		"# TYPE websocket_clients gauge",
+		// Go và process collector của registry mặc định
+		"go_goroutines ",
+		"go_memstats_alloc_bytes ",
+		"process_start_time_seconds ",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics output has no %q", want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package mymqtt
+
+import (
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// paho chỉ nhận OnConnectHandler lúc tạo client, còn bridge được tạo sau nên đăng ký hook ở đây
+var (
+	connectHooksMu sync.Mutex
+	connectHooks   []mqtt.OnConnectHandler
+)
+
+// RunConnectHooks - gắn vào ClientOptions.SetOnConnectHandler, chạy mọi hook sau mỗi lần (re)connect.
+// Session sạch nên broker không giữ subscription, hook phải subscribe lại.
+func RunConnectHooks(client mqtt.Client) {
+	connectHooksMu.Lock()
+	hooks := append([]mqtt.OnConnectHandler(nil), connectHooks...)
+	connectHooksMu.Unlock()
+	for _, hook := range hooks {
+		hook(client)
+	}
+}
+
+// OnConnect - đăng ký hook, client đang kết nối thì chạy luôn. Kết nối xen giữa lúc đăng ký
+// có thể làm hook chạy hai lần, subscribe lại cùng topic không gây hại.
+func OnConnect(client mqtt.Client, hook mqtt.OnConnectHandler) {
+	connectHooksMu.Lock()
+	connectHooks = append(connectHooks, hook)
+	connectHooksMu.Unlock()
+	if client.IsConnectionOpen() {
+		go hook(client)
+	}
+}
@@ -1,9 +1,14 @@
 package mymqtt
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"iot/pkg/tracing"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func Subscribe(client mqtt.Client, topic string, qos byte, handler mqtt.MessageHandler) error {
@@ -16,16 +21,51 @@ func Subscribe(client mqtt.Client, topic string, qos byte, handler mqtt.MessageH
 	return token.Error()
 }
 
-func Publish(client mqtt.Client, topic string, qos byte, retained bool, payload interface{}) error {
+// Publish - span producer cho mỗi lần gửi. Payload là JSON object thì thêm field "traceparent"
+// (MQTT 3.1.1 không có user property) để board gửi lại trong bản tin trạng thái/telemetry kế tiếp,
+// nhờ vậy trace đi từ request HTTP tới lúc board phản hồi.
+func Publish(ctx context.Context, client mqtt.Client, topic string, qos byte, retained bool, payload interface{}) error {
 	if client == nil {
 		return fmt.Errorf("MQTT client is nil")
 	}
+	// IsConnected vẫn true khi paho đang thử kết nối lại, publish lúc đó bị xếp hàng và Wait treo
+	if !client.IsConnectionOpen() {
+		return fmt.Errorf("MQTT broker is not connected")
+	}
+
+	ctx, span := tracing.Start(ctx, "publish "+topic, trace.SpanKindProducer,
+		attribute.String("messaging.system", "mqtt"),
+		attribute.String("messaging.operation.type", "publish"),
+		attribute.String("messaging.destination.name", topic),
+	)
+	defer span.End()
+	if raw, ok := payload.([]byte); ok {
+		payload = withTraceparent(raw, tracing.Traceparent(ctx))
+	}
 
 	token := client.Publish(topic, qos, retained, payload)
 	token.Wait()
+	tracing.RecordError(span, token.Error())
 	return token.Error()
 }
 
+// withTraceparent - payload không phải JSON object thì giữ nguyên
+func withTraceparent(payload []byte, traceparent string) []byte {
+	if traceparent == "" {
+		return payload
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &object); err != nil || object == nil {
+		return payload
+	}
+	object[tracing.TraceparentHeader], _ = json.Marshal(traceparent)
+	enriched, err := json.Marshal(object)
+	if err != nil {
+		return payload
+	}
+	return enriched
+}
+
 func Disconnect(client mqtt.Client) {
 	if client != nil {
 		client.Disconnect(1000)
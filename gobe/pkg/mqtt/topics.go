@@ -0,0 +1,40 @@
+package mymqtt
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Topic riêng của từng board theo tenant và client ID. Broker ACL chỉ cho board publish telemetry
+// và subscribe command của chính nó trong tenant của credential.
+const (
+	LegacyTelemetryTopic = "sensor/information"
+	LegacyCommandTopic   = "devices/control"
+	TelemetryWildcard    = "tenants/+/sensor/+/information"
+)
+
+func TelemetryTopic(tenantID uint, clientID string) string {
+	return tenantPrefix(tenantID) + "sensor/" + clientID + "/information"
+}
+
+func CommandTopic(tenantID uint, clientID string) string {
+	return tenantPrefix(tenantID) + "devices/" + clientID + "/control"
+}
+
+// ParseTelemetryTopic - lấy tenant và client ID từ tenants/<tenant_id>/sensor/<client_id>/information,
+// ok = false với topic cũ hoặc topic sai định dạng
+func ParseTelemetryTopic(topic string) (uint, string, bool) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 5 || parts[0] != "tenants" || parts[2] != "sensor" || parts[4] != "information" || parts[3] == "" {
+		return 0, "", false
+	}
+	tenantID, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || tenantID == 0 {
+		return 0, "", false
+	}
+	return uint(tenantID), parts[3], true
+}
+
+func tenantPrefix(tenantID uint) string {
+	return "tenants/" + strconv.FormatUint(uint64(tenantID), 10) + "/"
+}
@@ -0,0 +1,27 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// RandomString - chuỗi base64url ngẫu nhiên cho state, nonce và code_verifier
+func RandomString(bytes int) (string, error) {
+	b := make([]byte, bytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewVerifier - code_verifier 43 ký tự theo RFC 7636
+func NewVerifier() (string, error) {
+	return RandomString(32)
+}
+
+// S256Challenge - code_challenge = BASE64URL(SHA256(verifier))
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
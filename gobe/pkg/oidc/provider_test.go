@@ -0,0 +1,255 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "iot-backend"
+
+// testIssuer - provider giả phục vụ discovery và JWKS, jwksHits đếm số lần tải JWKS
+type testIssuer struct {
+	server   *httptest.Server
+	keys     []jwk
+	jwksHits atomic.Int32
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	issuer := &testIssuer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                issuer.server.URL,
+			AuthorizationEndpoint: issuer.server.URL + "/authorize",
+			TokenEndpoint:         issuer.server.URL + "/token",
+			JWKSURI:               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		issuer.jwksHits.Add(1)
+		json.NewEncoder(w).Encode(jwkSet{Keys: issuer.keys})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func encodeInt(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (i *testIssuer) addRSA(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.keys = append(i.keys, jwk{Kty: "RSA", Kid: kid, Use: "sig", N: encodeInt(key.N.Bytes()), E: encodeInt(big.NewInt(int64(key.E)).Bytes())})
+	return key
+}
+
+func (i *testIssuer) addEC(t *testing.T, kid string) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.keys = append(i.keys, jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: encodeInt(key.X.FillBytes(make([]byte, 32))), Y: encodeInt(key.Y.FillBytes(make([]byte, 32)))})
+	return key
+}
+
+// claims - ID token hợp lệ cho testClientID, override thay/xóa (nil) từng claim
+func (i *testIssuer) claims(override jwt.MapClaims) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            i.server.URL,
+		"aud":            testClientID,
+		"sub":            "user-123",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+		"nonce":          "nonce-1",
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+	for k, v := range override {
+		if v == nil {
+			delete(claims, k)
+		} else {
+			claims[k] = v
+		}
+	}
+	return claims
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	rsaKey := issuer.addRSA(t, "rsa-1")
+	ecKey := issuer.addEC(t, "ec-1")
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	provider := NewProvider(Config{Issuer: issuer.server.URL + "/", ClientID: testClientID})
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		ok    bool
+	}{
+		{"rsa signed", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(nil)), "nonce-1", true},
+		{"ec signed", sign(t, jwt.SigningMethodES256, "ec-1", ecKey, issuer.claims(nil)), "nonce-1", true},
+		{"nonce mismatch", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(nil)), "nonce-2", false},
+		{"nonce missing from token", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"nonce": nil})), "nonce-1", false},
+		{"no nonce expected", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"nonce": ""})), "", false},
+		{"other issuer", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"iss": "https://evil.example.com"})), "nonce-1", false},
+		{"other audience", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"aud": "another-client"})), "nonce-1", false},
+		{"several audiences without azp", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"aud": []string{testClientID, "another-client"}})), "nonce-1", false},
+		{"several audiences with azp", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"aud": []string{testClientID, "another-client"}, "azp": testClientID})), "nonce-1", true},
+		{"expired", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"exp": time.Now().Add(-2 * time.Minute).Unix()})), "nonce-1", false},
+		{"expiry within leeway", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"exp": time.Now().Add(-30 * time.Second).Unix()})), "nonce-1", true},
+		{"no expiry", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"exp": nil})), "nonce-1", false},
+		{"issued in the future", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"iat": time.Now().Add(5 * time.Minute).Unix()})), "nonce-1", false},
+		{"no subject", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, issuer.claims(jwt.MapClaims{"sub": nil})), "nonce-1", false},
+		{"signed with a key outside the jwks", sign(t, jwt.SigningMethodRS256, "rsa-1", other, issuer.claims(nil)), "nonce-1", false},
+		{"hmac with the client id as secret", sign(t, jwt.SigningMethodHS256, "rsa-1", []byte(testClientID), issuer.claims(nil)), "nonce-1", false},
+		{"no kid with several keys", sign(t, jwt.SigningMethodRS256, "", rsaKey, issuer.claims(nil)), "nonce-1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idToken, err := provider.Verify(context.Background(), tt.token, tt.nonce)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("Verify = %v, want ErrInvalidIDToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idToken.Subject != "user-123" || idToken.Email != "alice@example.com" || !idToken.EmailVerified || idToken.Name != "Alice" {
+				t.Errorf("id token %+v", idToken)
+			}
+		})
+	}
+	if hits := issuer.jwksHits.Load(); hits != 1 {
+		t.Errorf("jwks fetched %d times, want 1", hits)
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	issuer := newTestIssuer(t)
+	oldKey := issuer.addRSA(t, "old")
+	provider := NewProvider(Config{Issuer: issuer.server.URL, ClientID: testClientID})
+	ctx := context.Background()
+
+	// token không có kid được chấp nhận khi JWKS chỉ có một key
+	if _, err := provider.Verify(ctx, sign(t, jwt.SigningMethodRS256, "", oldKey, issuer.claims(nil)), "nonce-1"); err != nil {
+		t.Fatalf("single key without kid: %v", err)
+	}
+
+	newKey := issuer.addRSA(t, "new")
+	rotated := sign(t, jwt.SigningMethodRS256, "new", newKey, issuer.claims(nil))
+	if _, err := provider.Verify(ctx, rotated, "nonce-1"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("unknown kid right after a fetch = %v, want ErrInvalidIDToken", err)
+	}
+	if hits := issuer.jwksHits.Load(); hits != 1 {
+		t.Fatalf("jwks fetched %d times, want 1 within the refresh interval", hits)
+	}
+
+	provider.mu.Lock()
+	provider.keysFetchedAt = time.Now().Add(-jwksRefreshInterval)
+	provider.mu.Unlock()
+	if _, err := provider.Verify(ctx, rotated, "nonce-1"); err != nil {
+		t.Fatalf("rotated key after the refresh interval: %v", err)
+	}
+	if hits := issuer.jwksHits.Load(); hits != 2 {
+		t.Errorf("jwks fetched %d times, want 2", hits)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := NewProvider(Config{Issuer: issuer.server.URL, ClientID: testClientID, RedirectURL: "https://app.example.com/callback", Scopes: []string{"openid", "email"}})
+
+	verifier, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verifier) != 43 {
+		t.Errorf("verifier has %d characters, want 43", len(verifier))
+	}
+	raw, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", S256Challenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          "https://app.example.com/callback",
+		"scope":                 "openid email",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(sum[:]),
+		"code_challenge_method": "S256",
+	}
+	for k, v := range want {
+		if got := u.Query().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if u.Path != "/authorize" {
+		t.Errorf("path = %s, want /authorize", u.Path)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	// metadata khai báo issuer khác issuer cấu hình (ví dụ proxy trỏ nhầm realm)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                "https://evil.example.com",
+			AuthorizationEndpoint: "https://evil.example.com/authorize",
+			TokenEndpoint:         "https://evil.example.com/token",
+			JWKSURI:               "https://evil.example.com/jwks",
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(Config{Issuer: server.URL, ClientID: testClientID})
+	if _, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", "challenge"); err == nil {
+		t.Error("discovery accepted metadata for another issuer")
+	}
+}
@@ -0,0 +1,196 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// cursor - giá trị sort của dòng biên. Sort đi kèm để cursor không bị dùng với thứ tự khác.
+// Không cần ký: mọi giá trị đều được parse theo Kind và đi qua tham số "?"
+type cursor struct {
+	Sort     string   `json:"s"`
+	Values   []string `json:"v"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+func (c cursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(raw, sort string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid("malformed cursor")
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, invalid("malformed cursor")
+	}
+	if c.Sort != sort {
+		return nil, invalid("cursor was issued for sort %q, not %q", c.Sort, sort)
+	}
+	return &c, nil
+}
+
+// Find - đếm tổng rồi lấy một trang của query theo req.
+// Có cursor thì dùng keyset (WHERE theo giá trị sort của dòng biên), không thì dùng offset.
+func Find[T any](query *gorm.DB, spec *Spec, req *Request) ([]T, *Page, error) {
+	orders, err := spec.orders(req.Sort)
+	if err != nil {
+		return nil, nil, err
+	}
+	page := &Page{Limit: req.Limit, Offset: req.Offset, Sort: formatOrders(orders)}
+
+	var boundary *cursor
+	if req.Cursor != "" {
+		if boundary, err = decodeCursor(req.Cursor, page.Sort); err != nil {
+			return nil, nil, err
+		}
+		if len(boundary.Values) != len(orders) {
+			return nil, nil, invalid("malformed cursor")
+		}
+	}
+
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, nil, err
+	}
+
+	tx := query.Session(&gorm.Session{})
+	backward := boundary != nil && boundary.Backward
+	if boundary != nil {
+		sql, args, err := spec.after(orders, boundary)
+		if err != nil {
+			return nil, nil, err
+		}
+		tx = tx.Where(sql, args...)
+	} else if req.Offset > 0 {
+		tx = tx.Offset(req.Offset)
+	}
+	for _, order := range orders {
+		// Trang trước: đọc ngược từ dòng biên rồi đảo lại
+		if order.Desc != backward {
+			tx = tx.Order(spec.Fields[order.Field].Column + " DESC")
+		} else {
+			tx = tx.Order(spec.Fields[order.Field].Column + " ASC")
+		}
+	}
+
+	var rows []T
+	result := tx.Limit(req.Limit + 1).Find(&rows)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	more := len(rows) > req.Limit
+	if more {
+		rows = rows[:req.Limit]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	// Trang rỗng sau cursor vẫn quay lại được từ chính dòng biên
+	first, last := boundary, boundary
+	if len(rows) > 0 {
+		if first, err = rowCursor(result, spec, orders, &rows[0]); err != nil {
+			return nil, nil, err
+		}
+		if last, err = rowCursor(result, spec, orders, &rows[len(rows)-1]); err != nil {
+			return nil, nil, err
+		}
+	}
+	hasNext, hasPrev := more, boundary != nil || req.Offset > 0
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext && last != nil {
+		page.NextCursor = cursor{Sort: page.Sort, Values: last.Values}.encode()
+	}
+	if hasPrev && first != nil {
+		page.PrevCursor = cursor{Sort: page.Sort, Values: first.Values, Backward: true}.encode()
+	}
+	return rows, page, nil
+}
+
+// after - (a > ?) OR (a = ? AND b > ?) OR ..., chiều so sánh theo chiều sort của từng field
+func (s *Spec) after(orders []Order, c *cursor) (string, []interface{}, error) {
+	values := make([]interface{}, len(orders))
+	for i, order := range orders {
+		value, err := parseValue(s.Fields[order.Field].Kind, c.Values[i])
+		if err != nil {
+			return "", nil, err
+		}
+		values[i] = value
+	}
+	var terms []string
+	var args []interface{}
+	for i, order := range orders {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, s.Fields[orders[j].Field].Column+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if order.Desc != c.Backward {
+			op = " < ?"
+		}
+		parts = append(parts, s.Fields[order.Field].Column+op)
+		args = append(args, values[i])
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", args, nil
+}
+
+// rowCursor - đọc giá trị các cột sort của row qua schema GORM đã parse khi Find
+func rowCursor[T any](result *gorm.DB, spec *Spec, orders []Order, row *T) (*cursor, error) {
+	c := &cursor{}
+	value := reflect.ValueOf(row).Elem()
+	for _, order := range orders {
+		column := spec.Fields[order.Field].Column
+		if _, name, ok := strings.Cut(column, "."); ok {
+			column = name
+		}
+		field := result.Statement.Schema.LookUpField(column)
+		if field == nil {
+			return nil, fmt.Errorf("pagination: column %s is not a field of %s", column, result.Statement.Schema.Name)
+		}
+		fieldValue, _ := field.ValueOf(result.Statement.Context, value)
+		c.Values = append(c.Values, formatValue(fieldValue))
+	}
+	return c, nil
+}
+
+func formatValue(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+func parseValue(kind Kind, raw string) (interface{}, error) {
+	var value interface{}
+	var err error
+	switch kind {
+	case KindInteger:
+		value, err = strconv.ParseInt(raw, 10, 64)
+	case KindNumber:
+		value, err = strconv.ParseFloat(raw, 64)
+	case KindTime:
+		value, err = time.Parse(time.RFC3339Nano, raw)
+	default:
+		value = raw
+	}
+	if err != nil {
+		return nil, invalid("malformed cursor")
+	}
+	return value, nil
+}
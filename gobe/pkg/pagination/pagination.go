@@ -0,0 +1,171 @@
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+// ErrInvalid - limit/offset/sort/cursor sai, handler trả 400
+var ErrInvalid = errors.New("invalid pagination")
+
+type Kind int
+
+const (
+	KindInteger Kind = iota
+	KindNumber
+	KindString
+	KindTime
+)
+
+// Field - field được phép sort. Column do code khai báo nên là thứ duy nhất ghép thẳng vào ORDER BY,
+// phải là cột NOT NULL để so sánh keyset đúng
+type Field struct {
+	Column string
+	Kind   Kind
+}
+
+// Spec - whitelist sort của một resource
+type Spec struct {
+	Fields map[string]Field
+	// Default - sort khi request không truyền, ví dụ "created_at:desc"
+	Default string
+	// Unique - field duy nhất (thường là id) luôn được thêm cuối sort để thứ tự xác định, cursor cần điều này
+	Unique string
+}
+
+type Order struct {
+	Field string
+	Desc  bool
+}
+
+// Request - tham số phân trang thô từ query, được kiểm tra theo Spec khi Find
+type Request struct {
+	Limit  int
+	Offset int
+	// Sort - "created_at:desc,id" hoặc "-created_at,id"
+	Sort   string
+	Cursor string
+}
+
+// Page - phần "pagination" của response list
+type Page struct {
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	Total      int64  `json:"total"`
+	Sort       string `json:"sort"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// Parse - đọc limit, offset, sort, cursor. sort_by + order vẫn được nhận cho client cũ.
+func Parse(values url.Values) (*Request, error) {
+	req := &Request{Limit: DefaultLimit, Sort: values.Get("sort"), Cursor: values.Get("cursor")}
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return nil, invalid("limit must be a positive integer")
+		}
+		req.Limit = min(limit, MaxLimit)
+	}
+	if raw := values.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return nil, invalid("offset must be a non-negative integer")
+		}
+		req.Offset = offset
+	}
+	if req.Cursor != "" && req.Offset > 0 {
+		return nil, invalid("offset cannot be combined with cursor")
+	}
+	if req.Sort == "" && (values.Get("sort_by") != "" || values.Get("order") != "") {
+		req.Sort = values.Get("sort_by")
+		if order := values.Get("order"); order != "" {
+			req.Sort += ":" + order
+		}
+	}
+	return req, nil
+}
+
+// orders - sort của request theo whitelist, thêm Spec.Unique nếu chưa có
+func (s *Spec) orders(raw string) ([]Order, error) {
+	if strings.TrimSpace(raw) == "" {
+		raw = s.Default
+	}
+	var orders []Order
+	seen := map[string]bool{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		order := Order{}
+		switch {
+		case strings.HasPrefix(item, "-"):
+			order.Field, order.Desc = item[1:], true
+		case strings.HasPrefix(item, "+"):
+			order.Field = item[1:]
+		default:
+			name, dir, _ := strings.Cut(item, ":")
+			order.Field = name
+			switch strings.ToLower(dir) {
+			case "", "asc":
+			case "desc":
+				order.Desc = true
+			default:
+				return nil, invalid("sort direction %q must be asc or desc", dir)
+			}
+		}
+		// chỉ có order (client cũ gửi order=asc) nghĩa là đổi chiều field sort mặc định
+		if order.Field == "" && len(orders) == 0 && raw != s.Default {
+			defaults, err := s.orders(s.Default)
+			if err != nil {
+				return nil, err
+			}
+			order.Field = defaults[0].Field
+		}
+		order.Field = strings.ToLower(order.Field)
+		if _, ok := s.Fields[order.Field]; !ok {
+			return nil, invalid("unknown sort field %q, allowed fields are %s", order.Field, strings.Join(s.fieldNames(), ", "))
+		}
+		if seen[order.Field] {
+			return nil, invalid("sort field %q is repeated", order.Field)
+		}
+		seen[order.Field] = true
+		orders = append(orders, order)
+	}
+	if !seen[s.Unique] {
+		orders = append(orders, Order{Field: s.Unique, Desc: orders[len(orders)-1].Desc})
+	}
+	return orders, nil
+}
+
+func (s *Spec) fieldNames() []string {
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatOrders(orders []Order) string {
+	parts := make([]string, 0, len(orders))
+	for _, order := range orders {
+		dir := "asc"
+		if order.Desc {
+			dir = "desc"
+		}
+		parts = append(parts, order.Field+":"+dir)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,253 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testSpec = &Spec{
+	Fields: map[string]Field{
+		"id":         {Column: "items.id", Kind: KindInteger},
+		"name":       {Column: "items.name", Kind: KindString},
+		"score":      {Column: "items.score", Kind: KindNumber},
+		"created_at": {Column: "items.created_at", Kind: KindTime},
+	},
+	Default: "id:asc",
+	Unique:  "id",
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  *Request
+		err   bool
+	}{
+		{"defaults", "", &Request{Limit: DefaultLimit}, false},
+		{"limit is capped", "limit=1000", &Request{Limit: MaxLimit}, false},
+		{"offset and sort", "limit=5&offset=10&sort=-name", &Request{Limit: 5, Offset: 10, Sort: "-name"}, false},
+		{"legacy sort_by and order", "sort_by=name&order=desc", &Request{Limit: DefaultLimit, Sort: "name:desc"}, false},
+		{"legacy order only", "order=desc", &Request{Limit: DefaultLimit, Sort: ":desc"}, false},
+		{"zero limit", "limit=0", nil, true},
+		{"negative offset", "offset=-1", nil, true},
+		{"offset with cursor", "offset=1&cursor=abc", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			got, err := Parse(values)
+			if tt.err {
+				if !errors.Is(err, ErrInvalid) {
+					t.Fatalf("err = %v, want ErrInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrders(t *testing.T) {
+	tests := []struct {
+		sort string
+		want string
+		err  bool
+	}{
+		{"", "id:asc", false},
+		{"name", "name:asc,id:asc", false},
+		{"-name", "name:desc,id:desc", false},
+		{"+score,created_at:desc", "score:asc,created_at:desc,id:desc", false},
+		{"NAME:DESC,id:asc", "name:desc,id:asc", false},
+		{":desc", "id:desc", false},
+		{"password", "", true},
+		{"name:up", "", true},
+		{"name,name", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			orders, err := testSpec.orders(tt.sort)
+			if tt.err {
+				if !errors.Is(err, ErrInvalid) {
+					t.Fatalf("err = %v, want ErrInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("orders: %v", err)
+			}
+			if got := formatOrders(orders); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	issued := cursor{Sort: "name:asc,id:asc", Values: []string{"b", "2"}, Backward: true}.encode()
+	tests := []struct {
+		name string
+		raw  string
+		sort string
+		err  bool
+	}{
+		{"round trip", issued, "name:asc,id:asc", false},
+		{"other sort", issued, "id:asc", true},
+		{"not base64", "***", "id:asc", true},
+		{"not json", "bm90LWpzb24", "id:asc", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.raw, tt.sort)
+			if tt.err {
+				if !errors.Is(err, ErrInvalid) {
+					t.Fatalf("err = %v, want ErrInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			want := &cursor{Sort: "name:asc,id:asc", Values: []string{"b", "2"}, Backward: true}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestAfter(t *testing.T) {
+	created := time.Date(2025, 10, 1, 8, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		sort   string
+		cursor cursor
+		sql    string
+		args   []interface{}
+		err    bool
+	}{
+		{"single field", "id", cursor{Values: []string{"7"}},
+			"((items.id > ?))", []interface{}{int64(7)}, false},
+		{"descending", "-name", cursor{Values: []string{"b", "7"}},
+			"((items.name < ?) OR (items.name = ? AND items.id < ?))", []interface{}{"b", "b", int64(7)}, false},
+		{"backward flips direction", "name", cursor{Values: []string{"b", "7"}, Backward: true},
+			"((items.name < ?) OR (items.name = ? AND items.id < ?))", []interface{}{"b", "b", int64(7)}, false},
+		{"mixed directions", "score:desc,created_at:asc", cursor{Values: []string{"1.5", created.Format(time.RFC3339Nano), "3"}},
+			"((items.score < ?) OR (items.score = ? AND items.created_at > ?) OR (items.score = ? AND items.created_at = ? AND items.id > ?))",
+			[]interface{}{1.5, 1.5, created, 1.5, created, int64(3)}, false},
+		{"malformed integer", "id", cursor{Values: []string{"x"}}, "", nil, true},
+		{"malformed time", "created_at", cursor{Values: []string{"yesterday", "1"}}, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := testSpec.orders(tt.sort)
+			if err != nil {
+				t.Fatalf("orders: %v", err)
+			}
+			sql, args, err := testSpec.after(orders, &tt.cursor)
+			if tt.err {
+				if !errors.Is(err, ErrInvalid) {
+					t.Fatalf("err = %v, want ErrInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("after: %v", err)
+			}
+			if sql != tt.sql {
+				t.Errorf("sql = %q, want %q", sql, tt.sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %#v, want %#v", args, tt.args)
+			}
+		})
+	}
+}
+
+type item struct {
+	ID    uint
+	Name  string
+	Score float64
+}
+
+func (item) TableName() string {
+	return "items"
+}
+
+// TestFindWalk - đi hết các trang bằng next_cursor rồi quay lại bằng prev_cursor, tên trùng nhau
+// để kiểm tra id làm tie-breaker
+func TestFindWalk(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&item{}); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"c", "a", "b", "a", "c", "b", "a"}
+	for _, name := range names {
+		db.Create(&item{Name: name})
+	}
+
+	tests := []struct {
+		sort string
+		want []uint
+	}{
+		{"", []uint{1, 2, 3, 4, 5, 6, 7}},
+		{"name", []uint{2, 4, 7, 3, 6, 1, 5}},
+		{"-name", []uint{5, 1, 6, 3, 7, 4, 2}},
+		{"name,id:desc", []uint{7, 4, 2, 6, 3, 5, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			var forward []uint
+			var pages []*Page
+			req := &Request{Limit: 3, Sort: tt.sort}
+			for {
+				rows, page, err := Find[item](db.Model(&item{}), testSpec, req)
+				if err != nil {
+					t.Fatalf("Find: %v", err)
+				}
+				if page.Total != int64(len(names)) {
+					t.Fatalf("total = %d, want %d", page.Total, len(names))
+				}
+				for _, row := range rows {
+					forward = append(forward, row.ID)
+				}
+				pages = append(pages, page)
+				if page.NextCursor == "" {
+					break
+				}
+				req = &Request{Limit: 3, Sort: tt.sort, Cursor: page.NextCursor}
+			}
+			if !reflect.DeepEqual(forward, tt.want) {
+				t.Fatalf("forward = %v, want %v", forward, tt.want)
+			}
+
+			// prev_cursor của trang cuối phải trả đúng trang áp chót
+			last := pages[len(pages)-1]
+			rows, _, err := Find[item](db.Model(&item{}), testSpec, &Request{Limit: 3, Sort: tt.sort, Cursor: last.PrevCursor})
+			if err != nil {
+				t.Fatalf("Find backward: %v", err)
+			}
+			var backward []uint
+			for _, row := range rows {
+				backward = append(backward, row.ID)
+			}
+			if want := tt.want[3:6]; !reflect.DeepEqual(backward, want) {
+				t.Errorf("backward = %v, want %v", backward, want)
+			}
+		})
+	}
+}
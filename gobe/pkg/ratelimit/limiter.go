@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript - sorted set theo thời gian (ms), mỗi request là một member.
+// Trả về {allowed, remaining, retry_after_ms}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return {1, limit - count - 1, 0}
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local retry = window
+if oldest[2] then
+	retry = window - (now - tonumber(oldest[2]))
+end
+return {0, 0, retry}
+`)
+
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter - sliding window log trên Redis, dùng chung giữa các instance backend
+type Limiter struct {
+	redis *redis.Client
+	now   func() time.Time
+}
+
+func NewLimiter(redis *redis.Client) *Limiter {
+	return &Limiter{redis: redis, now: time.Now}
+}
+
+// Allow - ghi nhận một request cho key nếu còn quota trong window
+func (l *Limiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (*Result, error) {
+	now := l.now().UnixMilli()
+	values, err := slidingWindowScript.Run(ctx, l.redis, []string{key},
+		now,
+		window.Milliseconds(),
+		limit,
+		uuid.NewString(),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestAllowSlidingWindow(t *testing.T) {
+	client := testRedis(t)
+	start := time.UnixMilli(1_700_000_000_000)
+	var clock time.Time
+	limiter := &Limiter{redis: client, now: func() time.Time { return clock }}
+
+	prefix := "test:ratelimit:"
+
+	// limit 3 trong 1s; request bị từ chối không được tính vào window
+	steps := []struct {
+		name      string
+		key       string
+		at        time.Duration
+		allowed   bool
+		remaining int
+		retry     time.Duration
+	}{
+		{"first", "a", 0, true, 2, 0},
+		{"second", "a", 100 * time.Millisecond, true, 1, 0},
+		{"third", "a", 200 * time.Millisecond, true, 0, 0},
+		{"over limit waits for the oldest", "a", 300 * time.Millisecond, false, 0, 700 * time.Millisecond},
+		{"other key has its own window", "b", 300 * time.Millisecond, true, 2, 0},
+		{"oldest slides out", "a", time.Second, true, 0, 0},
+		{"rejected requests are not counted", "a", 1050 * time.Millisecond, false, 0, 50 * time.Millisecond},
+		{"next slot frees up", "a", 1100 * time.Millisecond, true, 0, 0},
+		{"whole window expired", "a", 3 * time.Second, true, 2, 0},
+	}
+	for _, step := range steps {
+		clock = start.Add(step.at)
+		got, err := limiter.Allow(context.Background(), prefix+step.key, 3, time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		want := Result{Allowed: step.allowed, Remaining: step.remaining, RetryAfter: step.retry}
+		if *got != want {
+			t.Errorf("%s: got %+v, want %+v", step.name, *got, want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package socket
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
@@ -12,26 +13,64 @@ import (
 type MessageType string
 
 const (
-	BroadcastMsg MessageType = "broadcast"
-	DirectMsg    MessageType = "direct"
-	GroupMsg     MessageType = "group"
-	JoinGroupMsg MessageType = "join_group"
+	GroupMsg      MessageType = "group"
+	JoinGroupMsg  MessageType = "join_group"
 	LeaveGroupMsg MessageType = "leave_group"
+	SubscribeMsg  MessageType = "subscribe"
+	CommandMsg    MessageType = "command"
+
+	CommandResultMsg MessageType = "command_result"
 )
 
+// roomGroupPrefix - group do client tự join, tên client gửi lên được gắn tenant để không gửi/nhận chéo tenant
+const roomGroupPrefix = "room:"
+
 type ClientMessage struct {
-	Type     MessageType `json:"type"`
-	Content  string      `json:"content,omitempty"`
-	To       string      `json:"to,omitempty"`
-	GroupID  string      `json:"groupId,omitempty"`
+	Type      MessageType     `json:"type"`
+	Content   string          `json:"content,omitempty"`
+	GroupID   string          `json:"groupId,omitempty"`
+	Since     string          `json:"since,omitempty"`
+	RequestID string          `json:"requestId,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// CommandResult - phản hồi cho message "command", RequestID khớp với request của client
+type CommandResult struct {
+	Type      MessageType `json:"type"`
+	RequestID string      `json:"requestId,omitempty"`
+	OK        bool        `json:"ok"`
+	Data      any         `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// RoomMessage - message client gửi vào group, server gói với type cố định nên client
+// không giả được event của server (device_state, telemetry...)
+type RoomMessage struct {
+	Type    MessageType `json:"type"`
+	GroupID string      `json:"groupId"`
+	From    string      `json:"from"`
+	Content string      `json:"content"`
+}
+
+// Identity - người dùng đã xác thực của kết nối, nil nếu client ẩn danh
+type Identity struct {
+	UserID   uint
+	TenantID uint
+	Username string
+	Email    string
+	Role     string
+	// IP, UserAgent - của request mở kết nối, dùng cho audit log
+	IP        string
+	UserAgent string
 }
 
 type Client struct {
-	ID     string
-	Hub    *Hub
-	Conn   *websocket.Conn
-	Send   chan []byte
-	Groups map[string]bool
+	ID       string
+	Hub      *Hub
+	Conn     *websocket.Conn
+	Send     chan []byte
+	Groups   map[string]bool
+	Identity *Identity
 }
 
 func NewClient(hub *Hub, conn *websocket.Conn) *Client {
@@ -54,50 +93,49 @@ func (c *Client) ReadPump() {
 		if err != nil {
 			break
 		}
-		
-		// Try to parse as JSON message
+
 		var clientMsg ClientMessage
 		if err := json.Unmarshal(message, &clientMsg); err != nil {
-			// If not JSON, treat as plain broadcast message
-			c.Hub.Broadcast <- message
+			c.Hub.sendTo(c, map[string]string{"type": "error", "message": "invalid message"})
 			continue
 		}
-		
-		// Handle different message types
+
+		// Client không gửi broadcast/direct được, chỉ gửi vào group của tenant mình
 		switch clientMsg.Type {
-		case BroadcastMsg:
-			c.Hub.Broadcast <- []byte(clientMsg.Content)
-		case DirectMsg:
-			if clientMsg.To != "" {
-				c.Hub.Direct <- DirectMessage{
-					To:      clientMsg.To,
-					Message: []byte(clientMsg.Content),
-				}
-			}
 		case GroupMsg:
-			if clientMsg.GroupID != "" {
-				c.Hub.Group <- GroupMessage{
-					GroupID: clientMsg.GroupID,
-					Message: []byte(clientMsg.Content),
+			if groupID, ok := c.roomGroup(clientMsg.GroupID); ok {
+				if b, err := json.Marshal(RoomMessage{Type: GroupMsg, GroupID: clientMsg.GroupID, From: c.ID, Content: clientMsg.Content}); err == nil {
+					c.Hub.Group <- GroupMessage{GroupID: groupID, Message: b}
 				}
 			}
 		case JoinGroupMsg:
-			if clientMsg.GroupID != "" {
-				c.Hub.JoinGroup(c, clientMsg.GroupID)
-				log.Printf("Client %s joined group %s", c.ID, clientMsg.GroupID)
+			if groupID, ok := c.roomGroup(clientMsg.GroupID); ok {
+				c.Hub.JoinGroup(c, groupID)
+				log.Printf("Client %s joined group %s", c.ID, groupID)
 			}
 		case LeaveGroupMsg:
-			if clientMsg.GroupID != "" {
-				c.Hub.LeaveGroup(c, clientMsg.GroupID)
-				log.Printf("Client %s left group %s", c.ID, clientMsg.GroupID)
+			if groupID, ok := c.roomGroup(clientMsg.GroupID); ok {
+				c.Hub.LeaveGroup(c, groupID)
+				log.Printf("Client %s left group %s", c.ID, groupID)
 			}
+		case SubscribeMsg:
+			c.Hub.Subscribe(c, clientMsg.Since)
+		case CommandMsg:
+			c.Hub.HandleCommand(c, &clientMsg)
 		default:
-			// Unknown message type, broadcast as plain text
-			c.Hub.Broadcast <- message
+			c.Hub.sendTo(c, map[string]string{"type": "error", "message": "unsupported message type"})
 		}
 	}
 }
 
+// roomGroup - group client tự đặt tên trong tenant của mình, client ẩn danh không dùng group
+func (c *Client) roomGroup(name string) (string, bool) {
+	if name == "" || c.Identity == nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s%d:%s", roomGroupPrefix, c.Identity.TenantID, name), true
+}
+
 func (c *Client) WritePump() {
 	defer c.Conn.Close()
 	for {
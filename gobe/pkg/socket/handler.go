@@ -13,12 +13,13 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
+func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, identity *Identity) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
 	client := NewClient(hub, conn)
+	client.Identity = identity
 	hub.Register <- client
 
 	// Send client ID to the new client
@@ -31,6 +32,9 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		client.Send <- welcomeBytes
 	}
 
+	// Client reconnect có thể truyền ?since=<cursor> để nhận lại các bản ghi bị lỡ
+	hub.Subscribe(client, r.URL.Query().Get("since"))
+
 	go client.WritePump()
 	go client.ReadPump()
 }
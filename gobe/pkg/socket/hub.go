@@ -1,5 +1,73 @@
 package socket
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iot/pkg/metrics"
+	"log"
+	"strings"
+	"time"
+)
+
+// Group dành riêng cho server, client không tự join được bằng message join_group
+const (
+	telemetryGroupPrefix = "telemetry:"
+	deviceGroupPrefix    = "device:"
+)
+
+// TelemetryGroup - telemetry tách theo tenant, client chỉ nhận dữ liệu sensor của tenant trong token
+func TelemetryGroup(tenantID uint) string {
+	return fmt.Sprintf("%s%d", telemetryGroupPrefix, tenantID)
+}
+
+func DeviceGroup(deviceID uint) string {
+	return fmt.Sprintf("%s%d", deviceGroupPrefix, deviceID)
+}
+
+func IsReservedGroup(groupID string) bool {
+	return strings.HasPrefix(groupID, telemetryGroupPrefix) || strings.HasPrefix(groupID, deviceGroupPrefix)
+}
+
+// StateProvider cung cấp dữ liệu cho snapshot khi client subscribe và catch-up theo cursor.
+// Snapshot trả về thêm các group (telemetry, device:<id>) mà identity được phép nhận,
+// readings=false khi client vừa nhận catch-up để không gửi trùng bản ghi.
+type StateProvider interface {
+	Snapshot(ctx context.Context, identity *Identity, readings bool) (any, []string, error)
+	Since(ctx context.Context, identity *Identity, cursor string) (any, error)
+}
+
+// CommandHandler thực thi message "command" của client đã xác thực
+type CommandHandler interface {
+	HandleCommand(ctx context.Context, identity *Identity, payload json.RawMessage) (*CommandOutcome, error)
+}
+
+type CommandOutcome struct {
+	Result any          // trả về cho client gửi command
+	Events []GroupEvent // gửi tới các subscriber khác trong group tương ứng
+}
+
+type GroupEvent struct {
+	GroupID string
+	Payload any
+}
+
+// ExceptMessage - gửi cho mọi client (hoặc thành viên GroupID nếu có) trừ Except
+type ExceptMessage struct {
+	Except  *Client
+	GroupID string
+	Message []byte
+}
+
+// groupChange - reset=true thay toàn bộ group dành riêng của client bằng groups
+type groupChange struct {
+	client  *Client
+	groupID string
+	join    bool
+	reset   bool
+	groups  []string
+}
+
 type DirectMessage struct {
 	To      string
 	Message []byte
@@ -15,11 +83,16 @@ type Hub struct {
 	ClientsByID map[string]*Client
 	Groups      map[string]map[*Client]bool
 
-	Broadcast  chan []byte
-	Direct     chan DirectMessage
-	Group      chan GroupMessage
-	Register   chan *Client
-	Unregister chan *Client
+	Broadcast       chan []byte
+	BroadcastExcept chan ExceptMessage
+	Direct          chan DirectMessage
+	Group           chan GroupMessage
+	Register        chan *Client
+	Unregister      chan *Client
+	membership      chan groupChange
+
+	state    StateProvider
+	commands CommandHandler
 }
 
 func NewHub() *Hub {
@@ -28,11 +101,13 @@ func NewHub() *Hub {
 		ClientsByID: make(map[string]*Client),
 		Groups:      make(map[string]map[*Client]bool),
 
-		Broadcast:  make(chan []byte),
-		Direct:     make(chan DirectMessage),
-		Group:      make(chan GroupMessage),
-		Register:   make(chan *Client),
-		Unregister: make(chan *Client),
+		Broadcast:       make(chan []byte),
+		BroadcastExcept: make(chan ExceptMessage),
+		Direct:          make(chan DirectMessage),
+		Group:           make(chan GroupMessage),
+		Register:        make(chan *Client),
+		Unregister:      make(chan *Client),
+		membership:      make(chan groupChange),
 	}
 }
 
@@ -42,43 +117,101 @@ func (h *Hub) Run() {
 		case client := <-h.Register:
 			h.Clients[client] = true
 			h.ClientsByID[client.ID] = client
+			metrics.WebSocketClients.Set(float64(len(h.Clients)))
 
 		case client := <-h.Unregister:
-			if _, ok := h.Clients[client]; ok {
-				delete(h.Clients, client)
-				delete(h.ClientsByID, client.ID)
-				for group := range client.Groups {
-					delete(h.Groups[group], client)
-				}
-				close(client.Send)
-			}
+			h.removeClient(client)
 
 		case message := <-h.Broadcast:
 			for client := range h.Clients {
 				select {
 				case client.Send <- message:
 				default:
-					close(client.Send)
-					delete(h.Clients, client)
+					// Client không đọc kịp thì ngắt kết nối, WritePump thấy Send đóng sẽ tự thoát
+					h.removeClient(client)
+					metrics.WebSocketDropped.WithLabelValues("broadcast").Inc()
+				}
+			}
+
+		case em := <-h.BroadcastExcept:
+			targets := h.Clients
+			if em.GroupID != "" {
+				targets = h.Groups[em.GroupID]
+			}
+			for client := range targets {
+				if client == em.Except {
+					continue
+				}
+				select {
+				case client.Send <- em.Message:
+				default:
+					// Giống Broadcast: client không đọc kịp thì ngắt, không âm thầm mất event
+					h.removeClient(client)
+					metrics.WebSocketDropped.WithLabelValues("event").Inc()
+				}
+			}
+
+		case gc := <-h.membership:
+			if _, ok := h.Clients[gc.client]; !ok {
+				continue
+			}
+			switch {
+			case gc.reset:
+				for groupID := range gc.client.Groups {
+					if IsReservedGroup(groupID) {
+						h.removeFromGroup(gc.client, groupID)
+					}
+				}
+				for _, groupID := range gc.groups {
+					h.addToGroup(gc.client, groupID)
 				}
+			case gc.join:
+				h.addToGroup(gc.client, gc.groupID)
+			default:
+				h.removeFromGroup(gc.client, gc.groupID)
 			}
 
 		case dm := <-h.Direct:
 			if client, ok := h.ClientsByID[dm.To]; ok {
-				client.Send <- dm.Message
+				select {
+				case client.Send <- dm.Message:
+				default:
+					metrics.WebSocketDropped.WithLabelValues("direct").Inc()
+				}
 			}
 
 		case gm := <-h.Group:
 			if members, ok := h.Groups[gm.GroupID]; ok {
 				for client := range members {
-					client.Send <- gm.Message
+					select {
+					case client.Send <- gm.Message:
+					default:
+						log.Printf("Client %s send buffer full, group message dropped", client.ID)
+						metrics.WebSocketDropped.WithLabelValues("group").Inc()
+					}
 				}
 			}
 		}
 	}
 }
 
-func (h *Hub) JoinGroup(client *Client, groupID string) {
+// removeClient - bỏ client khỏi mọi map rồi mới đóng Send, sau đó không còn đường nào ghi vào kênh đã đóng
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.Clients[client]; !ok {
+		return
+	}
+	delete(h.Clients, client)
+	if h.ClientsByID[client.ID] == client {
+		delete(h.ClientsByID, client.ID)
+	}
+	for groupID := range client.Groups {
+		h.removeFromGroup(client, groupID)
+	}
+	close(client.Send)
+	metrics.WebSocketClients.Set(float64(len(h.Clients)))
+}
+
+func (h *Hub) addToGroup(client *Client, groupID string) {
 	if _, ok := h.Groups[groupID]; !ok {
 		h.Groups[groupID] = make(map[*Client]bool)
 	}
@@ -86,9 +219,101 @@ func (h *Hub) JoinGroup(client *Client, groupID string) {
 	client.Groups[groupID] = true
 }
 
-func (h *Hub) LeaveGroup(client *Client, groupID string) {
-	if _, ok := h.Groups[groupID]; ok {
-		delete(h.Groups[groupID], client)
+func (h *Hub) removeFromGroup(client *Client, groupID string) {
+	if members, ok := h.Groups[groupID]; ok {
+		delete(members, client)
+		if len(members) == 0 {
+			delete(h.Groups, groupID)
+		}
 	}
 	delete(client.Groups, groupID)
 }
+
+// JoinGroup/LeaveGroup đi qua Run() để map Groups chỉ bị sửa trên một goroutine
+func (h *Hub) JoinGroup(client *Client, groupID string) {
+	h.membership <- groupChange{client: client, groupID: groupID, join: true}
+}
+
+func (h *Hub) LeaveGroup(client *Client, groupID string) {
+	h.membership <- groupChange{client: client, groupID: groupID, join: false}
+}
+
+func (h *Hub) SetStateProvider(p StateProvider) {
+	h.state = p
+}
+
+func (h *Hub) SetCommandHandler(handler CommandHandler) {
+	h.commands = handler
+}
+
+// HandleCommand - chạy command và trả kết quả cho đúng client theo requestId
+func (h *Hub) HandleCommand(client *Client, msg *ClientMessage) {
+	result := CommandResult{Type: CommandResultMsg, RequestID: msg.RequestID}
+
+	switch {
+	case h.commands == nil:
+		result.Error = "commands are not supported"
+	case client.Identity == nil:
+		result.Error = "unauthorized"
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		outcome, err := h.commands.HandleCommand(ctx, client.Identity, msg.Payload)
+		cancel()
+		if err != nil {
+			result.Error = err.Error()
+			break
+		}
+		result.OK = true
+		if outcome != nil {
+			result.Data = outcome.Result
+			for _, e := range outcome.Events {
+				if b, err := json.Marshal(e.Payload); err == nil {
+					h.BroadcastExcept <- ExceptMessage{Except: client, GroupID: e.GroupID, Message: b}
+				}
+			}
+		}
+	}
+
+	h.sendTo(client, result)
+}
+
+// Subscribe - gửi snapshot hiện tại cho client, kèm các bản ghi bị lỡ nếu có cursor
+func (h *Hub) Subscribe(client *Client, since string) {
+	if h.state == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		caughtUp := false
+		if since != "" {
+			catchUp, err := h.state.Since(ctx, client.Identity, since)
+			if err != nil {
+				log.Printf("Catch-up for client %s failed: %v", client.ID, err)
+				h.sendTo(client, map[string]string{"type": "error", "message": "catch-up unavailable: " + err.Error()})
+			} else {
+				h.sendTo(client, catchUp)
+				caughtUp = true
+			}
+		}
+
+		snapshot, groups, err := h.state.Snapshot(ctx, client.Identity, !caughtUp)
+		if err != nil {
+			log.Printf("Snapshot for client %s failed: %v", client.ID, err)
+			return
+		}
+		// Quyền có thể đã thay đổi từ lần subscribe trước, thay toàn bộ group dành riêng
+		h.membership <- groupChange{client: client, reset: true, groups: groups}
+		h.sendTo(client, snapshot)
+	}()
+}
+
+// sendTo đi qua kênh Direct để Run() kiểm tra client còn kết nối trước khi ghi
+func (h *Hub) sendTo(client *Client, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	h.Direct <- DirectMessage{To: client.ID, Message: b}
+}
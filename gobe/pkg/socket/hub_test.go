@@ -0,0 +1,118 @@
+package socket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestClient - client không có Conn, chỉ dùng kênh Send
+func newTestClient(id string, buffer int) *Client {
+	return &Client{ID: id, Send: make(chan []byte, buffer), Groups: make(map[string]bool)}
+}
+
+// drain - đọc hết message đang chờ, closed=true nếu hub đã đóng Send
+func drain(c *Client) (messages []string, closed bool) {
+	for {
+		select {
+		case m, ok := <-c.Send:
+			if !ok {
+				return messages, true
+			}
+			messages = append(messages, string(m))
+		case <-time.After(50 * time.Millisecond):
+			return messages, false
+		}
+	}
+}
+
+func TestBroadcastExceptDropsSlowClient(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	sender := newTestClient("sender", 8)
+	fast := newTestClient("fast", 8)
+	slow := newTestClient("slow", 1)
+	for _, c := range []*Client{sender, fast, slow} {
+		hub.Register <- c
+	}
+	slow.Send <- []byte("backlog")
+
+	hub.BroadcastExcept <- ExceptMessage{Except: sender, Message: []byte("event")}
+	// Run xử lý xong event mới nhận message tiếp theo, Direct tới id lạ không gửi gì
+	hub.Direct <- DirectMessage{To: "nobody"}
+
+	if got, closed := drain(slow); !closed || len(got) != 1 {
+		t.Errorf("slow client got %q closed=%v, want only the backlog and a closed Send", got, closed)
+	}
+	if got, closed := drain(fast); closed || len(got) != 1 || got[0] != "event" {
+		t.Errorf("fast client got %q closed=%v, want [event]", got, closed)
+	}
+	if got, _ := drain(sender); len(got) != 0 {
+		t.Errorf("sender got %q, want nothing", got)
+	}
+}
+
+// tenantState - snapshot chỉ cấp group telemetry của tenant trong identity
+type tenantState struct{}
+
+func (tenantState) Snapshot(ctx context.Context, identity *Identity, readings bool) (any, []string, error) {
+	return map[string]string{"type": "snapshot"}, []string{TelemetryGroup(identity.TenantID)}, nil
+}
+
+func (tenantState) Since(ctx context.Context, identity *Identity, cursor string) (any, error) {
+	return nil, nil
+}
+
+func TestHubTenantIsolation(t *testing.T) {
+	hub := NewHub()
+	hub.SetStateProvider(tenantState{})
+	go hub.Run()
+
+	home := newTestClient("home", 8)
+	home.Identity = &Identity{UserID: 1, TenantID: 1}
+	office := newTestClient("office", 8)
+	office.Identity = &Identity{UserID: 2, TenantID: 2}
+	anonymous := newTestClient("anonymous", 8)
+	for _, c := range []*Client{home, office, anonymous} {
+		hub.Register <- c
+	}
+	// group được gán trước khi gửi snapshot, nhận snapshot là đã vào group
+	for _, c := range []*Client{home, office} {
+		hub.Subscribe(c, "")
+		if got, _ := drain(c); len(got) != 1 {
+			t.Fatalf("%s got %q, want the snapshot", c.ID, got)
+		}
+	}
+
+	hub.Group <- GroupMessage{GroupID: TelemetryGroup(1), Message: []byte("telemetry-1")}
+	if got, _ := drain(home); len(got) != 1 || got[0] != "telemetry-1" {
+		t.Errorf("home got %q, want its tenant's telemetry", got)
+	}
+	if got, _ := drain(office); len(got) != 0 {
+		t.Errorf("office got %q from another tenant", got)
+	}
+
+	// cùng tên room ở hai tenant là hai group khác nhau
+	homeRoom, _ := home.roomGroup("kitchen")
+	officeRoom, _ := office.roomGroup("kitchen")
+	if homeRoom == officeRoom {
+		t.Fatalf("room %q shared between tenants", homeRoom)
+	}
+	if _, ok := anonymous.roomGroup("kitchen"); ok {
+		t.Error("anonymous client can use rooms")
+	}
+	hub.JoinGroup(home, homeRoom)
+	hub.JoinGroup(office, officeRoom)
+	hub.BroadcastExcept <- ExceptMessage{Except: home, GroupID: homeRoom, Message: []byte("room")}
+	hub.Group <- GroupMessage{GroupID: officeRoom, Message: []byte("office-room")}
+	if got, _ := drain(home); len(got) != 0 {
+		t.Errorf("home got %q, want nothing from its own event or the other tenant's room", got)
+	}
+	if got, _ := drain(office); len(got) != 1 || got[0] != "office-room" {
+		t.Errorf("office got %q, want only its room", got)
+	}
+	if got, _ := drain(anonymous); len(got) != 0 {
+		t.Errorf("anonymous client got %q", got)
+	}
+}
@@ -0,0 +1,140 @@
+package stream
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TopicTelemetry   = "telemetry"
+	TopicDeviceState = "device_state"
+	TopicAlert       = "alert"
+)
+
+var Topics = []string{TopicTelemetry, TopicDeviceState, TopicAlert}
+
+type Event struct {
+	ID       string
+	Topic    string
+	TenantID uint // consumer chỉ nhận event của tenant mình
+	DeviceID uint // 0 nếu event không gắn với device cụ thể
+	Data     []byte
+}
+
+type Subscription struct {
+	C      chan Event
+	topics map[string]bool
+}
+
+// Broker - pub/sub trong process cho các consumer không dùng WebSocket (SSE)
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*Subscription]struct{})}
+}
+
+func (b *Broker) Subscribe(topics []string) *Subscription {
+	sub := &Subscription{
+		C:      make(chan Event, 64),
+		topics: make(map[string]bool, len(topics)),
+	}
+	for _, t := range topics {
+		sub.topics[t] = true
+	}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.C)
+	}
+	b.mu.Unlock()
+}
+
+// Publish không block: subscriber chậm sẽ bị bỏ qua event thay vì làm nghẽn producer
+func (b *Broker) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if !sub.topics[e.Topic] {
+			continue
+		}
+		select {
+		case sub.C <- e:
+		default:
+		}
+	}
+}
+
+func (s *Subscription) Wants(topic string) bool {
+	return s.topics[topic]
+}
+
+// ParseTopics - đọc danh sách topic phân tách bằng dấu phẩy, rỗng nghĩa là tất cả
+func ParseTopics(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return Topics, nil
+	}
+	var topics []string
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		valid := false
+		for _, known := range Topics {
+			if t == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown topic %q", t)
+		}
+		topics = append(topics, t)
+	}
+	return topics, nil
+}
+
+// ParseID - tách Redis stream ID dạng "<ms>-<seq>"
+func ParseID(id string) (uint64, uint64, error) {
+	msPart, seqPart, found := strings.Cut(id, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cursor %q", id)
+	}
+	var seq uint64
+	if found {
+		seq, err = strconv.ParseUint(seqPart, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid cursor %q", id)
+		}
+	}
+	return ms, seq, nil
+}
+
+func CompareIDs(a, b string) int {
+	aMs, aSeq, _ := ParseID(a)
+	bMs, bSeq, _ := ParseID(b)
+	switch {
+	case aMs < bMs || (aMs == bMs && aSeq < bSeq):
+		return -1
+	case aMs == bMs && aSeq == bSeq:
+		return 0
+	default:
+		return 1
+	}
+}
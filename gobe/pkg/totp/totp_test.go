@@ -0,0 +1,84 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// secret ASCII "12345678901234567890" của RFC 6238, mã 8 chữ số trong RFC cắt còn 6 chữ số cuối
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeAt(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := CodeAt(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("CodeAt(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+	if got, _ := CodeAt(" "+strings.ToLower(rfcSecret)+" ", 1); got != "287082" {
+		t.Errorf("secret typed in lower case = %s, want 287082", got)
+	}
+	if _, err := CodeAt("not base32!", 1); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	code := func(step int64) string {
+		c, _ := CodeAt(rfcSecret, step)
+		return c
+	}
+	tests := []struct {
+		name     string
+		code     string
+		skew     int64
+		ok       bool
+		wantStep int64
+	}{
+		{"current step", code(step), 1, true, step},
+		{"previous step within skew", code(step - 1), 1, true, step - 1},
+		{"next step within skew", code(step + 1), 1, true, step + 1},
+		{"outside skew", code(step - 2), 1, false, 0},
+		{"no skew", code(step - 1), 0, false, 0},
+		{"wrong length", code(step)[:5], 1, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.ok || got != tt.wantStep {
+				t.Errorf("Validate = (%d, %v), want (%d, %v)", got, ok, tt.wantStep, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+	if a == b || len(a) != 32 {
+		t.Errorf("secrets %q %q, want two different 160 bit secrets", a, b)
+	}
+	if _, err := CodeAt(a, 1); err != nil {
+		t.Errorf("generated secret not decodable: %v", err)
+	}
+}
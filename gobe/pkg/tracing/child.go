@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// ChildOnly - provider cho instrumentation của thư viện (Redis): chỉ tạo span khi ctx đã có span
+// (request, message MQTT) để job nền không sinh hàng loạt trace rời rạc
+func ChildOnly(provider trace.TracerProvider) trace.TracerProvider {
+	return childOnlyProvider{provider: provider}
+}
+
+type childOnlyProvider struct {
+	embedded.TracerProvider
+	provider trace.TracerProvider
+}
+
+func (p childOnlyProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return childOnlyTracer{tracer: p.provider.Tracer(name, opts...)}
+}
+
+type childOnlyTracer struct {
+	embedded.Tracer
+	tracer trace.Tracer
+}
+
+// Start - không có span cha thì trả span noop đang có trong ctx, không ghi gì
+func (t childOnlyTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return t.tracer.Start(ctx, name, opts...)
+}
@@ -0,0 +1,20 @@
+package tracing
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GinMiddleware - span server của otelgin cho mỗi request, nối vào trace của client nếu có header traceparent.
+// Trace ID trả lại qua X-Trace-Id để đối chiếu khi báo lỗi.
+func GinMiddleware(service string) gin.HandlersChain {
+	return gin.HandlersChain{otelgin.Middleware(service), traceIDHeader}
+}
+
+func traceIDHeader(c *gin.Context) {
+	if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+		c.Header("X-Trace-Id", sc.TraceID().String())
+	}
+	c.Next()
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// RegisterGormCallbacks - span cho mỗi câu lệnh SQL. Chỉ tạo khi context của statement đã có span
+// (request, message MQTT) để job nền và migrate không sinh hàng loạt trace rời rạc.
+func RegisterGormCallbacks(db *gorm.DB) error {
+	callbacks := db.Callback()
+	type hook struct {
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}
+	hooks := []hook{
+		{"INSERT", callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register},
+		{"SELECT", callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register},
+		{"UPDATE", callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register},
+		{"DELETE", callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register},
+		{"ROW", callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register},
+		{"RAW", callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		operation := h.operation
+		if err := h.before("tracing:start_"+strings.ToLower(operation), func(tx *gorm.DB) {
+			ctx := tx.Statement.Context
+			if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+				return
+			}
+			_, span := Start(ctx, strings.TrimSpace(operation+" "+tx.Statement.Table), trace.SpanKindClient,
+				attribute.String("db.system", tx.Dialector.Name()),
+				attribute.String("db.operation.name", operation),
+				attribute.String("db.collection.name", tx.Statement.Table),
+			)
+			tx.InstanceSet(gormSpanKey, span)
+		}); err != nil {
+			return err
+		}
+		if err := h.after("tracing:end_"+strings.ToLower(operation), endGormSpan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func endGormSpan(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	// SQL giữ placeholder, giá trị tham số không vào span
+	span.SetAttributes(
+		attribute.String("db.query.text", tx.Statement.SQL.String()),
+		attribute.Int64("db.response.returned_rows", tx.Statement.RowsAffected),
+	)
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		RecordError(span, tx.Error)
+	}
+	span.End()
+}
@@ -0,0 +1,25 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// TraceparentHeader - header/field theo W3C Trace Context: 00-<trace_id>-<span_id>-<flags>
+const TraceparentHeader = "traceparent"
+
+// traceContext - MQTT luôn dùng W3C Trace Context, không phụ thuộc propagator toàn cục
+var traceContext = propagation.TraceContext{}
+
+// Traceparent - trace context hiện tại để gửi đi, rỗng khi không có span
+func Traceparent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	traceContext.Inject(ctx, carrier)
+	return carrier.Get(TraceparentHeader)
+}
+
+// ContextWithTraceparent - span tạo sau đó nối vào trace của bên gửi, giá trị sai định dạng thì bỏ qua
+func ContextWithTraceparent(ctx context.Context, value string) context.Context {
+	return traceContext.Extract(ctx, propagation.MapCarrier{TraceparentHeader: value})
+}
@@ -0,0 +1,221 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// recordSpans - provider toàn cục ghi span vào bộ nhớ thay cho exporter, trả lại provider cũ khi test xong
+func recordSpans(t *testing.T) (*tracetest.SpanRecorder, trace.TracerProvider) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder, provider
+}
+
+func attributeOf(span sdktrace.ReadOnlySpan, key string) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{"none keeps the noop provider", ExporterNone, false},
+		{"empty means none", "", false},
+		{"unknown exporter", "jaeger", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := otel.GetTracerProvider()
+			shutdown, err := Setup(context.Background(), Options{Exporter: tt.exporter, ServiceName: "test", SampleRatio: 1})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if err := shutdown(context.Background()); err != nil {
+				t.Errorf("shutdown: %v", err)
+			}
+			if otel.GetTracerProvider() != previous {
+				t.Error("global tracer provider changed")
+			}
+		})
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	recordSpans(t)
+
+	if got := Traceparent(context.Background()); got != "" {
+		t.Errorf("Traceparent without span = %q, want empty", got)
+	}
+
+	ctx, parent := Start(context.Background(), "publish", trace.SpanKindProducer)
+	defer parent.End()
+	sc := parent.SpanContext()
+	want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	traceparent := Traceparent(ctx)
+	if traceparent != want {
+		t.Fatalf("Traceparent = %q, want %q", traceparent, want)
+	}
+
+	tests := []struct {
+		name   string
+		value  string
+		joined bool
+	}{
+		{"value sent by the board", traceparent, true},
+		{"empty", "", false},
+		{"malformed", "00-abc-def-01", false},
+		{"zero trace id", "00-00000000000000000000000000000000-" + sc.SpanID().String() + "-01", false},
+		{"invalid version", "ff-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, child := Start(ContextWithTraceparent(context.Background(), tt.value), "process", trace.SpanKindConsumer)
+			defer child.End()
+			joined := child.SpanContext().TraceID() == sc.TraceID()
+			if joined != tt.joined {
+				t.Errorf("joined trace = %v, want %v", joined, tt.joined)
+			}
+			if got := child.(sdktrace.ReadOnlySpan).Parent().SpanID(); joined && got != sc.SpanID() {
+				t.Errorf("parent span = %s, want %s", got, sc.SpanID())
+			}
+		})
+	}
+}
+
+func TestChildOnly(t *testing.T) {
+	recorder, provider := recordSpans(t)
+	tracer := ChildOnly(provider).Tracer("redis")
+
+	_, span := tracer.Start(context.Background(), "redis get")
+	span.End()
+	if span.IsRecording() || len(recorder.Ended()) != 0 {
+		t.Fatalf("span without parent was recorded")
+	}
+
+	ctx, parent := Start(context.Background(), "request", trace.SpanKindServer)
+	_, span = tracer.Start(ctx, "redis get")
+	span.End()
+	parent.End()
+	ended := recorder.Ended()
+	if len(ended) != 2 || ended[0].Name() != "redis get" || ended[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("want redis span under request span, got %d spans", len(ended))
+	}
+}
+
+func TestGormCallbacks(t *testing.T) {
+	recorder, _ := recordSpans(t)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterGormCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("CREATE TABLE devices (id integer PRIMARY KEY, name text)")
+	db.Exec("INSERT INTO devices (name) VALUES ('fan'), ('lamp')")
+
+	type device struct {
+		ID   uint
+		Name string
+	}
+	var devices []device
+	db.Table("devices").Find(&devices)
+	if n := len(recorder.Ended()); n != 0 {
+		t.Fatalf("statements outside a span created %d spans", n)
+	}
+
+	ctx, parent := Start(context.Background(), "request", trace.SpanKindServer)
+	db.WithContext(ctx).Table("devices").Where("name = ?", "fan").Find(&devices)
+	db.WithContext(ctx).Table("missing").Find(&devices)
+	parent.End()
+
+	ended := recorder.Ended()
+	if len(ended) != 3 {
+		t.Fatalf("got %d spans, want 2 statements and the request", len(ended))
+	}
+	query, failed := ended[0], ended[1]
+	if query.Name() != "SELECT devices" || query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("query span = %q under %s", query.Name(), query.Parent().SpanID())
+	}
+	if got := attributeOf(query, "db.query.text").AsString(); got != "SELECT * FROM `devices` WHERE name = ?" {
+		t.Errorf("db.query.text = %q, want the statement with placeholders", got)
+	}
+	if got := attributeOf(query, "db.response.returned_rows").AsInt64(); got != 1 {
+		t.Errorf("db.response.returned_rows = %d, want 1", got)
+	}
+	if query.Status().Code == codes.Error {
+		t.Errorf("successful query marked as error")
+	}
+	if failed.Status().Code != codes.Error {
+		t.Errorf("failed query status = %v, want error", failed.Status().Code)
+	}
+}
+
+func TestGinMiddleware(t *testing.T) {
+	recorder, _ := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinMiddleware("test")...)
+	r.GET("/devices/:id", func(c *gin.Context) {
+		_, span := Start(c.Request.Context(), "handler", trace.SpanKindInternal)
+		span.End()
+		c.Status(http.StatusNoContent)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/devices/1", nil)
+	req.Header.Set(TraceparentHeader, "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Trace-Id"); got != traceID {
+		t.Errorf("X-Trace-Id = %q, want %q", got, traceID)
+	}
+	ended := recorder.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("got %d spans, want handler and server span", len(ended))
+	}
+	handler, server := ended[0], ended[1]
+	if server.Name() != "GET /devices/:id" || server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("server span = %q kind %v", server.Name(), server.SpanKind())
+	}
+	if server.SpanContext().TraceID().String() != traceID || server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("server span not joined to client trace")
+	}
+	if handler.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("handler span not under server span")
+	}
+}